@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"link-shortener/internal/config"
+)
+
+const usage = `usage: server [flags] [command]
+
+Without a command the API server is started.
+
+Commands:
+  config check   print the effective configuration with secrets redacted
+                 and exit non-zero if it is invalid
+  links import   import links from a CSV or JSON file on behalf of a user;
+                 run "links import -h" for its flags
+
+Settings are read from built-in defaults, then the config file, then
+environment variables, then -set flags; later sources win.
+
+Flags:
+`
+
+func printUsage() {
+	fmt.Fprint(flag.CommandLine.Output(), usage)
+	flag.PrintDefaults()
+}
+
+// runCommand runs an administrative subcommand and returns the exit code
+func runCommand(cfg *config.Config, args []string) int {
+	if len(args) >= 2 && args[0] == "links" && args[1] == "import" {
+		return importLinks(cfg, args[2:], os.Stdout, os.Stderr)
+	}
+
+	switch strings.Join(args, " ") {
+	case "config check":
+		return checkConfig(cfg, os.Stdout)
+	case "help":
+		printUsage()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", strings.Join(args, " "))
+		printUsage()
+		return 2
+	}
+}
+
+func checkConfig(cfg *config.Config, w io.Writer) int {
+	fmt.Fprintln(w, "Effective configuration:")
+	cfg.WriteSettings(w)
+	fmt.Fprintln(w)
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+
+	fmt.Fprintln(w, "Configuration is valid")
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/config"
+	"link-shortener/internal/database"
+	"link-shortener/internal/importer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+const importUsage = `usage: server links import -user EMAIL [flags] FILE
+
+Imports the links of a CSV or JSON file as the given user and prints the
+report as JSON. Progress is written to stderr.
+
+Flags:
+`
+
+// importLinks runs "links import" and returns the exit code
+func importLinks(cfg *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("links import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	email := flags.String("user", "", "email of the user who will own the links")
+	workspace := flags.String("workspace", "", "ID of the workspace to import into")
+	format := flags.String("format", "", "csv or json; guessed from the file name when omitted")
+	policy := flags.String("policy", models.ConflictFail, "what to do with taken codes: skip, rename or fail")
+	dryRun := flags.Bool("dry-run", false, "only validate the file")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, importUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *email == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := &models.ImportOptions{Format: *format, ConflictPolicy: *policy, DryRun: *dryRun}
+	if *workspace != "" {
+		id, err := uuid.Parse(*workspace)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid workspace ID %q\n", *workspace)
+			return 2
+		}
+		opts.WorkspaceID = &id
+	}
+
+	path := flags.Arg(0)
+	if opts.Format == "" {
+		opts.Format = importer.DetectFormat("", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	db, err := database.NewDatabase(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	// Interrupting stops the import after the current chunk
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	user, err := repository.NewUserRepository(db).GetByEmail(ctx, *email)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to find user %s: %v\n", *email, err)
+		return 1
+	}
+
+	linkRepo := repository.NewLinkRepository(db)
+	linkService := services.NewLinkService(linkRepo, repository.NewWorkspaceRepository(db),
+		fmt.Sprintf("http://localhost:%s", cfg.Server.Port), cfg.Links, utils.NewBlocklist(cfg.Blocklist.Domains), nil)
+	// Queue link.created for the user's webhooks; the server delivers them
+	linkRepo.SetOutbox(services.NewWebhookService(repository.NewWebhookRepository(db), linkService, cfg.Webhooks))
+	importService := services.NewImportService(linkService, repository.NewImportRepository(db))
+
+	records, err := importService.Parse(file, opts.Format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	report, err := importService.Run(ctx, user.ID, opts, records, func(processed int, _ *models.ImportReport) {
+		fmt.Fprintf(stderr, "Processed %d of %d rows\n", processed, len(records))
+	})
+	if report != nil {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
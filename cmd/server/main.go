@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,126 +14,515 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"link-shortener/internal/config"
 	"link-shortener/internal/database"
 	"link-shortener/internal/handlers"
+	"link-shortener/internal/live"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/metrics"
 	"link-shortener/internal/middleware"
+	"link-shortener/internal/oidc"
+	"link-shortener/internal/openapi"
 	"link-shortener/internal/repository"
+	"link-shortener/internal/rpc"
 	"link-shortener/internal/services"
+	"link-shortener/internal/tracing"
 	"link-shortener/internal/utils"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration: defaults < config file < environment < flags
+	var opts config.Options
+	opts.RegisterFlags(flag.CommandLine)
+	flag.Usage = printUsage
+	flag.Parse()
+
+	cfg, err := config.LoadWithOptions(opts)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", err)
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(cfg, flag.Args()))
+	}
+
+	// Structured logging; the level can change on reload
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Log.SlogLevel())
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log.Format, logLevel))
+
+	// Report every configuration problem at once; refuse to start in production
+	if err := cfg.Validate(); err != nil {
+		var problems []string
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			problems = validationErr.Problems
+		}
+		if cfg.IsRelease() {
+			slog.Error("Invalid configuration", "problems", problems)
+			os.Exit(1)
+		}
+		slog.Warn("Invalid configuration; these problems prevent startup when GIN_MODE=release", "problems", problems)
 	}
 
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		slog.Debug("Route registered", "method", method, "path", path, "handler", handler)
+	}
+
+	// Spans are exported only when an exporter is configured
+	tracer := newTracer(cfg.Tracing)
+	tracing.SetDefault(tracer)
+
+	// work is the parent context of every request and background job; shutdown
+	// cancels it once the grace period is over
+	work, stopWork := context.WithCancel(context.Background())
+	defer stopWork()
 
 	// Initialize database
 	db, err := database.NewDatabase(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 	defer db.Close()
 
 	// Initialize database tables
 	if err := db.InitTables(); err != nil {
-		log.Fatalf("Failed to initialize database tables: %v", err)
+		fatal("Failed to initialize database tables", err)
 	}
 
-	// Initialize JWT manager
+	// Initialize JWT manager; asymmetric algorithms use stored, rotating keys
 	jwtMgr := utils.NewJWTManager(cfg.JWT.Secret, cfg.JWT.Expiry)
+	if cfg.JWT.Algorithm != utils.AlgorithmHS256 {
+		keyRing := utils.NewKeyRing()
+		keyService := services.NewKeyService(repository.NewSigningKeyRepository(db), keyRing, cfg.JWT)
+		if err := keyService.Load(work); err != nil {
+			fatal("Failed to load signing keys", err)
+		}
+		jwtMgr = utils.NewKeyRingJWTManager(keyRing, cfg.JWT.Expiry)
+
+		// Pick up keys rotated by other instances and rotate on schedule
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-work.Done():
+					return
+				case <-ticker.C:
+				}
+				if rotated, err := keyService.RotateIfDue(work); err != nil {
+					slog.Error("Failed to refresh signing keys", "error", err)
+				} else if rotated {
+					slog.Info("Rotated JWT signing key")
+				}
+			}
+		}()
+	}
+
+	// Initialize mailer
+	mail, err := mailer.New(&cfg.Mail)
+	if err != nil {
+		fatal("Failed to initialize mailer", err)
+	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	recoveryRepo := repository.NewRecoveryCodeRepository(db)
 	linkRepo := repository.NewLinkRepository(db)
+	workspaceRepo := repository.NewWorkspaceRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	importRepo := repository.NewImportRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, jwtMgr)
-	linkService := services.NewLinkService(linkRepo, fmt.Sprintf("http://localhost:%s", cfg.Server.Port))
+	authService := services.NewAuthService(userRepo, tokenRepo, recoveryRepo, jwtMgr, mail, cfg.Auth, cfg.Mail.AppURL)
+	blocklist := utils.NewBlocklist(cfg.Blocklist.Domains)
+	clickQueue := services.NewClickQueue(linkRepo, cfg.Clicks.QueueSize, cfg.Clicks.Workers)
+	linkService := services.NewLinkService(linkRepo, workspaceRepo, fmt.Sprintf("http://localhost:%s", cfg.Server.Port), cfg.Links, blocklist, clickQueue)
+	importService := services.NewImportService(linkService, importRepo)
+	webhookService := services.NewWebhookService(webhookRepo, linkService, cfg.Webhooks)
+	linkRepo.SetOutbox(webhookService)
+	liveHub := live.NewHub(cfg.Live.BufferSize, cfg.Live.SubscriberBuffer, cfg.Live.MaxSubscribers, cfg.Live.MaxStreamsPerUser)
+	linkService.SetLive(liveHub)
+	clickQueue.SetLive(liveHub)
+	webhookService.Start()
+	adminService := services.NewAdminService(userRepo, linkRepo, linkService)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg.Idempotency)
+	workspaceService := services.NewWorkspaceService(workspaceRepo, userRepo, mail, cfg.Auth, cfg.Mail.AppURL)
+	authService.SetLinks(linkService)
+	workspaceService.SetLinks(linkService)
+
+	// Bootstrap administrators from configuration
+	if err := adminService.PromoteAdmins(work, cfg.Auth.AdminEmails); err != nil {
+		fatal("Failed to promote admins", err)
+	}
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	linkHandler := handlers.NewLinkHandler(linkService)
+	importHandler := handlers.NewImportHandler(importService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
+	healthService := services.NewHealthService(db, clickQueue, cfg.Health)
+	healthHandler := handlers.NewHealthHandler(healthService)
+
+	// Single sign-on is only wired up when a provider is configured
+	var ssoHandler *handlers.SSOHandler
+	if cfg.OIDC.Enabled() {
+		ssoService := services.NewSSOService(authService, userRepo, identityRepo, oidc.NewProvider(&cfg.OIDC), cfg.OIDC, cfg.Auth.TokenSecret)
+		ssoHandler = handlers.NewSSOHandler(ssoService)
+	}
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtMgr)
-	rateLimiter := middleware.NewRateLimiter(100, time.Minute) // 100 requests per minute
+	authMiddleware := middleware.NewAuthMiddleware(jwtMgr, authService)
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit.Requests, cfg.RateLimit.Window)
+	corsPolicy := middleware.NewCORSPolicy(cfg.CORS.AllowedOrigins)
+	// Live streams authenticate again at every heartbeat
+	liveHandler := handlers.NewLiveHandler(linkService, authMiddleware, cfg.Live.Heartbeat)
 
-	// Setup router
-	router := gin.Default()
+	// Setup router; request IDs come first so every log line carries one
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
 
 	// Add middleware
-	router.Use(middleware.CORS())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Tracing())
+	router.Use(middleware.AccessLog())
+	router.Use(middleware.Metrics())
+	router.Use(middleware.Recovery())
+
+	// Every route registered through root is listed in /openapi.json
+	spec := handlers.NewOpenAPIDocument()
+	root := openapi.NewRouter(&router.RouterGroup, spec)
+
+	// Probes are registered before CORS and rate limiting so frequent checks
+	// from orchestrators are never throttled
+	handlers.RegisterProbes(root, healthHandler)
+
+	router.Use(corsPolicy.Handler())
 	router.Use(rateLimiter.RateLimit())
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "ok",
-			"message": "Link Shortener API is running",
-			"time":    time.Now().Format(time.RFC3339),
-		})
-	})
+	// Prometheus metrics, on the API port unless a separate port is set
+	var metricsSrv *http.Server
+	if cfg.Metrics.Enabled {
+		db.RegisterMetrics(metrics.Default)
+		metrics.Default.Register(metrics.NewGaugeFunc("click_queue_depth", "Clicks waiting to be recorded.",
+			func() float64 { return float64(clickQueue.Depth()) }))
+		metrics.Default.Register(metrics.NewGaugeFunc("click_queue_capacity", "Size of the click queue.",
+			func() float64 { return float64(clickQueue.Capacity()) }))
+		metrics.Default.Register(metrics.NewGaugeFunc("link_cache_entries", "Links held in the redirect cache.",
+			func() float64 { return float64(linkService.CacheSize()) }))
+		metrics.Default.Register(metrics.NewGaugeFunc("live_subscribers", "Open live click streams.",
+			func() float64 { return float64(liveHub.Subscribers()) }))
+
+		if cfg.Metrics.Port == "" {
+			slog.Warn("Serving metrics on the API port; set METRICS_PORT to keep them private")
+			router.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Default.Handler())
+			metricsSrv = &http.Server{Addr: ":" + cfg.Metrics.Port, Handler: mux}
+		}
+	}
+
+	// API documentation
+	docsHandler := handlers.NewDocsHandler(spec)
+	router.GET("/openapi.json", docsHandler.OpenAPI)
+	if cfg.Docs.UI {
+		router.GET("/docs", docsHandler.UI)
+	}
+
+	handlers.RegisterJWKS(root, jwtMgr.JWKS)
+
+	// Unverified accounts may optionally be kept from creating links
+	var verifiedEmail gin.HandlerFunc
+	var isEmailVerified func(context.Context, uuid.UUID) (bool, error)
+	if cfg.Auth.RequireVerifiedEmail {
+		verifiedEmail = authMiddleware.VerifiedEmailRequired(authService.IsEmailVerified)
+		isEmailVerified = authService.IsEmailVerified
+	}
 
 	// API routes
-	api := router.Group("/api")
-	{
-		// Auth routes
-		auth := api.Group("/auth")
-		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
-			auth.GET("/profile", authMiddleware.AuthRequired(), authHandler.GetProfile)
+	api := &handlers.API{
+		Auth:          authHandler,
+		SSO:           ssoHandler,
+		Links:         linkHandler,
+		Imports:       importHandler,
+		Workspaces:    workspaceHandler,
+		Webhooks:      webhookHandler,
+		Live:          liveHandler,
+		Admin:         adminHandler,
+		Middleware:    authMiddleware,
+		VerifiedEmail: verifiedEmail,
+		Idempotency:   middleware.Idempotent(idempotencyService, "links.create"),
+	}
+	// Versions are served at /api/<version>; /api keeps serving v1 for
+	// clients that predate versioning
+	var legacy *middleware.Deprecation
+	if cfg.API.LegacyAlias {
+		legacy = &middleware.Deprecation{Since: cfg.API.LegacyDeprecation, Sunset: cfg.API.LegacySunset}
+	}
+	handlers.MountAPI(root, []handlers.APIVersion{
+		{Name: "v1", Register: api.Register},
+	}, "v1", legacy)
+
+	// Redirect route (public)
+	handlers.RegisterRedirect(root, linkHandler)
+
+	// gRPC API on its own port, with the same authentication and rate limit
+	var grpcSrv *grpc.Server
+	if cfg.GRPC.Port != "" {
+		trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+		if err != nil {
+			fatal("Invalid trusted proxies", err)
+		}
+		var creds credentials.TransportCredentials
+		if cfg.GRPC.TLSCertFile != "" {
+			if creds, err = credentials.NewServerTLSFromFile(cfg.GRPC.TLSCertFile, cfg.GRPC.TLSKeyFile); err != nil {
+				fatal("Failed to load gRPC TLS certificate", err)
+			}
 		}
+		grpcSrv = rpc.NewServer(&rpc.API{
+			Links:          linkService,
+			Auth:           authService,
+			Authenticator:  authMiddleware,
+			RateLimiter:    rateLimiter,
+			TrustedProxies: trustedProxies,
+			Credentials:    creds,
+			VerifiedEmail:  isEmailVerified,
+			Reflection:     cfg.GRPC.Reflection,
+		})
+	}
+
+	// Remove accounts whose deletion grace period has expired
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-work.Done():
+				return
+			case <-ticker.C:
+			}
+			if purged, err := authService.PurgeDeletedAccounts(work); err != nil {
+				slog.Error("Failed to purge deleted accounts", "error", err)
+			} else if purged > 0 {
+				slog.Info("Purged deleted accounts", "count", purged)
+			}
+		}
+	}()
 
-		// Link routes (protected)
-		links := api.Group("/links")
-		links.Use(authMiddleware.AuthRequired())
-		{
-			links.POST("/", linkHandler.CreateLink)
-			links.GET("/", linkHandler.GetLinks)
-			links.GET("/stats", linkHandler.GetStats)
-			links.GET("/:id", linkHandler.GetLink)
-			links.PUT("/:id", linkHandler.UpdateLink)
-			links.DELETE("/:id", linkHandler.DeleteLink)
+	// Forget idempotency keys once their window has passed
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-work.Done():
+				return
+			case <-ticker.C:
+			}
+			if purged, err := idempotencyService.PurgeExpired(work); err != nil {
+				slog.Error("Failed to purge idempotency keys", "error", err)
+			} else if purged > 0 {
+				slog.Debug("Purged idempotency keys", "count", purged)
+			}
 		}
+	}()
+
+	// Drop click events once they are older than the retention
+	if cfg.Clicks.Retention > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-work.Done():
+					return
+				case <-ticker.C:
+				}
+				if purged, err := linkService.PurgeClickEvents(work, cfg.Clicks.Retention); err != nil {
+					slog.Error("Failed to purge click events", "error", err)
+				} else if purged > 0 {
+					slog.Info("Purged click events", "count", purged)
+				}
+			}
+		}()
 	}
 
-	// Redirect route (public)
-	router.GET("/r/:shortCode", linkHandler.Redirect)
+	// Live settings are reloaded on SIGHUP and when the config file changes
+	reloader := config.NewReloader(opts, cfg, func(next *config.Config) {
+		rateLimiter.Update(next.RateLimit.Requests, next.RateLimit.Window)
+		corsPolicy.Update(next.CORS.AllowedOrigins)
+		blocklist.Set(next.Blocklist.Domains)
+		logLevel.Set(next.Log.SlogLevel())
+	})
+	reload := func(trigger string) {
+		rejected, err := reloader.Reload()
+		if err != nil {
+			slog.Error("Config reload rejected", "trigger", trigger, "error", err)
+			return
+		}
+		for _, key := range rejected {
+			slog.Warn("Config change needs a restart; keeping the running value", "trigger", trigger, "key", key)
+		}
+		slog.Info("Config reloaded", "trigger", trigger)
+	}
+
+	go func() {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+
+		var watch <-chan time.Time
+		if reloader.HasFile() && cfg.Server.ConfigWatchInterval > 0 {
+			ticker := time.NewTicker(cfg.Server.ConfigWatchInterval)
+			defer ticker.Stop()
+			watch = ticker.C
+		}
+
+		for {
+			select {
+			case <-work.Done():
+				return
+			case <-hangup:
+				reload("SIGHUP")
+			case <-watch:
+				if reloader.Changed() {
+					reload("file change")
+				}
+			}
+		}
+	}()
 
 	// Create server
 	srv := &http.Server{
-		Addr:    ":" + cfg.Server.Port,
-		Handler: router,
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		BaseContext:       func(net.Listener) context.Context { return work },
 	}
+	// Live streams never finish on their own; end them so Shutdown can wait
+	// for the remaining requests
+	srv.RegisterOnShutdown(liveHub.Close)
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Starting server on port %s", cfg.Server.Port)
+		slog.Info("Starting server", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
+	if metricsSrv != nil {
+		go func() {
+			slog.Info("Starting metrics server", "port", cfg.Metrics.Port)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("Failed to start metrics server", err)
+			}
+		}()
+	}
+
+	if grpcSrv != nil {
+		listener, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			fatal("Failed to start gRPC server", err)
+		}
+		go func() {
+			slog.Info("Starting gRPC server", "port", cfg.GRPC.Port, "tls", cfg.GRPC.TLSCertFile != "")
+			if err := grpcSrv.Serve(listener); err != nil {
+				fatal("Failed to start gRPC server", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
+
+	// Report not-ready first so load balancers stop routing here, then drain
+	healthService.SetShuttingDown()
+	if cfg.Server.DrainDelay > 0 {
+		slog.Info("Waiting for load balancers to notice", "drain_delay", cfg.Server.DrainDelay)
+		time.Sleep(cfg.Server.DrainDelay)
+	}
 
-	// Give outstanding requests a deadline for completion
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Give outstanding requests a deadline for completion, then cancel their
+	// queries and background jobs and close the remaining connections
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		slog.Warn("Shutdown timed out; cancelling outstanding requests", "error", err)
+		stopWork()
+		srv.Close()
+	}
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			slog.Warn("gRPC shutdown timed out; closing outstanding calls")
+			grpcSrv.Stop()
+		}
 	}
+	stopWork()
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(ctx)
+	}
+
+	// Save the state of running imports, record the clicks of the last
+	// redirects and stop sending webhooks before the database closes
+	importService.Close()
+	clickQueue.Close()
+	webhookService.Close()
+
+	if tracer != nil {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := tracer.Shutdown(flushCtx); err != nil {
+			slog.Warn("Failed to flush spans", "error", err)
+		}
+	}
+
+	slog.Info("Server exited")
+}
+
+// newTracer builds the configured span exporter, or returns nil when tracing
+// is off
+func newTracer(cfg config.TracingConfig) *tracing.Tracer {
+	var exporter tracing.Exporter
+	switch cfg.Exporter {
+	case "stdout":
+		exporter = tracing.NewStdoutExporter(os.Stdout)
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(cfg.OTLPEndpoint, cfg.ServiceName)
+	default:
+		return nil
+	}
+	slog.Info("Tracing enabled", "exporter", cfg.Exporter, "sample_ratio", cfg.SampleRatio)
+	return tracing.NewTracer(exporter, cfg.SampleRatio)
+}
 
-	log.Println("Server exited")
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
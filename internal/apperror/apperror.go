@@ -0,0 +1,109 @@
+// Package apperror defines the errors that repositories and services return
+// to describe what went wrong, and renders them as HTTP responses.
+//
+// Every *Error has a kind, one of the sentinel errors below, a stable
+// machine-readable code and a message that is safe to show to clients. Match
+// kinds with errors.Is(err, apperror.ErrNotFound) and specific errors with
+// errors.Is(err, services.ErrLinkNotFound). Any other error is internal and
+// is rendered as a 500 without its message.
+package apperror
+
+import (
+	"errors"
+)
+
+// Kinds of errors; each maps to one HTTP status
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrExpired      = errors.New("expired")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("unavailable")
+)
+
+// FieldError describes a problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error of a known kind. Declare shared instances as package
+// variables so callers can match them with errors.Is.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Err is the underlying cause; it is logged but never shown to clients
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// Wrap returns a copy of e that records err as its cause
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithField returns a copy of e that reports a problem with one request field
+func (e *Error) WithField(field, message string) *Error {
+	withField := *e
+	withField.Fields = append(append([]FieldError(nil), e.Fields...), FieldError{Field: field, Message: message})
+	return &withField
+}
+
+// Is matches the same declared error even after Wrap
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Kind == e.Kind
+}
+
+func Validation(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Expired(code, message string) *Error {
+	return &Error{Kind: ErrExpired, Code: code, Message: message}
+}
+
+func RateLimited(code, message string) *Error {
+	return &Error{Kind: ErrRateLimited, Code: code, Message: message}
+}
+
+func Unavailable(code, message string) *Error {
+	return &Error{Kind: ErrUnavailable, Code: code, Message: message}
+}
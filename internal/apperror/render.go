@@ -0,0 +1,151 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// StatusClientClosedRequest is the non-standard status logged when the
+// client went away before the response was ready
+const StatusClientClosedRequest = 499
+
+var statuses = map[error]int{
+	ErrValidation:   http.StatusBadRequest,
+	ErrUnauthorized: http.StatusUnauthorized,
+	ErrForbidden:    http.StatusForbidden,
+	ErrNotFound:     http.StatusNotFound,
+	ErrConflict:     http.StatusConflict,
+	ErrExpired:      http.StatusGone,
+	ErrRateLimited:  http.StatusTooManyRequests,
+	ErrUnavailable:  http.StatusServiceUnavailable,
+}
+
+var (
+	errInternal = &Error{Code: "internal_error", Message: "Internal server error"}
+	errTimeout  = &Error{Code: "timeout", Message: "The request timed out"}
+	errCanceled = &Error{Code: "client_closed_request", Message: "The request was cancelled"}
+)
+
+// Response is the JSON body of every error response
+type Response struct {
+	Error   string       `json:"error"`
+	Code    string       `json:"code"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// Render writes err as an error response and aborts the request. Internal
+// errors are attached to the context for the access log and replaced by a
+// generic message.
+func Render(c *gin.Context, err error) {
+	status, appErr := classify(err)
+	if status >= http.StatusInternalServerError || status == StatusClientClosedRequest {
+		c.Error(err)
+	}
+
+	c.AbortWithStatusJSON(status, Response{
+		Error:   appErr.Message,
+		Code:    appErr.Code,
+		Details: appErr.Fields,
+	})
+}
+
+// ResponseOf returns the body err is rendered with, for errors reported inside
+// an otherwise successful response such as one item of a bulk request
+func ResponseOf(err error) *Response {
+	_, appErr := classify(err)
+	return &Response{Error: appErr.Message, Code: appErr.Code, Details: appErr.Fields}
+}
+
+// Status returns the HTTP status err is rendered with
+func Status(err error) int {
+	status, _ := classify(err)
+	return status
+}
+
+func classify(err error) (int, *Error) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		if status, ok := statuses[appErr.Kind]; ok {
+			return status, appErr
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, errCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, errTimeout
+	}
+	return http.StatusInternalServerError, errInternal
+}
+
+// FromBinding turns a request binding error into a validation error with one
+// entry per invalid field
+func FromBinding(err error) *Error {
+	invalid := Validation("invalid_request", "Invalid request data")
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fieldErr := range validationErrs {
+			invalid.Fields = append(invalid.Fields, FieldError{Field: fieldErr.Field(), Message: ruleMessage(fieldErr)})
+		}
+	case errors.As(err, &typeErr):
+		invalid.Fields = []FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		invalid.Message = "Request body must be valid JSON"
+	}
+	return invalid.Wrap(err)
+}
+
+func ruleMessage(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "min":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", param)
+		}
+		return "must be at least " + param
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", param)
+		}
+		return "must be at most " + param
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters", param)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(param, " ", ", ")
+	}
+	return "is invalid"
+}
+
+// Field errors name the JSON field rather than the Go struct field
+func init() {
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
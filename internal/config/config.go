@@ -3,16 +3,40 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	JWT      JWTConfig
+	Database    DatabaseConfig
+	Server      ServerConfig
+	JWT         JWTConfig
+	Auth        AuthConfig
+	Mail        MailConfig
+	OIDC        OIDCConfig
+	RateLimit   RateLimitConfig
+	Links       LinkConfig
+	CORS        CORSConfig
+	Blocklist   BlocklistConfig
+	Log         LogConfig
+	Clicks      ClickConfig
+	Webhooks    WebhookConfig
+	Live        LiveConfig
+	Idempotency IdempotencyConfig
+	GRPC        GRPCConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
+	Health      HealthConfig
+	Docs        DocsConfig
+	API         APIConfig
+
+	// problems are values that could not be parsed; see Validate
+	problems []problem
+	settings []Setting
 }
 
 type DatabaseConfig struct {
@@ -22,42 +46,397 @@ type DatabaseConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+	// Connection pool limits
+	MaxOpenConns int
+	MaxIdleConns int
+	// QueryTimeout bounds each statement; zero leaves only the caller's deadline
+	QueryTimeout time.Duration
 }
 
 type ServerConfig struct {
 	Port    string
 	GinMode string
+	// ShutdownTimeout bounds how long in-flight requests may take on shutdown
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout and WriteTimeout bound reading a request's headers
+	// and writing its response. Exports and live streams set their own.
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	// DrainDelay is how long /readyz reports not-ready before draining starts,
+	// so load balancers stop sending new requests
+	DrainDelay time.Duration
+	// ConfigWatchInterval is how often the config file is checked for
+	// changes; zero disables watching
+	ConfigWatchInterval time.Duration
+	// TrustedProxies are the addresses and CIDR ranges whose X-Forwarded-For
+	// and X-Real-IP name the client, for REST requests and gRPC calls alike
+	TrustedProxies []string
 }
 
 type JWTConfig struct {
 	Secret string
 	Expiry time.Duration
+	// Algorithm is HS256 (shared secret) or RS256/EdDSA (rotating key pairs)
+	Algorithm           string
+	KeyRotationInterval time.Duration
+	// KeyRetention is how long a retired key keeps verifying tokens
+	KeyRetention time.Duration
+}
+
+type AuthConfig struct {
+	TokenSecret              string
+	VerificationTokenExpiry  time.Duration
+	PasswordResetTokenExpiry time.Duration
+	RequireVerifiedEmail     bool
+	TwoFactorIssuer          string
+	TwoFactorChallengeExpiry time.Duration
+	EmailChangeTokenExpiry   time.Duration
+	AccountDeletionGrace     time.Duration
+	AdminEmails              []string
+	InvitationTokenExpiry    time.Duration
+}
+
+type MailConfig struct {
+	Driver       string
+	From         string
+	AppURL       string
+	FilePath     string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// RateLimitConfig limits requests per client IP within a sliding window
+type RateLimitConfig struct {
+	Requests int
+	Window   time.Duration
+}
+
+// CORSConfig lists the origins allowed to call the API; "*" allows any
+type CORSConfig struct {
+	AllowedOrigins []string
+}
+
+// BlocklistConfig lists destination domains that cannot be shortened or
+// redirected to. Subdomains are blocked too.
+type BlocklistConfig struct {
+	Domains []string
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string
+	// Format is json or text
+	Format string
+}
+
+type LinkConfig struct {
+	// ShortCodeLength is the length of generated short codes
+	ShortCodeLength int
+	// CacheTTL and CacheSize bound the in-memory redirect cache; zero disables it
+	CacheTTL  time.Duration
+	CacheSize int
+	// BulkMaxItems caps the links one bulk request may create or act on
+	BulkMaxItems int
+	// ImportMaxRows caps the rows of one import file
+	ImportMaxRows int
+	// ExportMaxConcurrent caps the exports running at once and ExportTimeout
+	// bounds each; zero disables either
+	ExportMaxConcurrent int
+	ExportTimeout       time.Duration
+}
+
+// ClickConfig sizes the background queue that records redirect clicks.
+// Click events older than Retention are purged; zero keeps them.
+type ClickConfig struct {
+	QueueSize int
+	Workers   int
+	Retention time.Duration
+}
+
+// LiveConfig sizes the live click streams. The last BufferSize clicks are
+// kept so that a reconnecting client can resume where it left off.
+type LiveConfig struct {
+	BufferSize int
+	// SubscriberBuffer is how many clicks a slow client may fall behind
+	// before it is disconnected
+	SubscriberBuffer int
+	MaxSubscribers   int
+	// MaxStreamsPerUser keeps one user from taking all MaxSubscribers
+	MaxStreamsPerUser int
+	Heartbeat         time.Duration
+}
+
+// IdempotencyConfig sets how long an Idempotency-Key is remembered. A key
+// whose request has not finished after LockTimeout, for example because the
+// instance handling it died, may be claimed by a retry.
+type IdempotencyConfig struct {
+	TTL         time.Duration
+	LockTimeout time.Duration
+}
+
+// WebhookConfig tunes webhook delivery. A failed delivery is retried after
+// RetryBase, doubling up to RetryMax, until MaxAttempts have failed.
+type WebhookConfig struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+	Workers      int
+	MaxAttempts  int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	// LogRetention is how long delivered and dead deliveries are kept
+	LogRetention time.Duration
+	// AllowPrivate permits endpoints on loopback and private networks
+	AllowPrivate bool
+}
+
+// privateNetworks are loopback and private ranges, where proxies in front of
+// the server usually are
+var privateNetworks = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+
+// GRPCConfig controls the gRPC API, which is served on Port when one is
+// set. Reflection lets tools such as grpcurl list the services. With
+// TLSCertFile and TLSKeyFile the API is served over TLS; otherwise a proxy in
+// front of it must terminate TLS.
+type GRPCConfig struct {
+	Port        string
+	Reflection  bool
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// MetricsConfig controls the Prometheus endpoint. When Port is set metrics
+// are served on their own listener instead of the API port.
+type MetricsConfig struct {
+	Enabled bool
+	Port    string
 }
 
+// HealthConfig tunes the readiness checks. The instance is not ready once the
+// click queue is fuller than ClickBacklogRatio of its capacity.
+type HealthConfig struct {
+	CheckTimeout      time.Duration
+	ClickBacklogRatio float64
+}
+
+// DocsConfig controls the API documentation. /openapi.json is always
+// served; UI adds an interactive page at /docs.
+type DocsConfig struct {
+	UI bool
+}
+
+// APIConfig controls the unversioned /api alias of the current API version.
+// The alias announces LegacyDeprecation, and LegacySunset once one is set.
+type APIConfig struct {
+	LegacyAlias       bool
+	LegacyDeprecation time.Time
+	LegacySunset      time.Time
+}
+
+// TracingConfig selects where spans are exported. Exporter is none, stdout
+// or otlp; SampleRatio is the fraction of new traces that are recorded.
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	ServiceName  string
+	SampleRatio  float64
+}
+
+// OIDCConfig configures single sign-on with an OpenID Connect provider. SSO
+// is disabled unless an issuer and client ID are set.
+type OIDCConfig struct {
+	ProviderName  string
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	AutoProvision bool
+	FlowExpiry    time.Duration
+}
+
+// Enabled reports whether single sign-on is configured
+func (c *OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// Load reads the configuration from the environment only
 func Load() (*Config, error) {
+	return LoadWithOptions(Options{})
+}
+
+// LoadWithOptions builds the configuration from, in increasing precedence,
+// built-in defaults, the config file, environment variables and command
+// line overrides. See docs/configuration.md for the schema.
+func LoadWithOptions(opts Options) (*Config, error) {
 	// Load .env file if exists
 	if err := godotenv.Load(); err != nil {
 		// .env file is optional, continue without it
 	}
 
+	if opts.File == "" {
+		opts.File = os.Getenv("CONFIG_FILE")
+	}
+
+	l := &loader{fileName: opts.File, overrides: opts.Overrides, used: map[string]bool{}}
+	if opts.File != "" {
+		values, err := readFile(opts.File)
+		if err != nil {
+			return nil, err
+		}
+		l.file = values
+	}
+
 	config := &Config{
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnvAsInt("DB_PORT", 5432),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "password"),
-			Name:     getEnv("DB_NAME", "link_shortener"),
-			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+			Host:         l.getString("database.host", "DB_HOST", "localhost"),
+			Port:         l.getInt("database.port", "DB_PORT", 5432),
+			User:         l.getString("database.user", "DB_USER", "postgres"),
+			Password:     l.getString("database.password", "DB_PASSWORD", "password"),
+			Name:         l.getString("database.name", "DB_NAME", "link_shortener"),
+			SSLMode:      l.getString("database.ssl_mode", "DB_SSL_MODE", "disable"),
+			MaxOpenConns: l.getInt("database.max_open_conns", "DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns: l.getInt("database.max_idle_conns", "DB_MAX_IDLE_CONNS", 5),
+			QueryTimeout: l.getDuration("database.query_timeout", "DB_QUERY_TIMEOUT", 5*time.Second),
 		},
 		Server: ServerConfig{
-			Port:    getEnv("PORT", "8080"),
-			GinMode: getEnv("GIN_MODE", "debug"),
+			Port:                l.getString("server.port", "PORT", "8080"),
+			GinMode:             l.getString("server.mode", "GIN_MODE", "debug"),
+			ShutdownTimeout:     l.getDuration("server.shutdown_timeout", "SHUTDOWN_TIMEOUT", 30*time.Second),
+			ReadHeaderTimeout:   l.getDuration("server.read_header_timeout", "READ_HEADER_TIMEOUT", 10*time.Second),
+			WriteTimeout:        l.getDuration("server.write_timeout", "WRITE_TIMEOUT", time.Minute),
+			ConfigWatchInterval: l.getDuration("server.config_watch_interval", "CONFIG_WATCH_INTERVAL", 5*time.Second),
+			DrainDelay:          l.getDuration("server.drain_delay", "SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+			TrustedProxies:      l.getSlice("server.trusted_proxies", "TRUSTED_PROXIES", privateNetworks),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-super-secret-jwt-key-here"),
-			Expiry: getEnvAsDuration("JWT_EXPIRY", 24*time.Hour),
+			Secret:              l.getString("jwt.secret", "JWT_SECRET", "your-super-secret-jwt-key-here"),
+			Expiry:              l.getDuration("jwt.expiry", "JWT_EXPIRY", 24*time.Hour),
+			Algorithm:           l.getString("jwt.algorithm", "JWT_ALGORITHM", "HS256"),
+			KeyRotationInterval: l.getDuration("jwt.key_rotation_interval", "JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		},
+		Auth: AuthConfig{
+			VerificationTokenExpiry:  l.getDuration("auth.verification_token_expiry", "VERIFICATION_TOKEN_EXPIRY", 48*time.Hour),
+			PasswordResetTokenExpiry: l.getDuration("auth.password_reset_token_expiry", "PASSWORD_RESET_TOKEN_EXPIRY", time.Hour),
+			RequireVerifiedEmail:     l.getBool("auth.require_verified_email", "REQUIRE_VERIFIED_EMAIL", false),
+			TwoFactorIssuer:          l.getString("auth.two_factor_issuer", "TWO_FACTOR_ISSUER", "Link Shortener"),
+			TwoFactorChallengeExpiry: l.getDuration("auth.two_factor_challenge_expiry", "TWO_FACTOR_CHALLENGE_EXPIRY", 5*time.Minute),
+			EmailChangeTokenExpiry:   l.getDuration("auth.email_change_token_expiry", "EMAIL_CHANGE_TOKEN_EXPIRY", 24*time.Hour),
+			AccountDeletionGrace:     l.getDuration("auth.account_deletion_grace_period", "ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+			AdminEmails:              l.getSlice("auth.admin_emails", "ADMIN_EMAILS", nil),
+			InvitationTokenExpiry:    l.getDuration("auth.workspace_invitation_expiry", "WORKSPACE_INVITATION_EXPIRY", 7*24*time.Hour),
+		},
+		Mail: MailConfig{
+			Driver:       l.getString("mail.driver", "MAIL_DRIVER", "log"),
+			From:         l.getString("mail.from", "MAIL_FROM", "no-reply@localhost"),
+			FilePath:     l.getString("mail.file_path", "MAIL_FILE_PATH", "mail.log"),
+			SMTPHost:     l.getString("mail.smtp_host", "SMTP_HOST", "localhost"),
+			SMTPPort:     l.getInt("mail.smtp_port", "SMTP_PORT", 587),
+			SMTPUsername: l.getString("mail.smtp_username", "SMTP_USERNAME", ""),
+			SMTPPassword: l.getString("mail.smtp_password", "SMTP_PASSWORD", ""),
+		},
+		OIDC: OIDCConfig{
+			ProviderName:  l.getString("oidc.provider_name", "OIDC_PROVIDER_NAME", "oidc"),
+			IssuerURL:     l.getString("oidc.issuer_url", "OIDC_ISSUER_URL", ""),
+			ClientID:      l.getString("oidc.client_id", "OIDC_CLIENT_ID", ""),
+			ClientSecret:  l.getString("oidc.client_secret", "OIDC_CLIENT_SECRET", ""),
+			Scopes:        l.getSlice("oidc.scopes", "OIDC_SCOPES", []string{"openid", "email", "profile"}),
+			AutoProvision: l.getBool("oidc.auto_provision", "OIDC_AUTO_PROVISION", true),
+			FlowExpiry:    l.getDuration("oidc.flow_expiry", "OIDC_FLOW_EXPIRY", 10*time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Requests: l.getInt("rate_limit.requests", "RATE_LIMIT_REQUESTS", 100),
+			Window:   l.getDuration("rate_limit.window", "RATE_LIMIT_WINDOW", time.Minute),
+		},
+		Links: LinkConfig{
+			ShortCodeLength:     l.getInt("links.short_code_length", "SHORT_CODE_LENGTH", 8),
+			CacheTTL:            l.getDuration("links.cache_ttl", "LINK_CACHE_TTL", 30*time.Second),
+			CacheSize:           l.getInt("links.cache_size", "LINK_CACHE_SIZE", 10000),
+			BulkMaxItems:        l.getInt("links.bulk_max_items", "LINK_BULK_MAX_ITEMS", 1000),
+			ImportMaxRows:       l.getInt("links.import_max_rows", "LINK_IMPORT_MAX_ROWS", 100000),
+			ExportMaxConcurrent: l.getInt("links.export_max_concurrent", "LINK_EXPORT_MAX_CONCURRENT", 4),
+			ExportTimeout:       l.getDuration("links.export_timeout", "LINK_EXPORT_TIMEOUT", 10*time.Minute),
+		},
+		Clicks: ClickConfig{
+			QueueSize: l.getInt("clicks.queue_size", "CLICK_QUEUE_SIZE", 10000),
+			Workers:   l.getInt("clicks.workers", "CLICK_WORKERS", 4),
+			Retention: l.getDuration("clicks.retention", "CLICK_RETENTION", 365*24*time.Hour),
+		},
+		Live: LiveConfig{
+			BufferSize:        l.getInt("live.buffer_size", "LIVE_BUFFER_SIZE", 1000),
+			SubscriberBuffer:  l.getInt("live.subscriber_buffer", "LIVE_SUBSCRIBER_BUFFER", 64),
+			MaxSubscribers:    l.getInt("live.max_subscribers", "LIVE_MAX_SUBSCRIBERS", 1000),
+			MaxStreamsPerUser: l.getInt("live.max_streams_per_user", "LIVE_MAX_STREAMS_PER_USER", 10),
+			Heartbeat:         l.getDuration("live.heartbeat", "LIVE_HEARTBEAT", 15*time.Second),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:         l.getDuration("idempotency.ttl", "IDEMPOTENCY_TTL", 24*time.Hour),
+			LockTimeout: l.getDuration("idempotency.lock_timeout", "IDEMPOTENCY_LOCK_TIMEOUT", 2*time.Minute),
+		},
+		Webhooks: WebhookConfig{
+			PollInterval: l.getDuration("webhooks.poll_interval", "WEBHOOK_POLL_INTERVAL", 2*time.Second),
+			Timeout:      l.getDuration("webhooks.timeout", "WEBHOOK_TIMEOUT", 10*time.Second),
+			Workers:      l.getInt("webhooks.workers", "WEBHOOK_WORKERS", 4),
+			MaxAttempts:  l.getInt("webhooks.max_attempts", "WEBHOOK_MAX_ATTEMPTS", 8),
+			RetryBase:    l.getDuration("webhooks.retry_base", "WEBHOOK_RETRY_BASE", 30*time.Second),
+			RetryMax:     l.getDuration("webhooks.retry_max", "WEBHOOK_RETRY_MAX", 6*time.Hour),
+			LogRetention: l.getDuration("webhooks.log_retention", "WEBHOOK_LOG_RETENTION", 7*24*time.Hour),
+			AllowPrivate: l.getBool("webhooks.allow_private", "WEBHOOK_ALLOW_PRIVATE", false),
 		},
+		GRPC: GRPCConfig{
+			Port:        l.getString("grpc.port", "GRPC_PORT", ""),
+			Reflection:  l.getBool("grpc.reflection", "GRPC_REFLECTION", false),
+			TLSCertFile: l.getString("grpc.tls_cert_file", "GRPC_TLS_CERT_FILE", ""),
+			TLSKeyFile:  l.getString("grpc.tls_key_file", "GRPC_TLS_KEY_FILE", ""),
+		},
+		Metrics: MetricsConfig{
+			Enabled: l.getBool("metrics.enabled", "METRICS_ENABLED", false),
+			Port:    l.getString("metrics.port", "METRICS_PORT", ""),
+		},
+		Health: HealthConfig{
+			CheckTimeout:      l.getDuration("health.check_timeout", "HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			ClickBacklogRatio: l.getFloat("health.click_backlog_ratio", "HEALTH_CLICK_BACKLOG_RATIO", 0.9),
+		},
+		Docs: DocsConfig{
+			UI: l.getBool("docs.ui", "DOCS_UI", false),
+		},
+		API: APIConfig{
+			LegacyAlias:       l.getBool("api.legacy_alias", "API_LEGACY_ALIAS", true),
+			LegacyDeprecation: l.getDate("api.legacy_deprecation", "API_LEGACY_DEPRECATION", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)),
+			LegacySunset:      l.getDate("api.legacy_sunset", "API_LEGACY_SUNSET", time.Time{}),
+		},
+		Tracing: TracingConfig{
+			Exporter:     l.getString("tracing.exporter", "OTEL_TRACES_EXPORTER", "none"),
+			OTLPEndpoint: l.getString("tracing.otlp_endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+			ServiceName:  l.getString("tracing.service_name", "OTEL_SERVICE_NAME", "link-shortener"),
+			SampleRatio:  l.getFloat("tracing.sample_ratio", "OTEL_TRACES_SAMPLER_ARG", 1),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: l.getSlice("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", []string{"*"}),
+		},
+		Blocklist: BlocklistConfig{
+			Domains: l.getSlice("blocklist.domains", "BLOCKED_DOMAINS", nil),
+		},
+		Log: LogConfig{
+			Level:  l.getString("log.level", "LOG_LEVEL", "info"),
+			Format: l.getString("log.format", "LOG_FORMAT", "json"),
+		},
+	}
+
+	// Action tokens fall back to the JWT secret when no dedicated secret is set
+	config.Auth.TokenSecret = l.getString("auth.token_secret", "TOKEN_SECRET", config.JWT.Secret)
+	config.Mail.AppURL = l.getString("mail.app_url", "APP_URL", "http://localhost:"+config.Server.Port)
+	// Retired keys must outlive every token they signed
+	config.JWT.KeyRetention = l.getDuration("jwt.key_retention", "JWT_KEY_RETENTION", config.JWT.Expiry)
+	if config.JWT.KeyRetention < config.JWT.Expiry {
+		config.JWT.KeyRetention = config.JWT.Expiry
 	}
+	config.OIDC.RedirectURL = l.getString("oidc.redirect_url", "OIDC_REDIRECT_URL", strings.TrimSuffix(config.Mail.AppURL, "/")+"/api/v1/auth/oidc/callback")
+
+	l.checkUnknown()
+	config.problems = l.problems
+	config.settings = l.settings
 
 	return config, nil
 }
@@ -73,27 +452,175 @@ func (c *Config) GetDatabaseURL() string {
 	)
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Setting is the effective value of one configuration key
+type Setting struct {
+	Key    string
+	Env    string
+	Value  string
+	Source string
+}
+
+// loader resolves each setting from its layers, remembering where every value
+// came from and every value that could not be parsed
+type loader struct {
+	file      map[string]string
+	fileName  string
+	overrides map[string]string
+	used      map[string]bool
+	problems  []problem
+	settings  []Setting
+}
+
+// problem is a value that could not be used, with the key it belongs to
+type problem struct {
+	key     string
+	message string
+}
+
+// lookup returns the highest precedence value set for a key and its source
+func (l *loader) lookup(key, env string) (string, string, bool) {
+	l.used[key] = true
+	if value, ok := l.overrides[key]; ok {
+		return value, "flag", true
 	}
-	return defaultValue
+	if value := os.Getenv(env); value != "" {
+		return value, "env", true
+	}
+	if value, ok := l.file[key]; ok {
+		return value, "file", true
+	}
+	return "", "default", false
+}
+
+func (l *loader) record(key, env, value, source string) {
+	l.settings = append(l.settings, Setting{Key: key, Env: env, Value: value, Source: source})
+}
+
+// invalid reports a value under the name it was given in its source
+func (l *loader) invalid(key, env, source, value, kind string) {
+	name := env
+	switch source {
+	case "file":
+		name = l.fileName + ": " + key
+	case "flag":
+		name = "-set " + key
+	}
+	l.problems = append(l.problems, problem{key: key, message: fmt.Sprintf("%s: %q is not a valid %s", name, value, kind)})
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+// checkUnknown reports file keys and overrides that match no setting, which
+// are most likely typos
+func (l *loader) checkUnknown() {
+	var unknown []problem
+	for key := range l.file {
+		if !l.used[key] {
+			unknown = append(unknown, problem{key: key, message: fmt.Sprintf("%s: unknown setting %q", l.fileName, key)})
+		}
+	}
+	for key := range l.overrides {
+		if !l.used[key] {
+			unknown = append(unknown, problem{key: key, message: fmt.Sprintf("-set: unknown setting %q", key)})
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].message < unknown[j].message })
+	l.problems = append(l.problems, unknown...)
+}
+
+func (l *loader) getString(key, env, defaultValue string) string {
+	value, source, ok := l.lookup(key, env)
+	if !ok {
+		value = defaultValue
+	}
+	l.record(key, env, value, source)
+	return value
+}
+
+func (l *loader) getInt(key, env string, defaultValue int) int {
+	value, source, ok := l.lookup(key, env)
+	if ok {
 		if intValue, err := strconv.Atoi(value); err == nil {
+			l.record(key, env, value, source)
 			return intValue
 		}
+		l.invalid(key, env, source, value, "integer")
+	}
+	l.record(key, env, strconv.Itoa(defaultValue), "default")
+	return defaultValue
+}
+
+func (l *loader) getFloat(key, env string, defaultValue float64) float64 {
+	value, source, ok := l.lookup(key, env)
+	if ok {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			l.record(key, env, value, source)
+			return floatValue
+		}
+		l.invalid(key, env, source, value, "number")
 	}
+	l.record(key, env, strconv.FormatFloat(defaultValue, 'g', -1, 64), "default")
 	return defaultValue
 }
 
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
+func (l *loader) getDuration(key, env string, defaultValue time.Duration) time.Duration {
+	value, source, ok := l.lookup(key, env)
+	if ok {
 		if duration, err := time.ParseDuration(value); err == nil {
+			l.record(key, env, value, source)
 			return duration
 		}
+		l.invalid(key, env, source, value, "duration")
+	}
+	l.record(key, env, defaultValue.String(), "default")
+	return defaultValue
+}
+
+// getDate accepts a date such as 2026-01-31 or an RFC 3339 timestamp; the
+// zero time means unset
+func (l *loader) getDate(key, env string, defaultValue time.Time) time.Time {
+	value, source, ok := l.lookup(key, env)
+	if ok {
+		for _, layout := range []string{time.DateOnly, time.RFC3339} {
+			if date, err := time.Parse(layout, value); err == nil {
+				l.record(key, env, value, source)
+				return date
+			}
+		}
+		l.invalid(key, env, source, value, "date")
+	}
+
+	recorded := ""
+	if !defaultValue.IsZero() {
+		recorded = defaultValue.Format(time.DateOnly)
+	}
+	l.record(key, env, recorded, "default")
+	return defaultValue
+}
+
+func (l *loader) getBool(key, env string, defaultValue bool) bool {
+	value, source, ok := l.lookup(key, env)
+	if ok {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			l.record(key, env, value, source)
+			return boolValue
+		}
+		l.invalid(key, env, source, value, "boolean")
+	}
+	l.record(key, env, strconv.FormatBool(defaultValue), "default")
+	return defaultValue
+}
+
+func (l *loader) getSlice(key, env string, defaultValue []string) []string {
+	value, source, ok := l.lookup(key, env)
+	if ok {
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		l.record(key, env, strings.Join(values, ","), source)
+		return values
 	}
+	l.record(key, env, strings.Join(defaultValue, ","), "default")
 	return defaultValue
 }
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Options selects the config file and command line overrides used by
+// LoadWithOptions
+type Options struct {
+	// File is a YAML or TOML config file; CONFIG_FILE is used when empty
+	File string
+	// Overrides are file keys set on the command line, e.g. server.port
+	Overrides map[string]string
+}
+
+// RegisterFlags adds the -config and -set flags to a flag set
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "config", "", "path to a YAML or TOML config file (default $CONFIG_FILE)")
+	fs.Var((*overrideFlag)(o), "set", "override a setting, e.g. -set server.port=9090 (repeatable)")
+}
+
+type overrideFlag Options
+
+func (f *overrideFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(f.Overrides))
+	for key, value := range f.Overrides {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *overrideFlag) Set(value string) error {
+	key, setting, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	if f.Overrides == nil {
+		f.Overrides = map[string]string{}
+	}
+	f.Overrides[strings.TrimSpace(key)] = setting
+	return nil
+}
+
+// readFile parses a config file into flat dotted keys such as
+// database.max_open_conns. The format is chosen by extension.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file type %q; use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key, v, values)
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		case time.Time:
+			// YAML and TOML parse unquoted dates themselves
+			values[key] = v.Format(time.RFC3339)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
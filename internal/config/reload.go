@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// livePrefixes are the settings that can change without a restart
+var livePrefixes = []string{"rate_limit.", "cors.", "blocklist.", "log.level"}
+
+// IsLive reports whether a setting can be changed by a reload
+func IsLive(key string) bool {
+	for _, prefix := range livePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reloader re-reads the configuration and hands the live settings to an
+// apply function. Changes to any other setting are rejected, since they need
+// a restart, and keep their running value.
+type Reloader struct {
+	opts  Options
+	apply func(*Config)
+
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+}
+
+// NewReloader creates a reloader for a configuration loaded with opts
+func NewReloader(opts Options, current *Config, apply func(*Config)) *Reloader {
+	r := &Reloader{opts: opts, apply: apply, current: current}
+	if info, err := os.Stat(r.file()); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Current returns the running configuration
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the configuration again. When the live settings are valid they
+// are applied together and the changed settings that need a restart are
+// returned; otherwise nothing is applied.
+func (r *Reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := LoadWithOptions(r.opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := loaded.ValidateLive(); err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]Setting, len(r.current.settings))
+	for _, setting := range r.current.settings {
+		running[setting.Key] = setting
+	}
+
+	var rejected []string
+	settings := make([]Setting, 0, len(loaded.settings))
+	for _, setting := range loaded.settings {
+		previous, ok := running[setting.Key]
+		if IsLive(setting.Key) || !ok {
+			settings = append(settings, setting)
+			continue
+		}
+		// Derived defaults follow another key, which is reported instead
+		derived := previous.Source == "default" && setting.Source == "default"
+		if previous.Value != setting.Value && !derived {
+			rejected = append(rejected, setting.Key)
+		}
+		settings = append(settings, previous)
+	}
+
+	next := *r.current
+	next.RateLimit = loaded.RateLimit
+	next.CORS = loaded.CORS
+	next.Blocklist = loaded.Blocklist
+	next.Log.Level = loaded.Log.Level
+	next.settings = settings
+
+	r.current = &next
+	r.apply(&next)
+	return rejected, nil
+}
+
+// Changed reports whether the config file was modified since the last call
+func (r *Reloader) Changed() bool {
+	info, err := os.Stat(r.file())
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info.ModTime().Equal(r.modTime) {
+		return false
+	}
+	r.modTime = info.ModTime()
+	return true
+}
+
+// HasFile reports whether the configuration is read from a file
+func (r *Reloader) HasFile() bool {
+	return r.file() != ""
+}
+
+func (r *Reloader) file() string {
+	if r.opts.File != "" {
+		return r.opts.File
+	}
+	return os.Getenv("CONFIG_FILE")
+}
@@ -0,0 +1,356 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// placeholderJWTSecret is the value shipped in env.example and used when unset
+	placeholderJWTSecret = "your-super-secret-jwt-key-here"
+	// minSecretLength is the shortest secret accepted for signing tokens
+	minSecretLength = 32
+	// Generated short codes share the column with custom aliases
+	minShortCodeLength = 4
+	maxShortCodeLength = 20
+)
+
+// weakPasswords are database passwords that are never acceptable
+var weakPasswords = map[string]bool{
+	"":         true,
+	"password": true,
+	"postgres": true,
+	"admin":    true,
+	"secret":   true,
+	"changeme": true,
+}
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// SlogLevel returns the configured log level, defaulting to info
+func (c *LogConfig) SlogLevel() slog.Level {
+	if level, ok := logLevels[strings.ToLower(c.Level)]; ok {
+		return level
+	}
+	return slog.LevelInfo
+}
+
+// ValidationError lists every configuration problem found
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// IsRelease reports whether the server runs in production mode
+func (c *Config) IsRelease() bool {
+	return c.Server.GinMode == "release"
+}
+
+// Validate checks the whole configuration and reports every problem at once.
+// It returns nil or a *ValidationError.
+func (c *Config) Validate() error {
+	var problems []string
+	for _, p := range c.problems {
+		problems = append(problems, p.message)
+	}
+	parsed := len(problems)
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	c.validateLive(add)
+
+	switch c.Server.GinMode {
+	case "debug", "release", "test":
+	default:
+		add("GIN_MODE: %q must be one of debug, release or test", c.Server.GinMode)
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		add("PORT: %q is not a valid port", c.Server.Port)
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		add("DB_PORT: %d is not a valid port", c.Database.Port)
+	}
+
+	for key, value := range map[string]string{"DB_HOST": c.Database.Host, "DB_USER": c.Database.User, "DB_NAME": c.Database.Name} {
+		if value == "" {
+			add("%s is required", key)
+		}
+	}
+
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		add("DB_SSL_MODE: %q is not a valid sslmode", c.Database.SSLMode)
+	}
+
+	if c.Database.MaxOpenConns < 1 {
+		add("DB_MAX_OPEN_CONNS must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS")
+	}
+
+	if weakPasswords[c.Database.Password] {
+		add("DB_PASSWORD is missing or a well-known default")
+	}
+
+	if c.JWT.Secret == placeholderJWTSecret {
+		add("JWT_SECRET is the example placeholder; set a random secret of at least %d characters", minSecretLength)
+	} else if len(c.JWT.Secret) < minSecretLength {
+		add("JWT_SECRET must be at least %d characters", minSecretLength)
+	}
+	if c.Auth.TokenSecret != c.JWT.Secret && len(c.Auth.TokenSecret) < minSecretLength {
+		add("TOKEN_SECRET must be at least %d characters", minSecretLength)
+	}
+
+	switch c.JWT.Algorithm {
+	case "HS256", "RS256", "EdDSA":
+	default:
+		add("JWT_ALGORITHM: %q must be one of HS256, RS256 or EdDSA", c.JWT.Algorithm)
+	}
+
+	durations := map[string]time.Duration{
+		"JWT_EXPIRY":                    c.JWT.Expiry,
+		"JWT_KEY_ROTATION_INTERVAL":     c.JWT.KeyRotationInterval,
+		"VERIFICATION_TOKEN_EXPIRY":     c.Auth.VerificationTokenExpiry,
+		"PASSWORD_RESET_TOKEN_EXPIRY":   c.Auth.PasswordResetTokenExpiry,
+		"TWO_FACTOR_CHALLENGE_EXPIRY":   c.Auth.TwoFactorChallengeExpiry,
+		"EMAIL_CHANGE_TOKEN_EXPIRY":     c.Auth.EmailChangeTokenExpiry,
+		"ACCOUNT_DELETION_GRACE_PERIOD": c.Auth.AccountDeletionGrace,
+		"WORKSPACE_INVITATION_EXPIRY":   c.Auth.InvitationTokenExpiry,
+		"OIDC_FLOW_EXPIRY":              c.OIDC.FlowExpiry,
+		"SHUTDOWN_TIMEOUT":              c.Server.ShutdownTimeout,
+		"READ_HEADER_TIMEOUT":           c.Server.ReadHeaderTimeout,
+		"WRITE_TIMEOUT":                 c.Server.WriteTimeout,
+		"HEALTH_CHECK_TIMEOUT":          c.Health.CheckTimeout,
+		"WEBHOOK_POLL_INTERVAL":         c.Webhooks.PollInterval,
+		"LIVE_HEARTBEAT":                c.Live.Heartbeat,
+		"IDEMPOTENCY_TTL":               c.Idempotency.TTL,
+		"IDEMPOTENCY_LOCK_TIMEOUT":      c.Idempotency.LockTimeout,
+		"WEBHOOK_TIMEOUT":               c.Webhooks.Timeout,
+		"WEBHOOK_RETRY_BASE":            c.Webhooks.RetryBase,
+		"WEBHOOK_LOG_RETENTION":         c.Webhooks.LogRetention,
+	}
+	for key, value := range durations {
+		if value <= 0 {
+			add("%s must be a positive duration", key)
+		}
+	}
+
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		add("LOG_FORMAT: %q must be json or text", c.Log.Format)
+	}
+
+	if c.Links.CacheTTL < 0 || c.Links.CacheSize < 0 {
+		add("LINK_CACHE_TTL and LINK_CACHE_SIZE must not be negative")
+	}
+	if c.Links.BulkMaxItems < 1 || c.Links.ImportMaxRows < 1 {
+		add("LINK_BULK_MAX_ITEMS and LINK_IMPORT_MAX_ROWS must be at least 1")
+	}
+	if c.Links.ExportMaxConcurrent < 0 || c.Links.ExportTimeout < 0 {
+		add("LINK_EXPORT_MAX_CONCURRENT and LINK_EXPORT_TIMEOUT must not be negative")
+	}
+	if c.Clicks.QueueSize < 1 || c.Clicks.Workers < 1 {
+		add("CLICK_QUEUE_SIZE and CLICK_WORKERS must be at least 1")
+	}
+	if c.Clicks.Retention < 0 {
+		add("CLICK_RETENTION must not be negative")
+	}
+	if c.Live.BufferSize < 1 || c.Live.SubscriberBuffer < 1 || c.Live.MaxSubscribers < 1 || c.Live.MaxStreamsPerUser < 1 {
+		add("LIVE_BUFFER_SIZE, LIVE_SUBSCRIBER_BUFFER, LIVE_MAX_SUBSCRIBERS and LIVE_MAX_STREAMS_PER_USER must be at least 1")
+	}
+	if c.Webhooks.Workers < 1 || c.Webhooks.MaxAttempts < 1 {
+		add("WEBHOOK_WORKERS and WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.Webhooks.RetryMax < c.Webhooks.RetryBase {
+		add("WEBHOOK_RETRY_MAX must be at least WEBHOOK_RETRY_BASE")
+	}
+	if c.GRPC.Port != "" {
+		if port, err := strconv.Atoi(c.GRPC.Port); err != nil || port < 1 || port > 65535 {
+			add("GRPC_PORT: %q is not a valid port", c.GRPC.Port)
+		} else if c.GRPC.Port == c.Server.Port || c.GRPC.Port == c.Metrics.Port {
+			add("GRPC_PORT must differ from PORT and METRICS_PORT")
+		}
+	}
+	if (c.GRPC.TLSCertFile == "") != (c.GRPC.TLSKeyFile == "") {
+		add("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("TRUSTED_PROXIES: %q is not an IP address or CIDR range", proxy)
+		}
+	}
+	if c.Metrics.Port != "" {
+		if port, err := strconv.Atoi(c.Metrics.Port); err != nil || port < 1 || port > 65535 {
+			add("METRICS_PORT: %q is not a valid port", c.Metrics.Port)
+		} else if c.Metrics.Port == c.Server.Port {
+			add("METRICS_PORT must differ from PORT")
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if !isAbsoluteURL(c.Tracing.OTLPEndpoint) {
+			add("OTEL_EXPORTER_OTLP_ENDPOINT: %q must be an absolute URL", c.Tracing.OTLPEndpoint)
+		}
+	default:
+		add("OTEL_TRACES_EXPORTER: %q must be none, stdout or otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+	}
+
+	if c.Server.ConfigWatchInterval < 0 {
+		add("CONFIG_WATCH_INTERVAL must not be negative")
+	}
+	if c.Server.DrainDelay < 0 {
+		add("SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+	if c.Health.ClickBacklogRatio <= 0 || c.Health.ClickBacklogRatio > 1 {
+		add("HEALTH_CLICK_BACKLOG_RATIO must be greater than 0 and at most 1")
+	}
+	if !c.API.LegacySunset.IsZero() && !c.API.LegacySunset.After(c.API.LegacyDeprecation) {
+		add("API_LEGACY_SUNSET must be after API_LEGACY_DEPRECATION")
+	}
+	if c.Database.QueryTimeout < 0 {
+		add("DB_QUERY_TIMEOUT must not be negative")
+	}
+	// A request still running must never lose its key to a retry
+	lock := c.Idempotency.LockTimeout
+	if lock <= c.Database.QueryTimeout || lock <= c.Server.WriteTimeout || lock <= c.Server.ShutdownTimeout {
+		add("IDEMPOTENCY_LOCK_TIMEOUT must exceed DB_QUERY_TIMEOUT, WRITE_TIMEOUT and SHUTDOWN_TIMEOUT")
+	}
+
+	if c.Links.ShortCodeLength < minShortCodeLength || c.Links.ShortCodeLength > maxShortCodeLength {
+		add("SHORT_CODE_LENGTH must be between %d and %d", minShortCodeLength, maxShortCodeLength)
+	}
+
+	switch c.Mail.Driver {
+	case "log", "file", "smtp":
+	default:
+		add("MAIL_DRIVER: %q must be one of log, file or smtp", c.Mail.Driver)
+	}
+	if c.Mail.Driver == "smtp" {
+		if c.Mail.SMTPHost == "" {
+			add("SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+		if c.Mail.SMTPPort < 1 || c.Mail.SMTPPort > 65535 {
+			add("SMTP_PORT: %d is not a valid port", c.Mail.SMTPPort)
+		}
+	}
+	if !strings.Contains(c.Mail.From, "@") {
+		add("MAIL_FROM: %q is not an email address", c.Mail.From)
+	}
+
+	if !isAbsoluteURL(c.Mail.AppURL) {
+		add("APP_URL: %q is not an absolute URL", c.Mail.AppURL)
+	}
+
+	if (c.OIDC.IssuerURL == "") != (c.OIDC.ClientID == "") {
+		add("OIDC_ISSUER_URL and OIDC_CLIENT_ID must be set together")
+	}
+	if c.OIDC.IssuerURL != "" && !isAbsoluteURL(c.OIDC.IssuerURL) {
+		add("OIDC_ISSUER_URL: %q is not an absolute URL", c.OIDC.IssuerURL)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	// Map iteration order is random; keep the report stable
+	sort.Strings(problems[parsed:])
+	return &ValidationError{Problems: problems}
+}
+
+// ValidateLive checks only the settings that can change while running; see
+// IsLive. It returns nil or a *ValidationError.
+func (c *Config) ValidateLive() error {
+	var problems []string
+	for _, p := range c.problems {
+		if IsLive(p.key) {
+			problems = append(problems, p.message)
+		}
+	}
+	c.validateLive(func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	})
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+func (c *Config) validateLive(add func(format string, args ...interface{})) {
+	if c.RateLimit.Requests < 1 {
+		add("RATE_LIMIT_REQUESTS must be at least 1")
+	}
+	if c.RateLimit.Window <= 0 {
+		add("RATE_LIMIT_WINDOW must be a positive duration")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !isOrigin(origin) {
+			add("CORS_ALLOWED_ORIGINS: %q is not an origin such as https://app.example.com", origin)
+		}
+	}
+
+	for _, domain := range c.Blocklist.Domains {
+		if strings.ContainsAny(domain, "/:@ ") {
+			add("BLOCKED_DOMAINS: %q is not a domain name", domain)
+		}
+	}
+
+	if _, ok := logLevels[strings.ToLower(c.Log.Level)]; !ok {
+		add("LOG_LEVEL: %q must be one of debug, info, warn or error", c.Log.Level)
+	}
+}
+
+// WriteSettings prints the effective configuration with secrets redacted
+func (c *Config) WriteSettings(w io.Writer) {
+	for _, setting := range c.settings {
+		value := setting.Value
+		if isSecret(setting.Key) && value != "" {
+			value = "[redacted]"
+		}
+		source := setting.Source
+		if source == "env" {
+			source += " " + setting.Env
+		}
+		fmt.Fprintf(w, "%s=%s (%s)\n", setting.Key, value, source)
+	}
+}
+
+func isSecret(key string) bool {
+	return strings.HasSuffix(key, "secret") || strings.HasSuffix(key, "password")
+}
+
+func isOrigin(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && isAbsoluteURL(value) && parsed.Path == "" && parsed.RawQuery == ""
+}
+
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -1,16 +1,23 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	_ "github.com/lib/pq"
 	"link-shortener/internal/config"
+	"link-shortener/internal/metrics"
+	"link-shortener/migrations"
 )
 
 type Database struct {
 	DB *sql.DB
+
+	// queryTimeout bounds each statement run through the query helpers
+	queryTimeout time.Duration
 }
 
 func NewDatabase(cfg *config.Config) (*Database, error) {
@@ -20,57 +27,147 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	}
 
 	// Test the connection
-	if err := db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+
+	slog.Info("Database connected", "host", cfg.Database.Host, "name", cfg.Database.Name)
 
-	log.Println("Database connected successfully")
+	return New(db, cfg.Database.QueryTimeout), nil
+}
 
-	return &Database{DB: db}, nil
+// New wraps an open connection pool; queryTimeout bounds each statement run
+// through the query helpers, zero disables the bound
+func New(db *sql.DB, queryTimeout time.Duration) *Database {
+	return &Database{DB: db, queryTimeout: queryTimeout}
 }
 
 func (d *Database) Close() error {
 	return d.DB.Close()
 }
 
-// InitTables creates the necessary tables if they don't exist
+// RegisterMetrics exposes connection pool statistics
+func (d *Database) RegisterMetrics(registry *metrics.Registry) {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return read(d.DB.Stats()) }
+	}
+
+	registry.Register(metrics.NewGaugeFunc("db_open_connections", "Open database connections, in use or idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) })))
+	registry.Register(metrics.NewGaugeFunc("db_in_use_connections", "Database connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) })))
+	registry.Register(metrics.NewGaugeFunc("db_idle_connections", "Idle database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) })))
+	registry.Register(metrics.NewGaugeFunc("db_max_open_connections", "Maximum open database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })))
+	registry.Register(metrics.NewCounterFunc("db_wait_count_total", "Times a query waited for a free connection.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) })))
+	registry.Register(metrics.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a free connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })))
+	registry.Register(metrics.NewCounterFunc("db_max_idle_closed_total", "Connections closed because the idle pool was full.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })))
+	registry.Register(metrics.NewCounterFunc("db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })))
+}
+
+// migrationLock is the advisory lock key that keeps instances starting at
+// the same time from applying a migration twice
+const migrationLock = 7461020
+
+// InitTables brings the schema up to date by applying, in order, every
+// migration file that schema_migrations does not list. Each file runs in its
+// own transaction together with the row that records it, so a version is
+// recorded only once its SQL has run.
 func (d *Database) InitTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			username VARCHAR(50) UNIQUE NOT NULL,
-			email VARCHAR(100) UNIQUE NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS links (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-			original_url TEXT NOT NULL,
-			short_code VARCHAR(20) UNIQUE NOT NULL,
-			title VARCHAR(255),
-			clicks INTEGER DEFAULT 0,
-			is_active BOOLEAN DEFAULT true,
-			expires_at TIMESTAMP,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_links_short_code ON links(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_links_user_id ON links(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+	ctx := context.Background()
+	all, err := migrations.All()
+	if err != nil {
+		return err
 	}
 
-	for _, query := range queries {
-		if _, err := d.DB.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := d.DB.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := 0
+	for _, migration := range all {
+		ran, err := d.applyMigration(ctx, migration)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", migration.Name, err)
+		}
+		if ran {
+			slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+			applied++
 		}
 	}
 
-	log.Println("Database tables initialized successfully")
+	slog.Info("Database schema is current", "schema_version", migrations.Latest(), "applied", applied)
 	return nil
 }
+
+// applyMigration runs a migration unless it is already recorded and reports
+// whether it ran
+func (d *Database) applyMigration(ctx context.Context, migration migrations.Migration) (bool, error) {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return false, err
+	}
+
+	var done bool
+	query := `SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`
+	if err := tx.QueryRowContext(ctx, query, migration.Version).Scan(&done); err != nil {
+		return false, err
+	}
+	if done {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return false, err
+	}
+	query = `INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT (version) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, migration.Version); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Ping checks that the database answers within ctx's deadline
+func (d *Database) Ping(ctx context.Context) error {
+	return d.DB.PingContext(ctx)
+}
+
+// AppliedMigrations returns the versions recorded in schema_migrations in
+// order
+func (d *Database) AppliedMigrations(ctx context.Context) ([]int, error) {
+	rows, err := d.QueryContext(ctx, `SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+
+	"link-shortener/internal/logging"
+	"link-shortener/internal/tracing"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Row is a single-row result. The statement's deadline lasts until Scan.
+type Row struct {
+	row    *sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *Row) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
+
+func (r *Row) Err() error {
+	return r.row.Err()
+}
+
+// Rows is a result set. The statement's deadline lasts until Close.
+type Rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *Rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// QueryRowContext runs a query expected to return at most one row, in its own span
+func (d *Database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	return queryRow(ctx, d.DB, d.queryTimeout, query, args)
+}
+
+func (d *Database) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	return queryRows(ctx, d.DB, d.queryTimeout, query, args)
+}
+
+func (d *Database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return exec(ctx, d.DB, d.queryTimeout, query, args)
+}
+
+// StreamContext runs a query whose rows are read over a long time, such as an
+// export. Only ctx bounds it; the per-statement deadline does not apply.
+func (d *Database) StreamContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	return queryRows(ctx, d.DB, 0, query, args)
+}
+
+// Tx is a transaction whose statements are traced and bounded like those on
+// Database. Cancelling the context passed to BeginTx rolls it back.
+type Tx struct {
+	tx           *sql.Tx
+	ctx          context.Context
+	queryTimeout time.Duration
+}
+
+// BeginTx starts a transaction. Its statements are children of ctx's span.
+func (d *Database) BeginTx(ctx context.Context) (*Tx, error) {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, ctx: ctx, queryTimeout: d.queryTimeout}, nil
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	return queryRow(ctx, t.tx, t.queryTimeout, query, args)
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	return queryRows(ctx, t.tx, t.queryTimeout, query, args)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return exec(ctx, t.tx, t.queryTimeout, query, args)
+}
+
+func (t *Tx) Commit() error {
+	_, span := startQuery(t.ctx, "COMMIT")
+	defer span.End()
+	err := t.tx.Commit()
+	span.RecordError(err)
+	return err
+}
+
+// Rollback aborts the transaction; calling it after Commit is a no-op
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// withTimeout applies the per-statement deadline; a shorter deadline already
+// on ctx still wins
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func queryRow(ctx context.Context, q queryer, timeout time.Duration, query string, args []interface{}) *Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	ctx, cancel := withTimeout(ctx, timeout)
+	row := q.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != sql.ErrNoRows {
+		span.RecordError(err)
+		logFailure(ctx, query, err)
+	}
+	return &Row{row: row, cancel: cancel}
+}
+
+func queryRows(ctx context.Context, q queryer, timeout time.Duration, query string, args []interface{}) (*Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	ctx, cancel := withTimeout(ctx, timeout)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		span.RecordError(err)
+		logFailure(ctx, query, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, cancel: cancel}, nil
+}
+
+func exec(ctx context.Context, q queryer, timeout time.Duration, query string, args []interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	result, err := q.ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	logFailure(ctx, query, err)
+	return result, err
+}
+
+// logFailure logs a failed statement at debug level through the logger of
+// ctx, so that it is listed with the request that ran it
+func logFailure(ctx context.Context, query string, err error) {
+	if err == nil {
+		return
+	}
+	logging.FromContext(ctx).Debug("Query failed", "db.operation", operationOf(query), "error", err)
+}
+
+// startQuery opens a client span named after the SQL operation, following
+// the OpenTelemetry database conventions
+func startQuery(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	statement := strings.Join(strings.Fields(query), " ")
+	operation := operationOf(statement)
+	return tracing.StartKind(ctx, operation, tracing.KindClient,
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.operation", operation),
+		tracing.String("db.statement", statement),
+	)
+}
+
+// operationOf returns the SQL keyword a statement starts with
+func operationOf(query string) string {
+	operation := strings.TrimSpace(query)
+	if i := strings.IndexFunc(operation, unicode.IsSpace); i > 0 {
+		operation = operation[:i]
+	}
+	return strings.ToUpper(operation)
+}
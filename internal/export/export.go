@@ -0,0 +1,120 @@
+// Package export writes rows as CSV, a JSON array or newline-delimited JSON
+// while they are being read, so exports of any size use constant memory.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"link-shortener/internal/apperror"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+var ErrUnknownFormat = apperror.Validation("unknown_export_format", "export format must be csv, json or ndjson")
+
+// ContentType returns the media type of format, or "" when it is unknown
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return ""
+}
+
+// Writer writes rows in one format. Rows are buffered; call Flush to send
+// what has been written so far and Close once every row is written.
+type Writer struct {
+	format string
+	out    *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+	header []string
+	rows   int
+}
+
+// NewWriter returns a writer for format. header names the CSV columns; JSON
+// rows are encoded as they are.
+func NewWriter(w io.Writer, format string, header []string) (*Writer, error) {
+	if ContentType(format) == "" {
+		return nil, ErrUnknownFormat
+	}
+
+	out := bufio.NewWriter(w)
+	writer := &Writer{format: format, out: out, header: header}
+	if format == FormatCSV {
+		writer.csv = csv.NewWriter(out)
+	} else {
+		writer.json = json.NewEncoder(out)
+		writer.json.SetEscapeHTML(false)
+	}
+	return writer, nil
+}
+
+// Write adds a row: v for the JSON formats, record for CSV
+func (w *Writer) Write(v interface{}, record []string) error {
+	w.rows++
+	switch w.format {
+	case FormatCSV:
+		if w.rows == 1 {
+			if err := w.csv.Write(w.header); err != nil {
+				return err
+			}
+		}
+		return w.csv.Write(record)
+	case FormatJSON:
+		separator := ",\n"
+		if w.rows == 1 {
+			separator = "[\n"
+		}
+		if _, err := w.out.WriteString(separator); err != nil {
+			return err
+		}
+	}
+	// Encode ends each value with a newline, which NDJSON needs anyway
+	return w.json.Encode(v)
+}
+
+// Rows is the number of rows written
+func (w *Writer) Rows() int {
+	return w.rows
+}
+
+// Flush sends the buffered rows to the underlying writer
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// Close finishes the output, writing the CSV header or the empty JSON array
+// when there were no rows, and flushes it
+func (w *Writer) Close() error {
+	var err error
+	switch {
+	case w.format == FormatCSV && w.rows == 0:
+		err = w.csv.Write(w.header)
+	case w.format == FormatJSON && w.rows == 0:
+		_, err = w.out.WriteString("[]\n")
+	case w.format == FormatJSON:
+		_, err = w.out.WriteString("]\n")
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+}
+
+func NewAdminHandler(adminService *services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// ListUsers lists and searches all users
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	users, err := h.adminService.ListUsers(c.Request.Context(), c.Query("q"), limit, offset)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	responses := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, toUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": responses,
+		"pagination": gin.H{
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// GetUser returns a single user
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": toUserResponse(user),
+	})
+}
+
+// DisableUser disables an account and signs it out everywhere
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.setUserDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled account
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.setUserDisabled(c, false)
+}
+
+func (h *AdminHandler) setUserDisabled(c *gin.Context, disabled bool) {
+	adminID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.SetUserDisabled(c.Request.Context(), adminID, userID, disabled); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	message := "User enabled successfully"
+	if disabled {
+		message = "User disabled successfully"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+// UpdateUserRole changes a user's role and extra permissions
+func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
+	adminID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.adminService.UpdateUserRole(c.Request.Context(), adminID, userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+		"data":    toUserResponse(user),
+	})
+}
+
+// ListLinks lists and searches links of all users
+func (h *AdminHandler) ListLinks(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	ownerID, ok := parseIDQuery(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	links, err := h.adminService.ListLinks(c.Request.Context(), c.Query("q"), ownerID, limit, offset)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": links,
+		"pagination": gin.H{
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// TakedownLink disables an abusive link
+func (h *AdminHandler) TakedownLink(c *gin.Context) {
+	linkID, ok := parseIDParam(c, "id", "Invalid link ID")
+	if !ok {
+		return
+	}
+
+	var req models.TakedownLinkRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	link, err := h.adminService.TakedownLink(c.Request.Context(), linkID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Link taken down successfully",
+		"data":    link,
+	})
+}
+
+// RestoreLink lifts a takedown
+func (h *AdminHandler) RestoreLink(c *gin.Context) {
+	linkID, ok := parseIDParam(c, "id", "Invalid link ID")
+	if !ok {
+		return
+	}
+
+	link, err := h.adminService.RestoreLink(c.Request.Context(), linkID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Link restored successfully",
+		"data":    link,
+	})
+}
+
+// GetStats returns system-wide statistics
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	stats, err := h.adminService.GetSystemStats(c.Request.Context())
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stats,
+	})
+}
+
+// parsePagination reads limit and offset query parameters with sane defaults
+func parsePagination(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"link-shortener/internal/middleware"
+	"link-shortener/internal/apperror"
 	"link-shortener/internal/models"
 	"link-shortener/internal/services"
 )
@@ -22,19 +22,13 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 // Register handles user registration
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request data",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	response, err := h.authService.Register(&req)
+	response, err := h.authService.Register(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		apperror.Render(c, err)
 		return
 	}
 
@@ -47,18 +41,20 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // Login handles user login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request data",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	response, err := h.authService.Login(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
+		apperror.Render(c, err)
+		return
+	}
+
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Two-factor authentication required",
+			"data":    response,
 		})
 		return
 	}
@@ -71,31 +67,103 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 // GetProfile returns current user profile
 func (h *AuthHandler) GetProfile(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
-	user, err := h.authService.GetUserByID(userID)
+	user, err := h.authService.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "User not found",
-		})
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": toUserResponse(user),
+	})
+}
+
+// toUserResponse returns user data without the password or secrets
+func toUserResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		TwoFactor:     user.TOTPEnabled,
+		DeletionAt:    user.DeletionAt,
+		Role:          user.Role,
+		Permissions:   user.EffectivePermissions(),
+		DisabledAt:    user.DisabledAt,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+// VerifyEmail confirms the user's email address using the emailed token
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerification sends a new verification email to the current user
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), userID); err != nil {
+		apperror.Render(c, err)
 		return
 	}
 
-	// Return user data without password
-	userResponse := models.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+	})
+}
+
+// ForgotPassword emails a password reset link
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	// Same response whether or not the account exists
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password using the emailed token
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), &req); err != nil {
+		apperror.Render(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": userResponse,
+		"message": "Password reset successfully",
 	})
 }
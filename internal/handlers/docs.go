@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docsPage renders /openapi.json with Swagger UI, loaded from a CDN
+//
+//go:embed static/docs.html
+var docsPage []byte
+
+type DocsHandler struct {
+	doc *openapi.Document
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewDocsHandler serves doc. The document is encoded on the first request,
+// after every route has been registered.
+func NewDocsHandler(doc *openapi.Document) *DocsHandler {
+	return &DocsHandler{doc: doc}
+}
+
+// OpenAPI serves the OpenAPI document
+func (h *DocsHandler) OpenAPI(c *gin.Context) {
+	h.once.Do(func() {
+		h.spec, h.err = json.Marshal(h.doc)
+	})
+	if h.err != nil {
+		apperror.Render(c, h.err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// UI serves the interactive API documentation
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
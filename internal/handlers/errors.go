@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/middleware"
+)
+
+var (
+	errNotAuthenticated  = apperror.Unauthorized("not_authenticated", "User not authenticated")
+	errProviderRejected  = apperror.Unauthorized("provider_rejected", "Identity provider rejected the login")
+	errShortCodeRequired = apperror.Validation("short_code_required", "Short code is required")
+	errInvalidID         = apperror.Validation("invalid_id", "Invalid ID")
+	errInvalidQueryParam = apperror.Validation("invalid_query", "Invalid query parameter")
+)
+
+// requireUserID returns the authenticated user. It writes the error response
+// itself and returns false when there is none.
+func requireUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		apperror.Render(c, errNotAuthenticated)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// parseIDParam reads a UUID path parameter
+func parseIDParam(c *gin.Context, name, message string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		invalid := *errInvalidID
+		invalid.Message = message
+		apperror.Render(c, invalid.WithField(name, "must be a UUID"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseIDQuery reads an optional UUID query parameter
+func parseIDQuery(c *gin.Context, name, message string) (*uuid.UUID, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		invalid := *errInvalidQueryParam
+		invalid.Message = message
+		apperror.Render(c, invalid.WithField(name, "must be a UUID"))
+		return nil, false
+	}
+	return &id, true
+}
+
+// parseTimeQuery reads an optional time query parameter, given in RFC 3339
+// or as a date
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t, true
+		}
+	}
+	apperror.Render(c, errInvalidQueryParam.WithField(name, "must be an RFC 3339 time or a date"))
+	return nil, false
+}
+
+// bindJSON decodes and validates the request body, reporting each invalid field
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		apperror.Render(c, apperror.FromBinding(err))
+		return false
+	}
+	return true
+}
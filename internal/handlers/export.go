@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/export"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// exportFlushRows is how many rows are sent to the client at a time
+const exportFlushRows = 1000
+
+var (
+	linkExportHeader  = []string{"id", "short_code", "short_url", "original_url", "title", "tags", "clicks", "is_active", "expires_at", "workspace_id", "created_at", "updated_at"}
+	clickExportHeader = []string{"id", "link_id", "short_code", "clicked_at", "referrer", "user_agent"}
+)
+
+// ExportLinks handles streaming an export of links
+func (h *LinkHandler) ExportLinks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := exportFilter(c)
+	if !ok {
+		return
+	}
+
+	setWriteTimeout(c, h.linkService.ExportTimeout())
+	streamExport(c, "links", linkExportHeader, func(write func(interface{}, []string) error) error {
+		return h.linkService.ExportLinks(c.Request.Context(), userID, filter, func(link *models.LinkResponse) error {
+			return write(link, []string{
+				link.ID.String(), link.ShortCode, link.ShortURL, link.OriginalURL, link.Title,
+				strings.Join(link.Tags, ","), strconv.Itoa(link.Clicks), strconv.FormatBool(link.IsActive),
+				formatTime(link.ExpiresAt), formatID(link.WorkspaceID),
+				formatTime(&link.CreatedAt), formatTime(&link.UpdatedAt),
+			})
+		})
+	})
+}
+
+// ExportClicks handles streaming an export of click events
+func (h *LinkHandler) ExportClicks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := exportFilter(c)
+	if !ok {
+		return
+	}
+	if filter.LinkID, ok = parseIDQuery(c, "link_id", "Invalid link ID"); !ok {
+		return
+	}
+
+	setWriteTimeout(c, h.linkService.ExportTimeout())
+	streamExport(c, "clicks", clickExportHeader, func(write func(interface{}, []string) error) error {
+		return h.linkService.ExportClicks(c.Request.Context(), userID, filter, func(click *models.ClickEvent) error {
+			return write(click, []string{
+				strconv.FormatInt(click.ID, 10), click.LinkID.String(), click.ShortCode,
+				formatTime(&click.ClickedAt), click.Referrer, click.UserAgent,
+			})
+		})
+	})
+}
+
+func exportFilter(c *gin.Context) (*models.ExportFilter, bool) {
+	var filter models.ExportFilter
+	var ok bool
+	if filter.WorkspaceID, ok = parseIDQuery(c, "workspace_id", "Invalid workspace ID"); !ok {
+		return nil, false
+	}
+	if filter.From, ok = parseTimeQuery(c, "from"); !ok {
+		return nil, false
+	}
+	if filter.To, ok = parseTimeQuery(c, "to"); !ok {
+		return nil, false
+	}
+	return &filter, true
+}
+
+// streamExport writes the rows run produces as they arrive. The response
+// starts with the first row, so errors before it are rendered as usual;
+// later ones can only cut the download short, which is logged.
+func streamExport(c *gin.Context, name string, header []string, run func(write func(interface{}, []string) error) error) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	writer, err := export.NewWriter(c.Writer, format, header)
+	if err != nil {
+		apperror.Render(c, export.ErrUnknownFormat.WithField("format", "must be csv, json or ndjson"))
+		return
+	}
+
+	started := false
+	start := func() {
+		started = true
+		c.Header("Content-Type", export.ContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().UTC().Format("20060102"), format))
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+	}
+
+	err = run(func(v interface{}, record []string) error {
+		if !started {
+			start()
+		}
+		if err := writer.Write(v, record); err != nil {
+			return err
+		}
+		if writer.Rows()%exportFlushRows == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		apperror.Render(c, err)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		logging.FromContext(c.Request.Context()).Warn("Export cut short", "export", name, "rows", writer.Rows(), "error", err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+	if err := writer.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// setWriteTimeout replaces the server's write timeout for a response that
+// takes longer, such as an export or a live stream; zero removes it
+func setWriteTimeout(c *gin.Context, timeout time.Duration) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	// Writers that cannot set deadlines, such as test recorders, have none
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(deadline)
+}
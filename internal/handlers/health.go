@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Health is kept for existing monitors; it only reports that the process is up
+func (h *HealthHandler) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Link Shortener API is running",
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
+
+// Livez reports that the process is serving requests. It checks no
+// dependencies, so a database outage does not get the instance restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// Readyz reports whether the instance should receive traffic, with the
+// result of each dependency check
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	readiness := h.healthService.Readiness(c.Request.Context())
+
+	status := http.StatusOK
+	if readiness.Status != models.ReadinessReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/importer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes bounds the size of an uploaded import file
+const maxImportBytes = 32 << 20
+
+type ImportHandler struct {
+	importService *services.ImportService
+}
+
+func NewImportHandler(importService *services.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+// ImportLinks handles link imports. The file is the request body or the file
+// field of a multipart form. A dry run answers with the report; otherwise the
+// import runs in the background and the job is returned for polling.
+func (h *ImportHandler) ImportLinks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := parseIDQuery(c, "workspace_id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		apperror.Render(c, errInvalidQueryParam.WithField("dry_run", "must be true or false"))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	file, format, err := importFile(c)
+	if err != nil {
+		apperror.Render(c, importError(err))
+		return
+	}
+	defer file.Close()
+
+	records, err := h.importService.Parse(file, format)
+	if err != nil {
+		apperror.Render(c, importError(err))
+		return
+	}
+
+	opts := &models.ImportOptions{
+		Format:         format,
+		ConflictPolicy: c.Query("conflict_policy"),
+		DryRun:         dryRun,
+		WorkspaceID:    workspaceID,
+	}
+	if dryRun {
+		report, err := h.importService.Run(c.Request.Context(), userID, opts, records, nil)
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("%d of %d links would be imported", report.Created, report.Total),
+			"data":    report,
+		})
+		return
+	}
+
+	job, err := h.importService.Start(c.Request.Context(), userID, opts, records)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+	c.Header("Location", c.Request.URL.Path+"/"+job.ID.String())
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Import started",
+		"data":    job,
+	})
+}
+
+// GetImport handles polling an import
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	jobID, ok := parseIDParam(c, "id", "Invalid import ID")
+	if !ok {
+		return
+	}
+
+	job, err := h.importService.GetJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": job,
+	})
+}
+
+// importFile returns the uploaded file and its format, taken from the format
+// query parameter or else guessed from the content type or file name
+func importFile(c *gin.Context) (io.ReadCloser, string, error) {
+	format := c.Query("format")
+	if c.ContentType() != "multipart/form-data" {
+		if format == "" {
+			format = importer.DetectFormat(c.ContentType(), "")
+		}
+		return c.Request.Body, format, checkFormat(format)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, "", importer.ErrInvalidFile.WithField("file", "is required")
+		}
+		return nil, "", importer.ErrInvalidFile.WithField("file", err.Error()).Wrap(err)
+	}
+	if format == "" {
+		format = importer.DetectFormat(header.Header.Get("Content-Type"), header.Filename)
+	}
+	if err := checkFormat(format); err != nil {
+		return nil, "", err
+	}
+
+	var file multipart.File
+	if file, err = header.Open(); err != nil {
+		return nil, "", fmt.Errorf("failed to open upload: %w", err)
+	}
+	return file, format, nil
+}
+
+// importError reports files over maxImportBytes as such
+func importError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return importer.ErrInvalidFile.WithField("file", fmt.Sprintf("must be at most %d MB", maxImportBytes>>20))
+	}
+	return err
+}
+
+func checkFormat(format string) error {
+	if format != importer.FormatCSV && format != importer.FormatJSON {
+		return importer.ErrUnknownFormat.WithField("format", "must be csv or json")
+	}
+	return nil
+}
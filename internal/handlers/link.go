@@ -1,15 +1,15 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
-	"link-shortener/internal/middleware"
+	"link-shortener/internal/apperror"
 	"link-shortener/internal/models"
 	"link-shortener/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type LinkHandler struct {
@@ -24,28 +24,19 @@ func NewLinkHandler(linkService *services.LinkService) *LinkHandler {
 
 // CreateLink handles link creation
 func (h *LinkHandler) CreateLink(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
 	var req models.CreateLinkRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request data",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	response, err := h.linkService.CreateLink(userID, &req)
+	response, err := h.linkService.CreateLink(c.Request.Context(), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		apperror.Render(c, err)
 		return
 	}
 
@@ -55,13 +46,77 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 	})
 }
 
+// CreateLinks handles bulk link creation. Partial success is reported as
+// 200 with the failed items marked.
+func (h *LinkHandler) CreateLinks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.BulkCreateLinksRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.linkService.CreateLinks(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.Failed > 0 {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{
+		"message": fmt.Sprintf("%d links created, %d failed", result.Succeeded, result.Failed),
+		"data":    result,
+	})
+}
+
+// ActivateLinks handles bulk activation
+func (h *LinkHandler) ActivateLinks(c *gin.Context) {
+	h.applyLinkAction(c, models.BulkActionActivate)
+}
+
+// DeactivateLinks handles bulk deactivation
+func (h *LinkHandler) DeactivateLinks(c *gin.Context) {
+	h.applyLinkAction(c, models.BulkActionDeactivate)
+}
+
+// DeleteLinks handles bulk deletion
+func (h *LinkHandler) DeleteLinks(c *gin.Context) {
+	h.applyLinkAction(c, models.BulkActionDelete)
+}
+
+func (h *LinkHandler) applyLinkAction(c *gin.Context, action string) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.BulkLinkActionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.linkService.ApplyLinkAction(c.Request.Context(), userID, action, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%d links updated, %d failed", result.Succeeded, result.Failed),
+		"data":    result,
+	})
+}
+
 // GetLinks handles getting user's links with pagination
 func (h *LinkHandler) GetLinks(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
@@ -79,14 +134,26 @@ func (h *LinkHandler) GetLinks(c *gin.Context) {
 		offset = 0
 	}
 
-	links, err := h.linkService.GetLinksByUserID(userID, limit, offset)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get links",
-		})
+	workspaceID, ok := parseIDQuery(c, "workspace_id", "Invalid workspace ID")
+	if !ok {
 		return
 	}
 
+	var links []*models.LinkResponse
+	if workspaceID != nil {
+		links, err = h.linkService.GetLinksByWorkspaceID(c.Request.Context(), userID, *workspaceID, limit, offset)
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+	} else {
+		links, err = h.linkService.GetLinksByUserID(c.Request.Context(), userID, limit, offset)
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": links,
 		"pagination": gin.H{
@@ -98,28 +165,19 @@ func (h *LinkHandler) GetLinks(c *gin.Context) {
 
 // GetLink handles getting a specific link
 func (h *LinkHandler) GetLink(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
-	linkIDStr := c.Param("id")
-	linkID, err := uuid.Parse(linkIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid link ID",
-		})
+	linkID, ok := parseIDParam(c, "id", "Invalid link ID")
+	if !ok {
 		return
 	}
 
-	link, err := h.linkService.GetLinkByID(userID, linkID)
+	link, err := h.linkService.GetLinkByID(c.Request.Context(), userID, linkID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
+		apperror.Render(c, err)
 		return
 	}
 
@@ -130,37 +188,24 @@ func (h *LinkHandler) GetLink(c *gin.Context) {
 
 // UpdateLink handles link updates
 func (h *LinkHandler) UpdateLink(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
-	linkIDStr := c.Param("id")
-	linkID, err := uuid.Parse(linkIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid link ID",
-		})
+	linkID, ok := parseIDParam(c, "id", "Invalid link ID")
+	if !ok {
 		return
 	}
 
 	var req models.UpdateLinkRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request data",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	link, err := h.linkService.UpdateLink(userID, linkID, &req)
+	link, err := h.linkService.UpdateLink(c.Request.Context(), userID, linkID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		apperror.Render(c, err)
 		return
 	}
 
@@ -172,27 +217,18 @@ func (h *LinkHandler) UpdateLink(c *gin.Context) {
 
 // DeleteLink handles link deletion
 func (h *LinkHandler) DeleteLink(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
-	linkIDStr := c.Param("id")
-	linkID, err := uuid.Parse(linkIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid link ID",
-		})
+	linkID, ok := parseIDParam(c, "id", "Invalid link ID")
+	if !ok {
 		return
 	}
 
-	if err := h.linkService.DeleteLink(userID, linkID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	if err := h.linkService.DeleteLink(c.Request.Context(), userID, linkID); err != nil {
+		apperror.Render(c, err)
 		return
 	}
 
@@ -205,17 +241,16 @@ func (h *LinkHandler) DeleteLink(c *gin.Context) {
 func (h *LinkHandler) Redirect(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 	if shortCode == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Short code is required",
-		})
+		apperror.Render(c, errShortCodeRequired)
 		return
 	}
 
-	originalURL, err := h.linkService.RedirectToOriginal(shortCode)
+	originalURL, err := h.linkService.RedirectToOriginal(c.Request.Context(), shortCode, models.ClickEvent{
+		Referrer:  c.Request.Referer(),
+		UserAgent: c.Request.UserAgent(),
+	})
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Link not found or expired",
-		})
+		apperror.Render(c, err)
 		return
 	}
 
@@ -224,22 +259,32 @@ func (h *LinkHandler) Redirect(c *gin.Context) {
 
 // GetStats handles getting user's link statistics
 func (h *LinkHandler) GetStats(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-		})
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
-	stats, err := h.linkService.GetStats(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get statistics",
-		})
+	workspaceID, ok := parseIDQuery(c, "workspace_id", "Invalid workspace ID")
+	if !ok {
 		return
 	}
 
+	var stats *models.LinkStats
+	var err error
+	if workspaceID != nil {
+		stats, err = h.linkService.GetWorkspaceStats(c.Request.Context(), userID, *workspaceID)
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+	} else {
+		stats, err = h.linkService.GetStats(c.Request.Context(), userID)
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": stats,
 	})
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/services"
+)
+
+// liveRetry is the reconnection delay suggested to clients, in milliseconds
+const liveRetry = 3000
+
+// LiveHandler streams clicks as Server-Sent Events. Streams outlive the
+// authentication of the request that opened them, so auth checks the token
+// and session again at every heartbeat.
+type LiveHandler struct {
+	linkService *services.LinkService
+	auth        *middleware.AuthMiddleware
+	heartbeat   time.Duration
+}
+
+func NewLiveHandler(linkService *services.LinkService, auth *middleware.AuthMiddleware, heartbeat time.Duration) *LiveHandler {
+	return &LiveHandler{linkService: linkService, auth: auth, heartbeat: heartbeat}
+}
+
+// WatchLink streams the clicks on one link
+func (h *LiveHandler) WatchLink(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	linkID, ok := parseIDParam(c, "id", "Invalid link ID")
+	if !ok {
+		return
+	}
+
+	stream, err := h.linkService.WatchLink(c.Request.Context(), userID, linkID, lastEventID(c))
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+	h.stream(c, stream)
+}
+
+// WatchLinks streams the clicks on the user's personal links, or on a
+// workspace's links with workspace_id
+func (h *LiveHandler) WatchLinks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	workspaceID, ok := parseIDQuery(c, "workspace_id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	stream, err := h.linkService.WatchLinks(c.Request.Context(), userID, workspaceID, lastEventID(c))
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+	h.stream(c, stream)
+}
+
+// stream writes each click as a "click" event and a comment line every
+// heartbeat, so proxies keep the connection open, until the client goes away
+// or the subscription ends. A subscription ends when the client falls behind
+// or the server shuts down; clients reconnect with Last-Event-ID. The stream
+// also ends at a heartbeat once the user may no longer watch it.
+func (h *LiveHandler) stream(c *gin.Context, sub *services.LiveStream) {
+	defer sub.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", liveRetry)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		// A client that stops reading is dropped after missing a heartbeat
+		setWriteTimeout(c, 2*h.heartbeat)
+
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			var data []byte
+			if data, err = json.Marshal(event.Click); err != nil {
+				logging.FromContext(c.Request.Context()).Error("Failed to encode click", "error", err)
+				return
+			}
+			_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: click\ndata: %s\n\n", event.ID, data)
+		case <-heartbeat.C:
+			if err = h.authorize(c, sub); err != nil {
+				return
+			}
+			_, err = io.WriteString(c.Writer, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// authorize checks that the session that opened a stream is still valid and
+// still grants access to it
+func (h *LiveHandler) authorize(c *gin.Context, sub *services.LiveStream) error {
+	ctx := c.Request.Context()
+	_, err := h.auth.Authenticate(ctx, c.GetHeader("Authorization"))
+	if err == nil {
+		err = sub.Authorize(ctx)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Info("Live stream no longer authorized", "error", err)
+	}
+	return err
+}
+
+// lastEventID is where a reconnecting client resumes. Browsers send the
+// Last-Event-ID header; the query parameter is for clients that cannot.
+func lastEventID(c *gin.Context) string {
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query("last_event_id")
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
+)
+
+// UpdateProfile updates the current user's profile
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.authService.UpdateProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated successfully",
+		"data":    toUserResponse(user),
+	})
+}
+
+// ChangePassword changes the password and signs out every other session
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.ChangePassword(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+		"data":    response,
+	})
+}
+
+// ChangeEmail sends a confirmation link to the new email address
+func (h *AuthHandler) ChangeEmail(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.RequestEmailChange(c.Request.Context(), userID, &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Confirmation email sent to the new address",
+	})
+}
+
+// ConfirmEmailChange applies an email change using the emailed token
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	var req models.ConfirmEmailChangeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.ConfirmEmailChange(c.Request.Context(), &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email changed successfully",
+	})
+}
+
+// DeleteAccount schedules the current account for deletion
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	deletionAt, err := h.authService.ScheduleAccountDeletion(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Account scheduled for deletion",
+		"data": gin.H{
+			"deletion_scheduled_at": deletionAt,
+		},
+	})
+}
+
+// CancelAccountDeletion keeps an account that is pending deletion
+func (h *AuthHandler) CancelAccountDeletion(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.CancelAccountDeletion(c.Request.Context(), userID); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account deletion cancelled",
+	})
+}
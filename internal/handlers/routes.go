@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"net/http"
+
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/export"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/openapi"
+	"link-shortener/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewOpenAPIDocument returns the document the routes below are recorded in
+func NewOpenAPIDocument() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Link Shortener API",
+		Description: "Shorten URLs, manage links and workspaces, and administer users. Errors use the envelope in the Error response. The unversioned /api routes are a deprecated alias of /api/v1.",
+		Version:     "1.0.0",
+	}, apperror.Response{})
+	doc.Tags = []openapi.Tag{
+		{Name: "health", Description: "Liveness and readiness probes"},
+		{Name: "auth", Description: "Accounts, sessions and profile"},
+		{Name: "two-factor", Description: "TOTP two-factor authentication"},
+		{Name: "links", Description: "Short links owned by the caller or a workspace"},
+		{Name: "workspaces", Description: "Shared workspaces, members and invitations"},
+		{Name: "webhooks", Description: "Signed event notifications about your links"},
+		{Name: "admin", Description: "User and link administration"},
+		{Name: "redirect", Description: "Public short link redirects"},
+	}
+	return doc
+}
+
+// API is everything the /api routes are served by
+type API struct {
+	Auth       *AuthHandler
+	SSO        *SSOHandler // nil when single sign-on is disabled
+	Links      *LinkHandler
+	Imports    *ImportHandler
+	Workspaces *WorkspaceHandler
+	Webhooks   *WebhookHandler
+	Live       *LiveHandler
+	Admin      *AdminHandler
+	Middleware *middleware.AuthMiddleware
+	// VerifiedEmail, when set, runs before link creation
+	VerifiedEmail gin.HandlerFunc
+	// Idempotency, when set, lets link creation be retried with an
+	// Idempotency-Key
+	Idempotency gin.HandlerFunc
+}
+
+// Register mounts the API routes on r
+func (a *API) Register(r *openapi.Router) {
+	a.registerAuth(r.Group("/auth"))
+	a.registerLinks(r.Group("/links").Secure(a.Middleware.AuthRequired()))
+	a.registerWorkspaces(r.Group("/workspaces").Secure(a.Middleware.AuthRequired()))
+	a.registerWebhooks(r.Group("/webhooks").Secure(a.Middleware.AuthRequired()))
+	a.registerAdmin(r.Group("/admin").Secure(a.Middleware.AuthRequired()))
+}
+
+func (a *API) registerAuth(auth *openapi.Router) {
+	required := a.Middleware.AuthRequired()
+
+	auth.POST("/register", openapi.Op("Register an account", "auth").
+		Body(models.RegisterRequest{}).
+		Returns(http.StatusCreated, "Account created", withMessage(models.AuthResponse{})),
+		a.Auth.Register)
+	auth.POST("/login", openapi.Op("Log in", "auth").
+		Describe("When two-factor authentication is enabled the response carries two_factor_required and a challenge_token instead of an access token.").
+		Body(models.LoginRequest{}).
+		Returns(http.StatusOK, "Logged in, or a two-factor challenge", withMessage(models.AuthResponse{})),
+		a.Auth.Login)
+	auth.POST("/login/2fa", openapi.Op("Complete a two-factor login", "auth", "two-factor").
+		Body(models.TwoFactorLoginRequest{}).
+		Returns(http.StatusOK, "Logged in", withMessage(models.AuthResponse{})),
+		a.Auth.LoginTwoFactor)
+	auth.GET("/profile", openapi.Op("Get the profile", "auth").Secured().
+		Returns(http.StatusOK, "Profile", withData(models.UserResponse{})),
+		required, a.Auth.GetProfile)
+	auth.PUT("/profile", openapi.Op("Update the profile", "auth").Secured().
+		Body(models.UpdateProfileRequest{}).
+		Returns(http.StatusOK, "Profile updated", withMessage(models.UserResponse{})),
+		required, a.Auth.UpdateProfile)
+	auth.POST("/change-password", openapi.Op("Change the password", "auth").Secured().
+		Describe("Revokes every other session and returns a fresh access token.").
+		Body(models.ChangePasswordRequest{}).
+		Returns(http.StatusOK, "Password changed", withMessage(models.AuthResponse{})),
+		required, a.Auth.ChangePassword)
+	auth.POST("/change-email", openapi.Op("Request an email change", "auth").Secured().
+		Body(models.ChangeEmailRequest{}).
+		Returns(http.StatusAccepted, "Confirmation sent to the new address", withMessage(nil)),
+		required, a.Auth.ChangeEmail)
+	auth.POST("/confirm-email-change", openapi.Op("Confirm an email change", "auth").
+		Body(models.ConfirmEmailChangeRequest{}).
+		Returns(http.StatusOK, "Email changed", withMessage(nil)),
+		a.Auth.ConfirmEmailChange)
+	auth.DELETE("/account", openapi.Op("Schedule account deletion", "auth").Secured().
+		Body(models.DeleteAccountRequest{}).
+		Returns(http.StatusAccepted, "Deletion scheduled", withMessage(openapi.Object(map[string]*openapi.Schema{
+			"deletion_scheduled_at": {Type: "string", Format: "date-time"},
+		}, "deletion_scheduled_at"))),
+		required, a.Auth.DeleteAccount)
+	auth.POST("/account/cancel-deletion", openapi.Op("Cancel account deletion", "auth").Secured().
+		Returns(http.StatusOK, "Deletion cancelled", withMessage(nil)),
+		required, a.Auth.CancelAccountDeletion)
+	auth.POST("/verify", openapi.Op("Verify the email address", "auth").
+		Body(models.VerifyEmailRequest{}).
+		Returns(http.StatusOK, "Email verified", withMessage(nil)),
+		a.Auth.VerifyEmail)
+	auth.POST("/resend-verification", openapi.Op("Resend the verification email", "auth").Secured().
+		Returns(http.StatusOK, "Verification email sent", withMessage(nil)),
+		required, a.Auth.ResendVerification)
+	auth.POST("/forgot-password", openapi.Op("Request a password reset", "auth").
+		Describe("Responds the same way whether or not the email is registered.").
+		Body(models.ForgotPasswordRequest{}).
+		Returns(http.StatusOK, "Reset email sent if the account exists", withMessage(nil)),
+		a.Auth.ForgotPassword)
+	auth.POST("/reset-password", openapi.Op("Reset the password", "auth").
+		Body(models.ResetPasswordRequest{}).
+		Returns(http.StatusOK, "Password reset", withMessage(nil)),
+		a.Auth.ResetPassword)
+
+	if a.SSO != nil {
+		auth.GET("/oidc/login", openapi.Op("Start a single sign-on login", "auth").
+			Redirects(http.StatusFound, "Redirect to the identity provider"),
+			a.SSO.Login)
+		auth.GET("/oidc/callback", openapi.Op("Complete a single sign-on login", "auth").
+			Query("state", openapi.String(), "State returned by the identity provider").
+			Query("code", openapi.String(), "Authorization code").
+			Query("error", openapi.String(), "Error reported by the identity provider").
+			Query("error_description", openapi.String(), "Description of the error").
+			Returns(http.StatusOK, "Logged in, or a two-factor challenge", withMessage(models.AuthResponse{})),
+			a.SSO.Callback)
+	}
+
+	twoFactor := auth.Group("/2fa").Secure(required)
+	twoFactor.POST("/setup", openapi.Op("Start two-factor setup", "two-factor").
+		Returns(http.StatusOK, "Secret to add to an authenticator", withMessage(models.TwoFactorSetupResponse{})),
+		a.Auth.SetupTwoFactor)
+	twoFactor.POST("/confirm", openapi.Op("Enable two-factor authentication", "two-factor").
+		Body(models.TwoFactorConfirmRequest{}).
+		Returns(http.StatusOK, "Enabled; recovery codes are shown once", withMessage(models.TwoFactorConfirmResponse{})),
+		a.Auth.ConfirmTwoFactor)
+	twoFactor.POST("/disable", openapi.Op("Disable two-factor authentication", "two-factor").
+		Body(models.TwoFactorReauthRequest{}).
+		Returns(http.StatusOK, "Disabled", withMessage(nil)),
+		a.Auth.DisableTwoFactor)
+	twoFactor.POST("/recovery-codes", openapi.Op("Regenerate recovery codes", "two-factor").
+		Body(models.TwoFactorReauthRequest{}).
+		Returns(http.StatusOK, "New recovery codes", withMessage(models.TwoFactorConfirmResponse{})),
+		a.Auth.RegenerateRecoveryCodes)
+}
+
+const bulkDescription = "In atomic mode, the default, either every item succeeds or nothing changes and the error lists the failed items; " +
+	"in partial mode the items that can succeed do and the rest are reported in the results."
+
+func (a *API) registerLinks(links *openapi.Router) {
+	createLink := []gin.HandlerFunc{a.Links.CreateLink}
+	bulkCreate := []gin.HandlerFunc{a.Links.CreateLinks}
+	importLinks := []gin.HandlerFunc{a.Imports.ImportLinks}
+	if a.Idempotency != nil {
+		createLink = append([]gin.HandlerFunc{a.Idempotency}, createLink...)
+	}
+	if a.VerifiedEmail != nil {
+		createLink = append([]gin.HandlerFunc{a.VerifiedEmail}, createLink...)
+		bulkCreate = append([]gin.HandlerFunc{a.VerifiedEmail}, bulkCreate...)
+		importLinks = append([]gin.HandlerFunc{a.VerifiedEmail}, importLinks...)
+	}
+
+	links.POST("/", openapi.Op("Create a link", "links").
+		Describe("Send an Idempotency-Key to retry safely: a repeat with the same key and body is answered with the first response and an Idempotent-Replayed header, "+
+			"a different body is a 409 idempotency_key_reused and a repeat while the first request runs is a 409 idempotency_request_in_progress.").
+		Header(middleware.IdempotencyKeyHeader, openapi.String(), "Up to 255 printable characters, such as a UUID, naming this request across retries").
+		Body(models.CreateLinkRequest{}).
+		Returns(http.StatusCreated, "Link created", withMessage(models.LinkResponse{})),
+		createLink...)
+	links.POST("/bulk", openapi.Op("Create many links", "links").
+		Describe(bulkDescription+" Responds 201 when every link was created and 200 when some failed.").
+		Body(models.BulkCreateLinksRequest{}).
+		Returns(http.StatusCreated, "Links created", withMessage(models.BulkResult{})).
+		Returns(http.StatusOK, "Some links failed", withMessage(models.BulkResult{})),
+		bulkCreate...)
+	for _, action := range []struct {
+		name, summary string
+		handler       gin.HandlerFunc
+	}{
+		{models.BulkActionActivate, "Activate many links", a.Links.ActivateLinks},
+		{models.BulkActionDeactivate, "Deactivate many links", a.Links.DeactivateLinks},
+		{models.BulkActionDelete, "Delete many links", a.Links.DeleteLinks},
+	} {
+		links.POST("/bulk/"+action.name, openapi.Op(action.summary, "links").
+			Describe(bulkDescription+" Name the links either by ids or by a filter over your personal links or the links of one workspace.").
+			Body(models.BulkLinkActionRequest{}).
+			Returns(http.StatusOK, "Per-link results", withMessage(models.BulkResult{})),
+			action.handler)
+	}
+	links.POST("/import", openapi.Op("Import links", "links").
+		Describe("Imports a CSV or JSON export of another shortener, keeping its short codes. "+
+			"Send the file as the body or as the file field of a multipart form. "+
+			"A dry run validates every row and responds with the report; otherwise the import runs in the background and responds 202 with a job to poll.").
+		Accepts("text/csv", &openapi.Schema{Type: "string", Description: "A header row naming original_url and optionally short_code, title, expires_at and tags"}).
+		Accepts("application/json", openapi.ArrayOf(importRowSchema())).
+		Accepts("multipart/form-data", openapi.Object(map[string]*openapi.Schema{
+			"file": {Type: "string", Format: "binary"},
+		}, "file")).
+		Query("format", &openapi.Schema{Type: "string", Enum: []string{"csv", "json"}}, "File format; guessed from the content type or file name when omitted").
+		Query("conflict_policy", &openapi.Schema{Type: "string", Enum: []string{models.ConflictSkip, models.ConflictRename, models.ConflictFail}},
+			"What to do with codes that are already taken (default fail, which imports nothing if any is)").
+		Query("dry_run", openapi.Boolean(), "Only validate the file").
+		Query("workspace_id", uuidSchema(), "Import into this workspace instead of your own links").
+		Returns(http.StatusOK, "Dry-run report", withMessage(models.ImportReport{})).
+		Returns(http.StatusAccepted, "Import started", withMessage(models.ImportJob{})),
+		importLinks...)
+	links.GET("/import/:id", openapi.Op("Get an import", "links").
+		Describe("Poll until status is succeeded or failed; processed counts the rows handled so far.").
+		Returns(http.StatusOK, "Import job", withData(models.ImportJob{})),
+		a.Imports.GetImport)
+	links.GET("/export", exported(openapi.Op("Export links", "links"), models.LinkResponse{}).
+		Describe(exportDescription+" Links are filtered by creation time."),
+		a.Links.ExportLinks)
+	links.GET("/export/clicks", exported(openapi.Op("Export click events", "links"), models.ClickEvent{}).
+		Describe(exportDescription+" Clicks are filtered by click time.").
+		Query("link_id", uuidSchema(), "Only clicks on this link"),
+		a.Links.ExportClicks)
+	links.GET("/", paginated(openapi.Op("List links", "links")).
+		Query("workspace_id", uuidSchema(), "List the links of this workspace instead of your own").
+		Returns(http.StatusOK, "Links", page(models.LinkResponse{})),
+		a.Links.GetLinks)
+	links.GET("/stats", openapi.Op("Get link statistics", "links").
+		Query("workspace_id", uuidSchema(), "Count the links of this workspace instead of your own").
+		Returns(http.StatusOK, "Statistics", withData(models.LinkStats{})),
+		a.Links.GetStats)
+	links.GET("/live", streamed(openapi.Op("Stream clicks on your links", "links")).
+		Query("workspace_id", uuidSchema(), "Stream the clicks on this workspace's links instead of your own"),
+		a.Live.WatchLinks)
+	links.GET("/:id/live", streamed(openapi.Op("Stream clicks on a link", "links")),
+		a.Live.WatchLink)
+	links.GET("/:id", openapi.Op("Get a link", "links").
+		Returns(http.StatusOK, "Link", withData(models.LinkResponse{})),
+		a.Links.GetLink)
+	links.PUT("/:id", openapi.Op("Update a link", "links").
+		Body(models.UpdateLinkRequest{}).
+		Returns(http.StatusOK, "Link updated", withMessage(models.LinkResponse{})),
+		a.Links.UpdateLink)
+	links.DELETE("/:id", openapi.Op("Delete a link", "links").
+		Returns(http.StatusOK, "Link deleted", withMessage(nil)),
+		a.Links.DeleteLink)
+}
+
+func (a *API) registerWorkspaces(workspaces *openapi.Router) {
+	h := a.Workspaces
+
+	workspaces.POST("/", openapi.Op("Create a workspace", "workspaces").
+		Body(models.CreateWorkspaceRequest{}).
+		Returns(http.StatusCreated, "Workspace created", withMessage(models.WorkspaceMembership{})),
+		h.CreateWorkspace)
+	workspaces.GET("/", openapi.Op("List your workspaces", "workspaces").
+		Returns(http.StatusOK, "Workspaces with your role", withData([]models.WorkspaceMembership{})),
+		h.ListWorkspaces)
+	workspaces.POST("/invitations/accept", openapi.Op("Accept an invitation", "workspaces").
+		Body(models.AcceptInvitationRequest{}).
+		Returns(http.StatusOK, "Joined the workspace", withMessage(models.WorkspaceMembership{})),
+		h.AcceptInvitation)
+	workspaces.GET("/:id", openapi.Op("Get a workspace", "workspaces").
+		Returns(http.StatusOK, "Workspace", withData(models.WorkspaceMembership{})),
+		h.GetWorkspace)
+	workspaces.PUT("/:id", openapi.Op("Rename a workspace", "workspaces").
+		Body(models.UpdateWorkspaceRequest{}).
+		Returns(http.StatusOK, "Workspace updated", withMessage(models.WorkspaceMembership{})),
+		h.UpdateWorkspace)
+	workspaces.DELETE("/:id", openapi.Op("Delete a workspace", "workspaces").
+		Returns(http.StatusOK, "Workspace deleted", withMessage(nil)),
+		h.DeleteWorkspace)
+	workspaces.GET("/:id/members", openapi.Op("List members", "workspaces").
+		Returns(http.StatusOK, "Members", withData([]models.WorkspaceMember{})),
+		h.ListMembers)
+	workspaces.PUT("/:id/members/:userId", openapi.Op("Change a member's role", "workspaces").
+		Body(models.UpdateMemberRoleRequest{}).
+		Returns(http.StatusOK, "Role updated", withMessage(nil)),
+		h.UpdateMemberRole)
+	workspaces.DELETE("/:id/members/:userId", openapi.Op("Remove a member", "workspaces").
+		OptionalBody(models.RemoveMemberRequest{}).
+		Returns(http.StatusOK, "Member removed", withMessage(nil)),
+		h.RemoveMember)
+	workspaces.POST("/:id/leave", openapi.Op("Leave a workspace", "workspaces").
+		OptionalBody(models.LeaveWorkspaceRequest{}).
+		Returns(http.StatusOK, "Left the workspace", withMessage(nil)),
+		h.LeaveWorkspace)
+	workspaces.POST("/:id/transfer", openapi.Op("Transfer ownership", "workspaces").
+		Body(models.TransferOwnershipRequest{}).
+		Returns(http.StatusOK, "Ownership transferred", withMessage(nil)),
+		h.TransferOwnership)
+	workspaces.POST("/:id/invitations", openapi.Op("Invite a member", "workspaces").
+		Body(models.InviteMemberRequest{}).
+		Returns(http.StatusCreated, "Invitation sent", withMessage(models.WorkspaceInvitation{})),
+		h.InviteMember)
+	workspaces.GET("/:id/invitations", openapi.Op("List pending invitations", "workspaces").
+		Returns(http.StatusOK, "Invitations", withData([]models.WorkspaceInvitation{})),
+		h.ListInvitations)
+	workspaces.DELETE("/:id/invitations/:invitationId", openapi.Op("Revoke an invitation", "workspaces").
+		Returns(http.StatusOK, "Invitation revoked", withMessage(nil)),
+		h.RevokeInvitation)
+}
+
+func (a *API) registerWebhooks(webhooks *openapi.Router) {
+	h := a.Webhooks
+
+	webhooks.POST("/", openapi.Op("Register a webhook", "webhooks").
+		Describe("The endpoint is sent the chosen events about links you created. The response carries the signing secret, which is not shown again.").
+		Body(models.CreateWebhookRequest{}).
+		Returns(http.StatusCreated, "Webhook created", withMessage(models.CreatedWebhook{})),
+		h.CreateWebhook)
+	webhooks.GET("/", openapi.Op("List your webhooks", "webhooks").
+		Returns(http.StatusOK, "Webhooks", withData([]models.Webhook{})),
+		h.ListWebhooks)
+	webhooks.GET("/:id", openapi.Op("Get a webhook", "webhooks").
+		Returns(http.StatusOK, "Webhook", withData(models.Webhook{})),
+		h.GetWebhook)
+	webhooks.PUT("/:id", openapi.Op("Update a webhook", "webhooks").
+		Body(models.UpdateWebhookRequest{}).
+		Returns(http.StatusOK, "Webhook updated", withMessage(models.Webhook{})),
+		h.UpdateWebhook)
+	webhooks.DELETE("/:id", openapi.Op("Delete a webhook", "webhooks").
+		Returns(http.StatusOK, "Webhook deleted", withMessage(nil)),
+		h.DeleteWebhook)
+	webhooks.GET("/:id/deliveries", paginated(openapi.Op("List deliveries", "webhooks")).
+		Describe("The delivery log, newest first. Delivered and dead deliveries are kept for the configured retention.").
+		Query("status", &openapi.Schema{Type: "string", Enum: []string{models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead}}, "Only deliveries with this status").
+		Returns(http.StatusOK, "Deliveries", page(models.WebhookDelivery{})),
+		h.ListDeliveries)
+	webhooks.POST("/:id/deliveries/:deliveryId/retry", openapi.Op("Retry a delivery", "webhooks").
+		Describe("Sends a delivered or dead delivery again with a fresh set of attempts.").
+		Returns(http.StatusAccepted, "Delivery queued", withMessage(models.WebhookDelivery{})),
+		h.RetryDelivery)
+	webhooks.POST("/:id/ping", openapi.Op("Send a test event", "webhooks").
+		Describe("Queues a ping event, whatever events the webhook subscribes to.").
+		Returns(http.StatusAccepted, "Ping queued", withMessage(models.WebhookDelivery{})),
+		h.Ping)
+}
+
+// registerAdmin gates each route per permission so that roles can be extended
+func (a *API) registerAdmin(admin *openapi.Router) {
+	h := a.Admin
+	can := a.Middleware.PermissionRequired
+
+	admin.GET("/stats", openapi.Op("Get system statistics", "admin").
+		Returns(http.StatusOK, "Statistics", withData(models.SystemStats{})),
+		can(models.PermissionStatsRead), h.GetStats)
+	admin.GET("/users", paginated(openapi.Op("List users", "admin")).
+		Query("q", openapi.String(), "Search by username or email").
+		Returns(http.StatusOK, "Users", page(models.UserResponse{})),
+		can(models.PermissionUsersRead), h.ListUsers)
+	admin.GET("/users/:id", openapi.Op("Get a user", "admin").
+		Returns(http.StatusOK, "User", withData(models.UserResponse{})),
+		can(models.PermissionUsersRead), h.GetUser)
+	admin.POST("/users/:id/disable", openapi.Op("Disable a user", "admin").
+		Returns(http.StatusOK, "User disabled", withMessage(nil)),
+		can(models.PermissionUsersManage), h.DisableUser)
+	admin.POST("/users/:id/enable", openapi.Op("Enable a user", "admin").
+		Returns(http.StatusOK, "User enabled", withMessage(nil)),
+		can(models.PermissionUsersManage), h.EnableUser)
+	admin.PUT("/users/:id/role", openapi.Op("Change a user's role", "admin").
+		Describe("Only admins may change roles.").
+		Body(models.UpdateRoleRequest{}).
+		Returns(http.StatusOK, "Role updated", withMessage(models.UserResponse{})),
+		a.Middleware.AdminRequired(), h.UpdateUserRole)
+	admin.GET("/links", paginated(openapi.Op("List all links", "admin")).
+		Query("q", openapi.String(), "Search by short code, title or URL").
+		Query("user_id", uuidSchema(), "Only links owned by this user").
+		Returns(http.StatusOK, "Links", page(models.AdminLinkResponse{})),
+		can(models.PermissionLinksRead), h.ListLinks)
+	admin.POST("/links/:id/takedown", openapi.Op("Take down a link", "admin").
+		Body(models.TakedownLinkRequest{}).
+		Returns(http.StatusOK, "Link taken down", withMessage(models.AdminLinkResponse{})),
+		can(models.PermissionLinksManage), h.TakedownLink)
+	admin.POST("/links/:id/restore", openapi.Op("Restore a link", "admin").
+		Returns(http.StatusOK, "Link restored", withMessage(models.AdminLinkResponse{})),
+		can(models.PermissionLinksManage), h.RestoreLink)
+}
+
+// RegisterProbes mounts the health endpoints
+func RegisterProbes(r *openapi.Router, h *HealthHandler) {
+	r.GET("/health", openapi.Op("Legacy health check", "health").
+		Returns(http.StatusOK, "The process is up", openapi.Object(map[string]*openapi.Schema{
+			"status":  openapi.String(),
+			"message": openapi.String(),
+			"time":    {Type: "string", Format: "date-time"},
+		}, "status", "message", "time")),
+		h.Health)
+	r.GET("/livez", openapi.Op("Liveness probe", "health").
+		Returns(http.StatusOK, "The process is serving requests", openapi.Object(map[string]*openapi.Schema{
+			"status": openapi.String(),
+		}, "status")),
+		h.Livez)
+	r.GET("/readyz", openapi.Op("Readiness probe", "health").
+		Returns(http.StatusOK, "Ready for traffic", models.ReadinessResponse{}).
+		Returns(http.StatusServiceUnavailable, "A dependency check failed or the instance is shutting down", models.ReadinessResponse{}),
+		h.Readyz)
+}
+
+// RegisterJWKS mounts the public keys for verifying access tokens
+func RegisterJWKS(r *openapi.Router, keys func() utils.JWKSet) {
+	r.GET("/.well-known/jwks.json", openapi.Op("Get the token signing keys", "auth").
+		Returns(http.StatusOK, "JSON Web Key Set", utils.JWKSet{}),
+		func(c *gin.Context) {
+			c.Header("Cache-Control", "public, max-age=300")
+			c.JSON(http.StatusOK, keys())
+		})
+}
+
+// RegisterRedirect mounts the public short link redirect
+func RegisterRedirect(r *openapi.Router, h *LinkHandler) {
+	r.GET("/r/:shortCode", openapi.Op("Follow a short link", "redirect").
+		Redirects(http.StatusMovedPermanently, "Redirect to the destination URL"),
+		h.Redirect)
+}
+
+const exportDescription = "Streams every matching row, oldest first, in constant memory. " +
+	"Exports running at once are limited; more are rejected with 429 too_many_exports. " +
+	"An error after the first row, or running out of time, cuts the download short; a JSON array is then left unterminated."
+
+// exported documents an export of rows of the type of v in every format
+func exported(op *openapi.Operation, v interface{}) *openapi.Operation {
+	return op.
+		Query("format", &openapi.Schema{Type: "string", Enum: []string{export.FormatCSV, export.FormatJSON, export.FormatNDJSON}}, "Output format (default csv)").
+		Query("from", &openapi.Schema{Type: "string", Format: "date-time"}, "Only rows at or after this time; a date is read as midnight UTC").
+		Query("to", &openapi.Schema{Type: "string", Format: "date-time"}, "Only rows before this time").
+		Query("workspace_id", uuidSchema(), "Export the links of this workspace instead of your own").
+		Returns(http.StatusOK, "Rows as an attachment", openapi.ArrayOf(openapi.TypeOf(v))).
+		Produces(http.StatusOK, "text/csv", &openapi.Schema{Type: "string", Description: "A header row, then one row per item"}).
+		Produces(http.StatusOK, "application/x-ndjson", &openapi.Schema{Type: "string", Description: "One JSON object per line"})
+}
+
+// streamed documents a Server-Sent Events stream of clicks
+func streamed(op *openapi.Operation) *openapi.Operation {
+	return op.
+		Describe("Server-Sent Events: a click event per recorded click, with the click of Export Click Events as data, and a comment line as heartbeat. Reconnect with the Last-Event-ID header to receive the clicks missed meanwhile, as long as the server still buffers them.").
+		Query("last_event_id", openapi.String(), "Resume after this event, for clients that cannot send Last-Event-ID").
+		Returns(http.StatusOK, "Event stream", nil).
+		Produces(http.StatusOK, "text/event-stream", &openapi.Schema{Type: "string", Description: "id: <event id>\nevent: click\ndata: <click JSON>"})
+}
+
+// importRowSchema documents a link of a JSON import file
+func importRowSchema() *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"original_url": openapi.String(),
+		"short_code":   openapi.String(),
+		"title":        openapi.String(),
+		"expires_at":   openapi.String(),
+		"tags":         openapi.ArrayOf(openapi.String()),
+	}, "original_url")
+}
+
+// withData documents the {"data": ...} envelope
+func withData(v interface{}) *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{"data": openapi.TypeOf(v)}, "data")
+}
+
+// withMessage documents the {"message": ..., "data": ...} envelope; nil
+// documents a message on its own
+func withMessage(v interface{}) *openapi.Schema {
+	if v == nil {
+		return openapi.Object(map[string]*openapi.Schema{"message": openapi.String()}, "message")
+	}
+	return openapi.Object(map[string]*openapi.Schema{
+		"message": openapi.String(),
+		"data":    openapi.TypeOf(v),
+	}, "message", "data")
+}
+
+// page documents a list response with its pagination
+func page(v interface{}) *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"data": openapi.ArrayOf(openapi.TypeOf(v)),
+		"pagination": openapi.Object(map[string]*openapi.Schema{
+			"limit":  openapi.Integer(),
+			"offset": openapi.Integer(),
+		}, "limit", "offset"),
+	}, "data", "pagination")
+}
+
+func paginated(op *openapi.Operation) *openapi.Operation {
+	return op.
+		Query("limit", openapi.Integer(), "Page size, 1 to 100 (default 10)").
+		Query("offset", openapi.Integer(), "Items to skip (default 0)")
+}
+
+func uuidSchema() *openapi.Schema {
+	return &openapi.Schema{Type: "string", Format: "uuid"}
+}
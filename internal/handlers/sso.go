@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/services"
+)
+
+const (
+	ssoFlowCookie = "oidc_flow"
+	// The login and the callback may be reached through different API
+	// versions or the unversioned alias, so the cookie covers all of /api
+	ssoCookiePath = "/api"
+)
+
+type SSOHandler struct {
+	ssoService *services.SSOService
+}
+
+func NewSSOHandler(ssoService *services.SSOService) *SSOHandler {
+	return &SSOHandler{
+		ssoService: ssoService,
+	}
+}
+
+// Login redirects the browser to the identity provider
+func (h *SSOHandler) Login(c *gin.Context) {
+	authURL, sealedFlow, err := h.ssoService.StartLogin()
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	// Lax is required so the cookie survives the top-level redirect back from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoFlowCookie, sealedFlow, int(h.ssoService.FlowExpiry().Seconds()), ssoCookiePath, "", c.Request.TLS != nil, true)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the login and returns the same response as a password login
+func (h *SSOHandler) Callback(c *gin.Context) {
+	sealedFlow, _ := c.Cookie(ssoFlowCookie)
+
+	// The flow is single use whatever the outcome
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoFlowCookie, "", -1, ssoCookiePath, "", c.Request.TLS != nil, true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		apperror.Render(c, errProviderRejected.WithField("error", providerError+": "+c.Query("error_description")))
+		return
+	}
+
+	response, err := h.ssoService.CompleteLogin(c.Request.Context(), sealedFlow, c.Query("state"), c.Query("code"))
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Two-factor authentication required",
+			"data":    response,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login successful",
+		"data":    response,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
+)
+
+// LoginTwoFactor completes a two-step login
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.LoginTwoFactor(c.Request.Context(), &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login successful",
+		"data":    response,
+	})
+}
+
+// SetupTwoFactor starts TOTP enrollment for the current user
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.authService.SetupTwoFactor(c.Request.Context(), userID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scan the QR code and confirm with a code from your authenticator",
+		"data":    response,
+	})
+}
+
+// ConfirmTwoFactor enables TOTP and returns the recovery codes
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorConfirmRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.ConfirmTwoFactor(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication enabled",
+		"data":    response,
+	})
+}
+
+// DisableTwoFactor turns TOTP off after re-authentication
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorReauthRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(c.Request.Context(), userID, &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after re-authentication
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorReauthRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Recovery codes regenerated",
+		"data":    response,
+	})
+}
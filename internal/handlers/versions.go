@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/openapi"
+)
+
+// APIVersion is one major version of the API, served at /api/<Name>. Each
+// version registers its own routes, so a new version can bind and return its
+// own models while reusing the handlers that did not change.
+type APIVersion struct {
+	Name     string
+	Register func(r *openapi.Router)
+	// Deprecation, when set, is announced on every route of the version
+	Deprecation *middleware.Deprecation
+}
+
+// MountAPI serves every version under /api. When legacy is set, the routes of
+// the current version are also served at /api itself, where they were before
+// versioning; that alias announces legacy and is left out of the document.
+func MountAPI(root *openapi.Router, versions []APIVersion, current string, legacy *middleware.Deprecation) {
+	api := root.Group("/api")
+	for _, version := range versions {
+		group := api.Group("/" + version.Name)
+		if version.Deprecation != nil {
+			group.Deprecate(middleware.Deprecated(*version.Deprecation))
+		}
+		version.Register(group)
+
+		if version.Name == current && legacy != nil {
+			alias := *legacy
+			if alias.Successor == "" {
+				alias.Successor = "/api/" + version.Name
+			}
+			version.Register(api.Undocumented().Group("").Deprecate(middleware.Deprecated(alias)))
+		}
+	}
+}
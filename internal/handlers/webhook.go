@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// CreateWebhook registers an endpoint. The signing secret is only ever
+// returned here.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully",
+		"data":    webhook,
+	})
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.webhookService.ListWebhooks(c.Request.Context(), userID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": webhooks,
+	})
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	userID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(c.Request.Context(), userID, webhookID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": webhook,
+	})
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	userID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(c.Request.Context(), userID, webhookID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook updated successfully",
+		"data":    webhook,
+	})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(c.Request.Context(), userID, webhookID); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListDeliveries returns the delivery log of a webhook, optionally filtered
+// by status
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	userID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+	limit, offset := parsePagination(c)
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Request.Context(), userID, webhookID, c.Query("status"), limit, offset)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": deliveries,
+		"pagination": gin.H{
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// RetryDelivery queues a delivered or dead delivery to be sent again
+func (h *WebhookHandler) RetryDelivery(c *gin.Context) {
+	userID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseIDParam(c, "deliveryId", "Invalid delivery ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.RetryDelivery(c.Request.Context(), userID, webhookID, deliveryID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Delivery queued",
+		"data":    delivery,
+	})
+}
+
+// Ping queues a ping event to test the endpoint
+func (h *WebhookHandler) Ping(c *gin.Context) {
+	userID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Ping(c.Request.Context(), userID, webhookID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Ping queued",
+		"data":    delivery,
+	})
+}
+
+// webhookParams returns the current user and the webhook named in the path
+func webhookParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	webhookID, ok := parseIDParam(c, "id", "Invalid webhook ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, webhookID, true
+}
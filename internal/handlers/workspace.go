@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+)
+
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+}
+
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+	}
+}
+
+// CreateWorkspace creates a workspace owned by the current user
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateWorkspaceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Workspace created successfully",
+		"data":    workspace,
+	})
+}
+
+// ListWorkspaces lists the workspaces the current user belongs to
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	workspaces, err := h.workspaceService.ListWorkspaces(c.Request.Context(), userID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": workspaces,
+	})
+}
+
+// GetWorkspace returns a workspace and the caller's role in it
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.workspaceService.GetWorkspace(c.Request.Context(), userID, workspaceID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": workspace,
+	})
+}
+
+// UpdateWorkspace renames a workspace
+func (h *WorkspaceHandler) UpdateWorkspace(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWorkspaceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	workspace, err := h.workspaceService.UpdateWorkspace(c.Request.Context(), userID, workspaceID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Workspace updated successfully",
+		"data":    workspace,
+	})
+}
+
+// DeleteWorkspace deletes a workspace and its links
+func (h *WorkspaceHandler) DeleteWorkspace(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.DeleteWorkspace(c.Request.Context(), userID, workspaceID); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Workspace deleted successfully",
+	})
+}
+
+// ListMembers lists the members of a workspace
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(c.Request.Context(), userID, workspaceID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": members,
+	})
+}
+
+// UpdateMemberRole changes a member's role
+func (h *WorkspaceHandler) UpdateMemberRole(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, ok := parseIDParam(c, "userId", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMemberRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.workspaceService.UpdateMemberRole(c.Request.Context(), userID, workspaceID, memberID, &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member role updated successfully",
+	})
+}
+
+// RemoveMember removes a member and hands their links over
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, ok := parseIDParam(c, "userId", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req models.RemoveMemberRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(c.Request.Context(), userID, workspaceID, memberID, &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed successfully",
+	})
+}
+
+// LeaveWorkspace removes the current user from a workspace
+func (h *WorkspaceHandler) LeaveWorkspace(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.LeaveWorkspaceRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.workspaceService.LeaveWorkspace(c.Request.Context(), userID, workspaceID, &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Left workspace successfully",
+	})
+}
+
+// TransferOwnership makes another member the owner
+func (h *WorkspaceHandler) TransferOwnership(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.TransferOwnershipRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.workspaceService.TransferOwnership(c.Request.Context(), userID, workspaceID, &req); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ownership transferred successfully",
+	})
+}
+
+// InviteMember emails an invitation to join the workspace
+func (h *WorkspaceHandler) InviteMember(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	invitation, err := h.workspaceService.InviteMember(c.Request.Context(), userID, workspaceID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Invitation sent",
+		"data":    invitation,
+	})
+}
+
+// ListInvitations lists pending invitations of a workspace
+func (h *WorkspaceHandler) ListInvitations(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.workspaceService.ListInvitations(c.Request.Context(), userID, workspaceID)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": invitations,
+	})
+}
+
+// RevokeInvitation cancels a pending invitation
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	invitationID, ok := parseIDParam(c, "invitationId", "Invalid invitation ID")
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.RevokeInvitation(c.Request.Context(), userID, workspaceID, invitationID); err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invitation revoked successfully",
+	})
+}
+
+// AcceptInvitation joins a workspace with an emailed invitation token
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	workspace, err := h.workspaceService.AcceptInvitation(c.Request.Context(), userID, &req)
+	if err != nil {
+		apperror.Render(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invitation accepted",
+		"data":    workspace,
+	})
+}
+
+// workspaceParams reads the authenticated user and the :id workspace parameter
+func workspaceParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	workspaceID, ok := parseIDParam(c, "id", "Invalid workspace ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, workspaceID, true
+}
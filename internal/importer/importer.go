@@ -0,0 +1,270 @@
+// Package importer reads links exported by other shorteners from CSV or JSON
+// files.
+//
+// Column and key names are matched loosely so exports can usually be imported
+// as they are: original_url, url, long_url or destination for the target;
+// short_code, code, custom_alias, alias, keyword, slug or path for the code;
+// title or name; expires_at, expiry or expiration; and tags or labels. Rows
+// that cannot be read are returned with Err set rather than failing the file.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"link-shortener/internal/apperror"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var (
+	ErrInvalidFile   = apperror.Validation("invalid_import_file", "the import file could not be read")
+	ErrUnknownFormat = apperror.Validation("unknown_import_format", "import format must be csv or json")
+	ErrTooManyRows   = apperror.Validation("too_many_rows", "the import file has too many rows")
+	ErrInvalidRow    = apperror.Validation("invalid_row", "the row could not be read")
+)
+
+// Record is one link read from an import file
+type Record struct {
+	// Line is the line of a CSV file, or the position in a JSON list from 1
+	Line        int
+	OriginalURL string
+	ShortCode   string
+	Title       string
+	ExpiresAt   *time.Time
+	Tags        []string
+	// Err explains why the row could not be read
+	Err error
+}
+
+// fields maps accepted column and key names to the field they fill
+var fields = map[string]string{
+	"original_url": "url", "originalurl": "url", "url": "url", "long_url": "url", "longurl": "url",
+	"destination": "url", "destination_url": "url", "target": "url", "target_url": "url",
+	"short_code": "code", "shortcode": "code", "code": "code", "custom_code": "code", "custom_alias": "code",
+	"alias": "code", "keyword": "code", "slug": "code", "path": "code", "back_half": "code", "short_url": "code",
+	"title": "title", "name": "title",
+	"expires_at": "expires", "expiresat": "expires", "expiry": "expires", "expires": "expires",
+	"expiration": "expires", "expiration_date": "expires", "expire_at": "expires",
+	"tags": "tags", "tag": "tags", "labels": "tags",
+}
+
+// DetectFormat guesses the format from a content type or file name, and
+// returns "" when neither gives it away
+func DetectFormat(contentType, filename string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/csv" || mediaType == "application/csv" || strings.EqualFold(path.Ext(filename), ".csv"):
+		return FormatCSV
+	case mediaType == "application/json" || strings.EqualFold(path.Ext(filename), ".json"):
+		return FormatJSON
+	}
+	return ""
+}
+
+// Parse reads every record of r. Files with more than maxRows records are
+// rejected with ErrTooManyRows.
+func Parse(r io.Reader, format string, maxRows int) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r, maxRows)
+	case FormatJSON:
+		return parseJSON(r, maxRows)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func parseCSV(r io.Reader, maxRows int) ([]Record, error) {
+	buffered := bufio.NewReader(r)
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	// Spreadsheets in many locales separate fields with semicolons
+	start, _ := buffered.Peek(buffered.Size())
+	if firstLine := start[:lineEnd(start)]; bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrInvalidFile.WithField("file", "is empty")
+	}
+	if err != nil {
+		return nil, ErrInvalidFile.WithField("file", err.Error()).Wrap(err)
+	}
+	columns := make([]string, len(header))
+	hasURL := false
+	for i, name := range header {
+		columns[i] = fields[normalizeName(strings.TrimPrefix(name, "\ufeff"))]
+		hasURL = hasURL || columns[i] == "url"
+	}
+	if !hasURL {
+		return nil, ErrInvalidFile.WithField("file", "the header has no original_url column")
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidFile.WithField("file", err.Error()).Wrap(err)
+		}
+		if len(records) == maxRows {
+			return nil, ErrTooManyRows.WithField("file", fmt.Sprintf("must have at most %d rows", maxRows))
+		}
+
+		line, _ := reader.FieldPos(0)
+		record := Record{Line: line}
+		for i, value := range row {
+			if i < len(columns) {
+				setField(&record, columns[i], value)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+func parseJSON(r io.Reader, maxRows int) ([]Record, error) {
+	var document interface{}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, ErrInvalidFile.WithField("file", "must be valid JSON").Wrap(err)
+	}
+
+	// Either a list of links or an object with a links list
+	items, ok := document.([]interface{})
+	if object, isObject := document.(map[string]interface{}); isObject {
+		items, ok = object["links"].([]interface{})
+	}
+	if !ok {
+		return nil, ErrInvalidFile.WithField("file", "must be a list of links or an object with a links list")
+	}
+	if len(items) > maxRows {
+		return nil, ErrTooManyRows.WithField("file", fmt.Sprintf("must have at most %d rows", maxRows))
+	}
+
+	records := make([]Record, len(items))
+	for i, item := range items {
+		record := &records[i]
+		record.Line = i + 1
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			record.Err = ErrInvalidRow.WithField("link", "must be an object")
+			continue
+		}
+		for key, value := range object {
+			field := fields[normalizeName(key)]
+			switch value := value.(type) {
+			case nil:
+			case string:
+				setField(record, field, value)
+			case json.Number:
+				setField(record, field, value.String())
+			case []interface{}:
+				if field != "tags" {
+					record.Err = ErrInvalidRow.WithField(key, "must not be a list")
+					continue
+				}
+				for _, tag := range value {
+					if tag, ok := tag.(string); ok {
+						record.Tags = append(record.Tags, tag)
+					} else {
+						record.Err = ErrInvalidRow.WithField(key, "must be a list of strings")
+					}
+				}
+			default:
+				if field != "" {
+					record.Err = ErrInvalidRow.WithField(key, "must be a string")
+				}
+			}
+		}
+	}
+	return records, nil
+}
+
+func setField(record *Record, field, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	switch field {
+	case "url":
+		record.OriginalURL = value
+	case "code":
+		// Exports often hold the full short link rather than the code
+		if i := strings.Index(value, "://"); i >= 0 {
+			// Drop the scheme and host
+			value = value[i+len("://"):]
+			if i := strings.IndexByte(value, '/'); i >= 0 {
+				value = value[i:]
+			} else {
+				value = ""
+			}
+		}
+		value = strings.Trim(value, "/")
+		if i := strings.LastIndex(value, "/"); i >= 0 {
+			value = value[i+1:]
+		}
+		if value == "" {
+			record.Err = ErrInvalidRow.WithField("short_code", "must name a code, not just a domain")
+			return
+		}
+		record.ShortCode = value
+	case "title":
+		record.Title = value
+	case "expires":
+		expiresAt, err := parseTime(value)
+		if err != nil {
+			record.Err = ErrInvalidRow.WithField("expires_at", err.Error())
+			return
+		}
+		record.ExpiresAt = &expiresAt
+	case "tags":
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+			record.Tags = append(record.Tags, strings.TrimSpace(tag))
+		}
+	}
+}
+
+// parseTime accepts RFC 3339, the same without a zone (read as UTC), a
+// plain date, or Unix seconds
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", value)
+}
+
+// normalizeName lower-cases a column name and joins its words with underscores
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+func lineEnd(data []byte) int {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i
+	}
+	return len(data)
+}
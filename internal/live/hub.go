@@ -0,0 +1,228 @@
+// Package live fans recorded clicks out to the clients watching them.
+//
+// Publishing never blocks: every subscriber has a bounded queue, and one that
+// falls behind is disconnected rather than slowing the others down. The last
+// clicks are kept in a ring buffer so that a client that reconnects with the
+// ID of the last event it saw gets the clicks it missed, as long as they are
+// still buffered. Event IDs name the hub that issued them, so IDs from before
+// a restart are not mistaken for current ones.
+package live
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/metrics"
+	"link-shortener/internal/models"
+)
+
+var (
+	ErrTooManySubscribers = apperror.Unavailable("too_many_subscribers", "too many live streams are open; try again later")
+	ErrTooManyStreams     = apperror.RateLimited("too_many_streams", "you have too many live streams open; close one first")
+	ErrClosed             = apperror.Unavailable("live_closed", "the server is shutting down")
+)
+
+// Scope selects the clicks a subscription receives
+type Scope struct {
+	kind string
+	id   uuid.UUID
+}
+
+// LinkScope receives the clicks on one link
+func LinkScope(linkID uuid.UUID) Scope { return Scope{"link", linkID} }
+
+// UserScope receives the clicks on a user's personal links
+func UserScope(userID uuid.UUID) Scope { return Scope{"user", userID} }
+
+// WorkspaceScope receives the clicks on the links of a workspace
+func WorkspaceScope(workspaceID uuid.UUID) Scope { return Scope{"workspace", workspaceID} }
+
+// scopes returns every scope a click belongs to
+func scopes(click *models.ClickEvent) []Scope {
+	if click.WorkspaceID != nil {
+		return []Scope{LinkScope(click.LinkID), WorkspaceScope(*click.WorkspaceID)}
+	}
+	return []Scope{LinkScope(click.LinkID), UserScope(click.UserID)}
+}
+
+// Event is a click with the ID clients resume from
+type Event struct {
+	ID    string
+	Click *models.ClickEvent
+}
+
+type Hub struct {
+	subscriberBuffer int
+	maxSubscribers   int
+	maxPerUser       int
+	// epoch prefixes the event IDs of this hub
+	epoch string
+
+	mu          sync.Mutex
+	subscribers map[Scope]map[*Subscription]struct{}
+	count       int
+	perUser     map[uuid.UUID]int
+	seq         uint64
+	// ring holds the last events; ring[seq % len(ring)] is event seq
+	ring   []Event
+	closed bool
+}
+
+func NewHub(bufferSize, subscriberBuffer, maxSubscribers, maxPerUser int) *Hub {
+	return &Hub{
+		subscriberBuffer: subscriberBuffer,
+		maxSubscribers:   maxSubscribers,
+		maxPerUser:       maxPerUser,
+		epoch:            strconv.FormatInt(time.Now().UnixNano(), 36),
+		subscribers:      map[Scope]map[*Subscription]struct{}{},
+		perUser:          map[uuid.UUID]int{},
+		ring:             make([]Event, bufferSize),
+	}
+}
+
+// Publish sends a recorded click to its subscribers and buffers it
+func (h *Hub) Publish(click *models.ClickEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.seq++
+	event := Event{ID: h.epoch + "-" + strconv.FormatUint(h.seq, 10), Click: click}
+	h.ring[h.seq%uint64(len(h.ring))] = event
+
+	for _, scope := range scopes(click) {
+		for sub := range h.subscribers[scope] {
+			select {
+			case sub.events <- event:
+			default:
+				// Too slow; it can reconnect and resume from the buffer
+				metrics.LiveSubscribersDropped.Inc()
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribe starts receiving the clicks in scope for a user. When lastEventID
+// names an event of this hub, the buffered clicks after it are delivered
+// first.
+func (h *Hub) Subscribe(userID uuid.UUID, scope Scope, lastEventID string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if h.count >= h.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	if h.perUser[userID] >= h.maxPerUser {
+		return nil, ErrTooManyStreams
+	}
+
+	missed := h.since(scope, lastEventID)
+	sub := &Subscription{hub: h, userID: userID, scope: scope, events: make(chan Event, h.subscriberBuffer+len(missed))}
+	for _, event := range missed {
+		sub.events <- event
+	}
+
+	if h.subscribers[scope] == nil {
+		h.subscribers[scope] = map[*Subscription]struct{}{}
+	}
+	h.subscribers[scope][sub] = struct{}{}
+	h.count++
+	h.perUser[userID]++
+	return sub, nil
+}
+
+// since returns the buffered events in scope after lastEventID, oldest first
+func (h *Hub) since(scope Scope, lastEventID string) []Event {
+	epoch, seq, ok := strings.Cut(lastEventID, "-")
+	if !ok || epoch != h.epoch {
+		return nil
+	}
+	last, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || last >= h.seq {
+		return nil
+	}
+
+	// Older events have been overwritten
+	if size := uint64(len(h.ring)); h.seq > size && last < h.seq-size {
+		last = h.seq - size
+	}
+	var missed []Event
+	for s := last + 1; s <= h.seq; s++ {
+		event := h.ring[s%uint64(len(h.ring))]
+		for _, eventScope := range scopes(event.Click) {
+			if eventScope == scope {
+				missed = append(missed, event)
+				break
+			}
+		}
+	}
+	return missed
+}
+
+// Subscribers is the number of open subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Close ends every subscription and refuses new ones, so that open streams
+// finish and the server can shut down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// remove ends a subscription; h.mu must be held
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subscribers[sub.scope]
+	if _, subscribed := subs[sub]; !ok || !subscribed {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.scope)
+	}
+	h.count--
+	if h.perUser[sub.userID]--; h.perUser[sub.userID] == 0 {
+		delete(h.perUser, sub.userID)
+	}
+	close(sub.events)
+}
+
+// Subscription receives the clicks of one scope until it is closed. Its
+// channel is also closed when the subscriber falls behind or the hub closes.
+type Subscription struct {
+	hub    *Hub
+	userID uuid.UUID
+	scope  Scope
+	events chan Event
+}
+
+// Events delivers the clicks in the order they were published
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
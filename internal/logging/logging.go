@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type contextKey struct{}
+
+// New creates a logger writing JSON or text records at the given level
+func New(w io.Writer, format string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatText {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// WithLogger returns a context carrying a request-scoped logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// LogMailer writes outgoing email to the application log instead of sending it
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	slog.Info("Email", "from", m.from, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer appends outgoing email to a file, which is handy for local development
+type FileMailer struct {
+	path  string
+	from  string
+	mutex sync.Mutex
+}
+
+func NewFileMailer(path, from string) *FileMailer {
+	return &FileMailer{
+		path: path,
+		from: from,
+	}
+}
+
+func (m *FileMailer) Send(msg *Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open mail file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(formatMessage(m.from, msg), "\r\n\r\n"...)); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"link-shortener/internal/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing email
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New creates the mailer selected by the MAIL_DRIVER setting
+func New(cfg *config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case "file":
+		return NewFileMailer(cfg.FilePath, cfg.From), nil
+	case "log", "":
+		return NewLogMailer(cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
+
+// formatMessage renders msg as an RFC 5322 message
+func formatMessage(from string, msg *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// SMTPMailer sends email through an SMTP relay
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     fmt.Sprintf("%s:%d", host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{msg.To}, formatMessage(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package metrics
+
+// Metrics recorded across the application. Gauges that read runtime state,
+// such as the database pool, are registered where that state lives.
+var (
+	HTTPRequests = NewCounterVec("http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	HTTPRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by method, route and status.", DefaultBuckets, "method", "route", "status")
+
+	GRPCRequests = NewCounterVec("grpc_requests_total",
+		"gRPC calls by method and status code.", "method", "code")
+	GRPCRequestDuration = NewHistogramVec("grpc_request_duration_seconds",
+		"gRPC call latency by method and status code.", DefaultBuckets, "method", "code")
+
+	Redirects = NewCounterVec("redirects_total",
+		"Short link redirects by outcome: hit, miss, expired, inactive or blocked.", "outcome")
+
+	LinkCacheLookups = NewCounterVec("link_cache_lookups_total",
+		"Redirect cache lookups by result: hit or miss.", "result")
+
+	ClicksRecorded = NewCounterVec("clicks_recorded_total",
+		"Clicks written to the database by result: ok or error.", "result")
+	ClickQueueOverflow = NewCounterVec("click_queue_overflow_total",
+		"Clicks recorded outside the queue because it was full.")
+	ClicksDropped = NewCounterVec("clicks_dropped_total",
+		"Clicks not recorded by reason: full when the queue and its overflow were, or closed.", "reason")
+
+	LiveSubscribersDropped = NewCounterVec("live_subscribers_dropped_total",
+		"Live click streams disconnected for falling behind.")
+
+	WebhookDeliveries = NewCounterVec("webhook_deliveries_total",
+		"Webhook delivery attempts by outcome: delivered, retry or dead.", "outcome")
+
+	RateLimitRejections = NewCounterVec("rate_limit_rejections_total",
+		"Requests rejected by the rate limiter.")
+)
+
+func init() {
+	Default.Register(NewGaugeFunc("link_cache_hit_ratio",
+		"Share of redirect cache lookups that were hits since startup.", func() float64 {
+			hits, misses := LinkCacheLookups.Value("hit"), LinkCacheLookups.Value("miss")
+			if hits+misses == 0 {
+				return 0
+			}
+			return hits / (hits + misses)
+		}))
+}
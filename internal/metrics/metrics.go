@@ -0,0 +1,279 @@
+// Package metrics implements the small subset of Prometheus instrumentation
+// the server needs: counters, gauges and histograms with labels, exposed in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector writes one metric family in the text exposition format
+type Collector interface {
+	Name() string
+	Write(w io.Writer)
+}
+
+// Registry holds the collectors exposed on /metrics
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]Collector{}}
+}
+
+// Default is the registry the built-in metrics are registered with
+var Default = NewRegistry()
+
+// Register adds a collector, replacing any with the same name
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[c.Name()] = c
+}
+
+// Write writes every metric family sorted by name
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name() < collectors[j].Name() })
+
+	buffered := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.Write(buffered)
+	}
+	buffered.Flush()
+}
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// labelSeparator joins label values into a map key; it cannot appear in
+// valid UTF-8 text
+const labelSeparator = "\xff"
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (f *family) Name() string { return f.name }
+
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// labelPairs renders {a="x",b="y"} plus any extra pair such as le
+func (f *family) labelPairs(key string, extra ...string) string {
+	var values []string
+	if len(f.labels) > 0 {
+		values = strings.Split(key, labelSeparator)
+	}
+
+	pairs := make([]string, 0, len(f.labels)+1)
+	for i, label := range f.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// CounterVec is a monotonically increasing value per label combination
+type CounterVec struct {
+	family
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates a counter and registers it with Default
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: family{name: name, help: help, kind: "counter", labels: labels}, values: map[string]float64{}}
+	Default.Register(c)
+	return c
+}
+
+// Add increases the counter for the label values
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	c.values[key] += delta
+	c.mu.Unlock()
+}
+
+// Inc adds one for the label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the current count for the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) Write(w io.Writer) {
+	c.mu.Lock()
+	keys := sortedKeys(c.values)
+	values := make([]float64, len(keys))
+	for i, key := range keys {
+		values[i] = c.values[key]
+	}
+	c.mu.Unlock()
+
+	c.header(w)
+	for i, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatFloat(values[i]))
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets per label
+// combination
+type HistogramVec struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// DefaultBuckets suit request latencies in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// NewHistogramVec creates a histogram and registers it with Default
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		family:  family{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  map[string]*histogram{},
+	}
+	Default.Register(h)
+	return h
+}
+
+// Observe records one value for the label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.values[key]
+	if !ok {
+		series = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *HistogramVec) Write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+	for _, key := range sortedKeys(h.values) {
+		series := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(bound)), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), series.count)
+	}
+}
+
+// Func is a gauge or counter whose value is read when scraped
+type Func struct {
+	family
+	value func() float64
+}
+
+// NewGaugeFunc creates a gauge read from fn. It is not registered; pass it to
+// Registry.Register.
+func NewGaugeFunc(name, help string, fn func() float64) *Func {
+	return &Func{family: family{name: name, help: help, kind: "gauge"}, value: fn}
+}
+
+// NewCounterFunc creates a counter read from fn, for totals kept elsewhere
+func NewCounterFunc(name, help string, fn func() float64) *Func {
+	return &Func{family: family{name: name, help: help, kind: "counter"}, value: fn}
+}
+
+func (f *Func) Write(w io.Writer) {
+	f.header(w)
+	fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.value()))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
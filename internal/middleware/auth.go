@@ -1,43 +1,103 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
 	"link-shortener/internal/utils"
 )
 
+var (
+	errAuthorizationRequired   = apperror.Unauthorized("authorization_required", "Authorization header required")
+	errAuthorizationFormat     = apperror.Unauthorized("invalid_authorization_header", "Invalid authorization header format")
+	errInvalidToken            = apperror.Unauthorized("invalid_access_token", "Invalid or expired token")
+	errSessionRevoked          = apperror.Unauthorized("session_revoked", "Session has been revoked")
+	errNotAuthenticated        = apperror.Unauthorized("not_authenticated", "User not authenticated")
+	errAdminRequired           = apperror.Forbidden("admin_required", "Admin access required")
+	errInsufficientPermissions = apperror.Forbidden("insufficient_permissions", "Insufficient permissions")
+	errEmailNotVerified        = apperror.Forbidden("email_not_verified", "Email address must be verified")
+)
+
+// SessionValidator checks that the session behind a token has not been revoked
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, userID uuid.UUID, sessionVersion int) error
+}
+
 type AuthMiddleware struct {
-	jwtMgr *utils.JWTManager
+	jwtMgr   *utils.JWTManager
+	sessions SessionValidator
 }
 
-func NewAuthMiddleware(jwtMgr *utils.JWTManager) *AuthMiddleware {
+func NewAuthMiddleware(jwtMgr *utils.JWTManager, sessions SessionValidator) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtMgr: jwtMgr,
+		jwtMgr:   jwtMgr,
+		sessions: sessions,
 	}
 }
 
 func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := m.Authenticate(c.Request.Context(), c.GetHeader("Authorization"))
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+
+		// Set user information in context
+		setClaims(c, claims)
+
+		c.Next()
+	}
+}
+
+// Authenticate checks an Authorization value of the form "Bearer <token>"
+// and the session behind the token. The gRPC API authenticates with it too.
+func (m *AuthMiddleware) Authenticate(ctx context.Context, authHeader string) (*utils.Claims, error) {
+	if authHeader == "" {
+		return nil, errAuthorizationRequired
+	}
+
+	// Check if token starts with "Bearer "
+	tokenParts := strings.Split(authHeader, " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		return nil, errAuthorizationFormat
+	}
+
+	// Validate token
+	claims, err := m.jwtMgr.ValidateToken(tokenParts[1])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	// Reject tokens revoked by a password change or account deletion
+	if err := m.sessions.ValidateSession(ctx, claims.UserID, claims.SessionVersion); err != nil {
+		if apperror.Status(err) < http.StatusInternalServerError {
+			err = errSessionRevoked.Wrap(err)
+		}
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header required",
-			})
-			c.Abort()
+			c.Next()
 			return
 		}
 
 		// Check if token starts with "Bearer "
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid authorization header format",
-			})
-			c.Abort()
+			c.Next()
 			return
 		}
 
@@ -46,56 +106,90 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		// Validate token
 		claims, err := m.jwtMgr.ValidateToken(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired token",
-			})
-			c.Abort()
+			c.Next()
+			return
+		}
+
+		if err := m.sessions.ValidateSession(c.Request.Context(), claims.UserID, claims.SessionVersion); err != nil {
+			c.Next()
 			return
 		}
 
 		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("email", claims.Email)
+		setClaims(c, claims)
 
 		c.Next()
 	}
 }
 
-func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
+// AdminRequired rejects users without the admin role. It must run after AuthRequired.
+func (m *AuthMiddleware) AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.Next()
+		if c.GetString("role") != models.RoleAdmin {
+			apperror.Render(c, errAdminRequired)
 			return
 		}
 
-		// Check if token starts with "Bearer "
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.Next()
+		c.Next()
+	}
+}
+
+// PermissionRequired rejects users lacking the permission, whether it comes
+// from their role or was granted individually. It must run after AuthRequired.
+func (m *AuthMiddleware) PermissionRequired(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			apperror.Render(c, errInsufficientPermissions)
 			return
 		}
 
-		token := tokenParts[1]
+		c.Next()
+	}
+}
 
-		// Validate token
-		claims, err := m.jwtMgr.ValidateToken(token)
+// VerifiedEmailRequired rejects users who have not confirmed their email
+// address. It must run after AuthRequired.
+func (m *AuthMiddleware) VerifiedEmailRequired(isVerified func(ctx context.Context, userID uuid.UUID) (bool, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserIDFromContext(c)
 		if err != nil {
-			c.Next()
+			apperror.Render(c, errNotAuthenticated)
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("email", claims.Email)
+		verified, err := isVerified(c.Request.Context(), userID)
+		if err != nil {
+			apperror.Render(c, fmt.Errorf("failed to check email verification: %w", err))
+			return
+		}
+
+		if !verified {
+			apperror.Render(c, errEmailNotVerified)
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// HasPermission reports whether the authenticated user holds the permission
+func HasPermission(c *gin.Context, permission string) bool {
+	for _, p := range c.GetStringSlice("permissions") {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func setClaims(c *gin.Context, claims *utils.Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("email", claims.Email)
+	c.Set("role", claims.Role)
+	c.Set("permissions", claims.Permissions)
+}
+
 // GetUserIDFromContext extracts user ID from gin context
 func GetUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
 	userIDInterface, exists := c.Get("user_id")
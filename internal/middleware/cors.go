@@ -2,16 +2,49 @@ package middleware
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CORS middleware for handling cross-origin requests
-func CORS() gin.HandlerFunc {
+// CORSPolicy answers cross-origin requests for a set of allowed origins that
+// can be replaced while the server runs
+type CORSPolicy struct {
+	origins atomic.Pointer[map[string]bool]
+}
+
+func NewCORSPolicy(allowedOrigins []string) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.Update(allowedOrigins)
+	return p
+}
+
+// Update replaces the allowed origins; "*" allows any origin
+func (p *CORSPolicy) Update(allowedOrigins []string) {
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origins[origin] = true
+	}
+	p.origins.Store(&origins)
+}
+
+// Handler returns the CORS middleware
+func (p *CORSPolicy) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		origins := *p.origins.Load()
+		if origins["*"] {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Credentials", "true")
+		} else {
+			// The response depends on the origin even when it is refused,
+			// so caches must not serve it to other origins
+			c.Header("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); origins[origin] {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -22,3 +55,8 @@ func CORS() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// CORS middleware for handling cross-origin requests from any origin
+func CORS() gin.HandlerFunc {
+	return NewCORSPolicy([]string{"*"}).Handler()
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecation describes routes that clients should move off
+type Deprecation struct {
+	// Since is when the routes were deprecated
+	Since time.Time
+	// Sunset is when they may stop working; zero until that is decided
+	Sunset time.Time
+	// Successor is the base path of the routes that replace them
+	Successor string
+}
+
+// Deprecated announces a deprecation on every response with the Deprecation
+// header of RFC 9745, the Sunset header of RFC 8594 and a successor-version
+// link
+func Deprecated(d Deprecation) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(d.Since.Unix(), 10)
+	var sunset, link string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	if d.Successor != "" {
+		link = "<" + d.Successor + `>; rel="successor-version"`
+	}
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+		if link != "" {
+			c.Writer.Header().Add("Link", link)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+)
+
+const (
+	// IdempotencyKeyHeader makes a request safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from an earlier
+	// request with the same key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength bounds the keys clients may choose
+	maxIdempotencyKeyLength = 255
+)
+
+var errInvalidIdempotencyKey = apperror.Validation("invalid_idempotency_key",
+	"Idempotency-Key must be 1 to 255 printable ASCII characters, not all spaces")
+
+// IdempotencyStore claims keys and keeps the responses to replay
+type IdempotencyStore interface {
+	Begin(ctx context.Context, userID uuid.UUID, key, fingerprint string) (*models.StoredResponse, uuid.UUID, error)
+	Complete(ctx context.Context, userID uuid.UUID, key string, lockID uuid.UUID, resp *models.StoredResponse) error
+	Release(ctx context.Context, userID uuid.UUID, key string, lockID uuid.UUID) error
+}
+
+// Idempotent answers a retried request that carries an Idempotency-Key with
+// the response to the first one instead of running the handler again. Keys
+// belong to the user, so it must run after AuthRequired. The fingerprint of a
+// request is its scope and JSON body; reusing a key for a different request
+// is a conflict. Server errors release the key so that the retry runs.
+func Idempotent(store IdempotencyStore, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// A header sent blank or more than once is rejected rather than ignored
+		keys := c.Request.Header.Values(IdempotencyKeyHeader)
+		if len(keys) == 0 {
+			c.Next()
+			return
+		}
+		key := keys[0]
+		if len(keys) > 1 || !validIdempotencyKey(key) {
+			apperror.Render(c, errInvalidIdempotencyKey)
+			return
+		}
+
+		userID, err := GetUserIDFromContext(c)
+		if err != nil {
+			apperror.Render(c, errNotAuthenticated)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apperror.Render(c, fmt.Errorf("failed to read request body: %w", err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, lockID, err := store.Begin(c.Request.Context(), userID, key, requestFingerprint(scope, body))
+		if err != nil {
+			apperror.Render(c, err)
+			return
+		}
+		if stored != nil {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		// The outcome is recorded even when the client has gone away
+		ctx := context.WithoutCancel(c.Request.Context())
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		finished := false
+		defer func() {
+			// A panicking handler leaves the key free for the retry
+			if !finished {
+				if err := store.Release(ctx, userID, key, lockID); err != nil {
+					logging.FromContext(ctx).Error("Failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		c.Next()
+		finished = true
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == apperror.StatusClientClosedRequest {
+			if err := store.Release(ctx, userID, key, lockID); err != nil {
+				logging.FromContext(ctx).Error("Failed to release idempotency key", "error", err)
+			}
+			return
+		}
+		resp := &models.StoredResponse{
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := store.Complete(ctx, userID, key, lockID, resp); err != nil {
+			logging.FromContext(ctx).Error("Failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength || strings.TrimSpace(key) == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint hashes the scope and body of a request. JSON bodies are
+// re-encoded first so that a client resending the same object with another
+// key order or spacing is recognised.
+func requestFingerprint(scope string, body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil && !decoder.More() {
+		if canonical, err := json.Marshal(value); err == nil {
+			body = canonical
+		}
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(scope))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/logging"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps client supplied IDs so they cannot bloat the logs
+const maxRequestIDLength = 128
+
+// RequestID accepts the caller's X-Request-ID or generates one, echoes it in
+// the response and attaches a logger carrying it to the request context
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := AcceptRequestID(c.GetHeader(RequestIDHeader))
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
+
+		c.Next()
+	}
+}
+
+// AcceptRequestID returns the caller's request ID when it is at most 128
+// printable characters, and a new one otherwise
+func AcceptRequestID(requestID string) string {
+	if !validRequestID(requestID) {
+		return newRequestID()
+	}
+	return requestID
+}
+
+// GetRequestID returns the ID assigned by RequestID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// AccessLog writes one record per request once it has been handled
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"ip", c.ClientIP(),
+		}
+		if userID, err := GetUserIDFromContext(c); err == nil {
+			attrs = append(attrs, "user_id", userID.String())
+		}
+		if shortCode := c.Param("shortCode"); shortCode != "" {
+			attrs = append(attrs, "short_code", shortCode)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panic into a 500 response and logs it with the request
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		logging.FromContext(c.Request.Context()).Error("panic recovered", "panic", recovered, "stack", string(debug.Stack()))
+		apperror.Render(c, fmt.Errorf("panic: %v", recovered))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
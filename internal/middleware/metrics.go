@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/metrics"
+)
+
+// Metrics counts requests and observes their latency by route and status.
+// Requests that match no route are grouped under "unmatched" so unknown paths
+// cannot create unbounded series.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.Inc(c.Request.Method, route, status)
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
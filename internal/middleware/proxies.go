@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges, the forms gin's
+// SetTrustedProxies accepts
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ForwardedClientIP returns the client behind a connection from remoteIP the
+// way gin's Context.ClientIP does for REST requests. Only when remoteIP is a
+// trusted proxy are X-Forwarded-For, read from the right and skipping
+// further trusted proxies, and then X-Real-IP believed.
+func ForwardedClientIP(remoteIP string, trusted []*net.IPNet, forwardedFor, realIP string) string {
+	if !isTrusted(net.ParseIP(remoteIP), trusted) {
+		return remoteIP
+	}
+
+	if forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !isTrusted(ip, trusted) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+		return ip.String()
+	}
+	return remoteIP
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,16 @@
 package middleware
 
 import (
-	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/metrics"
 )
 
+var errRateLimited = apperror.RateLimited("rate_limited", "Rate limit exceeded")
+
 type RateLimiter struct {
 	requests map[string][]time.Time
 	mutex    sync.RWMutex
@@ -23,6 +26,14 @@ func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
 	}
 }
 
+// Update changes the limit and window for subsequent requests
+func (rl *RateLimiter) Update(limit int, window time.Duration) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.limit = limit
+	rl.window = window
+}
+
 func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get client IP
@@ -31,35 +42,42 @@ func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
 			clientIP = "unknown"
 		}
 
-		rl.mutex.Lock()
-		defer rl.mutex.Unlock()
+		if !rl.Allow(clientIP) {
+			apperror.Render(c, errRateLimited)
+			return
+		}
 
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
+		c.Next()
+	}
+}
 
-		// Clean old requests
-		if times, exists := rl.requests[clientIP]; exists {
-			var validTimes []time.Time
-			for _, t := range times {
-				if t.After(windowStart) {
-					validTimes = append(validTimes, t)
-				}
-			}
-			rl.requests[clientIP] = validTimes
-		}
+// Allow records a request from key, a client IP, and reports whether it is
+// within the limit. The gRPC API shares the limiter through it.
+func (rl *RateLimiter) Allow(key string) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
 
-		// Check if limit exceeded
-		if len(rl.requests[clientIP]) >= rl.limit {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded",
-			})
-			c.Abort()
-			return
-		}
+	now := time.Now()
+	windowStart := now.Add(-rl.window)
 
-		// Add current request
-		rl.requests[clientIP] = append(rl.requests[clientIP], now)
+	// Clean old requests
+	if times, exists := rl.requests[key]; exists {
+		var validTimes []time.Time
+		for _, t := range times {
+			if t.After(windowStart) {
+				validTimes = append(validTimes, t)
+			}
+		}
+		rl.requests[key] = validTimes
+	}
 
-		c.Next()
+	// Check if limit exceeded
+	if len(rl.requests[key]) >= rl.limit {
+		metrics.RateLimitRejections.Inc()
+		return false
 	}
+
+	// Add current request
+	rl.requests[key] = append(rl.requests[key], now)
+	return true
 }
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/tracing"
+)
+
+// Tracing opens a server span per request, continuing the caller's trace when
+// a valid traceparent header is sent. It must run after RequestID so the trace
+// ID can be added to the request logger.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracer := tracing.Default()
+		if tracer == nil {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if parent, ok := tracing.ParseTraceParent(c.GetHeader(tracing.TraceParentHeader)); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+
+		// Unmatched paths share one span name so they cannot explode cardinality
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx, span := tracer.Start(ctx, name, tracing.KindServer,
+			tracing.String("http.request.method", c.Request.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", c.Request.URL.Path),
+			tracing.String("client.address", c.ClientIP()),
+			tracing.String("user_agent.original", c.Request.UserAgent()),
+		)
+		defer span.End()
+
+		logger := logging.FromContext(ctx).With("trace_id", span.SpanContext().TraceID.String())
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+)
+
+// Bulk requests either apply every item or nothing (atomic, the default), or
+// apply the items that succeed and report the rest (partial)
+const (
+	BulkModeAtomic  = "atomic"
+	BulkModePartial = "partial"
+)
+
+// Actions that can be applied to many existing links at once
+const (
+	BulkActionActivate   = "activate"
+	BulkActionDeactivate = "deactivate"
+	BulkActionDelete     = "delete"
+)
+
+const (
+	BulkItemSucceeded = "succeeded"
+	BulkItemFailed    = "failed"
+)
+
+type BulkCreateLinksRequest struct {
+	Mode  string              `json:"mode,omitempty" binding:"omitempty,oneof=atomic partial"`
+	Links []CreateLinkRequest `json:"links" binding:"required,min=1"`
+}
+
+// BulkLinkActionRequest names the links to act on, either by ID or by filter
+type BulkLinkActionRequest struct {
+	Mode   string      `json:"mode,omitempty" binding:"omitempty,oneof=atomic partial"`
+	IDs    []uuid.UUID `json:"ids,omitempty"`
+	Filter *LinkFilter `json:"filter,omitempty"`
+}
+
+// LinkFilter selects the caller's personal links, or the links of a
+// workspace, narrowed by the optional fields
+type LinkFilter struct {
+	WorkspaceID   *uuid.UUID `json:"workspace_id,omitempty"`
+	Search        string     `json:"q,omitempty"`
+	IsActive      *bool      `json:"is_active,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+}
+
+// BulkItemResult is the outcome of one item. Index is the position of the
+// item in the request, or in the links matched by the filter.
+type BulkItemResult struct {
+	Index  int                `json:"index"`
+	ID     *uuid.UUID         `json:"id,omitempty"`
+	Status string             `json:"status"`
+	Link   *LinkResponse      `json:"link,omitempty"`
+	Error  *apperror.Response `json:"error,omitempty"`
+}
+
+type BulkResult struct {
+	Mode      string            `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []*BulkItemResult `json:"results"`
+}
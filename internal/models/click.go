@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClickEvent is one followed redirect. ShortCode is filled in when events are
+// read back; UserID, the link's creator, and WorkspaceID only on the redirect
+// path.
+type ClickEvent struct {
+	ID          int64      `json:"id"`
+	LinkID      uuid.UUID  `json:"link_id"`
+	UserID      uuid.UUID  `json:"-"`
+	WorkspaceID *uuid.UUID `json:"-"`
+	ShortCode   string     `json:"short_code"`
+	ClickedAt   time.Time  `json:"clicked_at"`
+	Referrer    string     `json:"referrer"`
+	UserAgent   string     `json:"user_agent"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportFilter narrows an export. Without a workspace it covers the user's
+// personal links. From is inclusive and To exclusive; links are filtered by
+// creation time and clicks by click time.
+type ExportFilter struct {
+	WorkspaceID *uuid.UUID
+	// LinkID limits a click export to one link
+	LinkID *uuid.UUID
+	From   *time.Time
+	To     *time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+
+	ReadinessReady        = "ready"
+	ReadinessNotReady     = "not_ready"
+	ReadinessShuttingDown = "shutting_down"
+)
+
+// ComponentHealth is the result of one readiness check
+type ComponentHealth struct {
+	Status    string                 `json:"status"`
+	LatencyMS float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentHealth `json:"components,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord is a stored Idempotency-Key. StatusCode is nil while the
+// request that claimed the key is still running.
+type IdempotencyRecord struct {
+	UserID      uuid.UUID `db:"user_id"`
+	Key         string    `db:"key"`
+	Fingerprint string    `db:"fingerprint"`
+	StatusCode  *int      `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// StoredResponse is the response a retry with the same key is answered with
+type StoredResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+)
+
+// What an import does with a row whose code is already taken, by an existing
+// link or an earlier row
+const (
+	ConflictSkip   = "skip"
+	ConflictRename = "rename"
+	// ConflictFail imports in one transaction that is rolled back when any
+	// code is taken
+	ConflictFail = "fail"
+)
+
+const (
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportFailed    = "failed"
+)
+
+// Outcomes reported for individual rows; rows that were created as they are
+// are only counted
+const (
+	ImportRowRenamed = "renamed"
+	ImportRowSkipped = "skipped"
+	ImportRowFailed  = "failed"
+)
+
+// ImportOptions control how a file is imported
+type ImportOptions struct {
+	Format         string
+	ConflictPolicy string
+	DryRun         bool
+	WorkspaceID    *uuid.UUID
+}
+
+// ImportIssue describes a row that was not created as it was
+type ImportIssue struct {
+	Line      int                `json:"line"`
+	ShortCode string             `json:"short_code,omitempty"`
+	Status    string             `json:"status"`
+	RenamedTo string             `json:"renamed_to,omitempty"`
+	Error     *apperror.Response `json:"error,omitempty"`
+}
+
+// ImportReport counts the outcome of every row. In a dry run Created counts
+// the links that would be created.
+type ImportReport struct {
+	ConflictPolicy string `json:"conflict_policy"`
+	Total          int    `json:"total"`
+	Created        int    `json:"created"`
+	Renamed        int    `json:"renamed"`
+	Skipped        int    `json:"skipped"`
+	Failed         int    `json:"failed"`
+	// Conflicts counts the rows whose code was taken, whatever the policy did
+	Conflicts int           `json:"conflicts"`
+	Issues    []ImportIssue `json:"issues"`
+	// IssuesTruncated is set when there were more issues than are listed
+	IssuesTruncated bool `json:"issues_truncated,omitempty"`
+}
+
+// ImportJob is an import running in the background. Processed counts the
+// rows handled so far out of Total.
+type ImportJob struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"-"`
+	WorkspaceID *uuid.UUID `json:"workspace_id,omitempty"`
+	Status      string     `json:"status"`
+	Processed   int        `json:"processed"`
+	Error       string     `json:"error,omitempty"`
+	ImportReport
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
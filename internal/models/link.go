@@ -7,49 +7,61 @@ import (
 )
 
 type Link struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	UserID      uuid.UUID `json:"user_id" db:"user_id"`
-	OriginalURL string    `json:"original_url" db:"original_url"`
-	ShortCode   string    `json:"short_code" db:"short_code"`
-	Title       string    `json:"title" db:"title"`
-	Clicks      int       `json:"clicks" db:"clicks"`
-	IsActive    bool      `json:"is_active" db:"is_active"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	WorkspaceID    *uuid.UUID `json:"workspace_id,omitempty" db:"workspace_id"`
+	OriginalURL    string     `json:"original_url" db:"original_url"`
+	ShortCode      string     `json:"short_code" db:"short_code"`
+	Title          string     `json:"title" db:"title"`
+	Tags           []string   `json:"tags" db:"tags"`
+	Clicks         int        `json:"clicks" db:"clicks"`
+	IsActive       bool       `json:"is_active" db:"is_active"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	TakenDownAt    *time.Time `json:"taken_down_at,omitempty" db:"taken_down_at"`
+	TakedownReason string     `json:"takedown_reason,omitempty" db:"takedown_reason"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type CreateLinkRequest struct {
-	OriginalURL string `json:"original_url" binding:"required,url"`
-	CustomAlias string `json:"custom_alias,omitempty" binding:"omitempty,min=3,max=20,alphanum"`
-	Title       string `json:"title,omitempty" binding:"omitempty,max=255"`
+	OriginalURL string     `json:"original_url" binding:"required,url"`
+	CustomAlias string     `json:"custom_alias,omitempty" binding:"omitempty,min=3,max=20,alphanum"`
+	Title       string     `json:"title,omitempty" binding:"omitempty,max=255"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	WorkspaceID *uuid.UUID `json:"workspace_id,omitempty"`
+	Tags        []string   `json:"tags,omitempty" binding:"omitempty,max=10"`
 }
 
 type UpdateLinkRequest struct {
-	OriginalURL string `json:"original_url,omitempty" binding:"omitempty,url"`
-	CustomAlias string `json:"custom_alias,omitempty" binding:"omitempty,min=3,max=20,alphanum"`
-	Title       string `json:"title,omitempty" binding:"omitempty,max=255"`
-	IsActive    *bool   `json:"is_active,omitempty"`
+	OriginalURL string     `json:"original_url,omitempty" binding:"omitempty,url"`
+	CustomAlias string     `json:"custom_alias,omitempty" binding:"omitempty,min=3,max=20,alphanum"`
+	Title       string     `json:"title,omitempty" binding:"omitempty,max=255"`
+	IsActive    *bool      `json:"is_active,omitempty"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// Tags replaces the tags when present; an empty list removes them
+	Tags []string `json:"tags,omitempty" binding:"omitempty,max=10"`
 }
 
 type LinkResponse struct {
-	ID          uuid.UUID `json:"id"`
-	OriginalURL string    `json:"original_url"`
-	ShortCode   string    `json:"short_code"`
-	ShortURL    string    `json:"short_url"`
-	Title       string    `json:"title"`
-	Clicks      int       `json:"clicks"`
-	IsActive    bool      `json:"is_active"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	WorkspaceID    *uuid.UUID `json:"workspace_id,omitempty"`
+	OriginalURL    string     `json:"original_url"`
+	ShortCode      string     `json:"short_code"`
+	ShortURL       string     `json:"short_url"`
+	Title          string     `json:"title"`
+	Tags           []string   `json:"tags"`
+	Clicks         int        `json:"clicks"`
+	IsActive       bool       `json:"is_active"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	TakenDownAt    *time.Time `json:"taken_down_at,omitempty"`
+	TakedownReason string     `json:"takedown_reason,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type LinkStats struct {
-	TotalLinks    int `json:"total_links"`
-	TotalClicks   int `json:"total_clicks"`
-	ActiveLinks   int `json:"active_links"`
-	ExpiredLinks  int `json:"expired_links"`
+	TotalLinks   int `json:"total_links"`
+	TotalClicks  int `json:"total_clicks"`
+	ActiveLinks  int `json:"active_links"`
+	ExpiredLinks int `json:"expired_links"`
 }
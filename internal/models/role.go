@@ -0,0 +1,98 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions can be granted through a role or individually per user
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersManage = "users:manage"
+	PermissionLinksRead   = "links:read"
+	PermissionLinksManage = "links:manage"
+	PermissionStatsRead   = "stats:read"
+)
+
+// RolePermissions lists the permissions every member of a role has
+var RolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersManage,
+		PermissionLinksRead,
+		PermissionLinksManage,
+		PermissionStatsRead,
+	},
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// IsValidPermission reports whether permission is granted by any role
+func IsValidPermission(permission string) bool {
+	for _, permissions := range RolePermissions {
+		for _, p := range permissions {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsDisabled reports whether an administrator disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// EffectivePermissions merges the role's permissions with the user's own grants
+func (u *User) EffectivePermissions() []string {
+	seen := make(map[string]bool)
+	var permissions []string
+	for _, p := range append(append([]string{}, RolePermissions[u.Role]...), u.Permissions...) {
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+type UpdateRoleRequest struct {
+	Role        string   `json:"role" binding:"required"`
+	Permissions []string `json:"permissions"`
+}
+
+type TakedownLinkRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// AdminLinkResponse is a link as seen by administrators
+type AdminLinkResponse struct {
+	*LinkResponse
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// SystemStats are system-wide counters for the admin dashboard
+type SystemStats struct {
+	TotalUsers     int `json:"total_users"`
+	AdminUsers     int `json:"admin_users"`
+	DisabledUsers  int `json:"disabled_users"`
+	VerifiedUsers  int `json:"verified_users"`
+	TotalLinks     int `json:"total_links"`
+	ActiveLinks    int `json:"active_links"`
+	ExpiredLinks   int `json:"expired_links"`
+	TakenDownLinks int `json:"taken_down_links"`
+	TotalClicks    int `json:"total_clicks"`
+}
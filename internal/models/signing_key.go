@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SigningKey is a stored asymmetric key for signing access tokens
+type SigningKey struct {
+	ID         string     `db:"id"`
+	Algorithm  string     `db:"algorithm"`
+	PrivateKey string     `db:"private_key"`
+	CreatedAt  time.Time  `db:"created_at"`
+	RetiredAt  *time.Time `db:"retired_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode is a one-time backup code for two-factor authentication
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorReauthRequest re-authenticates the user before a sensitive
+// two-factor change. Code may be a TOTP code or a recovery code.
+type TwoFactorReauthRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
@@ -7,12 +7,26 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Username     string    `json:"username" db:"username"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID              uuid.UUID  `json:"id" db:"id"`
+	Username        string     `json:"username" db:"username"`
+	Email           string     `json:"email" db:"email"`
+	PasswordHash    string     `json:"-" db:"password_hash"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	TOTPSecret      string     `json:"-" db:"totp_secret"`
+	TOTPEnabled     bool       `json:"two_factor_enabled" db:"totp_enabled"`
+	TOTPLastStep    int64      `json:"-" db:"totp_last_step"`
+	SessionVersion  int        `json:"-" db:"session_version"`
+	DeletionAt      *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	Role            string     `json:"role" db:"role"`
+	Permissions     []string   `json:"permissions,omitempty" db:"permissions"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 type RegisterRequest struct {
@@ -26,14 +40,47 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// AuthResponse is returned by register and login. When the account has
+// two-factor authentication enabled, login only returns a challenge token
+// that must be exchanged together with a code.
 type AuthResponse struct {
-	User  User   `json:"user"`
-	Token string `json:"token"`
+	User              *User  `json:"user,omitempty"`
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
 }
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            uuid.UUID  `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	TwoFactor     bool       `json:"two_factor_enabled"`
+	DeletionAt    *time.Time `json:"deletion_scheduled_at,omitempty"`
+	Role          string     `json:"role"`
+	Permissions   []string   `json:"permissions"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type UpdateProfileRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
 }
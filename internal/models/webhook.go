@@ -0,0 +1,109 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events a webhook can subscribe to
+const (
+	EventLinkCreated = "link.created"
+	EventLinkUpdated = "link.updated"
+	EventLinkDeleted = "link.deleted"
+	EventLinkExpired = "link.expired"
+	EventLinkClicked = "link.clicked"
+	// EventPing is only sent on request, to test an endpoint
+	EventPing = "ping"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkExpired, EventLinkClicked}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead is a delivery that failed every attempt; it is only
+	// retried on request
+	DeliveryDead = "dead"
+)
+
+// Webhook is an endpoint that is sent the events it subscribes to for the
+// links its owner created
+type Webhook struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"-"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Secret      string    `json:"-"`
+	Events      []string  `json:"events"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreatedWebhook is returned once, on creation, with the signing secret
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Description string   `json:"description,omitempty" binding:"omitempty,max=255"`
+	Events      []string `json:"events" binding:"required,min=1,dive,oneof=link.created link.updated link.deleted link.expired link.clicked"`
+}
+
+type UpdateWebhookRequest struct {
+	URL         string  `json:"url,omitempty" binding:"omitempty,url"`
+	Description *string `json:"description,omitempty" binding:"omitempty,max=255"`
+	// Events replaces the subscribed events when present
+	Events   []string `json:"events,omitempty" binding:"omitempty,min=1,dive,oneof=link.created link.updated link.deleted link.expired link.clicked"`
+	IsActive *bool    `json:"is_active,omitempty"`
+}
+
+// WebhookEvent is the body of every delivery. ID is shared by the deliveries
+// of one event to several webhooks, so receivers can drop duplicates.
+type WebhookEvent struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// OutboxEvent is an event for the webhooks of UserID, encoded as Payload
+type OutboxEvent struct {
+	UserID  uuid.UUID
+	ID      uuid.UUID
+	Type    string
+	Payload []byte
+}
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// DueDelivery is a delivery claimed for sending, with where to send it
+type DueDelivery struct {
+	ID       uuid.UUID
+	EventID  uuid.UUID
+	Event    string
+	Payload  []byte
+	Attempts int
+	URL      string
+	Secret   string
+}
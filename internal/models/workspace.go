@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WorkspaceRoleViewer = "viewer"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleOwner  = "owner"
+)
+
+// workspaceRoleRank orders roles so that a higher role includes the lower ones
+var workspaceRoleRank = map[string]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleEditor: 2,
+	WorkspaceRoleOwner:  3,
+}
+
+// IsValidWorkspaceRole reports whether role is a known workspace role
+func IsValidWorkspaceRole(role string) bool {
+	_, ok := workspaceRoleRank[role]
+	return ok
+}
+
+// WorkspaceRoleAllows reports whether role grants at least the required role
+func WorkspaceRoleAllows(role, required string) bool {
+	return workspaceRoleRank[role] >= workspaceRoleRank[required] && workspaceRoleRank[role] > 0
+}
+
+type Workspace struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WorkspaceMembership is a workspace together with the caller's role in it
+type WorkspaceMembership struct {
+	Workspace
+	Role string `json:"role"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID `json:"workspace_id" db:"workspace_id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Username    string    `json:"username" db:"username"`
+	Email       string    `json:"email" db:"email"`
+	Role        string    `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type WorkspaceInvitation struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	WorkspaceID uuid.UUID  `json:"workspace_id" db:"workspace_id"`
+	Email       string     `json:"email" db:"email"`
+	Role        string     `json:"role" db:"role"`
+	TokenHash   string     `json:"-" db:"token_hash"`
+	InvitedBy   uuid.UUID  `json:"invited_by" db:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
+
+type UpdateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+// RemoveMemberRequest names who inherits the links the departing member
+// created. When empty the workspace owner inherits them.
+type RemoveMemberRequest struct {
+	TransferTo *uuid.UUID `json:"transfer_to,omitempty"`
+}
+
+// LeaveWorkspaceRequest is used when a member leaves. An owner must hand the
+// workspace over to another member first by setting NewOwnerID.
+type LeaveWorkspaceRequest struct {
+	NewOwnerID *uuid.UUID `json:"new_owner_id,omitempty"`
+	TransferTo *uuid.UUID `json:"transfer_to,omitempty"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uuid.UUID `json:"user_id" binding:"required"`
+}
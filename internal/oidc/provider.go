@@ -0,0 +1,174 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"link-shortener/internal/config"
+)
+
+// Discovery holds the parts of the provider metadata document we use
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the token endpoint reply to an authorization code exchange
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Provider is an OpenID Connect relying party for a single issuer. Metadata
+// and signing keys are fetched lazily and cached.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	client       *http.Client
+
+	mu              sync.Mutex
+	discovery       *Discovery
+	keys            *keySet
+	keysRefreshedAt time.Time
+}
+
+// NewProvider creates a provider from configuration
+func NewProvider(cfg *config.OIDCConfig) *Provider {
+	return &Provider{
+		issuer:       strings.TrimSuffix(cfg.IssuerURL, "/"),
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		redirectURL:  cfg.RedirectURL,
+		scopes:       cfg.Scopes,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Discover fetches the provider metadata once and caches it
+func (p *Provider) Discover() (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery Discovery
+	if err := p.getJSON(p.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider metadata: %w", err)
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("provider metadata issuer %q does not match %q", discovery.Issuer, p.issuer)
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata is incomplete")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// AuthCodeURL builds the authorization request URL with PKCE (S256)
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", p.redirectURL)
+	params.Set("scope", strings.Join(p.scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens
+func (p *Provider) Exchange(code, codeVerifier string) (*TokenResponse, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.clientID)
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	return &token, nil
+}
+
+func (p *Provider) getJSON(endpoint string, v interface{}) error {
+	resp, err := p.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", endpoint, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,201 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyRefreshInterval limits how often unknown key IDs trigger a JWKS fetch
+const keyRefreshInterval = time.Minute
+
+// IDTokenClaims are the identity claims read from a verified ID token
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type keySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// VerifyIDToken checks the signature against the provider's JWKS and
+// validates issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(raw, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	if _, err := parser.ParseWithClaims(raw, claims, p.keyFunc); err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("invalid id token: missing exp")
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid id token: missing sub")
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.clientID {
+		return nil, fmt.Errorf("invalid id token: unexpected authorized party")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	if key, ok := p.lookupKey(kid, false); ok {
+		return key, nil
+	}
+
+	// The provider may have rotated its keys since we last looked
+	if key, ok := p.lookupKey(kid, true); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) lookupKey(kid string, refresh bool) (crypto.PublicKey, bool) {
+	p.mu.Lock()
+	keys := p.keys
+	if refresh {
+		if time.Since(p.keysRefreshedAt) < keyRefreshInterval {
+			refresh = false
+		} else {
+			p.keysRefreshedAt = time.Now()
+		}
+	}
+	p.mu.Unlock()
+
+	if keys == nil || refresh {
+		fetched, err := p.fetchKeys()
+		if err != nil {
+			return nil, false
+		}
+		keys = fetched
+	}
+
+	if kid == "" && len(keys.keys) == 1 {
+		for _, key := range keys.keys {
+			return key, true
+		}
+	}
+
+	key, ok := keys.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) fetchKeys() (*keySet, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(discovery.JWKSURI, &document); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := &keySet{keys: map[string]crypto.PublicKey{}}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys.keys[jwk.Kid] = key
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
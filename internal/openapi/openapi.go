@@ -0,0 +1,334 @@
+// Package openapi builds the OpenAPI 3 document served at /openapi.json.
+//
+// Routes are documented where they are registered: Router wraps a gin route
+// group and records an Operation for every route it adds, so the document
+// cannot list a route the server does not have. Request and response schemas
+// are generated from the Go types the handlers bind and return.
+package openapi
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of the generated document
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	schemas *Generator
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// BearerAuth is the name of the security scheme for access tokens
+const BearerAuth = "bearerAuth"
+
+// New returns an empty document. Every operation added to it gets a default
+// response that refers to errorSchema, the body of every error response.
+func New(info Info, errorSchema interface{}) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	doc.schemas = NewGenerator(doc.Components.Schemas)
+	doc.Components.Responses = map[string]*Response{
+		"Error": {
+			Description: "Error",
+			Content:     jsonContent(doc.schemas.Resolve(TypeOf(errorSchema))),
+		},
+	}
+	return doc
+}
+
+// Add documents an operation. gin path parameters such as :id become
+// {id} and are declared as required path parameters.
+func (d *Document) Add(method, path string, op *Operation) {
+	path, params := convertPath(path)
+	op.Parameters = append(params, op.Parameters...)
+	for _, param := range op.Parameters {
+		param.Schema = d.schemas.Resolve(param.Schema)
+	}
+	if op.RequestBody != nil {
+		for _, media := range op.RequestBody.Content {
+			media.Schema = d.schemas.Resolve(media.Schema)
+		}
+	}
+	for _, response := range op.Responses {
+		for _, media := range response.Content {
+			media.Schema = d.schemas.Resolve(media.Schema)
+		}
+	}
+	op.Responses["default"] = &Response{Ref: "#/components/responses/Error"}
+	if op.OperationID == "" {
+		op.OperationID = operationID(method, path)
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Operation returns the documented operation for a method and an OpenAPI path
+func (d *Document) Operation(method, path string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+	return (*item)[strings.ToLower(method)]
+}
+
+// Match finds the documented path template for a request path such as
+// /api/links/0c4f..., returning "" when no template matches
+func (d *Document) Match(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var templates []string
+	for template := range d.Paths {
+		templates = append(templates, template)
+	}
+	// Literal segments win over parameters, so /links/stats beats /links/{id}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+
+	for _, template := range templates {
+		parts := strings.Split(strings.Trim(template, "/"), "/")
+		if len(parts) != len(segments) || strings.HasSuffix(template, "/") != strings.HasSuffix(path, "/") {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			if !strings.HasPrefix(part, "{") && part != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return template
+		}
+	}
+	return ""
+}
+
+// ResponseSchema returns the schema documented for a response, falling back to the
+// error schema for statuses that are not listed
+func (d *Document) ResponseSchema(op *Operation, status int) *Schema {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		response = op.Responses["default"]
+	}
+	if response.Ref != "" {
+		response = d.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]
+	}
+	media, ok := response.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	return media.Schema
+}
+
+func convertPath(path string) (string, []*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+
+		schema := String()
+		if name == "id" || strings.HasSuffix(name, "Id") {
+			schema.Format = "uuid"
+		}
+		params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives an identifier such as getApiLinksId from the route
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '.' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// Op starts documenting an operation
+func Op(summary string, tags ...string) *Operation {
+	return &Operation{Summary: summary, Tags: tags, Responses: map[string]*Response{}}
+}
+
+// Describe adds a longer description
+func (o *Operation) Describe(description string) *Operation {
+	o.Description = description
+	return o
+}
+
+// Body documents a required JSON request body of the type of v
+func (o *Operation) Body(v interface{}) *Operation {
+	o.RequestBody = &RequestBody{Required: true, Content: jsonContent(TypeOf(v))}
+	return o
+}
+
+// OptionalBody documents a JSON request body that may be omitted
+func (o *Operation) OptionalBody(v interface{}) *Operation {
+	o.RequestBody = &RequestBody{Content: jsonContent(TypeOf(v))}
+	return o
+}
+
+// Accepts documents a required request body of another media type, such as a
+// file upload; call it once per accepted type
+func (o *Operation) Accepts(mediaType string, schema *Schema) *Operation {
+	if o.RequestBody == nil {
+		o.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{}}
+	}
+	o.RequestBody.Content[mediaType] = &MediaType{Schema: schema}
+	return o
+}
+
+// Query documents an optional query parameter
+func (o *Operation) Query(name string, schema *Schema, description string) *Operation {
+	o.Parameters = append(o.Parameters, &Parameter{Name: name, In: "query", Description: description, Schema: schema})
+	return o
+}
+
+// Header documents an optional request header
+func (o *Operation) Header(name string, schema *Schema, description string) *Operation {
+	o.Parameters = append(o.Parameters, &Parameter{Name: name, In: "header", Description: description, Schema: schema})
+	return o
+}
+
+// Returns documents a JSON response. v is a *Schema or a value whose type the
+// schema is generated from; nil documents a response without a body.
+func (o *Operation) Returns(status int, description string, v interface{}) *Operation {
+	response := &Response{Description: description}
+	if v != nil {
+		response.Content = jsonContent(TypeOf(v))
+	}
+	o.Responses[strconv.Itoa(status)] = response
+	return o
+}
+
+// Produces documents another media type of a response documented with
+// Returns, such as CSV alongside JSON
+func (o *Operation) Produces(status int, mediaType string, schema *Schema) *Operation {
+	response := o.Responses[strconv.Itoa(status)]
+	if response.Content == nil {
+		response.Content = map[string]*MediaType{}
+	}
+	response.Content[mediaType] = &MediaType{Schema: schema}
+	return o
+}
+
+// Redirects documents a redirect response with a Location header
+func (o *Operation) Redirects(status int, description string) *Operation {
+	o.Responses[strconv.Itoa(status)] = &Response{
+		Description: description,
+		Headers:     map[string]*Header{"Location": {Schema: &Schema{Type: "string", Format: "uri"}}},
+	}
+	return o
+}
+
+// Secured marks the operation as requiring an access token
+func (o *Operation) Secured() *Operation {
+	o.Security = []map[string][]string{{BearerAuth: {}}}
+	return o
+}
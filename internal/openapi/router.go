@@ -0,0 +1,93 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Router registers gin routes and documents them in the same call. A Router
+// without a document only registers routes, for mounting the same routes a
+// second time without listing them twice.
+type Router struct {
+	group      *gin.RouterGroup
+	doc        *Document
+	secured    bool
+	deprecated bool
+}
+
+func NewRouter(group *gin.RouterGroup, doc *Document) *Router {
+	return &Router{group: group, doc: doc}
+}
+
+// Group returns a router for a sub-path, as gin's Group does
+func (r *Router) Group(path string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{group: r.group.Group(path, handlers...), doc: r.doc, secured: r.secured, deprecated: r.deprecated}
+}
+
+// Undocumented returns a router for the same group that registers routes
+// without documenting them
+func (r *Router) Undocumented() *Router {
+	return &Router{group: r.group, secured: r.secured, deprecated: r.deprecated}
+}
+
+// Secure adds authentication middleware to the group and marks every route
+// added to it afterwards as requiring an access token
+func (r *Router) Secure(handlers ...gin.HandlerFunc) *Router {
+	r.group.Use(handlers...)
+	r.secured = true
+	return r
+}
+
+// Deprecate adds middleware announcing the deprecation to the group and marks
+// every route added to it afterwards as deprecated
+func (r *Router) Deprecate(handlers ...gin.HandlerFunc) *Router {
+	r.group.Use(handlers...)
+	r.deprecated = true
+	return r
+}
+
+// Handle registers a route and documents it with op
+func (r *Router) Handle(method, path string, op *Operation, handlers ...gin.HandlerFunc) {
+	r.group.Handle(method, path, handlers...)
+	if r.doc == nil {
+		return
+	}
+
+	if r.secured && op.Security == nil {
+		op.Secured()
+	}
+	op.Deprecated = op.Deprecated || r.deprecated
+	r.doc.Add(method, joinPath(r.group.BasePath(), path), op)
+}
+
+func (r *Router) GET(path string, op *Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodGet, path, op, handlers...)
+}
+
+func (r *Router) POST(path string, op *Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPost, path, op, handlers...)
+}
+
+func (r *Router) PUT(path string, op *Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPut, path, op, handlers...)
+}
+
+func (r *Router) DELETE(path string, op *Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodDelete, path, op, handlers...)
+}
+
+// joinPath mirrors how gin joins a group's base path with a relative path
+func joinPath(base, path string) string {
+	if path == "" {
+		return base
+	}
+	joined := base
+	if joined == "/" {
+		joined = ""
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	return joined + path
+}
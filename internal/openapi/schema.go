@@ -0,0 +1,271 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of JSON Schema that OpenAPI 3.0 uses
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+
+	// goType is generated into a schema when the operation is added
+	goType reflect.Type
+}
+
+func String() *Schema  { return &Schema{Type: "string"} }
+func Integer() *Schema { return &Schema{Type: "integer"} }
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+
+// Object describes a JSON object with the given properties
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// ArrayOf describes a JSON array; Go nil slices encode as null
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items, Nullable: true}
+}
+
+// TypeOf returns v if it is already a schema, otherwise a placeholder for the
+// schema generated from the type of v
+func TypeOf(v interface{}) *Schema {
+	if schema, ok := v.(*Schema); ok {
+		return schema
+	}
+	return &Schema{goType: reflect.TypeOf(v)}
+}
+
+// Generator turns Go types into schemas. Named structs are stored once in
+// the components and referenced with $ref.
+type Generator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func NewGenerator(components map[string]*Schema) *Generator {
+	return &Generator{components: components, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Resolve replaces every placeholder in schema with its generated schema
+func (g *Generator) Resolve(schema *Schema) *Schema {
+	if schema == nil {
+		return nil
+	}
+	if schema.goType != nil {
+		return g.schemaOf(schema.goType)
+	}
+	for name, property := range schema.Properties {
+		schema.Properties[name] = g.Resolve(property)
+	}
+	schema.Items = g.Resolve(schema.Items)
+	schema.AdditionalProperties = g.Resolve(schema.AdditionalProperties)
+	return schema
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schemaOf(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return ArrayOf(g.schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	// interface{} and anything else accept any value
+	return &Schema{}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.structBody(t)
+	}
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// Types of the same name in different packages, such as the models of
+	// two API versions, are told apart by their package
+	name := t.Name()
+	if g.components[name] != nil {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+	}
+	for i := 2; g.components[name] != nil; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+	g.names[t] = name
+	// Reserve the name first so recursive types terminate
+	g.components[name] = &Schema{}
+	*g.components[name] = *g.structBody(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// structBody lists the JSON fields of a struct, flattening embedded structs.
+// A field is required when its binding tag says so; structs without any
+// binding tags are responses, where every field without omitempty is always
+// present.
+func (g *Generator) structBody(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	request := hasBindingTags(t)
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || (!field.IsExported() && !field.Anonymous) {
+				continue
+			}
+
+			fieldType := field.Type
+			if field.Anonymous && name == "" {
+				if fieldType.Kind() == reflect.Pointer {
+					fieldType = fieldType.Elem()
+				}
+				if fieldType.Kind() == reflect.Struct {
+					addFields(fieldType)
+					continue
+				}
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			property := g.schemaOf(fieldType)
+			binding := field.Tag.Get("binding")
+			if property.Ref == "" {
+				applyBinding(property, binding)
+			}
+			schema.Properties[name] = property
+
+			omitempty := strings.Contains(options, "omitempty")
+			if hasRule(binding, "required") || (!request && !omitempty) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+	addFields(t)
+	return schema
+}
+
+func hasBindingTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("binding") != "" {
+			return true
+		}
+		if field.Anonymous {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && hasBindingTags(embedded) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyBinding documents the validation rules of a binding tag
+func applyBinding(schema *Schema, binding string) {
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			setBound(schema, name, n)
+		}
+	}
+}
+
+func setBound(schema *Schema, rule string, n int) {
+	if schema.Type == "string" {
+		if rule != "max" {
+			schema.MinLength = &n
+		}
+		if rule != "min" {
+			schema.MaxLength = &n
+		}
+		return
+	}
+
+	f := float64(n)
+	if rule != "max" {
+		schema.Minimum = &f
+	}
+	if rule != "min" {
+		schema.Maximum = &f
+	}
+}
@@ -0,0 +1,130 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Validate checks a JSON document against a schema of this document and
+// returns every mismatch. It covers the keywords the generator emits.
+func (d *Document) Validate(schema *Schema, data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var problems []string
+	d.validate(schema, value, "$", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("response does not match the schema:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, at string, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if schema.Ref != "" {
+		target, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			fail("unknown reference %s", schema.Ref)
+			return
+		}
+		schema = target
+	}
+
+	if value == nil {
+		if schema.Type != "" && !schema.Nullable {
+			fail("is null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "":
+		// Any value
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("expected a string, got %T", value)
+			return
+		}
+		validateString(schema, s, fail)
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("expected an integer, got %v", value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("expected a number, got %T", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected a boolean, got %T", value)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected an array, got %T", value)
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i), problems)
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected an object, got %T", value)
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, property := range object {
+			if propertySchema, ok := schema.Properties[name]; ok {
+				d.validate(propertySchema, property, at+"."+name, problems)
+			} else if schema.AdditionalProperties != nil {
+				d.validate(schema.AdditionalProperties, property, at+"."+name, problems)
+			} else if schema.Properties != nil {
+				fail("undocumented property %q", name)
+			}
+		}
+	default:
+		fail("unsupported schema type %q", schema.Type)
+	}
+}
+
+func validateString(schema *Schema, s string, fail func(string, ...interface{})) {
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			found = found || s == allowed
+		}
+		if !found {
+			fail("%q is not one of %v", s, schema.Enum)
+		}
+	}
+
+	switch schema.Format {
+	case "uuid":
+		if _, err := uuid.Parse(s); err != nil {
+			fail("%q is not a UUID", s)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			fail("%q is not an RFC 3339 date-time", s)
+		}
+	}
+}
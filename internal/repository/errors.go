@@ -0,0 +1,25 @@
+package repository
+
+import "link-shortener/internal/apperror"
+
+// Errors returned when a row is missing, was already consumed or clashes with
+// an existing one
+var (
+	ErrUserNotFound           = apperror.NotFound("user_not_found", "user not found")
+	ErrLinkNotFound           = apperror.NotFound("link_not_found", "link not found")
+	ErrIdentityNotFound       = apperror.NotFound("identity_not_found", "identity not found")
+	ErrTokenNotFound          = apperror.NotFound("token_not_found", "token not found")
+	ErrWorkspaceNotFound      = apperror.NotFound("workspace_not_found", "workspace not found")
+	ErrMemberNotFound         = apperror.NotFound("member_not_found", "member not found")
+	ErrInvitationNotFound     = apperror.NotFound("invitation_not_found", "invitation not found")
+	ErrImportNotFound         = apperror.NotFound("import_not_found", "import not found")
+	ErrWebhookNotFound        = apperror.NotFound("webhook_not_found", "webhook not found")
+	ErrDeliveryNotFound       = apperror.NotFound("delivery_not_found", "delivery not found")
+	ErrIdempotencyKeyNotFound = apperror.NotFound("idempotency_key_not_found", "idempotency key not found")
+
+	ErrTokenUsed        = apperror.Conflict("token_used", "token already used")
+	ErrInvitationUsed   = apperror.Conflict("invitation_used", "invitation already used")
+	ErrRecoveryCodeUsed = apperror.Conflict("recovery_code_used", "recovery code already used")
+	ErrCodeUsed         = apperror.Conflict("code_used", "code already used")
+	ErrShortCodeTaken   = apperror.Conflict("short_code_taken", "short code already exists")
+)
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type IdempotencyRepository struct {
+	db *database.Database
+}
+
+func NewIdempotencyRepository(db *database.Database) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim takes the key for a request unless someone else holds it. An expired
+// key, or one whose request has not finished within lockTimeout, is taken
+// over. The single statement makes concurrent claims safe: at most one
+// succeeds. The returned lock ID completes or releases the claim; it is
+// uuid.Nil when the key is held.
+func (r *IdempotencyRepository) Claim(ctx context.Context, userID uuid.UUID, key, fingerprint string, ttl, lockTimeout time.Duration) (uuid.UUID, error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, lock_id, expires_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, lock_id = EXCLUDED.lock_id, status_code = NULL,
+			content_type = '', body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= NOW() - $6 * INTERVAL '1 second')
+		RETURNING lock_id
+	`
+
+	var lockID uuid.UUID
+	err := r.db.QueryRowContext(ctx, query, userID, key, fingerprint, uuid.New(), ttl.Seconds(), lockTimeout.Seconds()).Scan(&lockID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return lockID, nil
+}
+
+// Get returns the record of a key that has not expired
+func (r *IdempotencyRepository) Get(ctx context.Context, userID uuid.UUID, key string) (*models.IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, key, fingerprint, status_code, content_type, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND expires_at > NOW()
+	`
+
+	record := &models.IdempotencyRecord{}
+	err := r.db.QueryRowContext(ctx, query, userID, key).Scan(
+		&record.UserID, &record.Key, &record.Fingerprint, &record.StatusCode,
+		&record.ContentType, &record.Body, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Complete stores the response of a claimed key. It reports false when the
+// claim was lost to a retry after the lock timed out.
+func (r *IdempotencyRepository) Complete(ctx context.Context, userID uuid.UUID, key string, lockID uuid.UUID, resp *models.StoredResponse) (bool, error) {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $4, content_type = $5, body = $6
+		WHERE user_id = $1 AND key = $2 AND lock_id = $3 AND status_code IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, key, lockID, resp.StatusCode, resp.ContentType, resp.Body)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// Release forgets a claimed key so the request can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, userID uuid.UUID, key string, lockID uuid.UUID) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND lock_id = $3 AND status_code IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID, key, lockID)
+	return err
+}
+
+// DeleteExpired removes keys past their expiry
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+)
+
+type IdentityRepository struct {
+	db *database.Database
+}
+
+func NewIdentityRepository(db *database.Database) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	return r.db.QueryRowContext(ctx,
+		query,
+		identity.ID,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.CreatedAt)
+}
+
+func (r *IdentityRepository) GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	identity := &models.UserIdentity{}
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities WHERE provider = $1 AND subject = $2
+	`
+
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	return identity, nil
+}
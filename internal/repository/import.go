@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+)
+
+type ImportRepository struct {
+	db *database.Database
+}
+
+func NewImportRepository(db *database.Database) *ImportRepository {
+	return &ImportRepository{db: db}
+}
+
+func (r *ImportRepository) Create(ctx context.Context, job *models.ImportJob) error {
+	query := `
+		INSERT INTO link_imports (id, user_id, workspace_id, status, conflict_policy, total)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, job.ID, job.UserID, job.WorkspaceID, job.Status, job.ConflictPolicy, job.Total).
+		Scan(&job.CreatedAt, &job.UpdatedAt)
+}
+
+// FailStale marks a running job as failed when it has made no progress for
+// longer than stale, which happens when the server running it stopped
+func (r *ImportRepository) FailStale(ctx context.Context, id uuid.UUID, stale time.Duration, message string) error {
+	query := `
+		UPDATE link_imports
+		SET status = 'failed', error = $3, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND updated_at < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second'
+	`
+	_, err := r.db.ExecContext(ctx, query, id, stale.Seconds(), message)
+	return err
+}
+
+// Update saves the progress and status of a job
+func (r *ImportRepository) Update(ctx context.Context, job *models.ImportJob) error {
+	issues, err := json.Marshal(job.Issues)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE link_imports
+		SET status = $2, processed = $3, created = $4, renamed = $5, skipped = $6, failed = $7,
+			conflicts = $8, issues = $9, error = $10, updated_at = CURRENT_TIMESTAMP,
+			finished_at = CASE WHEN $2 = 'running' THEN NULL ELSE COALESCE(finished_at, CURRENT_TIMESTAMP) END
+		WHERE id = $1
+		RETURNING updated_at, finished_at
+	`
+	err = r.db.QueryRowContext(ctx, query, job.ID, job.Status, job.Processed, job.Created, job.Renamed,
+		job.Skipped, job.Failed, job.Conflicts, string(issues), job.Error).Scan(&job.UpdatedAt, &job.FinishedAt)
+	if err == sql.ErrNoRows {
+		return ErrImportNotFound
+	}
+	return err
+}
+
+func (r *ImportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ImportJob, error) {
+	query := `
+		SELECT id, user_id, workspace_id, status, conflict_policy, total, processed, created, renamed,
+			skipped, failed, conflicts, issues, error, created_at, updated_at, finished_at
+		FROM link_imports
+		WHERE id = $1
+	`
+
+	job := &models.ImportJob{}
+	var issues []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.WorkspaceID,
+		&job.Status,
+		&job.ConflictPolicy,
+		&job.Total,
+		&job.Processed,
+		&job.Created,
+		&job.Renamed,
+		&job.Skipped,
+		&job.Failed,
+		&job.Conflicts,
+		&issues,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.FinishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(issues, &job.Issues); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
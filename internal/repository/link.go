@@ -1,228 +1,417 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"link-shortener/internal/database"
 	"link-shortener/internal/models"
 )
 
+const linkColumns = `id, user_id, workspace_id, original_url, short_code, title, tags, clicks, is_active, expires_at, taken_down_at, takedown_reason, created_at, updated_at`
+
 type LinkRepository struct {
-	db *database.Database
+	db     *database.Database
+	outbox Outbox
 }
 
 func NewLinkRepository(db *database.Database) *LinkRepository {
 	return &LinkRepository{db: db}
 }
 
-func (r *LinkRepository) Create(link *models.Link) error {
+// SetOutbox makes every link change and recorded click queue its webhook
+// events through outbox in the same transaction
+func (r *LinkRepository) SetOutbox(outbox Outbox) {
+	r.outbox = outbox
+}
+
+// change runs fn in a transaction and, before committing, queues event for
+// the links fn returns
+func (r *LinkRepository) change(ctx context.Context, event string, fn func(tx *database.Tx) ([]*models.Link, error)) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	links, err := fn(tx)
+	if err != nil {
+		return err
+	}
+	if r.outbox != nil && len(links) > 0 {
+		if err := r.outbox.QueueLinks(ctx, tx, event, links); err != nil {
+			return fmt.Errorf("failed to queue %s events: %w", event, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *LinkRepository) Create(ctx context.Context, link *models.Link) error {
 	query := `
-		INSERT INTO links (id, user_id, original_url, short_code, title, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO links (id, user_id, workspace_id, original_url, short_code, title, tags, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING created_at, updated_at
 	`
-	
-	return r.db.DB.QueryRow(
-		query,
-		link.ID,
-		link.UserID,
-		link.OriginalURL,
-		link.ShortCode,
-		link.Title,
-		link.ExpiresAt,
-	).Scan(&link.CreatedAt, &link.UpdatedAt)
-}
-
-func (r *LinkRepository) GetByID(id uuid.UUID) (*models.Link, error) {
-	link := &models.Link{}
+
+	return r.change(ctx, models.EventLinkCreated, func(tx *database.Tx) ([]*models.Link, error) {
+		err := tx.QueryRowContext(ctx,
+			query,
+			link.ID,
+			link.UserID,
+			link.WorkspaceID,
+			link.OriginalURL,
+			link.ShortCode,
+			link.Title,
+			tagArray(link.Tags),
+			link.ExpiresAt,
+		).Scan(&link.CreatedAt, &link.UpdatedAt)
+		return []*models.Link{link}, shortCodeError(err)
+	})
+}
+
+func (r *LinkRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE id = $1`
+	return scanLink(r.db.QueryRowContext(ctx, query, id))
+}
+
+// GetByShortCode returns the link whether or not it is active or expired;
+// callers decide whether it may be followed
+func (r *LinkRepository) GetByShortCode(ctx context.Context, shortCode string) (*models.Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE short_code = $1`
+	return scanLink(r.db.QueryRowContext(ctx, query, shortCode))
+}
+
+// GetByUserID returns the user's personal links, i.e. those outside any workspace
+func (r *LinkRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Link, error) {
 	query := `
-		SELECT id, user_id, original_url, short_code, title, clicks, is_active, expires_at, created_at, updated_at
-		FROM links WHERE id = $1
+		SELECT ` + linkColumns + `
+		FROM links
+		WHERE user_id = $1 AND workspace_id IS NULL
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
 	`
-	
-	err := r.db.DB.QueryRow(query, id).Scan(
-		&link.ID,
-		&link.UserID,
-		&link.OriginalURL,
-		&link.ShortCode,
-		&link.Title,
-		&link.Clicks,
-		&link.IsActive,
-		&link.ExpiresAt,
-		&link.CreatedAt,
-		&link.UpdatedAt,
-	)
-	
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("link not found")
-		}
 		return nil, err
 	}
-	
-	return link, nil
+	defer rows.Close()
+
+	return scanLinks(rows)
 }
 
-func (r *LinkRepository) GetByShortCode(shortCode string) (*models.Link, error) {
-	link := &models.Link{}
+func (r *LinkRepository) GetByWorkspaceID(ctx context.Context, workspaceID uuid.UUID, limit, offset int) ([]*models.Link, error) {
 	query := `
-		SELECT id, user_id, original_url, short_code, title, clicks, is_active, expires_at, created_at, updated_at
-		FROM links WHERE short_code = $1 AND is_active = true
+		SELECT ` + linkColumns + `
+		FROM links
+		WHERE workspace_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
 	`
-	
-	err := r.db.DB.QueryRow(query, shortCode).Scan(
-		&link.ID,
-		&link.UserID,
-		&link.OriginalURL,
-		&link.ShortCode,
-		&link.Title,
-		&link.Clicks,
-		&link.IsActive,
-		&link.ExpiresAt,
-		&link.CreatedAt,
-		&link.UpdatedAt,
-	)
-	
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID, limit, offset)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("link not found")
-		}
 		return nil, err
 	}
-	
-	// Check if link is expired
-	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
-		return nil, fmt.Errorf("link has expired")
-	}
-	
-	return link, nil
+	defer rows.Close()
+
+	return scanLinks(rows)
 }
 
-func (r *LinkRepository) GetByUserID(userID uuid.UUID, limit, offset int) ([]*models.Link, error) {
+// ReassignCreator hands the workspace links created by one member over to another
+func (r *LinkRepository) ReassignCreator(ctx context.Context, workspaceID, fromUserID, toUserID uuid.UUID) error {
+	query := `UPDATE links SET user_id = $3, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = $1 AND user_id = $2`
+	_, err := r.db.ExecContext(ctx, query, workspaceID, fromUserID, toUserID)
+	return err
+}
+
+// Search returns links across all users whose code, title or URL contains
+// search, optionally restricted to one owner
+func (r *LinkRepository) Search(ctx context.Context, search string, userID *uuid.UUID, limit, offset int) ([]*models.Link, error) {
 	query := `
-		SELECT id, user_id, original_url, short_code, title, clicks, is_active, expires_at, created_at, updated_at
-		FROM links 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3
+		SELECT ` + linkColumns + `
+		FROM links
+		WHERE ($1 = '' OR short_code ILIKE '%' || $1 || '%' OR title ILIKE '%' || $1 || '%' OR original_url ILIKE '%' || $1 || '%')
+			AND ($2::uuid IS NULL OR user_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
 	`
-	
-	rows, err := r.db.DB.Query(query, userID, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, search, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	
-	var links []*models.Link
-	for rows.Next() {
-		link := &models.Link{}
-		err := rows.Scan(
-			&link.ID,
-			&link.UserID,
-			&link.OriginalURL,
-			&link.ShortCode,
-			&link.Title,
-			&link.Clicks,
-			&link.IsActive,
-			&link.ExpiresAt,
-			&link.CreatedAt,
-			&link.UpdatedAt,
-		)
+
+	return scanLinks(rows)
+}
+
+// Takedown deactivates a link on behalf of an administrator. Owners cannot
+// reactivate a link that was taken down.
+func (r *LinkRepository) Takedown(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `
+		UPDATE links
+		SET is_active = false, taken_down_at = CURRENT_TIMESTAMP, takedown_reason = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	return r.execOne(ctx, query, id, reason)
+}
+
+// RestoreTakedown lifts a takedown and reactivates the link
+func (r *LinkRepository) RestoreTakedown(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE links
+		SET is_active = true, taken_down_at = NULL, takedown_reason = '', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND taken_down_at IS NOT NULL
+	`
+	return r.execOne(ctx, query, id)
+}
+
+// GetSystemStats fills in the link counters of stats
+func (r *LinkRepository) GetSystemStats(ctx context.Context, stats *models.SystemStats) error {
+	query := `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE is_active = true),
+			COUNT(*) FILTER (WHERE expires_at IS NOT NULL AND expires_at < NOW()),
+			COUNT(*) FILTER (WHERE taken_down_at IS NOT NULL),
+			COALESCE(SUM(clicks), 0)
+		FROM links
+	`
+	return r.db.QueryRowContext(ctx, query).Scan(
+		&stats.TotalLinks,
+		&stats.ActiveLinks,
+		&stats.ExpiredLinks,
+		&stats.TakenDownLinks,
+		&stats.TotalClicks,
+	)
+}
+
+// Update saves the editable fields of a link. Callers are responsible for
+// checking that the user may edit it.
+func (r *LinkRepository) Update(ctx context.Context, link *models.Link) error {
+	query := `
+		UPDATE links
+		SET original_url = $2, short_code = $3, title = $4, is_active = $5, expires_at = $6, tags = $7, updated_at = CURRENT_TIMESTAMP,
+			expiry_notified_at = CASE WHEN expires_at IS DISTINCT FROM $6 THEN NULL ELSE expiry_notified_at END
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	return r.change(ctx, models.EventLinkUpdated, func(tx *database.Tx) ([]*models.Link, error) {
+		err := tx.QueryRowContext(ctx,
+			query,
+			link.ID,
+			link.OriginalURL,
+			link.ShortCode,
+			link.Title,
+			link.IsActive,
+			link.ExpiresAt,
+			tagArray(link.Tags),
+		).Scan(&link.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return nil, ErrLinkNotFound
+		}
+		return []*models.Link{link}, shortCodeError(err)
+	})
+}
+
+// shortCodeError returns ErrShortCodeTaken for a violation of the unique short
+// code, which a concurrent request can cause after the caller checked the code
+func shortCodeError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "links_short_code_key" {
+		return ErrShortCodeTaken.Wrap(err)
+	}
+	return err
+}
+
+// Delete removes a link. Callers are responsible for checking that the user
+// may delete it.
+func (r *LinkRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM links WHERE id = $1 RETURNING ` + linkColumns
+	return r.change(ctx, models.EventLinkDeleted, func(tx *database.Tx) ([]*models.Link, error) {
+		link, err := scanLink(tx.QueryRowContext(ctx, query, id))
 		if err != nil {
 			return nil, err
 		}
-		links = append(links, link)
-	}
-	
-	return links, nil
+		return []*models.Link{link}, nil
+	})
 }
 
-func (r *LinkRepository) Update(link *models.Link) error {
+// RecordClick counts a click on its link and stores the event, setting its
+// ID. Clicks on links deleted in the meantime are dropped with
+// ErrLinkNotFound.
+func (r *LinkRepository) RecordClick(ctx context.Context, click *models.ClickEvent) error {
 	query := `
-		UPDATE links 
-		SET original_url = $3, short_code = $4, title = $5, is_active = $6, expires_at = $7, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1 AND user_id = $2
-		RETURNING updated_at
+		WITH link AS (
+			UPDATE links SET clicks = clicks + 1 WHERE id = $1 RETURNING id
+		)
+		INSERT INTO click_events (link_id, clicked_at, referrer, user_agent)
+		SELECT id, $2, $3, $4 FROM link
+		RETURNING id
 	`
-	
-	return r.db.DB.QueryRow(
-		query,
-		link.ID,
-		link.UserID,
-		link.OriginalURL,
-		link.ShortCode,
-		link.Title,
-		link.IsActive,
-		link.ExpiresAt,
-	).Scan(&link.UpdatedAt)
-}
-
-func (r *LinkRepository) Delete(id, userID uuid.UUID) error {
-	query := `DELETE FROM links WHERE id = $1 AND user_id = $2`
-	result, err := r.db.DB.Exec(query, id, userID)
+	tx, err := r.db.BeginTx(ctx)
 	if err != nil {
 		return err
 	}
-	
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, click.LinkID, click.ClickedAt, click.Referrer, click.UserAgent).Scan(&click.ID)
+	if err == sql.ErrNoRows {
+		return ErrLinkNotFound
+	}
 	if err != nil {
 		return err
 	}
-	
-	if rowsAffected == 0 {
-		return fmt.Errorf("link not found")
+	if r.outbox != nil {
+		if err := r.outbox.QueueClick(ctx, tx, click); err != nil {
+			return fmt.Errorf("failed to queue %s event: %w", models.EventLinkClicked, err)
+		}
 	}
-	
-	return nil
+	return tx.Commit()
 }
 
-func (r *LinkRepository) IncrementClicks(id uuid.UUID) error {
-	query := `UPDATE links SET clicks = clicks + 1 WHERE id = $1`
-	_, err := r.db.DB.Exec(query, id)
-	return err
+// DeleteClicksBefore removes up to limit click events older than retention
+// and returns how many it removed. links.clicks keeps counting them.
+func (r *LinkRepository) DeleteClicksBefore(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	query := `
+		DELETE FROM click_events
+		WHERE id IN (
+			SELECT id FROM click_events
+			WHERE clicked_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+			LIMIT $2
+		)
+	`
+	result, err := r.db.ExecContext(ctx, query, retention.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (r *LinkRepository) ShortCodeExists(shortCode string) (bool, error) {
+func (r *LinkRepository) ShortCodeExists(ctx context.Context, shortCode string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM links WHERE short_code = $1)`
-	
-	err := r.db.DB.QueryRow(query, shortCode).Scan(&exists)
+
+	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&exists)
 	return exists, err
 }
 
-func (r *LinkRepository) GetStats(userID uuid.UUID) (*models.LinkStats, error) {
+// GetStats returns statistics for the user's personal links
+func (r *LinkRepository) GetStats(ctx context.Context, userID uuid.UUID) (*models.LinkStats, error) {
+	return r.getStats(ctx, `user_id = $1 AND workspace_id IS NULL`, userID)
+}
+
+// GetWorkspaceStats returns statistics for the links of a workspace
+func (r *LinkRepository) GetWorkspaceStats(ctx context.Context, workspaceID uuid.UUID) (*models.LinkStats, error) {
+	return r.getStats(ctx, `workspace_id = $1`, workspaceID)
+}
+
+func (r *LinkRepository) getStats(ctx context.Context, filter string, arg interface{}) (*models.LinkStats, error) {
 	stats := &models.LinkStats{}
-	
+
 	// Total links
-	query := `SELECT COUNT(*) FROM links WHERE user_id = $1`
-	err := r.db.DB.QueryRow(query, userID).Scan(&stats.TotalLinks)
+	query := `SELECT COUNT(*) FROM links WHERE ` + filter
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&stats.TotalLinks)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Total clicks
-	query = `SELECT COALESCE(SUM(clicks), 0) FROM links WHERE user_id = $1`
-	err = r.db.DB.QueryRow(query, userID).Scan(&stats.TotalClicks)
+	query = `SELECT COALESCE(SUM(clicks), 0) FROM links WHERE ` + filter
+	err = r.db.QueryRowContext(ctx, query, arg).Scan(&stats.TotalClicks)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Active links
-	query = `SELECT COUNT(*) FROM links WHERE user_id = $1 AND is_active = true`
-	err = r.db.DB.QueryRow(query, userID).Scan(&stats.ActiveLinks)
+	query = `SELECT COUNT(*) FROM links WHERE ` + filter + ` AND is_active = true`
+	err = r.db.QueryRowContext(ctx, query, arg).Scan(&stats.ActiveLinks)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Expired links
-	query = `SELECT COUNT(*) FROM links WHERE user_id = $1 AND expires_at IS NOT NULL AND expires_at < NOW()`
-	err = r.db.DB.QueryRow(query, userID).Scan(&stats.ExpiredLinks)
+	query = `SELECT COUNT(*) FROM links WHERE ` + filter + ` AND expires_at IS NOT NULL AND expires_at < NOW()`
+	err = r.db.QueryRowContext(ctx, query, arg).Scan(&stats.ExpiredLinks)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return stats, nil
 }
+
+func scanLink(row rowScanner) (*models.Link, error) {
+	link := &models.Link{}
+	err := row.Scan(
+		&link.ID,
+		&link.UserID,
+		&link.WorkspaceID,
+		&link.OriginalURL,
+		&link.ShortCode,
+		&link.Title,
+		pq.Array(&link.Tags),
+		&link.Clicks,
+		&link.IsActive,
+		&link.ExpiresAt,
+		&link.TakenDownAt,
+		&link.TakedownReason,
+		&link.CreatedAt,
+		&link.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLinkNotFound
+		}
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func scanLinks(rows *database.Rows) ([]*models.Link, error) {
+	var links []*models.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// tagArray stores missing tags as an empty array rather than NULL
+func tagArray(tags []string) interface{} {
+	if tags == nil {
+		tags = []string{}
+	}
+	return pq.Array(tags)
+}
+
+// execOne runs a statement that is expected to touch exactly one link
+func (r *LinkRepository) execOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+)
+
+// linkInsertBatchSize bounds the rows of one INSERT statement, well below the
+// 65535 parameters PostgreSQL accepts
+const linkInsertBatchSize = 500
+
+// linkQuerier is satisfied by both *database.Database and *database.Tx
+type linkQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*database.Rows, error)
+}
+
+// CreatedLinks reports the outcome of CreateMany by link ID: Created holds
+// the links committed and Conflicts those skipped because their short code
+// was taken
+type CreatedLinks struct {
+	Created   map[uuid.UUID]bool
+	Conflicts map[uuid.UUID]bool
+}
+
+// CreateMany inserts links in batches. Each batch is committed with its
+// link.created events; when atomic is set everything is one transaction and
+// nothing is inserted unless every link is. The result is never nil: after an
+// error it still lists the batches committed before it. Inserted links get
+// their timestamps set, which does not mean they were committed.
+func (r *LinkRepository) CreateMany(ctx context.Context, links []*models.Link, atomic bool) (*CreatedLinks, error) {
+	result := &CreatedLinks{Created: map[uuid.UUID]bool{}, Conflicts: map[uuid.UUID]bool{}}
+	if !atomic {
+		for start := 0; start < len(links); start += linkInsertBatchSize {
+			batch := links[start:min(start+linkInsertBatchSize, len(links))]
+			conflicts := map[uuid.UUID]bool{}
+			var inserted []*models.Link
+			err := r.change(ctx, models.EventLinkCreated, func(tx *database.Tx) ([]*models.Link, error) {
+				var err error
+				inserted, err = insertLinkBatch(ctx, tx, batch, conflicts)
+				return inserted, err
+			})
+			if err != nil {
+				return result, err
+			}
+			result.add(inserted, conflicts)
+		}
+		return result, nil
+	}
+
+	errConflicts := errors.New("short codes taken")
+	conflicts := map[uuid.UUID]bool{}
+	var inserted []*models.Link
+	err := r.change(ctx, models.EventLinkCreated, func(tx *database.Tx) ([]*models.Link, error) {
+		for start := 0; start < len(links); start += linkInsertBatchSize {
+			batch, err := insertLinkBatch(ctx, tx, links[start:min(start+linkInsertBatchSize, len(links))], conflicts)
+			if err != nil {
+				return nil, err
+			}
+			inserted = append(inserted, batch...)
+		}
+		if len(conflicts) > 0 {
+			// Roll back
+			return nil, errConflicts
+		}
+		return inserted, nil
+	})
+	if errors.Is(err, errConflicts) {
+		result.add(nil, conflicts)
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.add(inserted, nil)
+	return result, nil
+}
+
+// add records a committed batch
+func (c *CreatedLinks) add(inserted []*models.Link, conflicts map[uuid.UUID]bool) {
+	for _, link := range inserted {
+		c.Created[link.ID] = true
+	}
+	for id := range conflicts {
+		c.Conflicts[id] = true
+	}
+}
+
+// LinkTx creates links in one transaction that is either committed with
+// their link.created events or rolled back as a whole
+type LinkTx struct {
+	repo     *LinkRepository
+	tx       *database.Tx
+	inserted []*models.Link
+}
+
+// BeginCreate starts a LinkTx; call Rollback when done, even after Commit
+func (r *LinkRepository) BeginCreate(ctx context.Context) (*LinkTx, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkTx{repo: r, tx: tx}, nil
+}
+
+// CreateMany inserts links like LinkRepository.CreateMany without atomic,
+// as part of the transaction; the links reported created are committed with
+// it
+func (t *LinkTx) CreateMany(ctx context.Context, links []*models.Link) (*CreatedLinks, error) {
+	result := &CreatedLinks{Created: map[uuid.UUID]bool{}, Conflicts: map[uuid.UUID]bool{}}
+	for start := 0; start < len(links); start += linkInsertBatchSize {
+		inserted, err := insertLinkBatch(ctx, t.tx, links[start:min(start+linkInsertBatchSize, len(links))], result.Conflicts)
+		if err != nil {
+			return nil, err
+		}
+		result.add(inserted, nil)
+		t.inserted = append(t.inserted, inserted...)
+	}
+	return result, nil
+}
+
+// Commit queues link.created for the links inserted and commits
+func (t *LinkTx) Commit(ctx context.Context) error {
+	if t.repo.outbox != nil && len(t.inserted) > 0 {
+		if err := t.repo.outbox.QueueLinks(ctx, t.tx, models.EventLinkCreated, t.inserted); err != nil {
+			return fmt.Errorf("failed to queue %s events: %w", models.EventLinkCreated, err)
+		}
+	}
+	return t.tx.Commit()
+}
+
+// Rollback discards the links inserted unless Commit succeeded
+func (t *LinkTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// insertLinkBatch inserts one batch, adds the IDs of links whose short code
+// was taken to conflicts and returns the links it inserted
+func insertLinkBatch(ctx context.Context, q linkQuerier, batch []*models.Link, conflicts map[uuid.UUID]bool) ([]*models.Link, error) {
+	values := make([]string, len(batch))
+	args := make([]interface{}, 0, len(batch)*8)
+	pending := make(map[uuid.UUID]*models.Link, len(batch))
+	for i, link := range batch {
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		args = append(args, link.ID, link.UserID, link.WorkspaceID, link.OriginalURL, link.ShortCode, link.Title, tagArray(link.Tags), link.ExpiresAt)
+		pending[link.ID] = link
+	}
+
+	query := `
+		INSERT INTO links (id, user_id, workspace_id, original_url, short_code, title, tags, expires_at)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (short_code) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, shortCodeError(err)
+	}
+	defer rows.Close()
+
+	var inserted []*models.Link
+	for rows.Next() {
+		var id uuid.UUID
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&id, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		pending[id].CreatedAt, pending[id].UpdatedAt = createdAt, updatedAt
+		inserted = append(inserted, pending[id])
+		delete(pending, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, shortCodeError(err)
+	}
+
+	for id := range pending {
+		conflicts[id] = true
+	}
+	return inserted, nil
+}
+
+// ExistingShortCodes returns which of codes are taken
+func (r *LinkRepository) ExistingShortCodes(ctx context.Context, codes []string) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT short_code FROM links WHERE short_code = ANY($1)`, pq.Array(codes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		existing[code] = true
+	}
+	return existing, rows.Err()
+}
+
+// GetByIDs returns the links among ids that exist, in no particular order
+func (r *LinkRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE id = ANY($1::uuid[])`
+
+	rows, err := r.db.QueryContext(ctx, query, uuidArray(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanLinks(rows)
+}
+
+// FindIDs returns the IDs of up to limit links matching filter, newest
+// first. Without a workspace the filter covers the user's personal links.
+func (r *LinkRepository) FindIDs(ctx context.Context, userID uuid.UUID, filter *models.LinkFilter, limit int) ([]uuid.UUID, error) {
+	scope, owner := `user_id = $1 AND workspace_id IS NULL`, userID
+	if filter.WorkspaceID != nil {
+		scope, owner = `workspace_id = $1`, *filter.WorkspaceID
+	}
+
+	query := `
+		SELECT id
+		FROM links
+		WHERE ` + scope + `
+			AND ($2 = '' OR short_code ILIKE '%' || $2 || '%' OR title ILIKE '%' || $2 || '%' OR original_url ILIKE '%' || $2 || '%')
+			AND ($3::boolean IS NULL OR is_active = $3)
+			AND ($4::timestamp IS NULL OR created_at >= $4)
+			AND ($5::timestamp IS NULL OR created_at < $5)
+		ORDER BY created_at DESC
+		LIMIT $6
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, owner, filter.Search, filter.IsActive, filter.CreatedAfter, filter.CreatedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetActiveMany activates or deactivates links and returns the IDs it
+// changed. Links taken down by an administrator are never activated. Callers
+// are responsible for checking that the user may edit them.
+func (r *LinkRepository) SetActiveMany(ctx context.Context, ids []uuid.UUID, active, atomic bool) (map[uuid.UUID]bool, error) {
+	query := `
+		UPDATE links
+		SET is_active = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ANY($1::uuid[]) AND (NOT $2 OR taken_down_at IS NULL)
+		RETURNING ` + linkColumns
+	return r.changeMany(ctx, models.EventLinkUpdated, ids, atomic, query, active)
+}
+
+// DeleteMany removes links and returns the IDs it removed. Callers are
+// responsible for checking that the user may delete them.
+func (r *LinkRepository) DeleteMany(ctx context.Context, ids []uuid.UUID, atomic bool) (map[uuid.UUID]bool, error) {
+	query := `DELETE FROM links WHERE id = ANY($1::uuid[]) RETURNING ` + linkColumns
+	return r.changeMany(ctx, models.EventLinkDeleted, ids, atomic, query)
+}
+
+// changeMany runs a statement over ids that returns the links it touched and
+// queues event for them. When atomic is set and any link was left untouched,
+// nothing is changed and ErrLinkNotFound is returned.
+func (r *LinkRepository) changeMany(ctx context.Context, event string, ids []uuid.UUID, atomic bool, query string, args ...interface{}) (map[uuid.UUID]bool, error) {
+	args = append([]interface{}{uuidArray(ids)}, args...)
+	changed := map[uuid.UUID]bool{}
+	err := r.change(ctx, event, func(tx *database.Tx) ([]*models.Link, error) {
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		links, err := scanLinks(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		if atomic && len(links) != len(ids) {
+			return nil, ErrLinkNotFound
+		}
+		for _, link := range links {
+			changed[link.ID] = true
+		}
+		return links, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+func uuidArray(ids []uuid.UUID) interface{} {
+	values := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/models"
+)
+
+// EachLink calls fn with every link matching filter, oldest first, reading
+// one row at a time so memory use does not grow with the number of links.
+// Iteration stops at the first error fn returns.
+func (r *LinkRepository) EachLink(ctx context.Context, userID uuid.UUID, filter *models.ExportFilter, fn func(*models.Link) error) error {
+	scope, owner := exportScope(userID, filter)
+	query := `
+		SELECT ` + linkColumns + `
+		FROM links
+		WHERE ` + scope + `
+			AND ($2::timestamp IS NULL OR created_at >= $2)
+			AND ($3::timestamp IS NULL OR created_at < $3)
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.StreamContext(ctx, query, owner, filter.From, filter.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachClick calls fn with every click event on the links matching filter,
+// oldest first, one row at a time like EachLink
+func (r *LinkRepository) EachClick(ctx context.Context, userID uuid.UUID, filter *models.ExportFilter, fn func(*models.ClickEvent) error) error {
+	scope, owner := exportScope(userID, filter)
+	query := `
+		SELECT e.id, e.link_id, l.short_code, e.clicked_at, e.referrer, e.user_agent
+		FROM click_events e
+		JOIN links l ON l.id = e.link_id
+		WHERE ` + scope + `
+			AND ($2::uuid IS NULL OR e.link_id = $2)
+			AND ($3::timestamp IS NULL OR e.clicked_at >= $3)
+			AND ($4::timestamp IS NULL OR e.clicked_at < $4)
+		ORDER BY e.clicked_at, e.id
+	`
+
+	rows, err := r.db.StreamContext(ctx, query, owner, filter.LinkID, filter.From, filter.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var click models.ClickEvent
+	for rows.Next() {
+		if err := rows.Scan(&click.ID, &click.LinkID, &click.ShortCode, &click.ClickedAt, &click.Referrer, &click.UserAgent); err != nil {
+			return err
+		}
+		if err := fn(&click); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// exportScope restricts an export to a workspace or the user's personal links
+func exportScope(userID uuid.UUID, filter *models.ExportFilter) (string, uuid.UUID) {
+	if filter.WorkspaceID != nil {
+		return `workspace_id = $1`, *filter.WorkspaceID
+	}
+	return `user_id = $1 AND workspace_id IS NULL`, userID
+}
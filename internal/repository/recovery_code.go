@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type RecoveryCodeRepository struct {
+	db *database.Database
+}
+
+func NewRecoveryCodeRepository(db *database.Database) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+// ReplaceForUser deletes the user's existing codes and stores the new hashes
+func (r *RecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		query := `INSERT INTO user_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, uuid.New(), userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *RecoveryCodeRepository) GetUnused(ctx context.Context, userID uuid.UUID) ([]*models.RecoveryCode, error) {
+	query := `
+		SELECT id, user_id, code_hash, used_at, created_at
+		FROM user_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []*models.RecoveryCode
+	for rows.Next() {
+		code := &models.RecoveryCode{}
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash, &code.UsedAt, &code.CreatedAt); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+func (r *RecoveryCodeRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecoveryCodeUsed
+	}
+
+	return nil
+}
+
+func (r *RecoveryCodeRepository) DeleteForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+)
+
+type SigningKeyRepository struct {
+	db *database.Database
+}
+
+func NewSigningKeyRepository(db *database.Database) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// Rotate stores a new key and retires every other key in one transaction
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *models.SigningKey) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO jwt_signing_keys (id, algorithm, private_key)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`
+	if err := tx.QueryRowContext(ctx, query, key.ID, key.Algorithm, key.PrivateKey).Scan(&key.CreatedAt); err != nil {
+		return err
+	}
+
+	query = `UPDATE jwt_signing_keys SET retired_at = CURRENT_TIMESTAMP WHERE id <> $1 AND retired_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, key.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListUsable returns the active keys and those retired after the cutoff
+func (r *SigningKeyRepository) ListUsable(ctx context.Context, retiredAfter time.Time) ([]*models.SigningKey, error) {
+	query := `
+		SELECT id, algorithm, private_key, created_at, retired_at
+		FROM jwt_signing_keys
+		WHERE retired_at IS NULL OR retired_at > $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, retiredAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.SigningKey{}
+	for rows.Next() {
+		key := &models.SigningKey{}
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt, &key.RetiredAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteRetiredBefore removes keys that no longer verify any live token
+func (r *SigningKeyRepository) DeleteRetiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM jwt_signing_keys WHERE retired_at IS NOT NULL AND retired_at <= $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type TokenRepository struct {
+	db *database.Database
+}
+
+func NewTokenRepository(db *database.Database) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, payload, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`
+
+	return r.db.QueryRowContext(ctx,
+		query,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.Payload,
+		token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+}
+
+func (r *TokenRepository) GetByHash(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	token := &models.UserToken{}
+	query := `
+		SELECT id, user_id, purpose, token_hash, payload, expires_at, used_at, created_at
+		FROM user_tokens WHERE purpose = $1 AND token_hash = $2
+	`
+
+	err := r.db.QueryRowContext(ctx, query, purpose, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.Payload,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed consumes the token. It fails if the token was already used, so
+// two concurrent requests cannot both redeem the same token.
+func (r *TokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrTokenUsed
+	}
+
+	return nil
+}
+
+// InvalidateForUser marks every outstanding token of a purpose as used
+func (r *TokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error {
+	query := `
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+	_, err := r.db.ExecContext(ctx, query, userID, purpose)
+	return err
+}
@@ -1,15 +1,20 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"time"
 
 	"link-shortener/internal/database"
 	"link-shortener/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const userColumns = `id, username, email, password_hash, email_verified_at, totp_secret, totp_enabled, totp_last_step, session_version, deletion_scheduled_at, role, permissions, disabled_at, created_at, updated_at`
+
 type UserRepository struct {
 	db *database.Database
 }
@@ -18,114 +23,318 @@ func NewUserRepository(db *database.Database) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, username, email, password_hash)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users (id, username, email, password_hash, role)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING created_at, updated_at
 	`
 
-	return r.db.DB.QueryRow(
+	return r.db.QueryRowContext(ctx,
 		query,
 		user.ID,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
+		user.Role,
 	).Scan(&user.CreatedAt, &user.UpdatedAt)
 }
 
-func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
-	user := &models.User{}
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, email))
+}
+
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, username))
+}
+
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
-		FROM users WHERE id = $1
+		UPDATE users 
+		SET username = $2, email = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
 	`
 
-	err := r.db.DB.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	return r.db.QueryRowContext(ctx, query, user.ID, user.Username, user.Email).Scan(&user.UpdatedAt)
+}
 
+// UpdatePassword sets a new password hash and revokes all existing sessions
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) (int, error) {
+	var sessionVersion int
+	query := `
+		UPDATE users
+		SET password_hash = $2, session_version = session_version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING session_version
+	`
+
+	err := r.db.QueryRowContext(ctx, query, id, passwordHash).Scan(&sessionVersion)
+	if err == sql.ErrNoRows {
+		return 0, ErrUserNotFound
+	}
+	return sessionVersion, err
+}
+
+// ChangeEmail switches the user to a new, already verified address
+func (r *UserRepository) ChangeEmail(ctx context.Context, id uuid.UUID, email string) error {
+	query := `
+		UPDATE users
+		SET email = $2, email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	return r.execOne(ctx, query, id, email)
+}
+
+// RevokeSessions invalidates every token issued to the user so far
+func (r *UserRepository) RevokeSessions(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET session_version = session_version + 1 WHERE id = $1`
+	return r.execOne(ctx, query, id)
+}
+
+// ScheduleDeletion marks the account for deletion at the given time, or
+// cancels a pending deletion when at is nil
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, at *time.Time) error {
+	query := `UPDATE users SET deletion_scheduled_at = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execOne(ctx, query, id, at)
+}
+
+// departing matches accounts under the given alias whose deletion grace
+// period has passed
+func departing(alias string) string {
+	return "(" + alias + ".deletion_scheduled_at IS NOT NULL AND " + alias + ".deletion_scheduled_at <= $1)"
+}
+
+// DeleteScheduled removes accounts whose grace period has passed. Workspaces
+// they own are handed to the highest-ranked remaining member, links they
+// created in shared workspaces are reassigned to the workspace owner, and
+// workspaces left without members are removed. It returns the number of
+// accounts removed and the short codes of the links removed with them.
+func (r *UserRepository) DeleteScheduled(ctx context.Context, now time.Time) (int64, []string, error) {
+	tx, err := r.db.BeginTx(ctx)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`UPDATE workspace_members m SET role = 'owner'
+		FROM (
+			SELECT DISTINCT ON (wm.workspace_id) wm.workspace_id, wm.user_id
+			FROM workspace_members wm
+			JOIN users u ON u.id = wm.user_id
+			WHERE NOT ` + departing("u") + `
+			AND wm.workspace_id IN (
+				SELECT o.workspace_id FROM workspace_members o
+				JOIN users ou ON ou.id = o.user_id
+				WHERE o.role = 'owner' AND ` + departing("ou") + `
+			)
+			ORDER BY wm.workspace_id, CASE wm.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, wm.created_at
+		) successor
+		WHERE m.workspace_id = successor.workspace_id AND m.user_id = successor.user_id`,
+		`UPDATE links l SET user_id = o.user_id, updated_at = CURRENT_TIMESTAMP
+		FROM workspace_members o, users creator, users ow
+		WHERE l.workspace_id = o.workspace_id AND o.role = 'owner'
+		AND ow.id = o.user_id AND NOT ` + departing("ow") + `
+		AND creator.id = l.user_id AND ` + departing("creator"),
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, now); err != nil {
+			return 0, nil, err
 		}
+	}
+
+	codes, err := departingLinkCodes(ctx, tx, now)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workspaces w WHERE NOT `+staffed("w"), now); err != nil {
+		return 0, nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users u WHERE `+departing("u"), now)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return deleted, codes, tx.Commit()
+}
+
+// staffed matches workspaces under the given alias that keep a member who is
+// not departing
+func staffed(alias string) string {
+	return `EXISTS (
+		SELECT 1 FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = ` + alias + `.id AND NOT ` + departing("u") + `
+	)`
+}
+
+// departingLinkCodes returns the short codes of the links DeleteScheduled is
+// about to remove: those still created by a departing account and those of
+// workspaces left without members
+func departingLinkCodes(ctx context.Context, tx *database.Tx, now time.Time) ([]string, error) {
+	query := `
+		SELECT l.short_code
+		FROM links l
+		JOIN users creator ON creator.id = l.user_id
+		LEFT JOIN workspaces w ON w.id = l.workspace_id
+		WHERE ` + departing("creator") + ` OR (w.id IS NOT NULL AND NOT ` + staffed("w") + `)
+	`
+	rows, err := tx.QueryContext(ctx, query, now)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return user, nil
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
 }
 
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
-	user := &models.User{}
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
-		FROM users WHERE email = $1
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
 	`
+	return r.execOne(ctx, query, id)
+}
 
-	err := r.db.DB.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// SetPendingTOTPSecret stores a secret that is not active until EnableTOTP
+func (r *UserRepository) SetPendingTOTPSecret(ctx context.Context, id uuid.UUID, secret string) error {
+	query := `UPDATE users SET totp_secret = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND totp_enabled = false`
+	return r.execOne(ctx, query, id, secret)
+}
 
+func (r *UserRepository) EnableTOTP(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET totp_enabled = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND totp_secret <> ''`
+	return r.execOne(ctx, query, id)
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET totp_secret = '', totp_enabled = false, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	return r.execOne(ctx, query, id)
+}
+
+// ConsumeTOTPStep records the last accepted TOTP time step. It fails if the
+// step is not newer than the previous one, which stops a code from being
+// replayed within its validity window.
+func (r *UserRepository) ConsumeTOTPStep(ctx context.Context, id uuid.UUID, step int64) error {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`
+	err := r.execOne(ctx, query, id, step)
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrCodeUsed
+	}
+	return err
+}
+
+// List returns users whose username or email contains search, newest first
+func (r *UserRepository) List(ctx context.Context, search string, limit, offset int) ([]*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE $1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, search, limit, offset)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
 		return nil, err
 	}
+	defer rows.Close()
 
-	return user, nil
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
 }
 
-func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
-	user := &models.User{}
+// SetRole changes the user's role and extra permissions. Existing tokens carry
+// the old role, so they are revoked.
+func (r *UserRepository) SetRole(ctx context.Context, id uuid.UUID, role string, permissions []string) error {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
-		FROM users WHERE username = $1
+		UPDATE users
+		SET role = $2, permissions = $3, session_version = session_version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
 	`
+	return r.execOne(ctx, query, id, role, pq.Array(permissions))
+}
 
-	err := r.db.DB.QueryRow(query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+// PromoteByEmail gives the admin role to the user with the given email, if any
+func (r *UserRepository) PromoteByEmail(ctx context.Context, email string) (bool, error) {
+	query := `UPDATE users SET role = $2, updated_at = CURRENT_TIMESTAMP WHERE email = $1 AND role <> $2`
+	result, err := r.db.ExecContext(ctx, query, email, models.RoleAdmin)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, err
+		return false, err
 	}
 
-	return user, nil
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
 }
 
-func (r *UserRepository) Update(user *models.User) error {
+// SetDisabled disables the account (revoking its sessions) or re-enables it when at is nil
+func (r *UserRepository) SetDisabled(ctx context.Context, id uuid.UUID, at *time.Time) error {
 	query := `
-		UPDATE users 
-		SET username = $2, email = $3, updated_at = CURRENT_TIMESTAMP
+		UPDATE users
+		SET disabled_at = $2, session_version = session_version + 1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
-		RETURNING updated_at
 	`
+	return r.execOne(ctx, query, id, at)
+}
 
-	return r.db.DB.QueryRow(query, user.ID, user.Username, user.Email).Scan(&user.UpdatedAt)
+// GetSystemStats fills in the user counters of stats
+func (r *UserRepository) GetSystemStats(ctx context.Context, stats *models.SystemStats) error {
+	query := `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE role = $1),
+			COUNT(*) FILTER (WHERE disabled_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE email_verified_at IS NOT NULL)
+		FROM users
+	`
+	return r.db.QueryRowContext(ctx, query, models.RoleAdmin).Scan(
+		&stats.TotalUsers,
+		&stats.AdminUsers,
+		&stats.DisabledUsers,
+		&stats.VerifiedUsers,
+	)
 }
 
-func (r *UserRepository) Delete(id uuid.UUID) error {
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.db.DB.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -136,24 +345,78 @@ func (r *UserRepository) Delete(id uuid.UUID) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
-func (r *UserRepository) EmailExists(email string) (bool, error) {
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
 
-	err := r.db.DB.QueryRow(query, email).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&exists)
 	return exists, err
 }
 
-func (r *UserRepository) UsernameExists(username string) (bool, error) {
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`
 
-	err := r.db.DB.QueryRow(query, username).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, username).Scan(&exists)
 	return exists, err
 }
+
+// execOne runs a statement that is expected to touch exactly one user
+func (r *UserRepository) execOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.EmailVerifiedAt,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.SessionVersion,
+		&user.DeletionAt,
+		&user.Role,
+		pq.Array(&user.Permissions),
+		&user.DisabledAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
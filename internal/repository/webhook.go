@@ -0,0 +1,384 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+)
+
+const (
+	webhookColumns  = `id, user_id, url, description, secret, events, is_active, created_at, updated_at`
+	deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at, delivered_at`
+)
+
+// enqueueQuery adds a delivery to each active webhook of a user that
+// subscribes to the event
+const enqueueQuery = `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+	SELECT id, $2, $3, $4 FROM webhooks
+	WHERE user_id = $1 AND is_active AND $3 = ANY(events)
+`
+
+// execer is satisfied by both *database.Database and *database.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type WebhookRepository struct {
+	db *database.Database
+}
+
+func NewWebhookRepository(db *database.Database) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, user_id, url, description, secret, events, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, webhook.ID, webhook.UserID, webhook.URL, webhook.Description,
+		webhook.Secret, pq.Array(webhook.Events), webhook.IsActive).Scan(&webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	return scanWebhook(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *WebhookRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *WebhookRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhooks WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = $2, description = $3, events = $4, is_active = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	err := r.db.QueryRowContext(ctx, query, webhook.ID, webhook.URL, webhook.Description,
+		pq.Array(webhook.Events), webhook.IsActive).Scan(&webhook.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// Delete removes a webhook along with its delivery log
+func (r *WebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Outbox turns link changes into webhook events. LinkRepository calls it
+// inside the transaction that changes the links or records the click, so a
+// change and its events are committed together or not at all.
+type Outbox interface {
+	QueueLinks(ctx context.Context, tx *database.Tx, event string, links []*models.Link) error
+	QueueClick(ctx context.Context, tx *database.Tx, click *models.ClickEvent) error
+}
+
+// EnqueueIn queues each event for every active webhook of its user that
+// subscribes to it, as part of tx
+func (r *WebhookRepository) EnqueueIn(ctx context.Context, tx *database.Tx, events []*models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	userIDs := make([]uuid.UUID, len(events))
+	eventIDs := make([]uuid.UUID, len(events))
+	types := make(pq.StringArray, len(events))
+	payloads := make(pq.StringArray, len(events))
+	for i, event := range events {
+		userIDs[i], eventIDs[i], types[i], payloads[i] = event.UserID, event.ID, event.Type, string(event.Payload)
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT w.id, e.event_id, e.event, e.payload
+		FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::text[]) AS e(user_id, event_id, event, payload)
+		JOIN webhooks w ON w.user_id = e.user_id AND w.is_active AND e.event = ANY(w.events)
+	`
+	_, err := tx.ExecContext(ctx, query, uuidArray(userIDs), uuidArray(eventIDs), types, payloads)
+	return err
+}
+
+// EnqueueFor queues an event for one webhook whatever it subscribes to
+func (r *WebhookRepository) EnqueueFor(ctx context.Context, webhookID, eventID uuid.UUID, event string, payload []byte) (*models.WebhookDelivery, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + deliveryColumns
+	return scanDelivery(r.db.QueryRowContext(ctx, query, webhookID, eventID, event, string(payload)))
+}
+
+func enqueue(ctx context.Context, db execer, userID, eventID uuid.UUID, event string, payload []byte) (int64, error) {
+	result, err := db.ExecContext(ctx, enqueueQuery, userID, eventID, event, string(payload))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// NotifyExpired marks up to limit links that expired within the last window
+// as notified and queues link.expired for each, in one transaction so that an
+// expiry is neither lost nor reported twice. payload returns the event ID and
+// body for a link. It returns how many links were marked.
+func (r *WebhookRepository) NotifyExpired(ctx context.Context, window time.Duration, limit int, payload func(*models.Link) (uuid.UUID, []byte, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE links SET expiry_notified_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM links
+			WHERE expiry_notified_at IS NULL AND expires_at <= CURRENT_TIMESTAMP
+				AND expires_at > CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+			ORDER BY expires_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + linkColumns
+	rows, err := tx.QueryContext(ctx, query, window.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	links, err := scanLinks(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, link := range links {
+		eventID, body, err := payload(link)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := enqueue(ctx, tx, link.UserID, eventID, models.EventLinkExpired, body); err != nil {
+			return 0, err
+		}
+	}
+	return len(links), tx.Commit()
+}
+
+// ClaimDue returns up to limit pending deliveries of active webhooks whose
+// next attempt is due and pushes that attempt back by lease, so that other
+// servers skip them while they are being sent. A delivery whose sender dies
+// is picked up again once the lease runs out.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.DueDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= CURRENT_TIMESTAMP AND w.is_active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING d.id, d.event_id, d.event, d.payload, d.attempts, w.url, w.secret
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*models.DueDelivery
+	for rows.Next() {
+		delivery := &models.DueDelivery{}
+		var payload string
+		if err := rows.Scan(&delivery.ID, &delivery.EventID, &delivery.Event, &payload,
+			&delivery.Attempts, &delivery.URL, &delivery.Secret); err != nil {
+			return nil, err
+		}
+		delivery.Payload = []byte(payload)
+		due = append(due, delivery)
+	}
+	return due, rows.Err()
+}
+
+// MarkDelivered records a successful attempt
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, id uuid.UUID, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = '',
+			delivered_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, statusCode)
+	return err
+}
+
+// MarkFailed records a failed attempt. The delivery is retried after retryIn,
+// or becomes dead when dead is set. A statusCode of 0 means there was no
+// response.
+func (r *WebhookRepository) MarkFailed(ctx context.Context, id uuid.UUID, statusCode int, message string, retryIn time.Duration, dead bool) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $5 THEN 'dead' ELSE 'pending' END, attempts = attempts + 1,
+			last_status_code = NULLIF($2, 0), last_error = $3,
+			next_attempt_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 second', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, statusCode, message, retryIn.Seconds(), dead)
+	return err
+}
+
+// ListDeliveries returns a webhook's deliveries, newest first, optionally
+// only those with status
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.db.QueryContext(ctx, query, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, webhookID, id uuid.UUID) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2`
+	return scanDelivery(r.db.QueryRowContext(ctx, query, id, webhookID))
+}
+
+// Redeliver makes a delivered or dead delivery pending again with a fresh
+// set of attempts. Pending deliveries are left alone and reported as not
+// found.
+func (r *WebhookRepository) Redeliver(ctx context.Context, webhookID, id uuid.UUID) (*models.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, delivered_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND webhook_id = $2 AND status <> 'pending'
+		RETURNING ` + deliveryColumns
+	return scanDelivery(r.db.QueryRowContext(ctx, query, id, webhookID))
+}
+
+// Prune deletes delivered and dead deliveries last updated before retention
+// ago and returns how many were deleted
+func (r *WebhookRepository) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+		DELETE FROM webhook_deliveries
+		WHERE status <> 'pending' AND updated_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+	`
+	result, err := r.db.ExecContext(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		&webhook.Description,
+		&webhook.Secret,
+		pq.Array(&webhook.Events),
+		&webhook.IsActive,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var payload string
+	var nextAttemptAt time.Time
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.Event,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&nextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+		&delivery.DeliveredAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = []byte(payload)
+	if delivery.Status == models.DeliveryPending {
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+	return delivery, nil
+}
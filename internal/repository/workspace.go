@@ -0,0 +1,398 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type WorkspaceRepository struct {
+	db *database.Database
+}
+
+func NewWorkspaceRepository(db *database.Database) *WorkspaceRepository {
+	return &WorkspaceRepository{db: db}
+}
+
+// Create inserts the workspace and makes ownerID its owner
+func (r *WorkspaceRepository) Create(ctx context.Context, workspace *models.Workspace, ownerID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO workspaces (id, name)
+		VALUES ($1, $2)
+		RETURNING created_at, updated_at
+	`
+	if err := tx.QueryRowContext(ctx, query, workspace.ID, workspace.Name).Scan(&workspace.CreatedAt, &workspace.UpdatedAt); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, workspace.ID, ownerID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WorkspaceRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Workspace, error) {
+	workspace := &models.Workspace{}
+	query := `SELECT id, name, created_at, updated_at FROM workspaces WHERE id = $1`
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.CreatedAt,
+		&workspace.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// ListForUser returns the workspaces the user belongs to with their role
+func (r *WorkspaceRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]*models.WorkspaceMembership, error) {
+	query := `
+		SELECT w.id, w.name, w.created_at, w.updated_at, m.role
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []*models.WorkspaceMembership{}
+	for rows.Next() {
+		membership := &models.WorkspaceMembership{}
+		if err := rows.Scan(
+			&membership.ID,
+			&membership.Name,
+			&membership.CreatedAt,
+			&membership.UpdatedAt,
+			&membership.Role,
+		); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *WorkspaceRepository) Update(ctx context.Context, workspace *models.Workspace) error {
+	query := `
+		UPDATE workspaces
+		SET name = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, workspace.ID, workspace.Name).Scan(&workspace.UpdatedAt)
+}
+
+// Delete removes the workspace together with its links, members and
+// invitations, and returns the short codes of the links removed
+func (r *WorkspaceRepository) Delete(ctx context.Context, id uuid.UUID) ([]string, error) {
+	// The SELECT sees the links as they were before the cascade removed them
+	query := `
+		WITH removed AS (DELETE FROM workspaces WHERE id = $1 RETURNING id)
+		SELECT l.short_code
+		FROM removed
+		LEFT JOIN links l ON l.workspace_id = removed.id
+	`
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := false
+	codes := []string{}
+	for rows.Next() {
+		var code sql.NullString
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		found = true
+		if code.Valid {
+			codes = append(codes, code.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrWorkspaceNotFound
+	}
+	return codes, nil
+}
+
+// GetMemberRole returns the user's role in the workspace
+func (r *WorkspaceRepository) GetMemberRole(ctx context.Context, workspaceID, userID uuid.UUID) (string, error) {
+	var role string
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+
+	err := r.db.QueryRowContext(ctx, query, workspaceID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrMemberNotFound
+		}
+		return "", err
+	}
+
+	return role, nil
+}
+
+func (r *WorkspaceRepository) ListMembers(ctx context.Context, workspaceID uuid.UUID) ([]*models.WorkspaceMember, error) {
+	query := `
+		SELECT m.workspace_id, m.user_id, u.username, u.email, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*models.WorkspaceMember{}
+	for rows.Next() {
+		member := &models.WorkspaceMember{}
+		if err := rows.Scan(
+			&member.WorkspaceID,
+			&member.UserID,
+			&member.Username,
+			&member.Email,
+			&member.Role,
+			&member.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *WorkspaceRepository) SetMemberRole(ctx context.Context, workspaceID, userID uuid.UUID, role string) error {
+	query := `UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2`
+	return r.execOne(ctx, ErrMemberNotFound, query, workspaceID, userID, role)
+}
+
+// RemoveMember removes a member and hands the links they created in the
+// workspace over to transferTo, so that the links outlive the membership
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID, transferTo uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE links SET user_id = $3, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = $1 AND user_id = $2`
+	if _, err := tx.ExecContext(ctx, query, workspaceID, userID, transferTo); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	return tx.Commit()
+}
+
+// TransferOwnership makes toUserID the owner and demotes the current owner to editor
+func (r *WorkspaceRepository) TransferOwnership(ctx context.Context, workspaceID, fromUserID, toUserID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2`
+
+	result, err := tx.ExecContext(ctx, query, workspaceID, toUserID, models.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, query, workspaceID, fromUserID, models.WorkspaceRoleEditor); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WorkspaceRepository) CreateInvitation(ctx context.Context, invitation *models.WorkspaceInvitation) error {
+	query := `
+		INSERT INTO workspace_invitations (id, workspace_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+
+	return r.db.QueryRowContext(ctx,
+		query,
+		invitation.ID,
+		invitation.WorkspaceID,
+		invitation.Email,
+		invitation.Role,
+		invitation.TokenHash,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+	).Scan(&invitation.CreatedAt)
+}
+
+const invitationColumns = `id, workspace_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at`
+
+func (r *WorkspaceRepository) GetInvitationByHash(ctx context.Context, tokenHash string) (*models.WorkspaceInvitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM workspace_invitations WHERE token_hash = $1`
+
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// ListPendingInvitations returns invitations that were neither accepted nor revoked
+func (r *WorkspaceRepository) ListPendingInvitations(ctx context.Context, workspaceID uuid.UUID) ([]*models.WorkspaceInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM workspace_invitations
+		WHERE workspace_id = $1 AND accepted_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*models.WorkspaceInvitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (r *WorkspaceRepository) DeleteInvitation(ctx context.Context, workspaceID, id uuid.UUID) error {
+	query := `DELETE FROM workspace_invitations WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL`
+	return r.execOne(ctx, ErrInvitationNotFound, query, id, workspaceID)
+}
+
+// AcceptInvitation consumes the invitation and adds the user to the workspace.
+// Consuming fails if the invitation was already accepted, so a token cannot be
+// redeemed twice.
+func (r *WorkspaceRepository) AcceptInvitation(ctx context.Context, invitation *models.WorkspaceInvitation, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workspace_invitations SET accepted_at = CURRENT_TIMESTAMP WHERE id = $1 AND accepted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, invitation.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationUsed
+	}
+
+	query = `
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, query, invitation.WorkspaceID, userID, invitation.Role); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execOne runs a statement that is expected to touch exactly one row and
+// returns notFound when it touched none
+func (r *WorkspaceRepository) execOne(ctx context.Context, notFound error, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+func scanInvitation(row rowScanner) (*models.WorkspaceInvitation, error) {
+	invitation := &models.WorkspaceInvitation{}
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.WorkspaceID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.TokenHash,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.CreatedAt,
+	)
+	return invitation, err
+}
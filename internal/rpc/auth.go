@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"context"
+
+	"link-shortener/internal/models"
+	pb "link-shortener/internal/rpc/shortlinkv1"
+	"link-shortener/internal/services"
+)
+
+type authServer struct {
+	pb.UnimplementedAuthServiceServer
+	auth *services.AuthService
+}
+
+func (s *authServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AuthResponse, error) {
+	register := &models.RegisterRequest{Username: req.Username, Email: req.Email, Password: req.Password}
+	if err := validate(register); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.auth.Register(ctx, register)
+	if err != nil {
+		return nil, err
+	}
+	return toAuthResponse(resp), nil
+}
+
+func (s *authServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	login := &models.LoginRequest{Email: req.Email, Password: req.Password}
+	if err := validate(login); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.auth.Login(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return toAuthResponse(resp), nil
+}
+
+func (s *authServer) LoginTwoFactor(ctx context.Context, req *pb.LoginTwoFactorRequest) (*pb.AuthResponse, error) {
+	login := &models.TwoFactorLoginRequest{ChallengeToken: req.ChallengeToken, Code: req.Code}
+	if err := validate(login); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.auth.LoginTwoFactor(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return toAuthResponse(resp), nil
+}
+
+func (s *authServer) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.User, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.auth.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toUser(user), nil
+}
@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/models"
+	pb "link-shortener/internal/rpc/shortlinkv1"
+)
+
+// validate checks a request against the binding rules of its REST
+// counterpart
+func validate(req any) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return apperror.FromBinding(err)
+	}
+	return nil
+}
+
+// parseID reads a required UUID field
+func parseID(field, value, message string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		invalid := *errInvalidID
+		invalid.Message = message
+		return uuid.Nil, invalid.WithField(field, "must be a UUID")
+	}
+	return id, nil
+}
+
+// parseOptionalID reads a UUID field that may be left empty
+func parseOptionalID(field, value, message string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := parseID(field, value, message)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// parseTime reads a timestamp field that may be left unset
+func parseTime(field string, ts *timestamppb.Timestamp) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return nil, errInvalidRequest.WithField(field, "must be a valid timestamp")
+	}
+	t := ts.AsTime()
+	return &t, nil
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func toLink(link *models.LinkResponse) *pb.Link {
+	out := &pb.Link{
+		Id:             link.ID.String(),
+		OriginalUrl:    link.OriginalURL,
+		ShortCode:      link.ShortCode,
+		ShortUrl:       link.ShortURL,
+		Title:          link.Title,
+		Tags:           link.Tags,
+		Clicks:         int64(link.Clicks),
+		IsActive:       link.IsActive,
+		ExpiresAt:      timestamp(link.ExpiresAt),
+		TakenDownAt:    timestamp(link.TakenDownAt),
+		TakedownReason: link.TakedownReason,
+		CreatedAt:      timestamppb.New(link.CreatedAt),
+		UpdatedAt:      timestamppb.New(link.UpdatedAt),
+	}
+	if link.WorkspaceID != nil {
+		out.WorkspaceId = link.WorkspaceID.String()
+	}
+	return out
+}
+
+func toLinkStats(stats *models.LinkStats) *pb.LinkStats {
+	return &pb.LinkStats{
+		TotalLinks:   int64(stats.TotalLinks),
+		TotalClicks:  int64(stats.TotalClicks),
+		ActiveLinks:  int64(stats.ActiveLinks),
+		ExpiredLinks: int64(stats.ExpiredLinks),
+	}
+}
+
+// toUser returns user data without the password or secrets
+func toUser(user *models.User) *pb.User {
+	return &pb.User{
+		Id:                  user.ID.String(),
+		Username:            user.Username,
+		Email:               user.Email,
+		EmailVerified:       user.IsEmailVerified(),
+		TwoFactorEnabled:    user.TOTPEnabled,
+		DeletionScheduledAt: timestamp(user.DeletionAt),
+		Role:                user.Role,
+		Permissions:         user.EffectivePermissions(),
+		DisabledAt:          timestamp(user.DisabledAt),
+		CreatedAt:           timestamppb.New(user.CreatedAt),
+	}
+}
+
+func toAuthResponse(resp *models.AuthResponse) *pb.AuthResponse {
+	out := &pb.AuthResponse{
+		Token:             resp.Token,
+		TwoFactorRequired: resp.TwoFactorRequired,
+		ChallengeToken:    resp.ChallengeToken,
+	}
+	if resp.User != nil {
+		out.User = toUser(resp.User)
+	}
+	return out
+}
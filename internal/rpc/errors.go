@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"link-shortener/internal/apperror"
+)
+
+var (
+	errNotAuthenticated  = apperror.Unauthorized("not_authenticated", "User not authenticated")
+	errEmailNotVerified  = apperror.Forbidden("email_not_verified", "Email address must be verified")
+	errRateLimited       = apperror.RateLimited("rate_limited", "Rate limit exceeded")
+	errInvalidRequest    = apperror.Validation("invalid_request", "Invalid request data")
+	errInvalidID         = apperror.Validation("invalid_id", "Invalid ID")
+	errShortCodeRequired = apperror.Validation("short_code_required", "Short code is required")
+)
+
+// errorDomain names this service in ErrorInfo details
+const errorDomain = "link-shortener"
+
+// grpcCodes maps the status the REST API renders an error with to the code
+// it is returned with over gRPC
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:              codes.InvalidArgument,
+	http.StatusUnauthorized:            codes.Unauthenticated,
+	http.StatusForbidden:               codes.PermissionDenied,
+	http.StatusNotFound:                codes.NotFound,
+	http.StatusConflict:                codes.AlreadyExists,
+	http.StatusGone:                    codes.FailedPrecondition,
+	http.StatusTooManyRequests:         codes.ResourceExhausted,
+	http.StatusServiceUnavailable:      codes.Unavailable,
+	http.StatusGatewayTimeout:          codes.DeadlineExceeded,
+	apperror.StatusClientClosedRequest: codes.Canceled,
+}
+
+// toStatus converts an error into the status a client receives. The message
+// is the one the REST API shows, the REST error code is the reason of an
+// ErrorInfo detail and invalid fields are listed in a BadRequest detail.
+// Internal errors keep their cause out of the status, as they do over REST.
+func toStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	body := apperror.ResponseOf(err)
+	code, ok := grpcCodes[apperror.Status(err)]
+	if !ok {
+		code = codes.Internal
+	}
+
+	st := status.New(code, body.Error)
+	info := &errdetails.ErrorInfo{Reason: body.Code, Domain: errorDomain}
+	withDetails, detailsErr := st.WithDetails(info)
+	if len(body.Details) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range body.Details {
+			badRequest.FieldViolations = append(badRequest.FieldViolations,
+				&errdetails.BadRequest_FieldViolation{Field: field.Field, Description: field.Message})
+		}
+		withDetails, detailsErr = st.WithDetails(info, badRequest)
+	}
+	if detailsErr != nil {
+		return st
+	}
+	return withDetails
+}
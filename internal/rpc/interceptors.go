@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/metrics"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/tracing"
+)
+
+// serverErrors are the codes logged at error level, as 5xx responses are
+var serverErrors = map[codes.Code]bool{
+	codes.Unknown:          true,
+	codes.Internal:         true,
+	codes.Unimplemented:    true,
+	codes.Unavailable:      true,
+	codes.DeadlineExceeded: true,
+	codes.DataLoss:         true,
+}
+
+type callKey struct{}
+
+// call collects what the access log reports about a call
+type call struct {
+	userID uuid.UUID
+}
+
+// observe does for calls what the RequestID, Tracing, AccessLog and Metrics
+// middleware do for HTTP requests, and turns errors into statuses
+func (a *API) observe(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := middleware.AcceptRequestID(first(md, middleware.RequestIDHeader))
+	grpc.SetHeader(ctx, metadata.Pairs(middleware.RequestIDHeader, requestID))
+	logger := slog.Default().With("request_id", requestID)
+
+	var span *tracing.Span
+	if tracer := tracing.Default(); tracer != nil {
+		if parent, ok := tracing.ParseTraceParent(first(md, tracing.TraceParentHeader)); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+		ctx, span = tracer.Start(ctx, strings.TrimPrefix(info.FullMethod, "/"), tracing.KindServer,
+			tracing.String("rpc.system", "grpc"),
+			tracing.String("rpc.method", info.FullMethod),
+		)
+		defer span.End()
+		logger = logger.With("trace_id", span.SpanContext().TraceID.String())
+	}
+
+	c := &call{}
+	ctx = context.WithValue(logging.WithLogger(ctx, logger), callKey{}, c)
+	resp, err := handler(ctx, req)
+
+	st := toStatus(err)
+	code := st.Code()
+	metrics.GRPCRequests.Inc(info.FullMethod, code.String())
+	metrics.GRPCRequestDuration.Observe(time.Since(start).Seconds(), info.FullMethod, code.String())
+	span.SetAttributes(tracing.Int("rpc.grpc.status_code", int(code)))
+
+	attrs := []any{
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if ip := a.clientIP(ctx); ip != "" {
+		attrs = append(attrs, "ip", ip)
+	}
+	if c.userID != uuid.Nil {
+		attrs = append(attrs, "user_id", c.userID.String())
+	}
+	level := slog.LevelInfo
+	if serverErrors[code] || code == codes.Canceled {
+		attrs = append(attrs, "error", err.Error())
+		span.RecordError(err)
+	}
+	if serverErrors[code] {
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, "rpc", attrs...)
+
+	if err != nil {
+		return nil, st.Err()
+	}
+	return resp, nil
+}
+
+// recoverPanic turns a panic into an internal error and logs it with the call
+func recoverPanic(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logging.FromContext(ctx).Error("panic recovered", "panic", recovered, "stack", string(debug.Stack()))
+			resp, err = nil, fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// limit applies the REST API's rate limit per client IP
+func (a *API) limit(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if a.RateLimiter == nil {
+		return handler(ctx, req)
+	}
+	key := a.clientIP(ctx)
+	if key == "" {
+		key = "unknown"
+	}
+	if !a.RateLimiter.Allow(key) {
+		return nil, errRateLimited
+	}
+	return handler(ctx, req)
+}
+
+// authenticate checks the authorization metadata of every call to a method
+// that is not public
+func (a *API) authenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	claims, err := a.Authenticator.Authenticate(ctx, first(md, "authorization"))
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		c.userID = claims.UserID
+	}
+	return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+}
+
+// first returns the first value of a metadata key; keys are case-insensitive
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// clientIP is the address of the caller, or of the client a trusted proxy
+// forwarded the call for, as for REST requests
+func (a *API) clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	remote := p.Addr.String()
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return middleware.ForwardedClientIP(remote, a.TrustedProxies, first(md, "x-forwarded-for"), first(md, "x-real-ip"))
+}
@@ -0,0 +1,204 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"link-shortener/internal/models"
+	pb "link-shortener/internal/rpc/shortlinkv1"
+	"link-shortener/internal/services"
+)
+
+type linkServer struct {
+	pb.UnimplementedLinkServiceServer
+	links         *services.LinkService
+	verifiedEmail func(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+func (s *linkServer) CreateLink(ctx context.Context, req *pb.CreateLinkRequest) (*pb.Link, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.verifiedEmail != nil {
+		verified, err := s.verifiedEmail(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check email verification: %w", err)
+		}
+		if !verified {
+			return nil, errEmailNotVerified
+		}
+	}
+
+	workspaceID, err := parseOptionalID("workspace_id", req.WorkspaceId, "Invalid workspace ID")
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := parseTime("expires_at", req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	create := &models.CreateLinkRequest{
+		OriginalURL: req.OriginalUrl,
+		CustomAlias: req.CustomAlias,
+		Title:       req.Title,
+		ExpiresAt:   expiresAt,
+		WorkspaceID: workspaceID,
+		Tags:        req.Tags,
+	}
+	if err := validate(create); err != nil {
+		return nil, err
+	}
+
+	link, err := s.links.CreateLink(ctx, userID, create)
+	if err != nil {
+		return nil, err
+	}
+	return toLink(link), nil
+}
+
+func (s *linkServer) GetLink(ctx context.Context, req *pb.GetLinkRequest) (*pb.Link, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	linkID, err := parseID("id", req.Id, "Invalid link ID")
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := s.links.GetLinkByID(ctx, userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	return toLink(link), nil
+}
+
+// ListLinks pages through links like GET /api/v1/links: out of range limits
+// fall back to 10
+func (s *linkServer) ListLinks(ctx context.Context, req *pb.ListLinksRequest) (*pb.ListLinksResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaceID, err := parseOptionalID("workspace_id", req.WorkspaceId, "Invalid workspace ID")
+	if err != nil {
+		return nil, err
+	}
+
+	limit, offset := int(req.Limit), int(req.Offset)
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var links []*models.LinkResponse
+	if workspaceID != nil {
+		links, err = s.links.GetLinksByWorkspaceID(ctx, userID, *workspaceID, limit, offset)
+	} else {
+		links, err = s.links.GetLinksByUserID(ctx, userID, limit, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListLinksResponse{Limit: int32(limit), Offset: int32(offset)}
+	for _, link := range links {
+		resp.Links = append(resp.Links, toLink(link))
+	}
+	return resp, nil
+}
+
+func (s *linkServer) UpdateLink(ctx context.Context, req *pb.UpdateLinkRequest) (*pb.Link, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	linkID, err := parseID("id", req.Id, "Invalid link ID")
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := parseTime("expires_at", req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	update := &models.UpdateLinkRequest{
+		OriginalURL: req.OriginalUrl,
+		CustomAlias: req.CustomAlias,
+		Title:       req.Title,
+		IsActive:    req.IsActive,
+		ExpiresAt:   expiresAt,
+	}
+	if req.Tags != nil {
+		// Set but empty removes the tags
+		update.Tags = append([]string{}, req.Tags.Values...)
+	}
+	if err := validate(update); err != nil {
+		return nil, err
+	}
+
+	link, err := s.links.UpdateLink(ctx, userID, linkID, update)
+	if err != nil {
+		return nil, err
+	}
+	return toLink(link), nil
+}
+
+func (s *linkServer) DeleteLink(ctx context.Context, req *pb.DeleteLinkRequest) (*emptypb.Empty, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	linkID, err := parseID("id", req.Id, "Invalid link ID")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.links.DeleteLink(ctx, userID, linkID); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ResolveLink is the redirect without the redirect: the click is counted and
+// the destination returned
+func (s *linkServer) ResolveLink(ctx context.Context, req *pb.ResolveLinkRequest) (*pb.ResolveLinkResponse, error) {
+	if req.ShortCode == "" {
+		return nil, errShortCodeRequired
+	}
+
+	originalURL, err := s.links.RedirectToOriginal(ctx, req.ShortCode, models.ClickEvent{
+		Referrer:  req.Referrer,
+		UserAgent: req.UserAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ResolveLinkResponse{OriginalUrl: originalURL}, nil
+}
+
+func (s *linkServer) GetStats(ctx context.Context, req *pb.GetStatsRequest) (*pb.LinkStats, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaceID, err := parseOptionalID("workspace_id", req.WorkspaceId, "Invalid workspace ID")
+	if err != nil {
+		return nil, err
+	}
+
+	var stats *models.LinkStats
+	if workspaceID != nil {
+		stats, err = s.links.GetWorkspaceStats(ctx, userID, *workspaceID)
+	} else {
+		stats, err = s.links.GetStats(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toLinkStats(stats), nil
+}
@@ -0,0 +1,80 @@
+// Package rpc serves the link and auth services over gRPC, as described in
+// proto/shortlink/v1/shortlink.proto. It mirrors the REST handlers: requests
+// are validated with the same rules, callers authenticate with the same bearer
+// tokens and share the rate limit, and errors keep the codes the REST API
+// reports.
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	"link-shortener/internal/middleware"
+	pb "link-shortener/internal/rpc/shortlinkv1"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+// Authenticator checks the authorization metadata of a call. The REST auth
+// middleware is one.
+type Authenticator interface {
+	Authenticate(ctx context.Context, authHeader string) (*utils.Claims, error)
+}
+
+// API holds what the gRPC services need, as handlers.API does for the REST
+// routes
+type API struct {
+	Links         *services.LinkService
+	Auth          *services.AuthService
+	Authenticator Authenticator
+	// RateLimiter, when set, limits calls per client IP
+	RateLimiter *middleware.RateLimiter
+	// TrustedProxies may name the client they forward a call for in
+	// x-forwarded-for or x-real-ip metadata, as for REST requests
+	TrustedProxies []*net.IPNet
+	// Credentials, when set, serve the API over TLS; otherwise TLS must be
+	// terminated by a proxy in front of it
+	Credentials credentials.TransportCredentials
+	// VerifiedEmail, when set, is checked before a link is created
+	VerifiedEmail func(ctx context.Context, userID uuid.UUID) (bool, error)
+	// Reflection lets tools such as grpcurl list the services
+	Reflection bool
+}
+
+// publicMethods can be called without authorization metadata
+var publicMethods = map[string]bool{
+	pb.AuthService_Register_FullMethodName:       true,
+	pb.AuthService_Login_FullMethodName:          true,
+	pb.AuthService_LoginTwoFactor_FullMethodName: true,
+	pb.LinkService_ResolveLink_FullMethodName:    true,
+}
+
+// NewServer builds a gRPC server with the link and auth services registered
+func NewServer(api *API) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(api.observe, recoverPanic, api.limit, api.authenticate)}
+	if api.Credentials != nil {
+		opts = append(opts, grpc.Creds(api.Credentials))
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterLinkServiceServer(srv, &linkServer{links: api.Links, verifiedEmail: api.VerifiedEmail})
+	pb.RegisterAuthServiceServer(srv, &authServer{auth: api.Auth})
+	if api.Reflection {
+		reflection.Register(srv)
+	}
+	return srv
+}
+
+type claimsKey struct{}
+
+// requireUserID returns the user authenticated by the call's metadata
+func requireUserID(ctx context.Context) (uuid.UUID, error) {
+	claims, ok := ctx.Value(claimsKey{}).(*utils.Claims)
+	if !ok {
+		return uuid.Nil, errNotAuthenticated
+	}
+	return claims.UserID, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+)
+
+type AdminService struct {
+	userRepo    *repository.UserRepository
+	linkRepo    *repository.LinkRepository
+	linkService *LinkService
+}
+
+func NewAdminService(userRepo *repository.UserRepository, linkRepo *repository.LinkRepository, linkService *LinkService) *AdminService {
+	return &AdminService{
+		userRepo:    userRepo,
+		linkRepo:    linkRepo,
+		linkService: linkService,
+	}
+}
+
+// PromoteAdmins gives the admin role to existing users with the given emails.
+// It is used to bootstrap the first administrators from configuration.
+func (s *AdminService) PromoteAdmins(ctx context.Context, emails []string) error {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+
+		promoted, err := s.userRepo.PromoteByEmail(ctx, email)
+		if err != nil {
+			return fmt.Errorf("failed to promote %s: %w", email, err)
+		}
+		if promoted {
+			logging.FromContext(ctx).Info("Promoted admin", "email", email)
+		}
+	}
+	return nil
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, search string, limit, offset int) ([]*models.User, error) {
+	users, err := s.userRepo.List(ctx, search, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling signs the user
+// out everywhere.
+func (s *AdminService) SetUserDisabled(ctx context.Context, adminID, userID uuid.UUID, disabled bool) error {
+	if adminID == userID {
+		return ErrDisableSelf
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+
+	return s.userRepo.SetDisabled(ctx, userID, disabledAt)
+}
+
+// UpdateUserRole changes a user's role and extra permissions
+func (s *AdminService) UpdateUserRole(ctx context.Context, adminID, userID uuid.UUID, req *models.UpdateRoleRequest) (*models.User, error) {
+	if !models.IsValidRole(req.Role) {
+		return nil, ErrInvalidRole.WithField("role", fmt.Sprintf("%q is not a role", req.Role))
+	}
+
+	for _, permission := range req.Permissions {
+		if !models.IsValidPermission(permission) {
+			return nil, ErrInvalidPermission.WithField("permissions", fmt.Sprintf("%q is not a permission", permission))
+		}
+	}
+
+	if adminID == userID && req.Role != models.RoleAdmin {
+		return nil, ErrRemoveOwnAdminRole
+	}
+
+	permissions := req.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	if err := s.userRepo.SetRole(ctx, userID, req.Role, permissions); err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// ListLinks searches links across all users
+func (s *AdminService) ListLinks(ctx context.Context, search string, userID *uuid.UUID, limit, offset int) ([]*models.AdminLinkResponse, error) {
+	links, err := s.linkRepo.Search(ctx, search, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	responses := make([]*models.AdminLinkResponse, 0, len(links))
+	for _, link := range links {
+		responses = append(responses, s.toAdminLinkResponse(link))
+	}
+
+	return responses, nil
+}
+
+// TakedownLink disables an abusive link so that it stops redirecting
+func (s *AdminService) TakedownLink(ctx context.Context, linkID uuid.UUID, req *models.TakedownLinkRequest) (*models.AdminLinkResponse, error) {
+	if err := s.linkRepo.Takedown(ctx, linkID, req.Reason); err != nil {
+		return nil, fmt.Errorf("failed to take down link: %w", err)
+	}
+	return s.getFreshLink(ctx, linkID)
+}
+
+// RestoreLink lifts a takedown
+func (s *AdminService) RestoreLink(ctx context.Context, linkID uuid.UUID) (*models.AdminLinkResponse, error) {
+	if err := s.linkRepo.RestoreTakedown(ctx, linkID); err != nil {
+		return nil, fmt.Errorf("failed to restore link: %w", err)
+	}
+	return s.getFreshLink(ctx, linkID)
+}
+
+// getFreshLink loads a link after a moderation change and drops it from the
+// redirect cache
+func (s *AdminService) getFreshLink(ctx context.Context, linkID uuid.UUID) (*models.AdminLinkResponse, error) {
+	link, err := s.getLink(ctx, linkID)
+	if err == nil {
+		s.linkService.ForgetLink(link.ShortCode)
+	}
+	return link, err
+}
+
+// GetSystemStats returns system-wide user and link counters
+func (s *AdminService) GetSystemStats(ctx context.Context) (*models.SystemStats, error) {
+	stats := &models.SystemStats{}
+
+	if err := s.userRepo.GetSystemStats(ctx, stats); err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+
+	if err := s.linkRepo.GetSystemStats(ctx, stats); err != nil {
+		return nil, fmt.Errorf("failed to get link stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (s *AdminService) getLink(ctx context.Context, linkID uuid.UUID) (*models.AdminLinkResponse, error) {
+	link, err := s.linkRepo.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link: %w", err)
+	}
+	return s.toAdminLinkResponse(link), nil
+}
+
+func (s *AdminService) toAdminLinkResponse(link *models.Link) *models.AdminLinkResponse {
+	return &models.AdminLinkResponse{
+		LinkResponse: s.linkService.toLinkResponse(link),
+		UserID:       link.UserID,
+	}
+}
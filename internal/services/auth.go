@@ -1,44 +1,70 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
-	"golang.org/x/crypto/bcrypt"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"link-shortener/internal/config"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/mailer"
 	"link-shortener/internal/models"
 	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
 	"link-shortener/internal/utils"
 )
 
 type AuthService struct {
-	userRepo  *repository.UserRepository
-	jwtMgr    *utils.JWTManager
+	userRepo     *repository.UserRepository
+	tokenRepo    *repository.TokenRepository
+	recoveryRepo *repository.RecoveryCodeRepository
+	jwtMgr       *utils.JWTManager
+	mailer       mailer.Mailer
+	cfg          config.AuthConfig
+	appURL       string
+	links        *LinkService
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtMgr *utils.JWTManager) *AuthService {
+func NewAuthService(userRepo *repository.UserRepository, tokenRepo *repository.TokenRepository, recoveryRepo *repository.RecoveryCodeRepository, jwtMgr *utils.JWTManager, mail mailer.Mailer, cfg config.AuthConfig, appURL string) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		jwtMgr:   jwtMgr,
+		userRepo:     userRepo,
+		tokenRepo:    tokenRepo,
+		recoveryRepo: recoveryRepo,
+		jwtMgr:       jwtMgr,
+		mailer:       mail,
+		cfg:          cfg,
+		appURL:       strings.TrimSuffix(appURL, "/"),
 	}
 }
 
-func (s *AuthService) Register(req *models.RegisterRequest) (*models.AuthResponse, error) {
+// SetLinks lets purged accounts drop their links from the redirect cache
+func (s *AuthService) SetLinks(links *LinkService) {
+	s.links = links
+}
+
+func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Register")
+	defer span.End()
+
 	// Check if email already exists
-	emailExists, err := s.userRepo.EmailExists(req.Email)
+	emailExists, err := s.userRepo.EmailExists(ctx, req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check email: %w", err)
 	}
 	if emailExists {
-		return nil, fmt.Errorf("email already exists")
+		return nil, ErrEmailTaken
 	}
 
 	// Check if username already exists
-	usernameExists, err := s.userRepo.UsernameExists(req.Username)
+	usernameExists, err := s.userRepo.UsernameExists(ctx, req.Username)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check username: %w", err)
 	}
 	if usernameExists {
-		return nil, fmt.Errorf("username already exists")
+		return nil, ErrUsernameTaken
 	}
 
 	// Hash password
@@ -53,52 +79,106 @@ func (s *AuthService) Register(req *models.RegisterRequest) (*models.AuthRespons
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: string(hashedPassword),
+		Role:         models.RoleUser,
 	}
 
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate JWT token
-	token, err := s.jwtMgr.GenerateToken(user.ID, user.Username, user.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+	// A failed email should not fail the signup; the user can ask for a new one
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		logging.FromContext(ctx).Error("Failed to send verification email", "email", user.Email, "error", err)
 	}
 
-	return &models.AuthResponse{
-		User:  *user,
-		Token: token,
-	}, nil
+	return s.issueSession(ctx, user)
 }
 
-func (s *AuthService) Login(req *models.LoginRequest) (*models.AuthResponse, error) {
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
+
 	// Get user by email
-	user, err := s.userRepo.GetByEmail(req.Email)
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.completeLogin(ctx, user)
+}
+
+// completeLogin finishes a first-factor login: disabled accounts are
+// rejected and accounts with 2FA only get a challenge until a code is submitted
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
 	}
 
-	// Generate JWT token
-	token, err := s.jwtMgr.GenerateToken(user.ID, user.Username, user.Email)
+	if user.TOTPEnabled {
+		challenge, err := s.jwtMgr.GenerateChallengeToken(user.ID, s.cfg.TwoFactorChallengeExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate challenge token: %w", err)
+		}
+
+		return &models.AuthResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	return s.issueSession(ctx, user)
+}
+
+// issueSession generates an access token for a fully authenticated user
+func (s *AuthService) issueSession(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	token, err := s.jwtMgr.GenerateToken(user)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	return &models.AuthResponse{
-		User:  *user,
+		User:  user,
 		Token: token,
 	}, nil
 }
 
-func (s *AuthService) GetUserByID(userID uuid.UUID) (*models.User, error) {
-	user, err := s.userRepo.GetByID(userID)
+// ValidateSession rejects tokens issued before the user's sessions were
+// revoked, as well as tokens of deleted or disabled users
+func (s *AuthService) ValidateSession(ctx context.Context, userID uuid.UUID, sessionVersion int) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ValidateSession")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.SessionVersion != sessionVersion {
+		return ErrSessionRevoked
+	}
+
+	if user.IsDisabled() {
+		return ErrAccountDisabled
+	}
+
+	return nil
+}
+
+func (s *AuthService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GetUserByID")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	return user, nil
 }
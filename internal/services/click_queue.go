@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"link-shortener/internal/live"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/metrics"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+)
+
+// ClickQueue records redirect clicks in the background so redirects never
+// wait on the database. When the queue is full a click is recorded in its own
+// goroutine, up to as many at a time as there are workers; beyond that, and
+// once the queue is closed, clicks are dropped.
+type ClickQueue struct {
+	linkRepo *repository.LinkRepository
+	live     *live.Hub
+	queue    chan queuedClick
+	overflow chan struct{}
+	workers  sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewClickQueue(linkRepo *repository.LinkRepository, size, workers int) *ClickQueue {
+	q := &ClickQueue{
+		linkRepo: linkRepo,
+		queue:    make(chan queuedClick, size),
+		overflow: make(chan struct{}, workers),
+	}
+
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for queued := range q.queue {
+				q.record(queued)
+			}
+		}()
+	}
+
+	return q
+}
+
+// SetLive makes the queue publish recorded clicks to the live streams of hub
+func (q *ClickQueue) SetLive(hub *live.Hub) {
+	q.live = hub
+}
+
+// queuedClick is a click waiting to be recorded with the logger of the
+// redirect that caused it
+type queuedClick struct {
+	click  *models.ClickEvent
+	logger *slog.Logger
+}
+
+// Enqueue schedules a click to be recorded; failures are logged through the
+// logger of ctx
+func (q *ClickQueue) Enqueue(ctx context.Context, click *models.ClickEvent) {
+	queued := queuedClick{click: click, logger: logging.FromContext(ctx)}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		metrics.ClicksDropped.Inc("closed")
+		return
+	}
+
+	select {
+	case q.queue <- queued:
+		return
+	default:
+	}
+
+	select {
+	case q.overflow <- struct{}{}:
+	default:
+		metrics.ClicksDropped.Inc("full")
+		return
+	}
+	metrics.ClickQueueOverflow.Inc()
+	q.workers.Add(1)
+	go func() {
+		defer q.workers.Done()
+		defer func() { <-q.overflow }()
+		q.record(queued)
+	}()
+}
+
+// Depth is the number of clicks waiting to be recorded
+func (q *ClickQueue) Depth() int {
+	return len(q.queue)
+}
+
+// Capacity is the queue size
+func (q *ClickQueue) Capacity() int {
+	return cap(q.queue)
+}
+
+// Close stops accepting clicks and waits for queued and overflowing ones to
+// be recorded
+func (q *ClickQueue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	q.workers.Wait()
+}
+
+// record runs after the redirect has been served, so it is not part of the
+// request's trace
+func (q *ClickQueue) record(queued queuedClick) {
+	ctx, click := context.Background(), queued.click
+	err := q.linkRepo.RecordClick(ctx, click)
+	if errors.Is(err, repository.ErrLinkNotFound) {
+		// The link was deleted after the redirect
+		metrics.ClicksRecorded.Inc("ok")
+		return
+	}
+	if err != nil {
+		metrics.ClicksRecorded.Inc("error")
+		queued.logger.Error("Failed to record click", "link_id", click.LinkID, "error", err)
+		return
+	}
+	metrics.ClicksRecorded.Inc("ok")
+
+	if q.live != nil {
+		q.live.Publish(click)
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import "link-shortener/internal/apperror"
+
+// ErrForbidden is returned when the user may not act on an existing resource
+var ErrForbidden = apperror.Forbidden("forbidden", "you do not have access to this resource")
+
+// Link errors
+var (
+	ErrInvalidURL         = apperror.Validation("invalid_url", "invalid URL")
+	ErrInvalidAlias       = apperror.Validation("invalid_alias", "invalid custom alias")
+	ErrInvalidTitle       = apperror.Validation("invalid_title", "invalid title")
+	ErrInvalidTags        = apperror.Validation("invalid_tags", "invalid tags")
+	ErrDomainBlocked      = apperror.Validation("domain_blocked", "destination domain is blocked")
+	ErrAliasTaken         = apperror.Conflict("alias_taken", "custom alias already exists")
+	ErrLinkTakenDown      = apperror.Forbidden("link_taken_down", "link has been taken down by an administrator")
+	ErrLinkInactive       = apperror.NotFound("link_inactive", "link is inactive")
+	ErrLinkExpired        = apperror.Expired("link_expired", "link has expired")
+	ErrDestinationBlocked = apperror.Forbidden("destination_blocked", "link destination is blocked")
+)
+
+// Bulk request errors
+var (
+	ErrTooManyItems   = apperror.Validation("too_many_items", "too many items in one request")
+	ErrBulkTarget     = apperror.Validation("invalid_bulk_target", "name the links either by ids or by filter")
+	ErrDuplicateItem  = apperror.Validation("duplicate_item", "item repeats an earlier item")
+	ErrBulkRejected   = apperror.Validation("bulk_rejected", "no changes were made because some items failed")
+	ErrBulkIncomplete = apperror.Unavailable("bulk_incomplete", "some items could not be processed; retry them")
+)
+
+// Import errors
+var (
+	ErrInvalidConflictPolicy = apperror.Validation("invalid_conflict_policy", "conflict policy must be skip, rename or fail")
+	ErrEmptyImport           = apperror.Validation("empty_import", "the import file has no links")
+	ErrImportConflicts       = apperror.Conflict("import_conflicts", "some short codes are already taken; nothing was imported")
+	ErrImportInterrupted     = apperror.Unavailable("import_interrupted", "the import was interrupted; import the remaining rows again")
+)
+
+// ErrLiveUnavailable is returned when live click streams are not set up
+var ErrLiveUnavailable = apperror.Unavailable("live_unavailable", "live click streams are not available")
+
+// Export errors
+var (
+	ErrInvalidExportRange = apperror.Validation("invalid_export_range", "from must be before to")
+	ErrTooManyExports     = apperror.RateLimited("too_many_exports", "too many exports are running; try again later")
+)
+
+// Webhook errors
+var (
+	ErrInvalidWebhookURL     = apperror.Validation("invalid_webhook_url", "invalid webhook URL")
+	ErrTooManyWebhooks       = apperror.Conflict("too_many_webhooks", "webhook limit reached")
+	ErrInvalidDeliveryStatus = apperror.Validation("invalid_delivery_status", "status must be pending, delivered or dead")
+	ErrDeliveryPending       = apperror.Conflict("delivery_pending", "delivery is already waiting to be sent")
+)
+
+// Account errors
+var (
+	ErrEmailTaken              = apperror.Conflict("email_taken", "email already exists")
+	ErrUsernameTaken           = apperror.Conflict("username_taken", "username already exists")
+	ErrInvalidCredentials      = apperror.Unauthorized("invalid_credentials", "invalid credentials")
+	ErrAccountDisabled         = apperror.Forbidden("account_disabled", "account disabled")
+	ErrSessionRevoked          = apperror.Unauthorized("session_revoked", "session revoked")
+	ErrInvalidToken            = apperror.Validation("invalid_token", "invalid or expired token")
+	ErrTokenExpired            = apperror.Expired("token_expired", "token has expired")
+	ErrEmailAlreadyVerified    = apperror.Conflict("email_already_verified", "email already verified")
+	ErrSameEmail               = apperror.Validation("same_email", "new email is the same as the current one")
+	ErrDeletionNotScheduled    = apperror.Conflict("deletion_not_scheduled", "account is not scheduled for deletion")
+	ErrInvalidChallenge        = apperror.Unauthorized("invalid_challenge", "invalid or expired challenge")
+	ErrInvalidTwoFactorCode    = apperror.Unauthorized("invalid_two_factor_code", "invalid two-factor code")
+	ErrTwoFactorEnabled        = apperror.Conflict("two_factor_enabled", "two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled     = apperror.Conflict("two_factor_not_enabled", "two-factor authentication is not enabled")
+	ErrTwoFactorSetupMissing   = apperror.Conflict("two_factor_setup_missing", "two-factor setup has not been started")
+	ErrInvalidLoginState       = apperror.Unauthorized("invalid_login_state", "invalid or expired login state")
+	ErrProviderUnavailable     = apperror.Unavailable("provider_unavailable", "identity provider unavailable")
+	ErrProviderLoginFailed     = apperror.Unauthorized("provider_login_failed", "sign-in with the identity provider failed")
+	ErrProviderEmailMissing    = apperror.Unauthorized("provider_email_missing", "identity provider did not return an email address")
+	ErrProviderEmailUnverified = apperror.Conflict("provider_email_unverified", "an account with this email already exists; the provider must verify the email before it can be linked")
+	ErrIdentityNotLinked       = apperror.Unauthorized("identity_not_linked", "no account is linked to this identity")
+)
+
+// Administration errors
+var (
+	ErrDisableSelf        = apperror.Validation("disable_self", "cannot disable your own account")
+	ErrRemoveOwnAdminRole = apperror.Validation("remove_own_admin_role", "cannot remove your own admin role")
+	ErrInvalidRole        = apperror.Validation("invalid_role", "invalid role")
+	ErrInvalidPermission  = apperror.Validation("invalid_permission", "invalid permission")
+)
+
+// Workspace errors
+var (
+	ErrChangeOwnRole           = apperror.Validation("change_own_role", "use ownership transfer to change your own role")
+	ErrRemoveSelf              = apperror.Validation("remove_self", "use leave to remove yourself")
+	ErrOwnerMustTransfer       = apperror.Conflict("owner_must_transfer", "the owner must transfer ownership before leaving")
+	ErrAlreadyOwner            = apperror.Conflict("already_owner", "you already own this workspace")
+	ErrInvalidInvitation       = apperror.Validation("invalid_invitation", "invalid or expired invitation")
+	ErrInvitationExpired       = apperror.Expired("invitation_expired", "invitation has expired")
+	ErrInvitationEmailMismatch = apperror.Forbidden("invitation_email_mismatch", "this invitation was sent to a different email address")
+	ErrTransferToSelf          = apperror.Validation("transfer_to_self", "links must be transferred to another member")
+	ErrTransferTargetRole      = apperror.Validation("transfer_target_role", "links can only be transferred to an editor or the owner")
+)
+
+// Idempotency errors
+var (
+	ErrIdempotencyKeyReused  = apperror.Conflict("idempotency_key_reused", "idempotency key was already used for a different request")
+	ErrIdempotencyInProgress = apperror.Conflict("idempotency_request_in_progress", "a request with this idempotency key is still in progress; retry later")
+)
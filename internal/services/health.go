@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"link-shortener/internal/config"
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+	"link-shortener/migrations"
+)
+
+// HealthService runs the readiness checks behind /readyz
+type HealthService struct {
+	db           *database.Database
+	clicks       *ClickQueue
+	cfg          config.HealthConfig
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(db *database.Database, clicks *ClickQueue, cfg config.HealthConfig) *HealthService {
+	return &HealthService{
+		db:     db,
+		clicks: clicks,
+		cfg:    cfg,
+	}
+}
+
+// SetShuttingDown makes every later readiness check fail without touching
+// the dependencies
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readiness checks the database, the schema version and the click backlog
+func (s *HealthService) Readiness(ctx context.Context) *models.ReadinessResponse {
+	if s.shuttingDown.Load() {
+		return &models.ReadinessResponse{Status: models.ReadinessShuttingDown}
+	}
+
+	components := map[string]*models.ComponentHealth{
+		"database":   s.check(ctx, s.checkDatabase),
+		"migrations": s.check(ctx, s.checkMigrations),
+		"clicks":     s.check(ctx, s.checkClicks),
+	}
+
+	status := models.ReadinessReady
+	for _, component := range components {
+		if component.Status != models.HealthStatusUp {
+			status = models.ReadinessNotReady
+		}
+	}
+	return &models.ReadinessResponse{Status: status, Components: components}
+}
+
+func (s *HealthService) check(ctx context.Context, run func(context.Context) (map[string]interface{}, error)) *models.ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.CheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := run(ctx)
+	component := &models.ComponentHealth{
+		Status:    models.HealthStatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		component.Status = models.HealthStatusDown
+		component.Error = err.Error()
+	}
+	return component
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) (map[string]interface{}, error) {
+	return nil, s.db.Ping(ctx)
+}
+
+// checkMigrations fails unless every migration file has been recorded as
+// applied
+func (s *HealthService) checkMigrations(ctx context.Context) (map[string]interface{}, error) {
+	applied, err := s.db.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[int]bool, len(applied))
+	version := 0
+	for _, v := range applied {
+		recorded[v] = true
+		if v > version {
+			version = v
+		}
+	}
+	missing := []int{}
+	for _, v := range migrations.Versions() {
+		if !recorded[v] {
+			missing = append(missing, v)
+		}
+	}
+
+	details := map[string]interface{}{"version": version, "expected": migrations.Latest()}
+	if len(missing) > 0 {
+		details["missing"] = missing
+		return details, fmt.Errorf("migrations not applied: %v", missing)
+	}
+	return details, nil
+}
+
+func (s *HealthService) checkClicks(ctx context.Context) (map[string]interface{}, error) {
+	depth, capacity := s.clicks.Depth(), s.clicks.Capacity()
+	details := map[string]interface{}{"depth": depth, "capacity": capacity}
+	if float64(depth) > s.cfg.ClickBacklogRatio*float64(capacity) {
+		return details, fmt.Errorf("click backlog is %d of %d", depth, capacity)
+	}
+	return details, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"link-shortener/internal/config"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyService remembers the responses to requests sent with an
+// Idempotency-Key so that a retry is answered without doing the work twice
+type IdempotencyService struct {
+	repo *repository.IdempotencyRepository
+	cfg  config.IdempotencyConfig
+}
+
+func NewIdempotencyService(repo *repository.IdempotencyRepository, cfg config.IdempotencyConfig) *IdempotencyService {
+	return &IdempotencyService{repo: repo, cfg: cfg}
+}
+
+// Begin claims the key for a request with the given fingerprint. When the
+// key was used before, the stored response is returned for the same request,
+// ErrIdempotencyKeyReused for a different one and ErrIdempotencyInProgress
+// while the first request is still running. Otherwise the returned lock ID
+// must be passed to Complete or Release.
+func (s *IdempotencyService) Begin(ctx context.Context, userID uuid.UUID, key, fingerprint string) (*models.StoredResponse, uuid.UUID, error) {
+	ctx, span := tracing.Start(ctx, "IdempotencyService.Begin")
+	defer span.End()
+
+	// The record can expire or be released between the claim and the read;
+	// claiming again then succeeds unless another retry got there first
+	for attempt := 0; attempt < 2; attempt++ {
+		lockID, err := s.repo.Claim(ctx, userID, key, fingerprint, s.cfg.TTL, s.cfg.LockTimeout)
+		if err != nil {
+			return nil, uuid.Nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+		if lockID != uuid.Nil {
+			return nil, lockID, nil
+		}
+
+		record, err := s.repo.Get(ctx, userID, key)
+		if errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, uuid.Nil, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+
+		if record.Fingerprint != fingerprint {
+			return nil, uuid.Nil, ErrIdempotencyKeyReused
+		}
+		if record.StatusCode == nil {
+			return nil, uuid.Nil, ErrIdempotencyInProgress
+		}
+		return &models.StoredResponse{
+			StatusCode:  *record.StatusCode,
+			ContentType: record.ContentType,
+			Body:        record.Body,
+		}, uuid.Nil, nil
+	}
+	return nil, uuid.Nil, ErrIdempotencyInProgress
+}
+
+// Complete stores the response to replay for the key
+func (s *IdempotencyService) Complete(ctx context.Context, userID uuid.UUID, key string, lockID uuid.UUID, resp *models.StoredResponse) error {
+	completed, err := s.repo.Complete(ctx, userID, key, lockID, resp)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	if !completed {
+		logging.FromContext(ctx).Warn("Idempotency key was taken over before its request finished", "user_id", userID, "key", key)
+	}
+	return nil
+}
+
+// Release forgets the key after a request that should be retried, such as
+// one that failed with a server error
+func (s *IdempotencyService) Release(ctx context.Context, userID uuid.UUID, key string, lockID uuid.UUID) error {
+	if err := s.repo.Release(ctx, userID, key, lockID); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired removes keys older than the configured window
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpired(ctx)
+}
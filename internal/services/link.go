@@ -1,106 +1,204 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"link-shortener/internal/config"
+	"link-shortener/internal/live"
+	"link-shortener/internal/metrics"
 	"link-shortener/internal/models"
 	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
 	"link-shortener/internal/utils"
 )
 
+// maxTitleLength matches the limit on the title of a create request
+const maxTitleLength = 255
+
 type LinkService struct {
-	linkRepo *repository.LinkRepository
-	baseURL  string
+	linkRepo      *repository.LinkRepository
+	workspaceRepo *repository.WorkspaceRepository
+	baseURL       string
+	cfg           config.LinkConfig
+	blocklist     *utils.Blocklist
+	clicks        *ClickQueue
+	cache         *linkCache
+	live          *live.Hub
+
+	// exports holds a token per running export; nil means no limit
+	exports chan struct{}
 }
 
-func NewLinkService(linkRepo *repository.LinkRepository, baseURL string) *LinkService {
+func NewLinkService(linkRepo *repository.LinkRepository, workspaceRepo *repository.WorkspaceRepository, baseURL string, cfg config.LinkConfig, blocklist *utils.Blocklist, clicks *ClickQueue) *LinkService {
 	return &LinkService{
-		linkRepo: linkRepo,
-		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		linkRepo:      linkRepo,
+		workspaceRepo: workspaceRepo,
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		cfg:           cfg,
+		blocklist:     blocklist,
+		clicks:        clicks,
+		cache:         newLinkCache(cfg.CacheTTL, cfg.CacheSize),
+		exports:       newSemaphore(cfg.ExportMaxConcurrent),
 	}
 }
 
-func (s *LinkService) CreateLink(userID uuid.UUID, req *models.CreateLinkRequest) (*models.LinkResponse, error) {
-	// Validate and sanitize URL
-	if err := utils.ValidateURL(req.OriginalURL); err != nil {
-		return nil, fmt.Errorf("invalid URL: %w", err)
+// CreateLink creates a personal link, or a workspace link when
+// req.WorkspaceID is set and the user is at least an editor there
+func (s *LinkService) CreateLink(ctx context.Context, userID uuid.UUID, req *models.CreateLinkRequest) (*models.LinkResponse, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.CreateLink")
+	defer span.End()
+
+	link, err := s.newLink(ctx, userID, req, s.workspaceRole(userID, models.WorkspaceRoleEditor))
+	if err != nil {
+		return nil, err
 	}
-	
-	originalURL := utils.SanitizeURL(req.OriginalURL)
 
-	// Generate or use custom short code
-	var shortCode string
-	if req.CustomAlias != "" {
-		// Validate custom alias
-		if err := utils.ValidateShortCode(req.CustomAlias); err != nil {
-			return nil, fmt.Errorf("invalid custom alias: %w", err)
-		}
-		
+	custom := link.ShortCode != ""
+	if custom {
 		// Check if custom alias already exists
-		exists, err := s.linkRepo.ShortCodeExists(req.CustomAlias)
+		exists, err := s.linkRepo.ShortCodeExists(ctx, link.ShortCode)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check short code: %w", err)
 		}
 		if exists {
-			return nil, fmt.Errorf("custom alias already exists")
-		}
-		shortCode = req.CustomAlias
-	} else {
-		// Generate random short code
-		for {
-			generatedCode, err := utils.GenerateShortCode(8)
-			if err != nil {
-				return nil, fmt.Errorf("failed to generate short code: %w", err)
-			}
-			
-			exists, err := s.linkRepo.ShortCodeExists(generatedCode)
-			if err != nil {
-				return nil, fmt.Errorf("failed to check short code: %w", err)
-			}
-			
-			if !exists {
-				shortCode = generatedCode
-				break
+			return nil, ErrAliasTaken
+		}
+	}
+
+	// A concurrent request can still take the code before the insert
+	for attempt := 1; ; attempt++ {
+		if !custom {
+			if link.ShortCode, err = s.freeShortCode(ctx); err != nil {
+				return nil, err
 			}
 		}
+
+		err := s.linkRepo.Create(ctx, link)
+		switch {
+		case err == nil:
+			return s.toLinkResponse(link), nil
+		case !errors.Is(err, repository.ErrShortCodeTaken):
+			return nil, fmt.Errorf("failed to create link: %w", err)
+		case custom:
+			return nil, ErrAliasTaken
+		case attempt == maxShortCodeAttempts:
+			return nil, fmt.Errorf("failed to find a free short code after %d attempts", maxShortCodeAttempts)
+		}
+	}
+}
+
+// freeShortCode generates random short codes until one is not taken
+func (s *LinkService) freeShortCode(ctx context.Context) (string, error) {
+	for {
+		code, err := utils.GenerateShortCode(s.cfg.ShortCodeLength)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate short code: %w", err)
+		}
+
+		exists, err := s.linkRepo.ShortCodeExists(ctx, code)
+		if err != nil {
+			return "", fmt.Errorf("failed to check short code: %w", err)
+		}
+		if !exists {
+			return code, nil
+		}
+	}
+}
+
+// newLink validates a create request and returns the link it describes. The
+// short code is left empty unless a custom alias was requested. checkWorkspace
+// is consulted for workspace links.
+func (s *LinkService) newLink(ctx context.Context, userID uuid.UUID, req *models.CreateLinkRequest, checkWorkspace func(context.Context, uuid.UUID) error) (*models.Link, error) {
+	if req.WorkspaceID != nil {
+		if err := checkWorkspace(ctx, *req.WorkspaceID); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create link
-	link := &models.Link{
+	// Validate and sanitize URL
+	if err := utils.ValidateURL(req.OriginalURL); err != nil {
+		return nil, ErrInvalidURL.WithField("original_url", err.Error()).Wrap(err)
+	}
+	if s.blocklist.Blocks(req.OriginalURL) {
+		return nil, ErrDomainBlocked.WithField("original_url", "destination domain is blocked")
+	}
+
+	// Validate custom alias
+	if req.CustomAlias != "" {
+		if err := utils.ValidateShortCode(req.CustomAlias); err != nil {
+			return nil, ErrInvalidAlias.WithField("custom_alias", err.Error()).Wrap(err)
+		}
+	}
+	if utf8.RuneCountInString(req.Title) > maxTitleLength {
+		return nil, ErrInvalidTitle.WithField("title", fmt.Sprintf("must be at most %d characters", maxTitleLength))
+	}
+	tags, err := utils.NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, ErrInvalidTags.WithField("tags", err.Error()).Wrap(err)
+	}
+
+	return &models.Link{
 		ID:          uuid.New(),
 		UserID:      userID,
-		OriginalURL: originalURL,
-		ShortCode:   shortCode,
+		WorkspaceID: req.WorkspaceID,
+		OriginalURL: utils.SanitizeURL(req.OriginalURL),
+		ShortCode:   req.CustomAlias,
 		Title:       req.Title,
+		Tags:        tags,
 		ExpiresAt:   req.ExpiresAt,
 		IsActive:    true,
+	}, nil
+}
+
+func (s *LinkService) GetLinkByID(ctx context.Context, userID, linkID uuid.UUID) (*models.LinkResponse, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.GetLinkByID")
+	defer span.End()
+
+	link, err := s.linkRepo.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link: %w", err)
 	}
 
-	if err := s.linkRepo.Create(link); err != nil {
-		return nil, fmt.Errorf("failed to create link: %w", err)
+	if err := s.authorize(ctx, userID, link, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
 	}
 
 	return s.toLinkResponse(link), nil
 }
 
-func (s *LinkService) GetLinkByID(userID, linkID uuid.UUID) (*models.LinkResponse, error) {
-	link, err := s.linkRepo.GetByID(linkID)
+func (s *LinkService) GetLinksByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.LinkResponse, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.GetLinksByUserID")
+	defer span.End()
+
+	links, err := s.linkRepo.GetByUserID(ctx, userID, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("link not found: %w", err)
+		return nil, fmt.Errorf("failed to get links: %w", err)
 	}
 
-	// Check if user owns this link
-	if link.UserID != userID {
-		return nil, fmt.Errorf("unauthorized")
+	var responses []*models.LinkResponse
+	for _, link := range links {
+		responses = append(responses, s.toLinkResponse(link))
 	}
 
-	return s.toLinkResponse(link), nil
+	return responses, nil
 }
 
-func (s *LinkService) GetLinksByUserID(userID uuid.UUID, limit, offset int) ([]*models.LinkResponse, error) {
-	links, err := s.linkRepo.GetByUserID(userID, limit, offset)
+// GetLinksByWorkspaceID lists the links of a workspace the user belongs to
+func (s *LinkService) GetLinksByWorkspaceID(ctx context.Context, userID, workspaceID uuid.UUID, limit, offset int) ([]*models.LinkResponse, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.GetLinksByWorkspaceID")
+	defer span.End()
+
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	links, err := s.linkRepo.GetByWorkspaceID(ctx, workspaceID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get links: %w", err)
 	}
@@ -113,38 +211,44 @@ func (s *LinkService) GetLinksByUserID(userID uuid.UUID, limit, offset int) ([]*
 	return responses, nil
 }
 
-func (s *LinkService) UpdateLink(userID, linkID uuid.UUID, req *models.UpdateLinkRequest) (*models.LinkResponse, error) {
+func (s *LinkService) UpdateLink(ctx context.Context, userID, linkID uuid.UUID, req *models.UpdateLinkRequest) (*models.LinkResponse, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.UpdateLink")
+	defer span.End()
+
 	// Get existing link
-	link, err := s.linkRepo.GetByID(linkID)
+	link, err := s.linkRepo.GetByID(ctx, linkID)
 	if err != nil {
-		return nil, fmt.Errorf("link not found: %w", err)
+		return nil, fmt.Errorf("failed to get link: %w", err)
 	}
 
-	// Check if user owns this link
-	if link.UserID != userID {
-		return nil, fmt.Errorf("unauthorized")
+	if err := s.authorize(ctx, userID, link, models.WorkspaceRoleEditor); err != nil {
+		return nil, err
 	}
+	previousCode := link.ShortCode
 
 	// Update fields if provided
 	if req.OriginalURL != "" {
 		if err := utils.ValidateURL(req.OriginalURL); err != nil {
-			return nil, fmt.Errorf("invalid URL: %w", err)
+			return nil, ErrInvalidURL.WithField("original_url", err.Error()).Wrap(err)
+		}
+		if s.blocklist.Blocks(req.OriginalURL) {
+			return nil, ErrDomainBlocked.WithField("original_url", "destination domain is blocked")
 		}
 		link.OriginalURL = utils.SanitizeURL(req.OriginalURL)
 	}
 
 	if req.CustomAlias != "" {
 		if err := utils.ValidateShortCode(req.CustomAlias); err != nil {
-			return nil, fmt.Errorf("invalid custom alias: %w", err)
+			return nil, ErrInvalidAlias.WithField("custom_alias", err.Error()).Wrap(err)
 		}
-		
+
 		// Check if new alias already exists (excluding current link)
-		exists, err := s.linkRepo.ShortCodeExists(req.CustomAlias)
+		exists, err := s.linkRepo.ShortCodeExists(ctx, req.CustomAlias)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check short code: %w", err)
 		}
 		if exists && req.CustomAlias != link.ShortCode {
-			return nil, fmt.Errorf("custom alias already exists")
+			return nil, ErrAliasTaken
 		}
 		link.ShortCode = req.CustomAlias
 	}
@@ -154,6 +258,9 @@ func (s *LinkService) UpdateLink(userID, linkID uuid.UUID, req *models.UpdateLin
 	}
 
 	if req.IsActive != nil {
+		if *req.IsActive && link.TakenDownAt != nil {
+			return nil, ErrLinkTakenDown
+		}
 		link.IsActive = *req.IsActive
 	}
 
@@ -161,60 +268,180 @@ func (s *LinkService) UpdateLink(userID, linkID uuid.UUID, req *models.UpdateLin
 		link.ExpiresAt = req.ExpiresAt
 	}
 
+	if req.Tags != nil {
+		if link.Tags, err = utils.NormalizeTags(req.Tags); err != nil {
+			return nil, ErrInvalidTags.WithField("tags", err.Error()).Wrap(err)
+		}
+	}
+
 	// Update link
-	if err := s.linkRepo.Update(link); err != nil {
+	if err := s.linkRepo.Update(ctx, link); err != nil {
+		if errors.Is(err, repository.ErrShortCodeTaken) {
+			return nil, ErrAliasTaken
+		}
 		return nil, fmt.Errorf("failed to update link: %w", err)
 	}
+	s.cache.remove(previousCode)
 
 	return s.toLinkResponse(link), nil
 }
 
-func (s *LinkService) DeleteLink(userID, linkID uuid.UUID) error {
-	// Check if link exists and user owns it
-	link, err := s.linkRepo.GetByID(linkID)
+func (s *LinkService) DeleteLink(ctx context.Context, userID, linkID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "LinkService.DeleteLink")
+	defer span.End()
+
+	// Check if link exists and user may delete it
+	link, err := s.linkRepo.GetByID(ctx, linkID)
 	if err != nil {
-		return fmt.Errorf("link not found: %w", err)
+		return fmt.Errorf("failed to get link: %w", err)
 	}
 
-	if link.UserID != userID {
-		return fmt.Errorf("unauthorized")
+	if err := s.authorize(ctx, userID, link, models.WorkspaceRoleEditor); err != nil {
+		return err
 	}
 
-	return s.linkRepo.Delete(linkID, userID)
+	if err := s.linkRepo.Delete(ctx, linkID); err != nil {
+		return err
+	}
+	s.cache.remove(link.ShortCode)
+	return nil
 }
 
-func (s *LinkService) RedirectToOriginal(shortCode string) (string, error) {
-	link, err := s.linkRepo.GetByShortCode(shortCode)
-	if err != nil {
-		return "", fmt.Errorf("link not found: %w", err)
+// RedirectToOriginal resolves a short code and queues the click, with the
+// referrer and user agent of visit. Outcomes are counted in the
+// redirects_total metric and recorded on the span.
+func (s *LinkService) RedirectToOriginal(ctx context.Context, shortCode string, visit models.ClickEvent) (string, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.RedirectToOriginal", tracing.String("link.short_code", shortCode))
+	defer span.End()
+
+	record := func(outcome string) {
+		metrics.Redirects.Inc(outcome)
+		span.SetAttributes(tracing.String("redirect.outcome", outcome))
 	}
 
-	// Increment click count
-	go func() {
-		if err := s.linkRepo.IncrementClicks(link.ID); err != nil {
-			// Log error but don't fail the redirect
-			fmt.Printf("Failed to increment clicks: %v\n", err)
+	link, ok := s.cache.get(shortCode)
+	span.SetAttributes(tracing.Bool("link.cache_hit", ok))
+	if !ok {
+		var err error
+		link, err = s.linkRepo.GetByShortCode(ctx, shortCode)
+		if err != nil {
+			record("miss")
+			return "", fmt.Errorf("failed to get link: %w", err)
 		}
-	}()
+		s.cache.put(link)
+	}
+
+	if !link.IsActive {
+		record("inactive")
+		return "", ErrLinkInactive
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		record("expired")
+		return "", ErrLinkExpired
+	}
+
+	// Domains blocked after the link was created stop redirecting too
+	if s.blocklist.Blocks(link.OriginalURL) {
+		record("blocked")
+		return "", ErrDestinationBlocked
+	}
+
+	record("hit")
+	visit.LinkID, visit.ShortCode, visit.ClickedAt = link.ID, link.ShortCode, time.Now().UTC()
+	visit.UserID, visit.WorkspaceID = link.UserID, link.WorkspaceID
+	s.clicks.Enqueue(ctx, &visit)
 
 	return link.OriginalURL, nil
 }
 
-func (s *LinkService) GetStats(userID uuid.UUID) (*models.LinkStats, error) {
-	return s.linkRepo.GetStats(userID)
+// ForgetLink drops links from the redirect cache after they changed outside
+// this service. It does nothing on a nil service.
+func (s *LinkService) ForgetLink(shortCodes ...string) {
+	if s == nil {
+		return
+	}
+	for _, code := range shortCodes {
+		s.cache.remove(code)
+	}
+}
+
+// CacheSize is the number of links in the redirect cache
+func (s *LinkService) CacheSize() int {
+	return s.cache.size()
+}
+
+func (s *LinkService) GetStats(ctx context.Context, userID uuid.UUID) (*models.LinkStats, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.GetStats")
+	defer span.End()
+
+	return s.linkRepo.GetStats(ctx, userID)
+}
+
+func (s *LinkService) GetWorkspaceStats(ctx context.Context, userID, workspaceID uuid.UUID) (*models.LinkStats, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.GetWorkspaceStats")
+	defer span.End()
+
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+	return s.linkRepo.GetWorkspaceStats(ctx, workspaceID)
+}
+
+// authorize checks access to a link. Personal links are only accessible to
+// their creator; workspace links need at least the required workspace role.
+func (s *LinkService) authorize(ctx context.Context, userID uuid.UUID, link *models.Link, required string) error {
+	return authorizeLink(ctx, userID, link, s.workspaceAccess(userID, required))
+}
+
+func authorizeLink(ctx context.Context, userID uuid.UUID, link *models.Link, checkWorkspace func(context.Context, uuid.UUID) error) error {
+	if link.WorkspaceID == nil {
+		if link.UserID != userID {
+			return ErrForbidden
+		}
+		return nil
+	}
+	return checkWorkspace(ctx, *link.WorkspaceID)
+}
+
+// workspaceRole returns a check that the user holds at least the required
+// role in a workspace
+func (s *LinkService) workspaceRole(userID uuid.UUID, required string) func(context.Context, uuid.UUID) error {
+	return func(ctx context.Context, workspaceID uuid.UUID) error {
+		_, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, required)
+		return err
+	}
+}
+
+// workspaceAccess is workspaceRole for links that already exist, where a
+// workspace the user does not belong to is reported as forbidden
+func (s *LinkService) workspaceAccess(userID uuid.UUID, required string) func(context.Context, uuid.UUID) error {
+	checkRole := s.workspaceRole(userID, required)
+	return func(ctx context.Context, workspaceID uuid.UUID) error {
+		if err := checkRole(ctx, workspaceID); err != nil {
+			if errors.Is(err, repository.ErrWorkspaceNotFound) {
+				return ErrForbidden
+			}
+			return err
+		}
+		return nil
+	}
 }
 
 func (s *LinkService) toLinkResponse(link *models.Link) *models.LinkResponse {
 	return &models.LinkResponse{
-		ID:          link.ID,
-		OriginalURL: link.OriginalURL,
-		ShortCode:   link.ShortCode,
-		ShortURL:    fmt.Sprintf("%s/r/%s", s.baseURL, link.ShortCode),
-		Title:       link.Title,
-		Clicks:      link.Clicks,
-		IsActive:    link.IsActive,
-		ExpiresAt:   link.ExpiresAt,
-		CreatedAt:   link.CreatedAt,
-		UpdatedAt:   link.UpdatedAt,
+		ID:             link.ID,
+		WorkspaceID:    link.WorkspaceID,
+		OriginalURL:    link.OriginalURL,
+		ShortCode:      link.ShortCode,
+		ShortURL:       fmt.Sprintf("%s/r/%s", s.baseURL, link.ShortCode),
+		Title:          link.Title,
+		Tags:           link.Tags,
+		Clicks:         link.Clicks,
+		IsActive:       link.IsActive,
+		ExpiresAt:      link.ExpiresAt,
+		TakenDownAt:    link.TakenDownAt,
+		TakedownReason: link.TakedownReason,
+		CreatedAt:      link.CreatedAt,
+		UpdatedAt:      link.UpdatedAt,
 	}
 }
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
+	"link-shortener/internal/utils"
+)
+
+// maxShortCodeAttempts bounds how often a bulk insert regenerates short codes
+// that collided with existing ones
+const maxShortCodeAttempts = 5
+
+// CreateLinks creates many links at once. In atomic mode either every link
+// is created or none is and ErrBulkRejected lists the items that failed; in
+// partial mode the valid links are created and the rest reported per item.
+func (s *LinkService) CreateLinks(ctx context.Context, userID uuid.UUID, req *models.BulkCreateLinksRequest) (*models.BulkResult, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.CreateLinks", tracing.Int("bulk.items", len(req.Links)))
+	defer span.End()
+
+	if len(req.Links) > s.cfg.BulkMaxItems {
+		return nil, ErrTooManyItems.WithField("links", fmt.Sprintf("must contain at most %d items", s.cfg.BulkMaxItems))
+	}
+
+	batch := newBulkBatch(req.Mode, len(req.Links))
+	checkWorkspace := memoize(s.workspaceRole(userID, models.WorkspaceRoleEditor))
+	links := make(map[int]*models.Link, len(req.Links))
+	aliases := map[string]bool{}
+	var pending []int
+	for i := range req.Links {
+		link, err := s.newLink(ctx, userID, &req.Links[i], checkWorkspace)
+		if err == nil && aliases[link.ShortCode] {
+			err = ErrDuplicateItem.WithField("custom_alias", "is used by an earlier item")
+		}
+		if err != nil {
+			batch.fail(i, nil, err)
+			continue
+		}
+
+		if link.ShortCode == "" {
+			if link.ShortCode, err = utils.GenerateShortCode(s.cfg.ShortCodeLength); err != nil {
+				return nil, fmt.Errorf("failed to generate short code: %w", err)
+			}
+		} else {
+			aliases[link.ShortCode] = true
+		}
+		links[i] = link
+		pending = append(pending, i)
+	}
+	if batch.rejected() {
+		return nil, batch.rejection("links")
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		if attempt > maxShortCodeAttempts {
+			err := fmt.Errorf("failed to find free short codes after %d attempts", maxShortCodeAttempts)
+			if batch.atomic() {
+				// Every attempt was rolled back
+				return nil, err
+			}
+			// Earlier attempts stay created; report the rest
+			logging.FromContext(ctx).Error("Failed to create links", "error", err)
+			for _, i := range pending {
+				batch.fail(i, nil, ErrBulkIncomplete.Wrap(err))
+			}
+			break
+		}
+
+		insert := make([]*models.Link, len(pending))
+		for j, i := range pending {
+			insert[j] = links[i]
+		}
+		// A code taken concurrently fails its batch, which the next
+		// attempt inserts again
+		created, err := s.linkRepo.CreateMany(ctx, insert, batch.atomic())
+		if err != nil && !errors.Is(err, repository.ErrShortCodeTaken) {
+			if batch.atomic() {
+				return nil, fmt.Errorf("failed to create links: %w", err)
+			}
+			// Batches committed before the error stay created
+			logging.FromContext(ctx).Error("Failed to create links", "error", err)
+			for _, i := range pending {
+				if created.Created[links[i].ID] {
+					batch.succeed(i, links[i].ID, s.toLinkResponse(links[i]))
+				} else {
+					batch.fail(i, nil, ErrBulkIncomplete.Wrap(err))
+				}
+			}
+			break
+		}
+
+		var retry []int
+		for _, i := range pending {
+			link := links[i]
+			switch {
+			case created.Created[link.ID]:
+				batch.succeed(i, link.ID, s.toLinkResponse(link))
+			case !created.Conflicts[link.ID]:
+				// Rolled back with the rest; insert it again
+				retry = append(retry, i)
+			case req.Links[i].CustomAlias != "":
+				batch.fail(i, nil, ErrAliasTaken.WithField("custom_alias", "is already taken"))
+			default:
+				if link.ShortCode, err = utils.GenerateShortCode(s.cfg.ShortCodeLength); err != nil {
+					return nil, fmt.Errorf("failed to generate short code: %w", err)
+				}
+				retry = append(retry, i)
+			}
+		}
+		if batch.rejected() {
+			return nil, batch.rejection("links")
+		}
+		pending = retry
+	}
+
+	return batch.result, nil
+}
+
+// ApplyLinkAction activates, deactivates or deletes the links named in req,
+// with the same atomic and partial modes as CreateLinks. The user must be
+// able to edit every link.
+func (s *LinkService) ApplyLinkAction(ctx context.Context, userID uuid.UUID, action string, req *models.BulkLinkActionRequest) (*models.BulkResult, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.ApplyLinkAction", tracing.String("bulk.action", action))
+	defer span.End()
+
+	ids, field, err := s.resolveLinkIDs(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(tracing.Int("bulk.items", len(ids)))
+
+	batch := newBulkBatch(req.Mode, len(ids))
+	if len(ids) == 0 {
+		return batch.result, nil
+	}
+
+	found, err := s.linkRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get links: %w", err)
+	}
+	links := make(map[uuid.UUID]*models.Link, len(found))
+	for _, link := range found {
+		links[link.ID] = link
+	}
+
+	checkWorkspace := memoize(s.workspaceAccess(userID, models.WorkspaceRoleEditor))
+	seen := map[uuid.UUID]bool{}
+	var targets []uuid.UUID
+	for i, id := range ids {
+		id := id
+		link := links[id]
+		switch {
+		case seen[id]:
+			err = ErrDuplicateItem
+		case link == nil:
+			err = repository.ErrLinkNotFound
+		case action == models.BulkActionActivate && link.TakenDownAt != nil:
+			err = ErrLinkTakenDown
+		default:
+			err = authorizeLink(ctx, userID, link, checkWorkspace)
+		}
+		seen[id] = true
+		if err != nil {
+			batch.fail(i, &id, err)
+			continue
+		}
+		targets = append(targets, id)
+	}
+	if batch.rejected() {
+		return nil, batch.rejection(field)
+	}
+	if len(targets) == 0 {
+		return batch.result, nil
+	}
+
+	var changed map[uuid.UUID]bool
+	switch action {
+	case models.BulkActionActivate, models.BulkActionDeactivate:
+		changed, err = s.linkRepo.SetActiveMany(ctx, targets, action == models.BulkActionActivate, batch.atomic())
+	case models.BulkActionDelete:
+		changed, err = s.linkRepo.DeleteMany(ctx, targets, batch.atomic())
+	default:
+		return nil, fmt.Errorf("unknown bulk action %q", action)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s links: %w", action, err)
+	}
+
+	for i, id := range ids {
+		id := id
+		if batch.result.Results[i].Status == models.BulkItemFailed {
+			continue
+		}
+		if !changed[id] {
+			// Changed concurrently since it was loaded
+			batch.fail(i, &id, repository.ErrLinkNotFound)
+			continue
+		}
+		s.cache.remove(links[id].ShortCode)
+		batch.succeed(i, id, nil)
+	}
+	return batch.result, nil
+}
+
+// resolveLinkIDs returns the IDs a bulk action applies to and the request
+// field they came from
+func (s *LinkService) resolveLinkIDs(ctx context.Context, userID uuid.UUID, req *models.BulkLinkActionRequest) ([]uuid.UUID, string, error) {
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		return nil, "", ErrBulkTarget
+	}
+
+	if req.Filter == nil {
+		if len(req.IDs) > s.cfg.BulkMaxItems {
+			return nil, "", ErrTooManyItems.WithField("ids", fmt.Sprintf("must contain at most %d items", s.cfg.BulkMaxItems))
+		}
+		return req.IDs, "ids", nil
+	}
+
+	if req.Filter.WorkspaceID != nil {
+		if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, *req.Filter.WorkspaceID, userID, models.WorkspaceRoleEditor); err != nil {
+			return nil, "", err
+		}
+	}
+	ids, err := s.linkRepo.FindIDs(ctx, userID, req.Filter, s.cfg.BulkMaxItems+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find links: %w", err)
+	}
+	if len(ids) > s.cfg.BulkMaxItems {
+		return nil, "", ErrTooManyItems.WithField("filter", fmt.Sprintf("matches more than %d links", s.cfg.BulkMaxItems))
+	}
+	return ids, "filter", nil
+}
+
+// bulkBatch collects the per-item outcomes of a bulk request
+type bulkBatch struct {
+	result *models.BulkResult
+}
+
+func newBulkBatch(mode string, items int) *bulkBatch {
+	if mode == "" {
+		mode = models.BulkModeAtomic
+	}
+	results := make([]*models.BulkItemResult, items)
+	for i := range results {
+		results[i] = &models.BulkItemResult{Index: i}
+	}
+	return &bulkBatch{result: &models.BulkResult{Mode: mode, Results: results}}
+}
+
+func (b *bulkBatch) atomic() bool {
+	return b.result.Mode == models.BulkModeAtomic
+}
+
+func (b *bulkBatch) succeed(i int, id uuid.UUID, link *models.LinkResponse) {
+	item := b.result.Results[i]
+	item.ID, item.Status, item.Link = &id, models.BulkItemSucceeded, link
+	b.result.Succeeded++
+}
+
+func (b *bulkBatch) fail(i int, id *uuid.UUID, err error) {
+	item := b.result.Results[i]
+	item.ID, item.Status, item.Error = id, models.BulkItemFailed, apperror.ResponseOf(err)
+	b.result.Failed++
+}
+
+// rejected reports whether an atomic request must be abandoned
+func (b *bulkBatch) rejected() bool {
+	return b.atomic() && b.result.Failed > 0
+}
+
+// rejection describes every failed item as a field of ErrBulkRejected, such
+// as links[2] or links[2].original_url
+func (b *bulkBatch) rejection(field string) error {
+	rejected := ErrBulkRejected
+	for _, item := range b.result.Results {
+		if item.Status != models.BulkItemFailed {
+			continue
+		}
+		name := fmt.Sprintf("%s[%d]", field, item.Index)
+		if len(item.Error.Details) == 0 {
+			rejected = rejected.WithField(name, item.Error.Error)
+		}
+		for _, detail := range item.Error.Details {
+			rejected = rejected.WithField(name+"."+detail.Field, detail.Message)
+		}
+	}
+	return rejected
+}
+
+// memoize caches the outcome of a workspace check for the items of one request
+func memoize(check func(context.Context, uuid.UUID) error) func(context.Context, uuid.UUID) error {
+	results := map[uuid.UUID]error{}
+	return func(ctx context.Context, workspaceID uuid.UUID) error {
+		if err, ok := results[workspaceID]; ok {
+			return err
+		}
+		err := check(ctx, workspaceID)
+		results[workspaceID] = err
+		return err
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"link-shortener/internal/metrics"
+	"link-shortener/internal/models"
+)
+
+// linkCache keeps recently redirected links in memory for a short TTL. It is
+// local to the instance: changes made elsewhere show up once entries expire.
+type linkCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[string]linkCacheEntry
+}
+
+type linkCacheEntry struct {
+	link    models.Link
+	expires time.Time
+}
+
+// newLinkCache returns nil, which disables caching, when ttl or size is zero
+func newLinkCache(ttl time.Duration, maxSize int) *linkCache {
+	if ttl <= 0 || maxSize <= 0 {
+		return nil
+	}
+	return &linkCache{ttl: ttl, maxSize: maxSize, entries: map[string]linkCacheEntry{}}
+}
+
+func (c *linkCache) get(shortCode string) (*models.Link, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[shortCode]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, shortCode)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		metrics.LinkCacheLookups.Inc("miss")
+		return nil, false
+	}
+	metrics.LinkCacheLookups.Inc("hit")
+	link := entry.link
+	return &link, true
+}
+
+func (c *linkCache) put(link *models.Link) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxSize {
+		c.evict()
+	}
+	c.entries[link.ShortCode] = linkCacheEntry{link: *link, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *linkCache) remove(shortCode string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, shortCode)
+}
+
+func (c *linkCache) size() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evict drops expired entries, or an arbitrary tenth of the cache when none
+// have expired
+func (c *linkCache) evict() {
+	now := time.Now()
+	for code, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, code)
+		}
+	}
+
+	for code := range c.entries {
+		if len(c.entries) < c.maxSize-c.maxSize/10 {
+			break
+		}
+		delete(c.entries, code)
+	}
+}
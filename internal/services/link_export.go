@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/models"
+	"link-shortener/internal/tracing"
+)
+
+// clickPurgeBatchSize bounds the click events one statement deletes, so that
+// purging does not hold locks for long
+const clickPurgeBatchSize = 10000
+
+// ExportLinks calls fn with each of the user's personal links matching
+// filter, or those of a workspace they can view, oldest first. Rows are
+// streamed from the database rather than collected.
+func (s *LinkService) ExportLinks(ctx context.Context, userID uuid.UUID, filter *models.ExportFilter, fn func(*models.LinkResponse) error) error {
+	ctx, span := tracing.Start(ctx, "LinkService.ExportLinks")
+	defer span.End()
+
+	if err := s.checkExport(ctx, userID, filter); err != nil {
+		return err
+	}
+	ctx, done, err := s.startExport(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	err = s.linkRepo.EachLink(ctx, userID, filter, func(link *models.Link) error {
+		return fn(s.toLinkResponse(link))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export links: %w", err)
+	}
+	return nil
+}
+
+// ExportClicks calls fn with each click on the links ExportLinks would cover,
+// or on filter.LinkID alone, oldest first. The event passed to fn is reused
+// between calls.
+func (s *LinkService) ExportClicks(ctx context.Context, userID uuid.UUID, filter *models.ExportFilter, fn func(*models.ClickEvent) error) error {
+	ctx, span := tracing.Start(ctx, "LinkService.ExportClicks")
+	defer span.End()
+
+	if filter.LinkID != nil {
+		link, err := s.linkRepo.GetByID(ctx, *filter.LinkID)
+		if err != nil {
+			return fmt.Errorf("failed to get link: %w", err)
+		}
+		if err := s.authorize(ctx, userID, link, models.WorkspaceRoleViewer); err != nil {
+			return err
+		}
+		// The link's own scope covers it whatever workspace was asked for
+		filter.WorkspaceID = link.WorkspaceID
+	}
+	if err := s.checkExport(ctx, userID, filter); err != nil {
+		return err
+	}
+	ctx, done, err := s.startExport(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := s.linkRepo.EachClick(ctx, userID, filter, fn); err != nil {
+		return fmt.Errorf("failed to export clicks: %w", err)
+	}
+	return nil
+}
+
+func (s *LinkService) checkExport(ctx context.Context, userID uuid.UUID, filter *models.ExportFilter) error {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return ErrInvalidExportRange.WithField("from", "must be before to")
+	}
+	if filter.WorkspaceID != nil {
+		if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, *filter.WorkspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startExport takes an export slot and bounds the export by the configured
+// timeout. Call done when the export ends.
+func (s *LinkService) startExport(ctx context.Context) (_ context.Context, done func(), _ error) {
+	if s.exports != nil {
+		select {
+		case s.exports <- struct{}{}:
+		default:
+			return nil, nil, ErrTooManyExports
+		}
+	}
+	release := func() {
+		if s.exports != nil {
+			<-s.exports
+		}
+	}
+
+	if s.cfg.ExportTimeout <= 0 {
+		return ctx, release, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.ExportTimeout)
+	return ctx, func() { cancel(); release() }, nil
+}
+
+// ExportTimeout is how long an export may run; zero means no limit
+func (s *LinkService) ExportTimeout() time.Duration {
+	return s.cfg.ExportTimeout
+}
+
+// PurgeClickEvents removes click events older than retention in batches and
+// returns how many it removed
+func (s *LinkService) PurgeClickEvents(ctx context.Context, retention time.Duration) (int64, error) {
+	var purged int64
+	for {
+		deleted, err := s.linkRepo.DeleteClicksBefore(ctx, retention, clickPurgeBatchSize)
+		purged += deleted
+		if err != nil || deleted < clickPurgeBatchSize {
+			return purged, err
+		}
+	}
+}
+
+// newSemaphore returns a channel with n slots, or nil when n is not positive
+func newSemaphore(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
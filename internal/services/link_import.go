@@ -0,0 +1,493 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/importer"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
+	"link-shortener/internal/utils"
+)
+
+const (
+	// importChunkSize is how many rows are checked and inserted at a time;
+	// the progress of a job is saved after each chunk
+	importChunkSize = 500
+	// maxImportIssues bounds the rows listed in a report; the counts stay exact
+	maxImportIssues = 1000
+	// importStaleAfter is how long a running job may go without progress
+	// before it is reported as interrupted
+	importStaleAfter = 10 * time.Minute
+	// renameCandidates is how many numbered variants of a taken code are
+	// tried before falling back to a generated one
+	renameCandidates = 9
+	// maxShortCodeLength matches utils.ValidateShortCode
+	maxShortCodeLength = 20
+)
+
+// ImportService imports links exported by other shorteners, either directly
+// or as a background job whose progress can be polled
+type ImportService struct {
+	links *LinkService
+	jobs  *repository.ImportRepository
+
+	// ctx is the parent of background jobs; Close cancels it
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewImportService(links *LinkService, jobs *repository.ImportRepository) *ImportService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ImportService{links: links, jobs: jobs, ctx: ctx, cancel: cancel}
+}
+
+// Close interrupts running jobs and waits until they have saved their state
+func (s *ImportService) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Parse reads an import file, allowing up to the configured number of rows
+func (s *ImportService) Parse(r io.Reader, format string) ([]importer.Record, error) {
+	return importer.Parse(r, format, s.links.cfg.ImportMaxRows)
+}
+
+// Run imports records and returns the report, calling progress after each
+// chunk when it is set. With opts.DryRun nothing is written. With the fail
+// policy the links are created in one transaction, and when any code is taken
+// it is rolled back and the import returns the report and ErrImportConflicts.
+func (s *ImportService) Run(ctx context.Context, userID uuid.UUID, opts *models.ImportOptions, records []importer.Record, progress func(processed int, report *models.ImportReport)) (*models.ImportReport, error) {
+	ctx, span := tracing.Start(ctx, "ImportService.Run",
+		tracing.Int("import.rows", len(records)), tracing.Bool("import.dry_run", opts.DryRun))
+	defer span.End()
+
+	if err := s.check(ctx, userID, opts, records); err != nil {
+		return nil, err
+	}
+
+	if opts.ConflictPolicy != models.ConflictFail || opts.DryRun {
+		return s.run(ctx, userID, opts, records, progress, nil)
+	}
+
+	tx, err := s.links.linkRepo.BeginCreate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	report, err := s.run(ctx, userID, opts, records, progress, tx)
+	if err == nil && report.Conflicts > 0 {
+		err = ErrImportConflicts
+	}
+	if err == nil {
+		if err = tx.Commit(ctx); err != nil {
+			err = fmt.Errorf("failed to create links: %w", err)
+		}
+	}
+	if err != nil && report != nil {
+		// Rolled back
+		report.Created = 0
+	}
+	return report, err
+}
+
+// Start checks the request and imports records in the background. Poll the
+// job with GetJob.
+func (s *ImportService) Start(ctx context.Context, userID uuid.UUID, opts *models.ImportOptions, records []importer.Record) (*models.ImportJob, error) {
+	ctx, span := tracing.Start(ctx, "ImportService.Start", tracing.Int("import.rows", len(records)))
+	defer span.End()
+
+	if err := s.check(ctx, userID, opts, records); err != nil {
+		return nil, err
+	}
+
+	job := &models.ImportJob{
+		ID:          uuid.New(),
+		UserID:      userID,
+		WorkspaceID: opts.WorkspaceID,
+		Status:      models.ImportRunning,
+		ImportReport: models.ImportReport{
+			ConflictPolicy: opts.ConflictPolicy,
+			Total:          len(records),
+			Issues:         []models.ImportIssue{},
+		},
+	}
+	if err := s.jobs.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create import: %w", err)
+	}
+
+	started := *job
+	s.wg.Add(1)
+	go s.runJob(logging.FromContext(ctx).With("import_id", job.ID), job, opts, records)
+	return &started, nil
+}
+
+// runJob runs in the background and logs through the logger of the request
+// that started it
+func (s *ImportService) runJob(logger *slog.Logger, job *models.ImportJob, opts *models.ImportOptions, records []importer.Record) {
+	defer s.wg.Done()
+
+	report, err := s.Run(s.ctx, job.UserID, opts, records, func(processed int, report *models.ImportReport) {
+		job.Processed, job.ImportReport = processed, *report
+		if err := s.jobs.Update(s.ctx, job); err != nil {
+			logger.Error("Failed to save import progress", "error", err)
+		}
+	})
+	if report != nil {
+		job.ImportReport = *report
+	}
+
+	job.Status = models.ImportSucceeded
+	if err != nil {
+		job.Status = models.ImportFailed
+		if errors.Is(err, context.Canceled) {
+			err = ErrImportInterrupted.Wrap(err)
+		}
+		job.Error = apperror.ResponseOf(err).Error
+		if apperror.Status(err) >= 500 {
+			logger.Error("Import failed", "error", err)
+		}
+	} else {
+		job.Processed = job.Total
+	}
+
+	// Save the outcome even when the jobs were cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(s.ctx), 5*time.Second)
+	defer cancel()
+	if err := s.jobs.Update(ctx, job); err != nil {
+		logger.Error("Failed to save import", "error", err)
+		return
+	}
+	logger.Info("Import finished", "status", job.Status, "created", job.Created, "failed", job.Failed)
+}
+
+// GetJob returns one of the user's import jobs
+func (s *ImportService) GetJob(ctx context.Context, userID, jobID uuid.UUID) (*models.ImportJob, error) {
+	ctx, span := tracing.Start(ctx, "ImportService.GetJob")
+	defer span.End()
+
+	job, err := s.jobs.GetByID(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import: %w", err)
+	}
+	if job.UserID != userID {
+		return nil, repository.ErrImportNotFound
+	}
+	if job.Status != models.ImportRunning {
+		return job, nil
+	}
+
+	// A job whose server stopped would otherwise look busy forever
+	if err := s.jobs.FailStale(ctx, jobID, importStaleAfter, ErrImportInterrupted.Message); err != nil {
+		return nil, fmt.Errorf("failed to check import: %w", err)
+	}
+	job, err = s.jobs.GetByID(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import: %w", err)
+	}
+	return job, nil
+}
+
+// check validates the options, filling in defaults, and the user's access
+func (s *ImportService) check(ctx context.Context, userID uuid.UUID, opts *models.ImportOptions, records []importer.Record) error {
+	switch opts.ConflictPolicy {
+	case "":
+		opts.ConflictPolicy = models.ConflictFail
+	case models.ConflictSkip, models.ConflictRename, models.ConflictFail:
+	default:
+		return ErrInvalidConflictPolicy.WithField("conflict_policy", "must be one of skip, rename, fail")
+	}
+
+	if len(records) == 0 {
+		return ErrEmptyImport
+	}
+	if opts.WorkspaceID != nil {
+		if _, err := requireWorkspaceRole(ctx, s.links.workspaceRepo, *opts.WorkspaceID, userID, models.WorkspaceRoleEditor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run imports records chunk by chunk; the links are created as part of tx
+// when it is set
+func (s *ImportService) run(ctx context.Context, userID uuid.UUID, opts *models.ImportOptions, records []importer.Record, progress func(int, *models.ImportReport), tx *repository.LinkTx) (*models.ImportReport, error) {
+	run := &importRun{
+		links:          s.links,
+		tx:             tx,
+		userID:         userID,
+		opts:           opts,
+		checkWorkspace: memoize(s.links.workspaceRole(userID, models.WorkspaceRoleEditor)),
+		claimed:        map[string]bool{},
+		report: &models.ImportReport{
+			ConflictPolicy: opts.ConflictPolicy,
+			Total:          len(records),
+			Issues:         []models.ImportIssue{},
+		},
+	}
+
+	for start := 0; start < len(records); start += importChunkSize {
+		if err := ctx.Err(); err != nil {
+			return run.report, err
+		}
+		end := min(start+importChunkSize, len(records))
+		if err := run.chunk(ctx, records[start:end]); err != nil {
+			return run.report, err
+		}
+		if progress != nil {
+			progress(end, run.report)
+		}
+	}
+	return run.report, nil
+}
+
+// importRun is the state of one import
+type importRun struct {
+	links          *LinkService
+	tx             *repository.LinkTx
+	userID         uuid.UUID
+	opts           *models.ImportOptions
+	checkWorkspace func(context.Context, uuid.UUID) error
+	report         *models.ImportReport
+	// claimed holds the codes of earlier rows
+	claimed map[string]bool
+}
+
+// importRow is a row waiting to be inserted. requested is the code the file
+// asked for, or "" for a generated one.
+type importRow struct {
+	line       int
+	link       *models.Link
+	requested  string
+	conflicted bool
+}
+
+func (r *importRun) chunk(ctx context.Context, records []importer.Record) error {
+	// Look up the requested codes of the whole chunk at once
+	var codes []string
+	for _, record := range records {
+		if record.Err == nil && record.ShortCode != "" {
+			codes = append(codes, record.ShortCode)
+		}
+	}
+	taken := map[string]bool{}
+	if len(codes) > 0 {
+		var err error
+		if taken, err = r.links.linkRepo.ExistingShortCodes(ctx, codes); err != nil {
+			return fmt.Errorf("failed to check short codes: %w", err)
+		}
+	}
+
+	var pending []*importRow
+	for _, record := range records {
+		if record.Err != nil {
+			r.issue(record.Line, record.ShortCode, models.ImportRowFailed, record.Err, "")
+			continue
+		}
+
+		link, err := r.links.newLink(ctx, r.userID, &models.CreateLinkRequest{
+			OriginalURL: record.OriginalURL,
+			CustomAlias: record.ShortCode,
+			Title:       record.Title,
+			ExpiresAt:   record.ExpiresAt,
+			WorkspaceID: r.opts.WorkspaceID,
+			Tags:        record.Tags,
+		}, r.checkWorkspace)
+		if err != nil {
+			r.issue(record.Line, record.ShortCode, models.ImportRowFailed, err, "")
+			continue
+		}
+
+		row := &importRow{line: record.Line, link: link, requested: record.ShortCode}
+		if row.requested != "" && (taken[row.requested] || r.claimed[row.requested]) {
+			keep, err := r.resolve(ctx, row)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+		}
+		r.claimed[link.ShortCode] = true
+		pending = append(pending, row)
+	}
+
+	// Once a code is taken, the transaction of the fail policy is rolled
+	// back, so the remaining rows are only checked
+	if r.opts.DryRun || (r.tx != nil && r.report.Conflicts > 0) {
+		for _, row := range pending {
+			r.created(row)
+		}
+		return nil
+	}
+	return r.insert(ctx, pending)
+}
+
+// insert creates the links of rows, generating codes where none was asked
+// for and applying the policy again to codes taken in the meantime
+func (r *importRun) insert(ctx context.Context, pending []*importRow) error {
+	for attempt := 1; len(pending) > 0; attempt++ {
+		if attempt > maxShortCodeAttempts {
+			return fmt.Errorf("failed to find free short codes after %d attempts", maxShortCodeAttempts)
+		}
+
+		links := make([]*models.Link, len(pending))
+		for i, row := range pending {
+			if row.link.ShortCode == "" {
+				code, err := utils.GenerateShortCode(r.links.cfg.ShortCodeLength)
+				if err != nil {
+					return fmt.Errorf("failed to generate short code: %w", err)
+				}
+				row.link.ShortCode = code
+			}
+			links[i] = row.link
+		}
+
+		var created *repository.CreatedLinks
+		var err error
+		if r.tx != nil {
+			created, err = r.tx.CreateMany(ctx, links)
+		} else {
+			created, err = r.links.linkRepo.CreateMany(ctx, links, false)
+		}
+		// Outside the transaction of the fail policy, a code taken
+		// concurrently fails its batch, which the next attempt inserts again
+		if err != nil && (r.tx != nil || !errors.Is(err, repository.ErrShortCodeTaken)) {
+			if errors.Is(err, repository.ErrShortCodeTaken) {
+				return ErrAliasTaken.Wrap(err)
+			}
+			// Batches committed before the error stay created; the
+			// transaction of the fail policy is rolled back as a whole
+			if r.tx == nil {
+				for _, row := range pending {
+					if created.Created[row.link.ID] {
+						r.created(row)
+					}
+				}
+			}
+			return fmt.Errorf("failed to create links: %w", err)
+		}
+
+		var retry []*importRow
+		for _, row := range pending {
+			switch {
+			case created.Created[row.link.ID]:
+				r.created(row)
+			case !created.Conflicts[row.link.ID]:
+				// Rolled back with the rest of its batch
+				retry = append(retry, row)
+			case row.requested == "":
+				row.link.ShortCode = ""
+				retry = append(retry, row)
+			default:
+				keep, err := r.resolve(ctx, row)
+				if err != nil {
+					return err
+				}
+				if keep {
+					r.claimed[row.link.ShortCode] = true
+					retry = append(retry, row)
+				}
+			}
+		}
+		pending = retry
+	}
+	return nil
+}
+
+// resolve applies the conflict policy to a row whose code is taken and
+// reports whether it should still be created
+func (r *importRun) resolve(ctx context.Context, row *importRow) (bool, error) {
+	if !row.conflicted {
+		row.conflicted = true
+		r.report.Conflicts++
+	}
+
+	switch r.opts.ConflictPolicy {
+	case models.ConflictSkip:
+		r.issue(row.line, row.requested, models.ImportRowSkipped, ErrAliasTaken.WithField("short_code", "is already taken"), "")
+		return false, nil
+	case models.ConflictRename:
+		code, err := r.rename(ctx, row.requested)
+		if err != nil {
+			return false, err
+		}
+		row.link.ShortCode = code
+		return true, nil
+	default:
+		r.issue(row.line, row.requested, models.ImportRowFailed, ErrAliasTaken.WithField("short_code", "is already taken"), "")
+		return false, nil
+	}
+}
+
+// rename finds a free variant of code by appending -2, -3 and so on, and
+// falls back to a generated code
+func (r *importRun) rename(ctx context.Context, code string) (string, error) {
+	var candidates []string
+	for n := 2; len(candidates) < renameCandidates; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		base := code
+		if len(base)+len(suffix) > maxShortCodeLength {
+			base = base[:maxShortCodeLength-len(suffix)]
+		}
+		if !r.claimed[base+suffix] {
+			candidates = append(candidates, base+suffix)
+		}
+	}
+
+	taken, err := r.links.linkRepo.ExistingShortCodes(ctx, candidates)
+	if err != nil {
+		return "", fmt.Errorf("failed to check short codes: %w", err)
+	}
+	for _, candidate := range candidates {
+		if !taken[candidate] {
+			return candidate, nil
+		}
+	}
+
+	code, err = utils.GenerateShortCode(r.links.cfg.ShortCodeLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate short code: %w", err)
+	}
+	return code, nil
+}
+
+func (r *importRun) created(row *importRow) {
+	r.report.Created++
+	if row.requested != "" && row.link.ShortCode != row.requested {
+		r.issue(row.line, row.requested, models.ImportRowRenamed, nil, row.link.ShortCode)
+	}
+}
+
+func (r *importRun) issue(line int, code, status string, err error, renamedTo string) {
+	switch status {
+	case models.ImportRowRenamed:
+		r.report.Renamed++
+	case models.ImportRowSkipped:
+		r.report.Skipped++
+	case models.ImportRowFailed:
+		r.report.Failed++
+	}
+
+	if len(r.report.Issues) == maxImportIssues {
+		r.report.IssuesTruncated = true
+		return
+	}
+	issue := models.ImportIssue{Line: line, ShortCode: code, Status: status, RenamedTo: renamedTo}
+	if err != nil {
+		issue.Error = apperror.ResponseOf(err)
+	}
+	r.report.Issues = append(r.report.Issues, issue)
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/live"
+	"link-shortener/internal/models"
+	"link-shortener/internal/tracing"
+)
+
+// SetLive makes live click streams available from hub
+func (s *LinkService) SetLive(hub *live.Hub) {
+	s.live = hub
+}
+
+// LiveStream is a subscription to clicks together with the access check it
+// was opened under
+type LiveStream struct {
+	*live.Subscription
+	check func(ctx context.Context) error
+}
+
+// Authorize repeats the access check of the stream, so that a stream is not
+// kept open after the user lost access to what it watches
+func (s *LiveStream) Authorize(ctx context.Context) error {
+	return s.check(ctx)
+}
+
+// WatchLink subscribes to the clicks on a link the user can view. Clicks
+// after lastEventID that are still buffered are delivered first.
+func (s *LinkService) WatchLink(ctx context.Context, userID, linkID uuid.UUID, lastEventID string) (*LiveStream, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.WatchLink")
+	defer span.End()
+
+	if s.live == nil {
+		return nil, ErrLiveUnavailable
+	}
+	check := func(ctx context.Context) error {
+		link, err := s.linkRepo.GetByID(ctx, linkID)
+		if err != nil {
+			return fmt.Errorf("failed to get link: %w", err)
+		}
+		return s.authorize(ctx, userID, link, models.WorkspaceRoleViewer)
+	}
+	return s.watch(ctx, userID, live.LinkScope(linkID), lastEventID, check)
+}
+
+// WatchLinks subscribes to the clicks on the user's personal links, or on the
+// links of a workspace they can view
+func (s *LinkService) WatchLinks(ctx context.Context, userID uuid.UUID, workspaceID *uuid.UUID, lastEventID string) (*LiveStream, error) {
+	ctx, span := tracing.Start(ctx, "LinkService.WatchLinks")
+	defer span.End()
+
+	if s.live == nil {
+		return nil, ErrLiveUnavailable
+	}
+	if workspaceID == nil {
+		personal := func(context.Context) error { return nil }
+		return s.watch(ctx, userID, live.UserScope(userID), lastEventID, personal)
+	}
+	checkWorkspace := s.workspaceRole(userID, models.WorkspaceRoleViewer)
+	check := func(ctx context.Context) error { return checkWorkspace(ctx, *workspaceID) }
+	return s.watch(ctx, userID, live.WorkspaceScope(*workspaceID), lastEventID, check)
+}
+
+// watch subscribes once check passes
+func (s *LinkService) watch(ctx context.Context, userID uuid.UUID, scope live.Scope, lastEventID string, check func(context.Context) error) (*LiveStream, error) {
+	if err := check(ctx); err != nil {
+		return nil, err
+	}
+	sub, err := s.live.Subscribe(userID, scope, lastEventID)
+	if err != nil {
+		return nil, err
+	}
+	return &LiveStream{Subscription: sub, check: check}, nil
+}
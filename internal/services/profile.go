@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/tracing"
+)
+
+// UpdateProfile changes the user's public profile fields
+func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, req *models.UpdateProfileRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.UpdateProfile")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if req.Username != user.Username {
+		exists, err := s.userRepo.UsernameExists(ctx, req.Username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check username: %w", err)
+		}
+		if exists {
+			return nil, ErrUsernameTaken
+		}
+		user.Username = req.Username
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return user, nil
+}
+
+// ChangePassword verifies the current password, sets the new one and revokes
+// every other session. The returned token keeps the caller signed in.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, req *models.ChangePasswordRequest) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ChangePassword")
+	defer span.End()
+
+	user, err := s.checkPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	sessionVersion, err := s.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update password: %w", err)
+	}
+	user.SessionVersion = sessionVersion
+
+	return s.issueSession(ctx, user)
+}
+
+// RequestEmailChange sends a confirmation link to the new address. The
+// email is only changed once that link is used.
+func (s *AuthService) RequestEmailChange(ctx context.Context, userID uuid.UUID, req *models.ChangeEmailRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.RequestEmailChange")
+	defer span.End()
+
+	user, err := s.checkPassword(ctx, userID, req.Password)
+	if err != nil {
+		return err
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return ErrSameEmail
+	}
+
+	exists, err := s.userRepo.EmailExists(ctx, newEmail)
+	if err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if exists {
+		return ErrEmailTaken
+	}
+
+	if err := s.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposeEmailChange); err != nil {
+		return fmt.Errorf("failed to invalidate email change tokens: %w", err)
+	}
+
+	token, err := s.issueToken(ctx, user.ID, models.TokenPurposeEmailChange, newEmail, s.cfg.EmailChangeTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm that you want to use this address for your account:\n\n%s\n\n"+
+			"The link expires in %s.\n",
+		user.Username,
+		s.actionURL("confirm-email-change", token),
+		s.cfg.EmailChangeTokenExpiry,
+	)
+
+	if err := s.mailer.Send(&mailer.Message{To: newEmail, Subject: "Confirm your new email address", Body: body}); err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	notice := fmt.Sprintf(
+		"Hi %s,\n\nA request was made to change the email address of your account to %s. "+
+			"If this was not you, change your password immediately.\n",
+		user.Username,
+		newEmail,
+	)
+
+	if err := s.mailer.Send(&mailer.Message{To: user.Email, Subject: "Email change requested", Body: notice}); err != nil {
+		logging.FromContext(ctx).Error("Failed to send email change notice", "email", user.Email, "error", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange redeems an email change token
+func (s *AuthService) ConfirmEmailChange(ctx context.Context, req *models.ConfirmEmailChangeRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ConfirmEmailChange")
+	defer span.End()
+
+	token, err := s.redeemToken(ctx, models.TokenPurposeEmailChange, req.Token)
+	if err != nil {
+		return err
+	}
+
+	// The address may have been taken since the change was requested
+	exists, err := s.userRepo.EmailExists(ctx, token.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if exists {
+		return ErrEmailTaken
+	}
+
+	if err := s.userRepo.ChangeEmail(ctx, token.UserID, token.Payload); err != nil {
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+
+	return nil
+}
+
+// ScheduleAccountDeletion marks the account for deletion after the grace
+// period and signs the user out everywhere. Logging in again and cancelling
+// within the grace period keeps the account.
+func (s *AuthService) ScheduleAccountDeletion(ctx context.Context, userID uuid.UUID, req *models.DeleteAccountRequest) (*time.Time, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ScheduleAccountDeletion")
+	defer span.End()
+
+	user, err := s.checkPassword(ctx, userID, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletionAt != nil {
+		return user.DeletionAt, nil
+	}
+
+	deletionAt := time.Now().Add(s.cfg.AccountDeletionGrace)
+	if err := s.userRepo.ScheduleDeletion(ctx, user.ID, &deletionAt); err != nil {
+		return nil, fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+
+	if err := s.userRepo.RevokeSessions(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return &deletionAt, nil
+}
+
+// CancelAccountDeletion keeps an account that is pending deletion
+func (s *AuthService) CancelAccountDeletion(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "AuthService.CancelAccountDeletion")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.DeletionAt == nil {
+		return ErrDeletionNotScheduled
+	}
+
+	if err := s.userRepo.ScheduleDeletion(ctx, user.ID, nil); err != nil {
+		return fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedAccounts removes accounts whose grace period has expired
+func (s *AuthService) PurgeDeletedAccounts(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.PurgeDeletedAccounts")
+	defer span.End()
+
+	deleted, codes, err := s.userRepo.DeleteScheduled(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	s.links.ForgetLink(codes...)
+	return deleted, nil
+}
+
+func (s *AuthService) checkPassword(ctx context.Context, userID uuid.UUID, password string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
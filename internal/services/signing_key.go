@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"link-shortener/internal/config"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/utils"
+)
+
+// KeyService keeps the JWT key ring in sync with the stored signing keys and
+// rotates the active key on schedule. Every instance reloads the keys
+// regularly so tokens signed elsewhere after a rotation still verify.
+type KeyService struct {
+	keyRepo *repository.SigningKeyRepository
+	ring    *utils.KeyRing
+	cfg     config.JWTConfig
+}
+
+func NewKeyService(keyRepo *repository.SigningKeyRepository, ring *utils.KeyRing, cfg config.JWTConfig) *KeyService {
+	return &KeyService{
+		keyRepo: keyRepo,
+		ring:    ring,
+		cfg:     cfg,
+	}
+}
+
+// Load reads the usable keys into the ring, creating a key if there is no
+// active one for the configured algorithm
+func (s *KeyService) Load(ctx context.Context) error {
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+
+	if active := s.ring.Active(); active == nil || active.Algorithm != s.cfg.Algorithm {
+		return s.Rotate(ctx)
+	}
+	return nil
+}
+
+// Rotate creates a new active key. The previous key is retired but keeps
+// verifying tokens for the retention period.
+func (s *KeyService) Rotate(ctx context.Context) error {
+	key, err := utils.GenerateSigningKey(s.cfg.Algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	encoded, err := utils.EncodePrivateKey(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	record := &models.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: encoded,
+	}
+	if err := s.keyRepo.Rotate(ctx, record); err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	return s.reload(ctx)
+}
+
+// RotateIfDue reloads the keys, rotates when the active key is older than the
+// rotation interval or uses a different algorithm, and drops expired keys
+func (s *KeyService) RotateIfDue(ctx context.Context) (bool, error) {
+	if err := s.reload(ctx); err != nil {
+		return false, err
+	}
+
+	rotated := false
+	active := s.ring.Active()
+	if active == nil || active.Algorithm != s.cfg.Algorithm || time.Since(active.CreatedAt) >= s.cfg.KeyRotationInterval {
+		if err := s.Rotate(ctx); err != nil {
+			return false, err
+		}
+		rotated = true
+	}
+
+	if _, err := s.keyRepo.DeleteRetiredBefore(ctx, time.Now().Add(-s.cfg.KeyRetention)); err != nil {
+		return rotated, fmt.Errorf("failed to delete retired keys: %w", err)
+	}
+
+	return rotated, nil
+}
+
+func (s *KeyService) reload(ctx context.Context) error {
+	records, err := s.keyRepo.ListUsable(ctx, time.Now().Add(-s.cfg.KeyRetention))
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make([]*utils.SigningKey, 0, len(records))
+	for _, record := range records {
+		privateKey, err := utils.DecodePrivateKey(record.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("failed to decode signing key %s: %w", record.ID, err)
+		}
+		keys = append(keys, &utils.SigningKey{
+			ID:         record.ID,
+			Algorithm:  record.Algorithm,
+			PrivateKey: privateKey,
+			CreatedAt:  record.CreatedAt,
+			RetiredAt:  record.RetiredAt,
+		})
+	}
+
+	s.ring.Set(keys)
+	return nil
+}
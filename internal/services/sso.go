@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"link-shortener/internal/config"
+	"link-shortener/internal/models"
+	"link-shortener/internal/oidc"
+	"link-shortener/internal/repository"
+)
+
+var usernameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// SSOService signs users in through an OpenID Connect provider and then
+// issues the same tokens as a password login
+type SSOService struct {
+	authService  *AuthService
+	userRepo     *repository.UserRepository
+	identityRepo *repository.IdentityRepository
+	provider     *oidc.Provider
+	cfg          config.OIDCConfig
+	secret       string
+}
+
+func NewSSOService(authService *AuthService, userRepo *repository.UserRepository, identityRepo *repository.IdentityRepository, provider *oidc.Provider, cfg config.OIDCConfig, secret string) *SSOService {
+	return &SSOService{
+		authService:  authService,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		provider:     provider,
+		cfg:          cfg,
+		secret:       secret,
+	}
+}
+
+// FlowExpiry is how long a started login stays valid
+func (s *SSOService) FlowExpiry() time.Duration {
+	return s.cfg.FlowExpiry
+}
+
+// StartLogin returns the provider URL to send the browser to and the sealed
+// state that must come back with the callback
+func (s *SSOService) StartLogin() (authURL, sealedFlow string, err error) {
+	flow, err := oidc.NewFlow(s.cfg.FlowExpiry)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to start login: %w", err)
+	}
+
+	authURL, err = s.provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge())
+	if err != nil {
+		return "", "", ErrProviderUnavailable.Wrap(err)
+	}
+
+	sealedFlow, err = flow.Seal(s.secret)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to start login: %w", err)
+	}
+
+	return authURL, sealedFlow, nil
+}
+
+// CompleteLogin validates the callback, exchanges the code and signs in the
+// linked, matched or newly provisioned user
+func (s *SSOService) CompleteLogin(ctx context.Context, sealedFlow, state, code string) (*models.AuthResponse, error) {
+	flow, err := oidc.OpenFlow(s.secret, sealedFlow)
+	if err != nil {
+		return nil, ErrInvalidLoginState
+	}
+
+	if state == "" || state != flow.State {
+		return nil, ErrInvalidLoginState
+	}
+
+	token, err := s.provider.Exchange(code, flow.CodeVerifier)
+	if err != nil {
+		return nil, ErrProviderLoginFailed.Wrap(fmt.Errorf("failed to exchange authorization code: %w", err))
+	}
+
+	claims, err := s.provider.VerifyIDToken(token.IDToken, flow.Nonce)
+	if err != nil {
+		return nil, ErrProviderLoginFailed.Wrap(err)
+	}
+
+	user, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.completeLogin(ctx, user)
+}
+
+// resolveUser finds the account for the provider identity. Unknown identities
+// are linked to an existing account only when the provider vouches for the
+// email address; otherwise a new account is provisioned if allowed.
+func (s *SSOService) resolveUser(ctx context.Context, claims *oidc.IDTokenClaims) (*models.User, error) {
+	linked, err := s.identityRepo.GetBySubject(ctx, s.cfg.ProviderName, claims.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	if claims.Email == "" {
+		return nil, ErrProviderEmailMissing
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err == nil {
+		if !claims.EmailVerified {
+			return nil, ErrProviderEmailUnverified
+		}
+	} else {
+		if !s.cfg.AutoProvision {
+			return nil, ErrIdentityNotLinked
+		}
+		if user, err = s.provisionUser(ctx, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	identity := &models.UserIdentity{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		Provider: s.cfg.ProviderName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return user, nil
+}
+
+// provisionUser creates an account for a first-time SSO user. It gets an
+// unusable random password; a local one can be set with a password reset.
+func (s *SSOService) provisionUser(ctx context.Context, claims *oidc.IDTokenClaims) (*models.User, error) {
+	username, err := s.availableUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &models.User{
+		ID:           uuid.New(),
+		Username:     username,
+		Email:        claims.Email,
+		PasswordHash: string(hashedPassword),
+		Role:         models.RoleUser,
+	}
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if claims.EmailVerified {
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+			return nil, fmt.Errorf("failed to verify email: %w", err)
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return user, nil
+}
+
+// availableUsername derives a username from the identity claims, adding a
+// random suffix when the preferred one is taken
+func (s *SSOService) availableUsername(ctx context.Context, claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = usernameUnsafeChars.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 5; attempt++ {
+		exists, err := s.userRepo.UsernameExists(ctx, candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to check username: %w", err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix, err := randomHex(3)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate username: %w", err)
+		}
+		candidate = base + "-" + suffix
+	}
+
+	return "", fmt.Errorf("could not find an available username")
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
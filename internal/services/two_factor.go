@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
+	"link-shortener/internal/utils"
+)
+
+const recoveryCodeCount = 10
+
+// LoginTwoFactor completes a two-step login with a TOTP or recovery code
+func (s *AuthService) LoginTwoFactor(ctx context.Context, req *models.TwoFactorLoginRequest) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.LoginTwoFactor")
+	defer span.End()
+
+	claims, err := s.jwtMgr.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err != nil || !user.TOTPEnabled || user.IsDisabled() {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	return s.issueSession(ctx, user)
+}
+
+// SetupTwoFactor generates a new secret for the user. It is not active until
+// confirmed with a valid code.
+func (s *AuthService) SetupTwoFactor(ctx context.Context, userID uuid.UUID) (*models.TwoFactorSetupResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.SetupTwoFactor")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	if err := s.userRepo.SetPendingTOTPSecret(ctx, user.ID, secret); err != nil {
+		return nil, fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.cfg.TwoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator works
+// and returns the one-time recovery codes
+func (s *AuthService) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, req *models.TwoFactorConfirmRequest) (*models.TwoFactorConfirmResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ConfirmTwoFactor")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorSetupMissing
+	}
+
+	if err := s.verifyTOTP(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.EnableTOTP(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	return &models.TwoFactorConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor turns 2FA off after re-authenticating the user
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, req *models.TwoFactorReauthRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.DisableTwoFactor")
+	defer span.End()
+
+	user, err := s.reauthenticate(ctx, userID, req)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DisableTOTP(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	if err := s.recoveryRepo.DeleteForUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after re-authenticating the user
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req *models.TwoFactorReauthRequest) (*models.TwoFactorConfirmResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	user, err := s.reauthenticate(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+// reauthenticate checks both the password and a second factor
+func (s *AuthService) reauthenticate(ctx context.Context, userID uuid.UUID, req *models.TwoFactorReauthRequest) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+func (s *AuthService) verifySecondFactor(ctx context.Context, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if strings.Contains(code, "-") {
+		return s.useRecoveryCode(ctx, user.ID, code)
+	}
+	return s.verifyTOTP(ctx, user, code)
+}
+
+func (s *AuthService) verifyTOTP(ctx context.Context, user *models.User, code string) error {
+	step, ok := utils.ValidateTOTPCode(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if err := s.userRepo.ConsumeTOTPStep(ctx, user.ID, step); err != nil {
+		if errors.Is(err, repository.ErrCodeUsed) {
+			return ErrInvalidTwoFactorCode
+		}
+		return fmt.Errorf("failed to record code: %w", err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) useRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error {
+	codes, err := s.recoveryRepo.GetUnused(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load recovery codes: %w", err)
+	}
+
+	code = strings.ToLower(code)
+	for _, candidate := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(candidate.CodeHash), []byte(code)) == nil {
+			if err := s.recoveryRepo.MarkUsed(ctx, candidate.ID); err != nil {
+				if errors.Is(err, repository.ErrRecoveryCodeUsed) {
+					return ErrInvalidTwoFactorCode
+				}
+				return fmt.Errorf("failed to use recovery code: %w", err)
+			}
+			return nil
+		}
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+func (s *AuthService) replaceRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash recovery code: %w", err)
+		}
+		hashes[i] = string(hash)
+	}
+
+	if err := s.recoveryRepo.ReplaceForUser(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
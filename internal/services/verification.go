@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
+	"link-shortener/internal/utils"
+)
+
+// VerifyEmail redeems an email verification token
+func (s *AuthService) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.VerifyEmail")
+	defer span.End()
+
+	token, err := s.redeemToken(ctx, models.TokenPurposeEmailVerification, req.Token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, token.UserID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return nil
+}
+
+// ResendVerification sends a fresh verification email to an unverified user
+func (s *AuthService) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ResendVerification")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.IsEmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// ForgotPassword emails a password reset token. Unknown addresses are
+// ignored silently so the endpoint cannot be used to discover accounts.
+func (s *AuthService) ForgotPassword(ctx context.Context, req *models.ForgotPasswordRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ForgotPassword")
+	defer span.End()
+
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		return nil
+	}
+
+	if err := s.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens: %w", err)
+	}
+
+	token, err := s.issueToken(ctx, user.ID, models.TokenPurposePasswordReset, "", s.cfg.PasswordResetTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to reset the password for your account. "+
+			"Use the link below to choose a new one:\n\n%s\n\n"+
+			"The link expires in %s. If you did not ask for this, you can ignore this email.\n",
+		user.Username,
+		s.actionURL("reset-password", token),
+		s.cfg.PasswordResetTokenExpiry,
+	)
+
+	if err := s.mailer.Send(&mailer.Message{To: user.Email, Subject: "Reset your password", Body: body}); err != nil {
+		logging.FromContext(ctx).Error("Failed to send password reset email", "email", user.Email, "error", err)
+	}
+
+	return nil
+}
+
+// ResetPassword redeems a password reset token and sets the new password.
+// Existing sessions are revoked.
+func (s *AuthService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+
+	token, err := s.redeemToken(ctx, models.TokenPurposePasswordReset, req.Token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if _, err := s.userRepo.UpdatePassword(ctx, token.UserID, string(hashedPassword)); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (s *AuthService) IsEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.IsEmailVerified")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsEmailVerified(), nil
+}
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	if err := s.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposeEmailVerification); err != nil {
+		return fmt.Errorf("failed to invalidate verification tokens: %w", err)
+	}
+
+	token, err := s.issueToken(ctx, user.ID, models.TokenPurposeEmailVerification, "", s.cfg.VerificationTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s.\n",
+		user.Username,
+		s.actionURL("verify-email", token),
+		s.cfg.VerificationTokenExpiry,
+	)
+
+	return s.mailer.Send(&mailer.Message{To: user.Email, Subject: "Verify your email address", Body: body})
+}
+
+// issueToken stores a new signed token and returns its plain value
+func (s *AuthService) issueToken(ctx context.Context, userID uuid.UUID, purpose, payload string, ttl time.Duration) (string, error) {
+	token, hash, err := utils.GenerateActionToken(s.cfg.TokenSecret, purpose)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	record := &models.UserToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		Payload:   payload,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := s.tokenRepo.Create(ctx, record); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return token, nil
+}
+
+// redeemToken validates a token and consumes it so it cannot be used twice
+func (s *AuthService) redeemToken(ctx context.Context, purpose, plain string) (*models.UserToken, error) {
+	hash, err := utils.VerifyActionToken(s.cfg.TokenSecret, purpose, plain)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := s.tokenRepo.GetByHash(ctx, purpose, hash)
+	if errors.Is(err, repository.ErrTokenNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	if token.UsedAt != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	if err := s.tokenRepo.MarkUsed(ctx, token.ID); err != nil {
+		if errors.Is(err, repository.ErrTokenUsed) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to use token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *AuthService) actionURL(path, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", s.appURL, path, url.QueryEscape(token))
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/config"
+	"link-shortener/internal/database"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/metrics"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/tracing"
+	"link-shortener/internal/webhook"
+)
+
+const (
+	// maxWebhooksPerUser bounds the endpoints one account can register
+	maxWebhooksPerUser = 20
+	// expirySweepInterval is how often links that have expired are looked
+	// for; link.expired is sent up to this long after the expiry
+	expirySweepInterval = time.Minute
+	// expiryWindow is how far back expiries are reported, so that enabling
+	// webhooks does not report every link that expired long ago
+	expiryWindow    = 24 * time.Hour
+	expiryBatchSize = 500
+	pruneInterval   = time.Hour
+	// maxDeliveryError bounds the error kept for a failed attempt
+	maxDeliveryError = 1000
+)
+
+// WebhookService manages webhooks and delivers their events. Events are
+// written to an outbox table and sent from there in the background, so a
+// delivery survives restarts and is retried with exponential backoff until
+// it succeeds or runs out of attempts.
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	links  *LinkService
+	sender *webhook.Sender
+	cfg    config.WebhookConfig
+
+	// ctx is the parent of deliveries in flight; Close cancels it
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, links *LinkService, cfg config.WebhookConfig) *WebhookService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookService{
+		repo:   repo,
+		links:  links,
+		sender: webhook.NewSender(cfg.Timeout, cfg.AllowPrivate),
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, userID uuid.UUID, req *models.CreateWebhookRequest) (*models.CreatedWebhook, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+
+	if err := s.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	count, err := s.repo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count webhooks: %w", err)
+	}
+	if count >= maxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks.WithField("url", fmt.Sprintf("at most %d webhooks can be registered", maxWebhooksPerUser))
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	hook := &models.Webhook{
+		ID:          uuid.New(),
+		UserID:      userID,
+		URL:         req.URL,
+		Description: req.Description,
+		Secret:      secret,
+		Events:      normalizeEvents(req.Events),
+		IsActive:    true,
+	}
+	if err := s.repo.Create(ctx, hook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &models.CreatedWebhook{Webhook: *hook, Secret: secret}, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]*models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListWebhooks")
+	defer span.End()
+
+	webhooks, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []*models.Webhook{}
+	}
+	return webhooks, nil
+}
+
+// GetWebhook returns one of the user's webhooks; other users' webhooks are
+// reported as not found
+func (s *WebhookService) GetWebhook(ctx context.Context, userID, webhookID uuid.UUID) (*models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.GetWebhook")
+	defer span.End()
+
+	hook, err := s.repo.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if hook.UserID != userID {
+		return nil, repository.ErrWebhookNotFound
+	}
+	return hook, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, userID, webhookID uuid.UUID, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.UpdateWebhook")
+	defer span.End()
+
+	hook, err := s.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != "" {
+		if err := s.checkURL(req.URL); err != nil {
+			return nil, err
+		}
+		hook.URL = req.URL
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if req.Events != nil {
+		hook.Events = normalizeEvents(req.Events)
+	}
+	if req.IsActive != nil {
+		hook.IsActive = *req.IsActive
+	}
+
+	if err := s.repo.Update(ctx, hook); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// DeleteWebhook removes a webhook, its pending deliveries and its log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, userID, webhookID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+
+	if _, err := s.GetWebhook(ctx, userID, webhookID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, webhookID)
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first. status
+// may be empty, pending, delivered or dead.
+func (s *WebhookService) ListDeliveries(ctx context.Context, userID, webhookID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListDeliveries")
+	defer span.End()
+
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, ErrInvalidDeliveryStatus.WithField("status", "must be pending, delivered or dead")
+	}
+	if _, err := s.GetWebhook(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(ctx, webhookID, status, limit, offset)
+}
+
+// RetryDelivery sends a delivered or dead delivery again, starting over with
+// a full set of attempts
+func (s *WebhookService) RetryDelivery(ctx context.Context, userID, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.RetryDelivery")
+	defer span.End()
+
+	if _, err := s.GetWebhook(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+	delivery, err := s.repo.GetDelivery(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == models.DeliveryPending {
+		return nil, ErrDeliveryPending
+	}
+
+	delivery, err = s.repo.Redeliver(ctx, webhookID, deliveryID)
+	if errors.Is(err, repository.ErrDeliveryNotFound) {
+		// Retried concurrently
+		return nil, ErrDeliveryPending
+	}
+	return delivery, err
+}
+
+// Ping queues a ping event for a webhook, whatever events it subscribes to,
+// so that an endpoint can be tested
+func (s *WebhookService) Ping(ctx context.Context, userID, webhookID uuid.UUID) (*models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Ping")
+	defer span.End()
+
+	hook, err := s.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	eventID, payload, err := newWebhookEvent(models.EventPing, map[string]interface{}{"webhook_id": hook.ID})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.EnqueueFor(ctx, hook.ID, eventID, models.EventPing, payload)
+}
+
+// QueueLinks queues event for the webhooks of each link's owner as part of
+// tx, so that it is sent only if the change is committed
+func (s *WebhookService) QueueLinks(ctx context.Context, tx *database.Tx, event string, links []*models.Link) error {
+	events := make([]*models.OutboxEvent, len(links))
+	for i, link := range links {
+		eventID, payload, err := newWebhookEvent(event, s.links.toLinkResponse(link))
+		if err != nil {
+			return err
+		}
+		events[i] = &models.OutboxEvent{UserID: link.UserID, ID: eventID, Type: event, Payload: payload}
+	}
+	return s.repo.EnqueueIn(ctx, tx, events)
+}
+
+// QueueClick queues link.clicked as part of the transaction recording click
+func (s *WebhookService) QueueClick(ctx context.Context, tx *database.Tx, click *models.ClickEvent) error {
+	eventID, payload, err := newWebhookEvent(models.EventLinkClicked, click)
+	if err != nil {
+		return err
+	}
+	event := &models.OutboxEvent{UserID: click.UserID, ID: eventID, Type: models.EventLinkClicked, Payload: payload}
+	return s.repo.EnqueueIn(ctx, tx, []*models.OutboxEvent{event})
+}
+
+// Start delivers queued events in the background until Close is called.
+// Each of the configured workers claims and sends one delivery at a time, so
+// a slow endpoint holds up only the worker sending to it.
+func (s *WebhookService) Start() {
+	s.wg.Add(1 + s.cfg.Workers)
+	go s.run()
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.work()
+	}
+}
+
+// Close stops delivering. Deliveries in flight are abandoned and sent again
+// once their lease runs out.
+func (s *WebhookService) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *WebhookService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var swept, pruned time.Time
+	for {
+		if time.Since(swept) >= expirySweepInterval {
+			s.notifyExpired()
+			swept = time.Now()
+		}
+		if time.Since(pruned) >= pruneInterval {
+			s.prune()
+			pruned = time.Now()
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// work sends due deliveries one after another, polling while there are none
+func (s *WebhookService) work() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while there is a backlog
+		for s.dispatch() {
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch claims and sends the next due delivery and reports whether there
+// was one
+func (s *WebhookService) dispatch() bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+
+	// The lease outlasts an attempt, which is bounded by the timeout
+	due, err := s.repo.ClaimDue(s.ctx, 1, s.cfg.Timeout+30*time.Second)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			logging.FromContext(s.ctx).Error("Failed to claim webhook deliveries", "error", err)
+		}
+		return false
+	}
+	if len(due) == 0 {
+		return false
+	}
+
+	s.deliver(due[0])
+	return true
+}
+
+// deliver makes one attempt at a delivery and records the outcome
+func (s *WebhookService) deliver(delivery *models.DueDelivery) {
+	attempt := delivery.Attempts + 1
+	ctx, span := tracing.Start(s.ctx, "WebhookService.deliver",
+		tracing.String("webhook.event", delivery.Event), tracing.Int("webhook.attempt", attempt))
+	defer span.End()
+
+	status, err := s.sender.Send(ctx, delivery)
+	if s.ctx.Err() != nil {
+		// Interrupted by shutdown; not counted as an attempt
+		return
+	}
+	span.SetAttributes(tracing.Int("http.status_code", status))
+
+	// Record the outcome even if shutdown starts meanwhile
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err == nil {
+		metrics.WebhookDeliveries.Inc("delivered")
+		if err := s.repo.MarkDelivered(ctx, delivery.ID, status); err != nil {
+			logging.FromContext(ctx).Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	span.RecordError(err)
+	dead := attempt >= s.cfg.MaxAttempts
+	outcome := "retry"
+	if dead {
+		outcome = "dead"
+		logging.FromContext(ctx).Warn("Webhook delivery failed for good", "delivery_id", delivery.ID, "attempts", attempt, "error", err)
+	}
+	metrics.WebhookDeliveries.Inc(outcome)
+
+	message := err.Error()
+	if len(message) > maxDeliveryError {
+		message = message[:maxDeliveryError]
+	}
+	retryIn := webhook.Backoff(attempt, s.cfg.RetryBase, s.cfg.RetryMax)
+	if err := s.repo.MarkFailed(ctx, delivery.ID, status, message, retryIn, dead); err != nil {
+		logging.FromContext(ctx).Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// notifyExpired queues link.expired for links that have expired since the
+// last sweep
+func (s *WebhookService) notifyExpired() {
+	for s.ctx.Err() == nil {
+		n, err := s.repo.NotifyExpired(s.ctx, expiryWindow, expiryBatchSize, func(link *models.Link) (uuid.UUID, []byte, error) {
+			return newWebhookEvent(models.EventLinkExpired, s.links.toLinkResponse(link))
+		})
+		if err != nil {
+			if s.ctx.Err() == nil {
+				logging.FromContext(s.ctx).Error("Failed to queue link expiry events", "error", err)
+			}
+			return
+		}
+		if n < expiryBatchSize {
+			return
+		}
+	}
+}
+
+// prune drops delivered and dead deliveries older than the retention
+func (s *WebhookService) prune() {
+	deleted, err := s.repo.Prune(s.ctx, s.cfg.LogRetention)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			logging.FromContext(s.ctx).Error("Failed to prune webhook deliveries", "error", err)
+		}
+		return
+	}
+	if deleted > 0 {
+		logging.FromContext(s.ctx).Info("Pruned webhook deliveries", "deleted", deleted)
+	}
+}
+
+func (s *WebhookService) checkURL(raw string) error {
+	if err := webhook.CheckURL(raw, s.cfg.AllowPrivate); err != nil {
+		return ErrInvalidWebhookURL.WithField("url", err.Error()).Wrap(err)
+	}
+	return nil
+}
+
+// newWebhookEvent returns the ID and body of a new event
+func newWebhookEvent(event string, data interface{}) (uuid.UUID, []byte, error) {
+	id := uuid.New()
+	payload, err := json.Marshal(models.WebhookEvent{ID: id, Type: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	return id, payload, nil
+}
+
+// normalizeEvents sorts events and drops repeats
+func normalizeEvents(events []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, event := range events {
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"link-shortener/internal/config"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/utils"
+)
+
+type WorkspaceService struct {
+	workspaceRepo *repository.WorkspaceRepository
+	userRepo      *repository.UserRepository
+	mailer        mailer.Mailer
+	cfg           config.AuthConfig
+	appURL        string
+	links         *LinkService
+}
+
+func NewWorkspaceService(workspaceRepo *repository.WorkspaceRepository, userRepo *repository.UserRepository, mail mailer.Mailer, cfg config.AuthConfig, appURL string) *WorkspaceService {
+	return &WorkspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		mailer:        mail,
+		cfg:           cfg,
+		appURL:        strings.TrimSuffix(appURL, "/"),
+	}
+}
+
+// SetLinks lets deleted workspaces drop their links from the redirect cache
+func (s *WorkspaceService) SetLinks(links *LinkService) {
+	s.links = links
+}
+
+// requireWorkspaceRole checks that the user is a member of the workspace with
+// at least the required role and returns their actual role
+func requireWorkspaceRole(ctx context.Context, repo *repository.WorkspaceRepository, workspaceID, userID uuid.UUID, required string) (string, error) {
+	role, err := repo.GetMemberRole(ctx, workspaceID, userID)
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		return "", repository.ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get workspace role: %w", err)
+	}
+
+	if !models.WorkspaceRoleAllows(role, required) {
+		return "", ErrForbidden
+	}
+
+	return role, nil
+}
+
+// CreateWorkspace creates a workspace owned by the user
+func (s *WorkspaceService) CreateWorkspace(ctx context.Context, userID uuid.UUID, req *models.CreateWorkspaceRequest) (*models.WorkspaceMembership, error) {
+	workspace := &models.Workspace{
+		ID:   uuid.New(),
+		Name: strings.TrimSpace(req.Name),
+	}
+
+	if err := s.workspaceRepo.Create(ctx, workspace, userID); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	return &models.WorkspaceMembership{Workspace: *workspace, Role: models.WorkspaceRoleOwner}, nil
+}
+
+// ListWorkspaces returns every workspace the user belongs to
+func (s *WorkspaceService) ListWorkspaces(ctx context.Context, userID uuid.UUID) ([]*models.WorkspaceMembership, error) {
+	memberships, err := s.workspaceRepo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	return memberships, nil
+}
+
+func (s *WorkspaceService) GetWorkspace(ctx context.Context, userID, workspaceID uuid.UUID) (*models.WorkspaceMembership, error) {
+	role, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WorkspaceMembership{Workspace: *workspace, Role: role}, nil
+}
+
+// UpdateWorkspace renames the workspace. Only the owner may do this.
+func (s *WorkspaceService) UpdateWorkspace(ctx context.Context, userID, workspaceID uuid.UUID, req *models.UpdateWorkspaceRequest) (*models.WorkspaceMembership, error) {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.Name = strings.TrimSpace(req.Name)
+	if err := s.workspaceRepo.Update(ctx, workspace); err != nil {
+		return nil, fmt.Errorf("failed to update workspace: %w", err)
+	}
+
+	return &models.WorkspaceMembership{Workspace: *workspace, Role: models.WorkspaceRoleOwner}, nil
+}
+
+// DeleteWorkspace removes the workspace and all of its links. Only the owner
+// may do this.
+func (s *WorkspaceService) DeleteWorkspace(ctx context.Context, userID, workspaceID uuid.UUID) error {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	codes, err := s.workspaceRepo.Delete(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	s.links.ForgetLink(codes...)
+
+	return nil
+}
+
+func (s *WorkspaceService) ListMembers(ctx context.Context, userID, workspaceID uuid.UUID) ([]*models.WorkspaceMember, error) {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.workspaceRepo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
+
+// UpdateMemberRole switches a member between editor and viewer. Ownership is
+// moved with TransferOwnership instead.
+func (s *WorkspaceService) UpdateMemberRole(ctx context.Context, userID, workspaceID, memberID uuid.UUID, req *models.UpdateMemberRoleRequest) error {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	if memberID == userID {
+		return ErrChangeOwnRole
+	}
+
+	if _, err := s.workspaceRepo.GetMemberRole(ctx, workspaceID, memberID); err != nil {
+		return err
+	}
+
+	return s.workspaceRepo.SetMemberRole(ctx, workspaceID, memberID, req.Role)
+}
+
+// RemoveMember removes another member. The links they created stay in the
+// workspace and are handed to req.TransferTo, or to the owner by default.
+func (s *WorkspaceService) RemoveMember(ctx context.Context, userID, workspaceID, memberID uuid.UUID, req *models.RemoveMemberRequest) error {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	if memberID == userID {
+		return ErrRemoveSelf
+	}
+
+	transferTo, err := s.linkHeir(ctx, workspaceID, memberID, req.TransferTo, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.workspaceRepo.RemoveMember(ctx, workspaceID, memberID, transferTo)
+}
+
+// LeaveWorkspace removes the user from the workspace. An owner must name a
+// new owner, who also inherits their links unless TransferTo says otherwise.
+func (s *WorkspaceService) LeaveWorkspace(ctx context.Context, userID, workspaceID uuid.UUID, req *models.LeaveWorkspaceRequest) error {
+	role, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	var ownerID uuid.UUID
+	if role == models.WorkspaceRoleOwner {
+		if req.NewOwnerID == nil {
+			return ErrOwnerMustTransfer
+		}
+		if err := s.TransferOwnership(ctx, userID, workspaceID, &models.TransferOwnershipRequest{UserID: *req.NewOwnerID}); err != nil {
+			return err
+		}
+		ownerID = *req.NewOwnerID
+	} else {
+		ownerID, err = s.ownerID(ctx, workspaceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	transferTo, err := s.linkHeir(ctx, workspaceID, userID, req.TransferTo, ownerID)
+	if err != nil {
+		return err
+	}
+
+	return s.workspaceRepo.RemoveMember(ctx, workspaceID, userID, transferTo)
+}
+
+// TransferOwnership makes another member the owner; the current owner becomes an editor
+func (s *WorkspaceService) TransferOwnership(ctx context.Context, userID, workspaceID uuid.UUID, req *models.TransferOwnershipRequest) error {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	if req.UserID == userID {
+		return ErrAlreadyOwner
+	}
+
+	if err := s.workspaceRepo.TransferOwnership(ctx, workspaceID, userID, req.UserID); err != nil {
+		return fmt.Errorf("failed to transfer ownership: %w", err)
+	}
+
+	return nil
+}
+
+// InviteMember emails an invitation token to the given address
+func (s *WorkspaceService) InviteMember(ctx context.Context, userID, workspaceID uuid.UUID, req *models.InviteMemberRequest) (*models.WorkspaceInvitation, error) {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	inviter, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, hash, err := utils.GenerateActionToken(s.cfg.TokenSecret, models.TokenPurposeWorkspaceInvite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	invitation := &models.WorkspaceInvitation{
+		ID:          uuid.New(),
+		WorkspaceID: workspaceID,
+		Email:       strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:        req.Role,
+		TokenHash:   hash,
+		InvitedBy:   userID,
+		ExpiresAt:   time.Now().Add(s.cfg.InvitationTokenExpiry),
+	}
+
+	if err := s.workspaceRepo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to store invitation: %w", err)
+	}
+
+	body := fmt.Sprintf(
+		"Hi,\n\n%s invited you to join the workspace \"%s\" as %s. "+
+			"Sign in with this email address and use the link below to accept:\n\n%s\n\n"+
+			"The link expires in %s.\n",
+		inviter.Username,
+		workspace.Name,
+		invitation.Role,
+		fmt.Sprintf("%s/workspaces/accept?token=%s", s.appURL, url.QueryEscape(token)),
+		s.cfg.InvitationTokenExpiry,
+	)
+
+	if err := s.mailer.Send(&mailer.Message{To: invitation.Email, Subject: "You have been invited to a workspace", Body: body}); err != nil {
+		logging.FromContext(ctx).Error("Failed to send workspace invitation", "email", invitation.Email, "error", err)
+	}
+
+	return invitation, nil
+}
+
+func (s *WorkspaceService) ListInvitations(ctx context.Context, userID, workspaceID uuid.UUID) ([]*models.WorkspaceInvitation, error) {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.workspaceRepo.ListPendingInvitations(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (s *WorkspaceService) RevokeInvitation(ctx context.Context, userID, workspaceID, invitationID uuid.UUID) error {
+	if _, err := requireWorkspaceRole(ctx, s.workspaceRepo, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	return s.workspaceRepo.DeleteInvitation(ctx, workspaceID, invitationID)
+}
+
+// AcceptInvitation redeems an invitation token. The invitation only works for
+// the account whose email it was sent to.
+func (s *WorkspaceService) AcceptInvitation(ctx context.Context, userID uuid.UUID, req *models.AcceptInvitationRequest) (*models.WorkspaceMembership, error) {
+	hash, err := utils.VerifyActionToken(s.cfg.TokenSecret, models.TokenPurposeWorkspaceInvite, req.Token)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation, err := s.workspaceRepo.GetInvitationByHash(ctx, hash)
+	if errors.Is(err, repository.ErrInvitationNotFound) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	if invitation.AcceptedAt != nil {
+		return nil, ErrInvalidInvitation
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	if err := s.workspaceRepo.AcceptInvitation(ctx, invitation, userID); err != nil {
+		if errors.Is(err, repository.ErrInvitationUsed) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	return s.GetWorkspace(ctx, userID, invitation.WorkspaceID)
+}
+
+// linkHeir picks who inherits the links of a departing member: the requested
+// member if they can edit links, otherwise the fallback
+func (s *WorkspaceService) linkHeir(ctx context.Context, workspaceID, departingID uuid.UUID, requested *uuid.UUID, fallback uuid.UUID) (uuid.UUID, error) {
+	if requested == nil {
+		return fallback, nil
+	}
+
+	if *requested == departingID {
+		return uuid.Nil, ErrTransferToSelf.WithField("transfer_to", "must be another member")
+	}
+
+	role, err := s.workspaceRepo.GetMemberRole(ctx, workspaceID, *requested)
+	if err != nil && !errors.Is(err, repository.ErrMemberNotFound) {
+		return uuid.Nil, fmt.Errorf("failed to get workspace role: %w", err)
+	}
+	if err != nil || !models.WorkspaceRoleAllows(role, models.WorkspaceRoleEditor) {
+		return uuid.Nil, ErrTransferTargetRole.WithField("transfer_to", "must be an editor or the owner")
+	}
+
+	return *requested, nil
+}
+
+func (s *WorkspaceService) ownerID(ctx context.Context, workspaceID uuid.UUID) (uuid.UUID, error) {
+	members, err := s.workspaceRepo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	for _, member := range members {
+		if member.Role == models.WorkspaceRoleOwner {
+			return member.UserID, nil
+		}
+	}
+
+	return uuid.Nil, fmt.Errorf("workspace has no owner")
+}
@@ -0,0 +1,241 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StdoutExporter writes one JSON object per span, for local runs
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+type stdoutSpan struct {
+	Name          string                 `json:"name"`
+	Kind          string                 `json:"kind"`
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	Start         time.Time              `json:"start"`
+	DurationMS    float64                `json:"duration_ms"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Events        []string               `json:"events,omitempty"`
+	Status        string                 `json:"status,omitempty"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+}
+
+var kindNames = map[SpanKind]string{KindInternal: "internal", KindServer: "server", KindClient: "client"}
+
+var statusNames = map[StatusCode]string{StatusOK: "ok", StatusError: "error"}
+
+func (e *StdoutExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		out := stdoutSpan{
+			Name:          span.Name,
+			Kind:          kindNames[span.Kind],
+			TraceID:       span.SpanContext.TraceID.String(),
+			SpanID:        span.SpanContext.SpanID.String(),
+			Start:         span.Start,
+			DurationMS:    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			Status:        statusNames[span.Status],
+			StatusMessage: span.StatusMessage,
+		}
+		if span.Parent.IsValid() {
+			out.ParentSpanID = span.Parent.String()
+		}
+		if len(span.Attributes) > 0 {
+			out.Attributes = make(map[string]interface{}, len(span.Attributes))
+			for _, attr := range span.Attributes {
+				out.Attributes[attr.Key] = attr.Value
+			}
+		}
+		for _, event := range span.Events {
+			out.Events = append(out.Events, event.Name)
+		}
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StdoutExporter) Shutdown(ctx context.Context) error { return nil }
+
+// OTLPExporter posts spans to an OpenTelemetry collector using OTLP/HTTP
+// with JSON encoding
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter sends to endpoint, e.g. http://localhost:4318; the
+// /v1/traces path is added when missing
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpEvent struct {
+	Name         string          `json:"name"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	scope := otlpScopeSpans{}
+	scope.Scope.Name = "link-shortener"
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: unixNano(span.Start),
+			EndTimeUnixNano:   unixNano(span.End),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.Parent.IsValid() {
+			out.ParentSpanID = span.Parent.String()
+		}
+		for _, event := range span.Events {
+			out.Events = append(out.Events, otlpEvent{
+				Name:         event.Name,
+				TimeUnixNano: unixNano(event.Time),
+				Attributes:   otlpAttributes(event.Attributes),
+			})
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = otlpAttributes([]Attribute{String("service.name", e.serviceName)})
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{resource}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func otlpAttributes(attrs []Attribute) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		out = append(out, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return out
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,434 @@
+// Package tracing records OpenTelemetry-compatible spans. Trace context is
+// carried in context.Context and propagated with the W3C traceparent header;
+// finished spans are batched and handed to an Exporter.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceParentHeader is the W3C trace context header
+const TraceParentHeader = "traceparent"
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies a span across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// TraceParent formats the span context as a traceparent header value
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceParent reads a traceparent header value
+func ParseTraceParent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+type SpanKind int
+
+// Values match the OTLP span kinds
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type StatusCode int
+
+// Values match the OTLP status codes
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a span attribute; Value is a string, int64, float64 or bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData is a finished span as handed to exporters
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is an operation in progress. A nil or unsampled span records nothing,
+// so callers never need to check before using one.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+func (s *Span) recording() bool { return s != nil && s.tracer != nil && s.data.SpanContext.Sampled }
+
+// SpanContext returns the span's identifiers; the zero value for a nil span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetStatus marks the span as failed or succeeded; OK is never downgraded
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Status == StatusOK {
+		return
+	}
+	s.data.Status = code
+	s.data.StatusMessage = message
+}
+
+// RecordError adds an exception event and marks the span as failed. A nil
+// error is ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.data.Events = append(s.data.Events, Event{
+		Name:       "exception",
+		Time:       time.Now(),
+		Attributes: []Attribute{String("exception.message", err.Error()), String("exception.type", fmt.Sprintf("%T", err))},
+	})
+	s.mu.Unlock()
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span and queues it for export. Calls after the first are
+// ignored.
+func (s *Span) End() {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.enqueue(data)
+}
+
+// Exporter sends finished spans to a backend
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+)
+
+// Tracer creates spans and exports them in batches from a background goroutine
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+	queue       chan SpanData
+	flush       chan chan struct{}
+	done        chan struct{}
+	mu          sync.RWMutex
+	closed      bool
+	dropped     atomic.Int64
+}
+
+// NewTracer starts a tracer that samples sampleRatio of new traces. Child
+// spans follow their parent's sampling decision.
+func NewTracer(exporter Exporter, sampleRatio float64) *Tracer {
+	t := &Tracer{
+		exporter:    exporter,
+		sampleRatio: sampleRatio,
+		queue:       make(chan SpanData, queueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start begins a span as a child of the span in ctx, if any
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx).SpanContext()
+	if !parent.IsValid() {
+		parent = remoteFromContext(ctx)
+	}
+	return t.start(ctx, name, kind, parent, attrs)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext, attrs []Attribute) (context.Context, *Span) {
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sample(sc.TraceID)
+	}
+
+	span := &Span{tracer: t, data: SpanData{
+		Name:        name,
+		Kind:        kind,
+		SpanContext: sc,
+		Parent:      parent.SpanID,
+		Start:       time.Now(),
+		Attributes:  attrs,
+	}}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// sample decides from the trace ID so every service makes the same choice
+func (t *Tracer) sample(id TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	bound := uint64(t.sampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:])>>1 < bound
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of spans discarded because the queue was full
+func (t *Tracer) Dropped() int64 { return t.dropped.Load() }
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			slog.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-t.queue:
+			if !ok {
+				export()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case reply := <-t.flush:
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			export()
+			close(reply)
+		}
+	}
+}
+
+// ForceFlush exports every span ended so far
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case t.flush <- reply:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the remaining spans and stops the tracer. Spans ended
+// afterwards are discarded.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.queue)
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// SpanFromContext returns the current span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent makes sc, typically read from an incoming
+// traceparent header, the parent of the next span started from ctx
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func remoteFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault installs the tracer used by Start; nil disables tracing
+func SetDefault(t *Tracer) { defaultTracer.Store(t) }
+
+// Default returns the tracer installed with SetDefault, or nil
+func Default() *Tracer { return defaultTracer.Load() }
+
+// Start begins an internal span with the default tracer. When tracing is
+// disabled it returns ctx unchanged and a nil span, which is safe to use.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal, attrs...)
+}
+
+// StartKind is Start with an explicit span kind
+func StartKind(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	t := Default()
+	if t == nil {
+		return ctx, nil
+	}
+	return t.Start(ctx, name, kind, attrs...)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// Blocklist holds destination domains that links may not point to. A domain
+// also blocks its subdomains. The list can be replaced while in use.
+type Blocklist struct {
+	domains atomic.Pointer[map[string]bool]
+}
+
+func NewBlocklist(domains []string) *Blocklist {
+	b := &Blocklist{}
+	b.Set(domains)
+	return b
+}
+
+// Set replaces the blocked domains
+func (b *Blocklist) Set(domains []string) {
+	set := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		set[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")] = true
+	}
+	b.domains.Store(&set)
+}
+
+// Blocks reports whether the URL's host is a blocked domain or a subdomain
+// of one
+func (b *Blocklist) Blocks(rawURL string) bool {
+	if b == nil {
+		return false
+	}
+	domains := *b.domains.Load()
+	if len(domains) == 0 {
+		return false
+	}
+
+	parsed, err := url.Parse(SanitizeURL(rawURL))
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	for host != "" {
+		if domains[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false
+}
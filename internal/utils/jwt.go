@@ -6,17 +6,41 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"link-shortener/internal/models"
+)
+
+// ScopeTwoFactorChallenge marks a token that only proves the password step
+// of a two-step login
+const ScopeTwoFactorChallenge = "2fa_challenge"
+
+// Every token is issued by tokenIssuer for one audience, so that a challenge
+// token is never accepted where an access token is expected or the other way
+// round
+const (
+	tokenIssuer = "link-shortener"
+
+	AudienceAPI                = "link-shortener:api"
+	AudienceTwoFactorChallenge = "link-shortener:2fa_challenge"
 )
 
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role,omitempty"`
+	Permissions []string  `json:"permissions,omitempty"`
+	Scope       string    `json:"scope,omitempty"`
+	// SessionVersion is bumped on the user to revoke every token issued before
+	SessionVersion int `json:"sv"`
 	jwt.RegisteredClaims
 }
 
+// JWTManager issues and validates tokens. It signs with a shared HMAC secret
+// unless it was created with a key ring, in which case tokens are signed with
+// the ring's active asymmetric key and carry its ID in the kid header.
 type JWTManager struct {
 	secretKey string
+	keys      *KeyRing
 	expiry    time.Duration
 }
 
@@ -27,31 +51,135 @@ func NewJWTManager(secretKey string, expiry time.Duration) *JWTManager {
 	}
 }
 
-func (j *JWTManager) GenerateToken(userID uuid.UUID, username, email string) (string, error) {
+// NewKeyRingJWTManager creates a manager that signs with asymmetric keys
+func NewKeyRingJWTManager(keys *KeyRing, expiry time.Duration) *JWTManager {
+	return &JWTManager{
+		keys:   keys,
+		expiry: expiry,
+	}
+}
+
+// JWKS returns the public verification keys. It is empty for HMAC signing.
+func (j *JWTManager) JWKS() JWKSet {
+	if j.keys == nil {
+		return JWKSet{Keys: []JWK{}}
+	}
+	return j.keys.JWKS()
+}
+
+// GenerateToken issues an access token carrying the user's identity and role
+func (j *JWTManager) GenerateToken(user *models.User) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:         user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           user.Role,
+		Permissions:    user.EffectivePermissions(),
+		SessionVersion: user.SessionVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "link-shortener",
+			Issuer:    tokenIssuer,
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{AudienceAPI},
+		},
+	}
+
+	return j.sign(claims)
+}
+
+// GenerateChallengeToken issues a short-lived token that can only be
+// exchanged for an access token together with a second factor
+func (j *JWTManager) GenerateChallengeToken(userID uuid.UUID, expiry time.Duration) (string, error) {
+	claims := &Claims{
+		UserID: userID,
+		Scope:  ScopeTwoFactorChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    tokenIssuer,
 			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{AudienceTwoFactorChallenge},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	return j.sign(claims)
 }
 
+// ValidateToken validates an access token
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+	claims, err := j.parse(tokenString, AudienceAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Scope != "" {
+		return nil, errors.New("invalid token scope")
+	}
+
+	return claims, nil
+}
+
+// ValidateChallengeToken validates a two-factor challenge token
+func (j *JWTManager) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString, AudienceTwoFactorChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Scope != ScopeTwoFactorChallenge {
+		return nil, errors.New("invalid token scope")
+	}
+
+	return claims, nil
+}
+
+func (j *JWTManager) sign(claims *Claims) (string, error) {
+	if j.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(j.secretKey))
+	}
+
+	key := j.keys.Active()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+func (j *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if j.keys == nil {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(j.secretKey), nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.keys.Key(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	// The algorithm comes from our key, never from the token header
+	if token.Method.Alg() != key.signingMethod().Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.PrivateKey.Public(), nil
+}
+
+// parse verifies the signature, expiry, issuer and audience of a token
+func (j *JWTManager) parse(tokenString, audience string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keyFunc,
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(audience),
+	)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey is an asymmetric key used to sign access tokens. Retired keys
+// no longer sign but still verify tokens issued before the rotation.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+}
+
+// JWK is the public half of a signing key as published in the JWKS
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// GenerateSigningKey creates a new key for the given algorithm
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var signer crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:         base64.RawURLEncoding.EncodeToString(id),
+		Algorithm:  algorithm,
+		PrivateKey: signer,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// EncodePrivateKey serialises a private key as PKCS#8 PEM for storage
+func EncodePrivateKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// DecodePrivateKey parses a PKCS#8 PEM private key
+func DecodePrivateKey(encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key cannot sign")
+	}
+	return signer, nil
+}
+
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK returns the public key in JSON Web Key form
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+
+	switch public := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
+
+// KeyRing holds the active signing key and the retired keys still accepted
+// for verification. It is safe for concurrent use and is replaced wholesale
+// whenever keys are loaded or rotated.
+type KeyRing struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: map[string]*SigningKey{}}
+}
+
+// Set replaces the keys. The newest key that is not retired becomes active.
+func (r *KeyRing) Set(keys []*SigningKey) {
+	byID := make(map[string]*SigningKey, len(keys))
+	var active *SigningKey
+	for _, key := range keys {
+		byID[key.ID] = key
+		if key.RetiredAt == nil && (active == nil || key.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = byID
+	r.active = active
+}
+
+// Active returns the key used for signing, or nil when none is loaded
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Key looks up a signing or verification key by ID
+func (r *KeyRing) Key(id string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// JWKS returns the public keys, newest first
+func (r *KeyRing) JWKS() JWKSet {
+	r.mu.RLock()
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// GenerateActionToken creates a random token signed for the given purpose.
+// The returned hash is what should be persisted; the token itself is only
+// ever handed to the user.
+func GenerateActionToken(secret, purpose string) (token, hash string, err error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(nonce)
+	token = payload + "." + signActionToken(secret, purpose, payload)
+
+	return token, HashToken(token), nil
+}
+
+// VerifyActionToken checks the token signature for the given purpose and
+// returns the hash to look up in storage
+func VerifyActionToken(secret, purpose, token string) (string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || payload == "" || signature == "" {
+		return "", errors.New("malformed token")
+	}
+
+	expected := signActionToken(secret, purpose, payload)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", errors.New("invalid token signature")
+	}
+
+	return HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func signActionToken(secret, purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -68,3 +68,33 @@ func SanitizeURL(urlStr string) string {
 
 	return urlStr
 }
+
+// Limits on the tags of one link
+const (
+	MaxTags      = 10
+	MaxTagLength = 50
+)
+
+// NormalizeTags trims tags and drops empty and repeated ones, keeping the
+// first spelling. It never returns nil.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		if len([]rune(tag)) > MaxTagLength {
+			return nil, fmt.Errorf("tags must be at most %d characters", MaxTagLength)
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", MaxTags)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,191 @@
+// Package webhook signs and sends webhook deliveries.
+//
+// Every request carries the event in its body and these headers:
+//
+//	X-Webhook-Id         the delivery ID, stable across retries
+//	X-Webhook-Event      the event type, such as link.created
+//	X-Webhook-Timestamp  Unix seconds when the attempt was made
+//	X-Webhook-Signature  sha256= and the hex HMAC-SHA256 of "timestamp.body"
+//	                     keyed with the webhook's secret
+//
+// Receivers should recompute the signature, compare it in constant time and
+// reject timestamps too far from their own clock.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"link-shortener/internal/models"
+)
+
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+	// maxErrorBody is how much of a failed response is kept in the log
+	maxErrorBody = 512
+)
+
+// ErrPrivateAddress is returned for endpoints on loopback, private or
+// link-local addresses unless they are allowed
+var ErrPrivateAddress = errors.New("webhook endpoints may not be on a private network")
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Webhook-Signature value for a body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature made by Sign and that timestamp is within
+// tolerance of now
+func Verify(secret, signature string, timestamp int64, body []byte, tolerance time.Duration) bool {
+	if d := time.Since(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// Backoff is the wait before the retry that follows a failed attempt: base
+// after the first, doubling with every attempt up to max
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	wait := float64(base) * math.Pow(2, float64(attempt-1))
+	if wait > float64(max) {
+		return max
+	}
+	return time.Duration(wait)
+}
+
+// CheckURL rejects endpoints that are not http(s) or that name a private
+// address directly. Host names are checked again when they are resolved.
+func CheckURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("URL must use http or https")
+	}
+	if u.Hostname() == "" {
+		return errors.New("URL must have a host")
+	}
+	if allowPrivate {
+		return nil
+	}
+	if strings.EqualFold(u.Hostname(), "localhost") {
+		return ErrPrivateAddress
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && isPrivate(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// Sender posts deliveries. Redirects are not followed, and unless
+// allowPrivate is set connections to private addresses are refused after DNS
+// resolution, so a webhook cannot be pointed at internal services.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Sender{client: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send makes one attempt at a delivery. It returns the response status, or
+// 0 when there was no response, and an error unless the status is 2xx.
+func (s *Sender) Send(ctx context.Context, delivery *models.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "link-shortener-webhooks/1.0")
+	req.Header.Set(HeaderID, delivery.ID.String())
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// Drain the body so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return resp.StatusCode, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("endpoint responded %s", resp.Status)
+	if text := strings.TrimSpace(string(body)); text != "" {
+		err = fmt.Errorf("%w: %s", err, text)
+	}
+	return resp.StatusCode, err
+}
@@ -42,8 +42,10 @@ END;
 $$ language 'plpgsql';
 
 -- Create triggers to automatically update updated_at
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
 CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_links_updated_at ON links;
 CREATE TRIGGER update_links_updated_at BEFORE UPDATE ON links
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,8 @@
+-- Bumped on password change to revoke outstanding tokens
+ALTER TABLE users ADD COLUMN IF NOT EXISTS session_version INTEGER NOT NULL DEFAULT 0;
+
+-- Accounts pending deletion are removed once this time has passed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+-- Purpose specific token data, e.g. the new address for an email change
+ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS payload TEXT NOT NULL DEFAULT '';
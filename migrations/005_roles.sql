@@ -0,0 +1,8 @@
+-- Roles and per-user permission grants
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS permissions TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+
+-- Administrative takedown of abusive links
+ALTER TABLE links ADD COLUMN IF NOT EXISTS taken_down_at TIMESTAMP;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS takedown_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- Asymmetric keys for signing access tokens. Retired keys are kept until
+-- every token they signed has expired.
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP
+);
@@ -0,0 +1,13 @@
+-- Records which migrations have been applied so readiness checks can tell
+-- whether the schema is current. Every later migration inserts its own
+-- version at the end. When the files are run by hand, in order and stopping
+-- at the first error, 1 to 8 have run by the time this one does; the server
+-- records each version itself as it applies the file.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO schema_migrations (version)
+SELECT generate_series(1, 9)
+ON CONFLICT (version) DO NOTHING;
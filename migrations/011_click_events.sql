@@ -0,0 +1,13 @@
+-- One row per recorded redirect, for exports and analytics; links.clicks
+-- keeps the running total
+CREATE TABLE IF NOT EXISTS click_events (
+    id BIGSERIAL PRIMARY KEY,
+    link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    clicked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_click_events_link_id_clicked_at ON click_events(link_id, clicked_at);
+
+INSERT INTO schema_migrations (version) VALUES (11) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,19 @@
+-- Idempotency-Key records for link creation. A row is claimed with a NULL
+-- status_code and a fresh lock_id before the request runs, then completed
+-- with the response that is replayed to retries until expires_at.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    lock_id UUID,
+    status_code INTEGER,
+    content_type VARCHAR(100) NOT NULL DEFAULT '',
+    body BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+INSERT INTO schema_migrations (version) VALUES (13) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Lets click events older than the retention be purged without a full scan
+CREATE INDEX IF NOT EXISTS idx_click_events_clicked_at ON click_events(clicked_at);
+
+INSERT INTO schema_migrations (version) VALUES (14) ON CONFLICT (version) DO NOTHING;
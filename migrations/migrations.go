@@ -0,0 +1,75 @@
+// Package migrations embeds the SQL migration files. The server applies them
+// at startup and knows from them which schema version it expects.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration is one SQL file, numbered by the NNN_ prefix of its name
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// All returns the migrations in the order they are applied
+func All() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Migration
+	for _, entry := range entries {
+		version, ok := versionOf(entry.Name())
+		if !ok {
+			continue
+		}
+		sql, err := files.ReadFile(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		all = append(all, Migration{Version: version, Name: entry.Name(), SQL: string(sql)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// Versions returns the number of every migration in order
+func Versions() []int {
+	entries, _ := files.ReadDir(".")
+	var versions []int
+	for _, entry := range entries {
+		if version, ok := versionOf(entry.Name()); ok {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Latest returns the highest migration number
+func Latest() int {
+	versions := Versions()
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1]
+}
+
+func versionOf(name string) (int, bool) {
+	if !strings.HasSuffix(name, ".sql") {
+		return 0, false
+	}
+	prefix, _, _ := strings.Cut(name, "_")
+	version, err := strconv.Atoi(prefix)
+	return version, err == nil
+}
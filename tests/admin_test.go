@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/utils"
+)
+
+// allowSessions accepts every session so middleware can be tested without a database
+type allowSessions struct{}
+
+func (allowSessions) ValidateSession(context.Context, uuid.UUID, int) error { return nil }
+
+func setupRBACTestRouter(jwtMgr *utils.JWTManager) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	authMiddleware := middleware.NewAuthMiddleware(jwtMgr, allowSessions{})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router := gin.New()
+	admin := router.Group("/api/admin")
+	admin.Use(authMiddleware.AuthRequired())
+	{
+		admin.GET("/users", authMiddleware.PermissionRequired(models.PermissionUsersRead), ok)
+		admin.PUT("/users/:id/role", authMiddleware.AdminRequired(), ok)
+	}
+
+	return router
+}
+
+func TestAdminAccess(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	router := setupRBACTestRouter(jwtMgr)
+
+	adminUser := &models.User{ID: uuid.New(), Role: models.RoleAdmin}
+	regularUser := &models.User{ID: uuid.New(), Role: models.RoleUser}
+	supportUser := &models.User{ID: uuid.New(), Role: models.RoleUser, Permissions: []string{models.PermissionUsersRead}}
+
+	tests := []struct {
+		name           string
+		user           *models.User
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"Admin lists users", adminUser, "GET", "/api/admin/users", http.StatusOK},
+		{"Admin changes role", adminUser, "PUT", "/api/admin/users/1/role", http.StatusOK},
+		{"User cannot list users", regularUser, "GET", "/api/admin/users", http.StatusForbidden},
+		{"User cannot change role", regularUser, "PUT", "/api/admin/users/1/role", http.StatusForbidden},
+		{"Granted permission lists users", supportUser, "GET", "/api/admin/users", http.StatusOK},
+		{"Granted permission is not admin", supportUser, "PUT", "/api/admin/users/1/role", http.StatusForbidden},
+		{"Anonymous is rejected", nil, "GET", "/api/admin/users", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.user != nil {
+				token, err := jwtMgr.GenerateToken(tt.user)
+				assert.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	user := &models.User{Role: models.RoleUser, Permissions: []string{models.PermissionStatsRead}}
+	assert.Equal(t, []string{models.PermissionStatsRead}, user.EffectivePermissions())
+
+	admin := &models.User{Role: models.RoleAdmin, Permissions: []string{models.PermissionStatsRead}}
+	assert.ElementsMatch(t, models.RolePermissions[models.RoleAdmin], admin.EffectivePermissions())
+}
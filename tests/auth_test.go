@@ -12,6 +12,7 @@ import (
 	"link-shortener/internal/config"
 	"link-shortener/internal/database"
 	"link-shortener/internal/handlers"
+	"link-shortener/internal/mailer"
 	"link-shortener/internal/middleware"
 	"link-shortener/internal/models"
 	"link-shortener/internal/repository"
@@ -34,9 +35,11 @@ func setupTestRouter() (*gin.Engine, *database.Database) {
 	// Initialize dependencies
 	jwtMgr := utils.NewJWTManager(cfg.JWT.Secret, cfg.JWT.Expiry)
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, jwtMgr)
+	tokenRepo := repository.NewTokenRepository(db)
+	recoveryRepo := repository.NewRecoveryCodeRepository(db)
+	authService := services.NewAuthService(userRepo, tokenRepo, recoveryRepo, jwtMgr, mailer.NewLogMailer(cfg.Mail.From), cfg.Auth, cfg.Mail.AppURL)
 	authHandler := handlers.NewAuthHandler(authService)
-	authMiddleware := middleware.NewAuthMiddleware(jwtMgr)
+	authMiddleware := middleware.NewAuthMiddleware(jwtMgr, authService)
 
 	// Setup router
 	router := gin.Default()
@@ -47,6 +50,7 @@ func setupTestRouter() (*gin.Engine, *database.Database) {
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.GET("/profile", authMiddleware.AuthRequired(), authHandler.GetProfile)
+			auth.POST("/change-password", authMiddleware.AuthRequired(), authHandler.ChangePassword)
 		}
 	}
 
@@ -187,3 +191,62 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	router, db := setupTestRouter()
+	defer db.Close()
+
+	registerBody := models.RegisterRequest{
+		Username: "changepw",
+		Email:    "changepw@example.com",
+		Password: "password123",
+	}
+	registerJSON, _ := json.Marshal(registerBody)
+	registerReq, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(registerJSON))
+	registerReq.Header.Set("Content-Type", "application/json")
+	registerW := httptest.NewRecorder()
+	router.ServeHTTP(registerW, registerReq)
+
+	var registerResponse struct {
+		Data models.AuthResponse `json:"data"`
+	}
+	json.Unmarshal(registerW.Body.Bytes(), &registerResponse)
+	oldToken := registerResponse.Data.Token
+
+	changeBody, _ := json.Marshal(models.ChangePasswordRequest{
+		CurrentPassword: "password123",
+		NewPassword:     "newpassword123",
+	})
+	changeReq, _ := http.NewRequest("POST", "/api/auth/change-password", bytes.NewBuffer(changeBody))
+	changeReq.Header.Set("Content-Type", "application/json")
+	changeReq.Header.Set("Authorization", "Bearer "+oldToken)
+	changeW := httptest.NewRecorder()
+	router.ServeHTTP(changeW, changeReq)
+	assert.Equal(t, http.StatusOK, changeW.Code)
+
+	var changeResponse struct {
+		Data models.AuthResponse `json:"data"`
+	}
+	json.Unmarshal(changeW.Body.Bytes(), &changeResponse)
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{"Old token is revoked", oldToken, http.StatusUnauthorized},
+		{"New token works", changeResponse.Data.Token, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/auth/profile", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
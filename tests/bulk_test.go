@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/config"
+	"link-shortener/internal/database"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+// The requests below fail before any item reaches the database, so the
+// service runs without repositories
+func TestBulkLinks(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	linkService := services.NewLinkService(nil, nil, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8, BulkMaxItems: 3}, utils.NewBlocklist([]string{"blocked.example"}), nil)
+	router, doc := setupAPITestRouter(t, jwtMgr, linkService)
+
+	token, err := jwtMgr.GenerateToken(&models.User{ID: uuid.New(), Role: models.RoleUser})
+	require.NoError(t, err)
+
+	post := func(t *testing.T, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/links"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		op := doc.Operation(http.MethodPost, "/api/v1/links"+path)
+		require.NotNil(t, op)
+		assert.NoError(t, doc.Validate(doc.ResponseSchema(op, w.Code), w.Body.Bytes()))
+		return w
+	}
+	decodeError := func(t *testing.T, w *httptest.ResponseRecorder) apperror.Response {
+		var body apperror.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	t.Run("Atomic mode rejects the whole request", func(t *testing.T) {
+		w := post(t, "/bulk", `{"links":[
+			{"original_url":"https://example.com/a"},
+			{"original_url":"https://blocked.example/b"},
+			{"original_url":"https://example.com/c","custom_alias":"no"}
+		]}`)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+		body := decodeError(t, w)
+		assert.Equal(t, "bulk_rejected", body.Code)
+		assert.Equal(t, []apperror.FieldError{
+			{Field: "links[1].original_url", Message: "destination domain is blocked"},
+			{Field: "links[2].custom_alias", Message: "short code must be between 3 and 20 characters"},
+		}, body.Details)
+	})
+
+	t.Run("Aliases must be unique within the request", func(t *testing.T) {
+		w := post(t, "/bulk", `{"mode":"atomic","links":[
+			{"original_url":"https://example.com/a","custom_alias":"promo"},
+			{"original_url":"https://example.com/b","custom_alias":"promo"}
+		]}`)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Equal(t, []apperror.FieldError{{Field: "links[1].custom_alias", Message: "is used by an earlier item"}}, decodeError(t, w).Details)
+	})
+
+	t.Run("Partial mode reports each item", func(t *testing.T) {
+		w := post(t, "/bulk", `{"mode":"partial","links":[
+			{"original_url":""},
+			{"original_url":"https://blocked.example"},
+			{"original_url":"https://example.com","title":"`+strings.Repeat("t", 256)+`"}
+		]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var body struct {
+			Data models.BulkResult `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, models.BulkModePartial, body.Data.Mode)
+		assert.Equal(t, 0, body.Data.Succeeded)
+		assert.Equal(t, 3, body.Data.Failed)
+
+		var codes []string
+		for i, item := range body.Data.Results {
+			assert.Equal(t, i, item.Index)
+			assert.Equal(t, models.BulkItemFailed, item.Status)
+			require.NotNil(t, item.Error)
+			codes = append(codes, item.Error.Code)
+		}
+		assert.Equal(t, []string{"invalid_url", "domain_blocked", "invalid_title"}, codes)
+	})
+
+	t.Run("Requests are bounded", func(t *testing.T) {
+		item := `{"original_url":"https://example.com"}`
+		w := post(t, "/bulk", `{"links":[`+strings.Repeat(item+",", 3)+item+`]}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "too_many_items", decodeError(t, w).Code)
+
+		w = post(t, "/bulk", `{"links":[]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = post(t, "/bulk", `{"mode":"some","links":[`+item+`]}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "mode", decodeError(t, w).Details[0].Field)
+
+		ids := make([]string, 4)
+		for i := range ids {
+			ids[i] = `"` + uuid.NewString() + `"`
+		}
+		w = post(t, "/bulk/delete", `{"ids":[`+strings.Join(ids, ",")+`]}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "too_many_items", decodeError(t, w).Code)
+	})
+
+	t.Run("Actions need either ids or a filter", func(t *testing.T) {
+		for _, body := range []string{`{}`, `{"ids":["` + uuid.NewString() + `"],"filter":{"q":"promo"}}`} {
+			for _, action := range []string{"activate", "deactivate", "delete"} {
+				w := post(t, "/bulk/"+action, body)
+				require.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Equal(t, "invalid_bulk_target", decodeError(t, w).Code)
+			}
+		}
+	})
+}
+
+func TestBulkLinksDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+	linkService := newTestLinkService(t, db, config.LinkConfig{BulkMaxItems: 600})
+	ctx := context.Background()
+
+	t.Run("Links are inserted in batches", func(t *testing.T) {
+		user := createTestUser(t, db)
+		req := &models.BulkCreateLinksRequest{Links: make([]models.CreateLinkRequest, 501)}
+		for i := range req.Links {
+			req.Links[i].OriginalURL = fmt.Sprintf("https://example.com/%d", i)
+		}
+
+		result, err := linkService.CreateLinks(ctx, user.ID, req)
+		require.NoError(t, err)
+		assert.Equal(t, 501, result.Succeeded)
+		assert.Equal(t, 501, countLinks(t, db, user.ID))
+
+		codes := map[string]bool{}
+		for i, item := range result.Results {
+			require.Equal(t, models.BulkItemSucceeded, item.Status)
+			assert.Equal(t, fmt.Sprintf("https://example.com/%d", i), item.Link.OriginalURL)
+			codes[item.Link.ShortCode] = true
+		}
+		assert.Len(t, codes, 501)
+	})
+
+	t.Run("Taken aliases roll back atomic requests", func(t *testing.T) {
+		user := createTestUser(t, db)
+		alias := "bulk" + uuid.NewString()[:8]
+		_, err := linkService.CreateLink(ctx, user.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com", CustomAlias: alias})
+		require.NoError(t, err)
+
+		links := []models.CreateLinkRequest{{OriginalURL: "https://example.com/a"}, {OriginalURL: "https://example.com/b", CustomAlias: alias}}
+		_, err = linkService.CreateLinks(ctx, user.ID, &models.BulkCreateLinksRequest{Links: links})
+		assert.ErrorIs(t, err, services.ErrBulkRejected)
+		assert.Equal(t, []apperror.FieldError{{Field: "links[1].custom_alias", Message: "is already taken"}}, apperror.ResponseOf(err).Details)
+		assert.Equal(t, 1, countLinks(t, db, user.ID))
+
+		result, err := linkService.CreateLinks(ctx, user.ID, &models.BulkCreateLinksRequest{Mode: models.BulkModePartial, Links: links})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Succeeded)
+		assert.Equal(t, "alias_taken", result.Results[1].Error.Code)
+		assert.Equal(t, 2, countLinks(t, db, user.ID))
+	})
+
+	// With one-character codes taken, every regenerated code collides until
+	// the attempts run out
+	t.Run("Short codes are regenerated a bounded number of times", func(t *testing.T) {
+		user := createTestUser(t, db)
+		var taken []*models.Link
+		for _, c := range "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_" {
+			taken = append(taken, &models.Link{ID: uuid.New(), UserID: user.ID, OriginalURL: "https://example.com", ShortCode: string(c)})
+		}
+		_, err := repository.NewLinkRepository(db).CreateMany(ctx, taken, false)
+		require.NoError(t, err)
+		before := countLinks(t, db, user.ID)
+
+		short := newTestLinkService(t, db, config.LinkConfig{ShortCodeLength: 1, BulkMaxItems: 10})
+		links := []models.CreateLinkRequest{{OriginalURL: "https://example.com/a"}, {OriginalURL: "https://example.com/b", CustomAlias: "bulk" + uuid.NewString()[:8]}}
+		_, err = short.CreateLinks(ctx, user.ID, &models.BulkCreateLinksRequest{Links: links})
+		assert.ErrorContains(t, err, "failed to find free short codes")
+		assert.Equal(t, before, countLinks(t, db, user.ID))
+
+		result, err := short.CreateLinks(ctx, user.ID, &models.BulkCreateLinksRequest{Mode: models.BulkModePartial, Links: links})
+		require.NoError(t, err)
+		assert.Equal(t, "bulk_incomplete", result.Results[0].Error.Code)
+		assert.Equal(t, models.BulkItemSucceeded, result.Results[1].Status)
+		assert.Equal(t, before+1, countLinks(t, db, user.ID))
+	})
+	t.Run("Batches whose events fail are not created", func(t *testing.T) {
+		user := createTestUser(t, db)
+		linkRepo := repository.NewLinkRepository(db)
+		linkRepo.SetOutbox(failingOutbox{})
+		failing := services.NewLinkService(linkRepo, repository.NewWorkspaceRepository(db), "http://localhost:8080",
+			config.LinkConfig{ShortCodeLength: 8, BulkMaxItems: 10}, utils.NewBlocklist(nil), nil)
+
+		links := []models.CreateLinkRequest{{OriginalURL: "https://example.com/a"}, {OriginalURL: "https://example.com/b"}}
+		result, err := failing.CreateLinks(ctx, user.ID, &models.BulkCreateLinksRequest{Mode: models.BulkModePartial, Links: links})
+		require.NoError(t, err)
+		assert.Zero(t, result.Succeeded)
+		for _, item := range result.Results {
+			assert.Equal(t, models.BulkItemFailed, item.Status)
+			assert.Equal(t, "bulk_incomplete", item.Error.Code)
+		}
+		assert.Zero(t, countLinks(t, db, user.ID))
+	})
+}
+
+// failingOutbox makes every transaction that queues events roll back
+type failingOutbox struct{}
+
+func (failingOutbox) QueueLinks(context.Context, *database.Tx, string, []*models.Link) error {
+	return errors.New("outbox unavailable")
+}
+
+func (failingOutbox) QueueClick(context.Context, *database.Tx, *models.ClickEvent) error {
+	return errors.New("outbox unavailable")
+}
@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+)
+
+func setValidConfigEnv(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "a-strong-database-password")
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("MAIL_DRIVER", "log")
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Run("Valid configuration", func(t *testing.T) {
+		setValidConfigEnv(t)
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("Reports every problem", func(t *testing.T) {
+		setValidConfigEnv(t)
+		t.Setenv("DB_PASSWORD", "password")
+		t.Setenv("JWT_SECRET", "your-super-secret-jwt-key-here")
+		t.Setenv("JWT_EXPIRY", "1x")
+		t.Setenv("DB_PORT", "abc")
+		t.Setenv("JWT_ALGORITHM", "none")
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+
+		var validationErr *config.ValidationError
+		require.True(t, errors.As(cfg.Validate(), &validationErr))
+
+		report := validationErr.Error()
+		assert.Len(t, validationErr.Problems, 5)
+		assert.Contains(t, report, `JWT_EXPIRY: "1x" is not a valid duration`)
+		assert.Contains(t, report, `DB_PORT: "abc" is not a valid integer`)
+		assert.Contains(t, report, "DB_PASSWORD")
+		assert.Contains(t, report, "JWT_SECRET is the example placeholder")
+		assert.Contains(t, report, "JWT_ALGORITHM")
+	})
+
+	t.Run("Short secret", func(t *testing.T) {
+		setValidConfigEnv(t)
+		t.Setenv("JWT_SECRET", "too-short")
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.ErrorContains(t, cfg.Validate(), "JWT_SECRET must be at least 32 characters")
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		setValidConfigEnv(t)
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector:4318")
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", "1.5")
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "OTEL_EXPORTER_OTLP_ENDPOINT")
+		assert.ErrorContains(t, err, "OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+	})
+
+	t.Run("Proxies and gRPC TLS", func(t *testing.T) {
+		setValidConfigEnv(t)
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 192.168.0.0/16, proxy.internal")
+		t.Setenv("GRPC_TLS_CERT_FILE", "/etc/tls/grpc.crt")
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1", "192.168.0.0/16", "proxy.internal"}, cfg.Server.TrustedProxies)
+
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, `TRUSTED_PROXIES: "proxy.internal" is not an IP address or CIDR range`)
+		assert.NotContains(t, err.Error(), "10.0.0.1")
+		assert.ErrorContains(t, err, "GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together")
+	})
+
+	t.Run("Metrics are off by default", func(t *testing.T) {
+		setValidConfigEnv(t)
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.False(t, cfg.Metrics.Enabled)
+	})
+
+	t.Run("Exports and click retention", func(t *testing.T) {
+		setValidConfigEnv(t)
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, 4, cfg.Links.ExportMaxConcurrent)
+		assert.Equal(t, 365*24*time.Hour, cfg.Clicks.Retention)
+		assert.Equal(t, time.Minute, cfg.Server.WriteTimeout)
+
+		t.Setenv("LINK_EXPORT_MAX_CONCURRENT", "-1")
+		t.Setenv("CLICK_RETENTION", "-1h")
+		t.Setenv("WRITE_TIMEOUT", "0s")
+		cfg, err = config.Load()
+		require.NoError(t, err)
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "LINK_EXPORT_MAX_CONCURRENT and LINK_EXPORT_TIMEOUT must not be negative")
+		assert.ErrorContains(t, err, "CLICK_RETENTION must not be negative")
+		assert.ErrorContains(t, err, "WRITE_TIMEOUT must be a positive duration")
+	})
+
+	t.Run("Idempotency lock outlasts requests", func(t *testing.T) {
+		setValidConfigEnv(t)
+		t.Setenv("IDEMPOTENCY_LOCK_TIMEOUT", "30s")
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.ErrorContains(t, cfg.Validate(), "IDEMPOTENCY_LOCK_TIMEOUT must exceed DB_QUERY_TIMEOUT, WRITE_TIMEOUT and SHUTDOWN_TIMEOUT")
+
+		t.Setenv("WRITE_TIMEOUT", "20s")
+		t.Setenv("SHUTDOWN_TIMEOUT", "20s")
+		cfg, err = config.Load()
+		require.NoError(t, err)
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("Legacy API dates", func(t *testing.T) {
+		setValidConfigEnv(t)
+		t.Setenv("API_LEGACY_SUNSET", "2027-04-01")
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		require.NoError(t, cfg.Validate())
+		assert.Equal(t, time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC), cfg.API.LegacySunset)
+
+		t.Setenv("API_LEGACY_DEPRECATION", "2027-05-01T00:00:00Z")
+		cfg, err = config.Load()
+		require.NoError(t, err)
+		assert.ErrorContains(t, cfg.Validate(), "API_LEGACY_SUNSET must be after API_LEGACY_DEPRECATION")
+
+		t.Setenv("API_LEGACY_SUNSET", "next spring")
+		cfg, err = config.Load()
+		require.NoError(t, err)
+		assert.ErrorContains(t, cfg.Validate(), `API_LEGACY_SUNSET: "next spring" is not a valid date`)
+	})
+}
+
+func TestConfigSettingsRedactSecrets(t *testing.T) {
+	setValidConfigEnv(t)
+	t.Setenv("SMTP_PASSWORD", "smtp-password-value")
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	cfg.WriteSettings(&out)
+
+	assert.Contains(t, out.String(), "database.password=[redacted] (env DB_PASSWORD)")
+	assert.Contains(t, out.String(), "mail.smtp_password=[redacted] (env SMTP_PASSWORD)")
+	assert.Contains(t, out.String(), "auth.password_reset_token_expiry=1h0m0s (default)")
+	assert.NotContains(t, out.String(), "a-strong-database-password")
+	assert.NotContains(t, out.String(), "0123456789abcdef")
+	assert.NotContains(t, out.String(), "smtp-password-value")
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfigLayers(t *testing.T) {
+	setValidConfigEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "9000"
+  shutdown_timeout: 10s
+database:
+  host: db.internal
+  max_open_conns: 50
+rate_limit:
+  requests: 20
+links:
+  short_code_length: 6
+oidc:
+  scopes: [openid, email]
+api:
+  legacy_sunset: 2027-04-01
+`)
+
+	// Environment beats the file, flags beat the environment
+	t.Setenv("DB_HOST", "db.env")
+	t.Setenv("RATE_LIMIT_REQUESTS", "30")
+
+	cfg, err := config.LoadWithOptions(config.Options{
+		File:      path,
+		Overrides: map[string]string{"rate_limit.requests": "40"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "9000", cfg.Server.Port)
+	assert.Equal(t, 10*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, "db.env", cfg.Database.Host)
+	assert.Equal(t, 50, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 5, cfg.Database.MaxIdleConns)
+	assert.Equal(t, 40, cfg.RateLimit.Requests)
+	assert.Equal(t, time.Minute, cfg.RateLimit.Window)
+	assert.Equal(t, 6, cfg.Links.ShortCodeLength)
+	assert.Equal(t, []string{"openid", "email"}, cfg.OIDC.Scopes)
+	assert.Equal(t, time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC), cfg.API.LegacySunset)
+	// Derived defaults follow the file
+	assert.Equal(t, "http://localhost:9000", cfg.Mail.AppURL)
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	setValidConfigEnv(t)
+
+	t.Run("TOML", func(t *testing.T) {
+		path := writeConfigFile(t, "config.toml", `
+[database]
+max_idle_conns = 2
+
+[links]
+short_code_length = 10
+`)
+		cfg, err := config.LoadWithOptions(config.Options{File: path})
+		require.NoError(t, err)
+		assert.Equal(t, 2, cfg.Database.MaxIdleConns)
+		assert.Equal(t, 10, cfg.Links.ShortCodeLength)
+	})
+
+	t.Run("Unknown and invalid keys", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", `
+database:
+  max_open_conn: 10
+rate_limit:
+  window: soon
+`)
+		cfg, err := config.LoadWithOptions(config.Options{File: path, Overrides: map[string]string{"links.length": "5"}})
+		require.NoError(t, err)
+
+		err = cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `rate_limit.window: "soon" is not a valid duration`)
+		assert.Contains(t, err.Error(), `unknown setting "database.max_open_conn"`)
+		assert.Contains(t, err.Error(), `-set: unknown setting "links.length"`)
+	})
+
+	t.Run("Unsupported or missing file", func(t *testing.T) {
+		_, err := config.LoadWithOptions(config.Options{File: writeConfigFile(t, "config.ini", "")})
+		assert.Error(t, err)
+
+		_, err = config.LoadWithOptions(config.Options{File: filepath.Join(t.TempDir(), "missing.yaml")})
+		assert.Error(t, err)
+	})
+}
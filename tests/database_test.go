@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+	"link-shortener/internal/database"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+// slowDriver answers "SELECT 1" immediately and blocks any query containing
+// "pg_sleep" until its context ends. It reports migrations 1 to
+// schemaVersion as applied, except skippedMigration.
+type slowDriver struct{}
+
+var schemaVersion, skippedMigration atomic.Int64
+
+func (slowDriver) Open(string) (driver.Conn, error) { return slowConn{}, nil }
+
+type slowConn struct{}
+
+func (slowConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (slowConn) Close() error                        { return nil }
+func (slowConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (slowConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "pg_sleep") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if strings.Contains(query, "schema_migrations") {
+		var versions []int64
+		for v := int64(1); v <= schemaVersion.Load(); v++ {
+			if v != skippedMigration.Load() {
+				versions = append(versions, v)
+			}
+		}
+		return &valueRows{values: versions}, nil
+	}
+	return &valueRows{values: []int64{1}}, nil
+}
+
+func (slowConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "pg_sleep") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// valueRows is a single column result
+type valueRows struct {
+	values []int64
+}
+
+func (*valueRows) Columns() []string { return []string{"value"} }
+func (*valueRows) Close() error      { return nil }
+
+func (r *valueRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("slow", slowDriver{})
+}
+
+func openSlowDatabase(t *testing.T, queryTimeout time.Duration) *database.Database {
+	db, err := sql.Open("slow", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return database.New(db, queryTimeout)
+}
+
+// openTestDatabase connects to the link_shortener_test database like
+// setupTestRouter and brings its schema up to date
+func openTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Database.Name = "link_shortener_test"
+
+	db, err := database.NewDatabase(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.InitTables())
+	return db
+}
+
+// createTestUser adds a user that is removed, with everything it owns, when
+// the test ends
+func createTestUser(t *testing.T, db *database.Database) *models.User {
+	t.Helper()
+	id := uuid.New()
+	user := &models.User{
+		ID:           id,
+		Username:     "user-" + id.String()[:8],
+		Email:        id.String() + "@example.com",
+		PasswordHash: "unused",
+		Role:         models.RoleUser,
+	}
+	require.NoError(t, repository.NewUserRepository(db).Create(context.Background(), user))
+	t.Cleanup(func() {
+		db.ExecContext(context.Background(), `DELETE FROM users WHERE id = $1`, id)
+	})
+	return user
+}
+
+// newTestLinkService is a link service on db that records clicks
+func newTestLinkService(t *testing.T, db *database.Database, cfg config.LinkConfig) *services.LinkService {
+	t.Helper()
+	if cfg.ShortCodeLength == 0 {
+		cfg.ShortCodeLength = 8
+	}
+	linkRepo := repository.NewLinkRepository(db)
+	clicks := services.NewClickQueue(linkRepo, 10, 1)
+	t.Cleanup(clicks.Close)
+	return services.NewLinkService(linkRepo, repository.NewWorkspaceRepository(db), "http://localhost:8080",
+		cfg, utils.NewBlocklist(nil), clicks)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db := openSlowDatabase(t, 50*time.Millisecond)
+
+	t.Run("Statements are bounded", func(t *testing.T) {
+		start := time.Now()
+		_, err := db.ExecContext(context.Background(), "SELECT pg_sleep(10)")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		var value int
+		err = db.QueryRowContext(context.Background(), "SELECT pg_sleep(10)").Scan(&value)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Row keeps its deadline until Scan", func(t *testing.T) {
+		row := db.QueryRowContext(context.Background(), "SELECT 1")
+		time.Sleep(10 * time.Millisecond)
+
+		var value int
+		require.NoError(t, row.Scan(&value))
+		assert.Equal(t, 1, value)
+	})
+
+	t.Run("Cancelling the caller stops the query", func(t *testing.T) {
+		unbounded := openSlowDatabase(t, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		rows, err := unbounded.QueryContext(ctx, "SELECT pg_sleep(10)")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, rows)
+	})
+
+	t.Run("Failures are logged with the request", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.New(&buf, logging.FormatJSON, slog.LevelDebug).With("request_id", "req-1")
+		ctx := logging.WithLogger(context.Background(), logger)
+
+		_, err := db.ExecContext(ctx, "UPDATE links SET clicks = pg_sleep(10)")
+		require.Error(t, err)
+
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "Query failed", record["msg"])
+		assert.Equal(t, "req-1", record["request_id"])
+		assert.Equal(t, "UPDATE", record["db.operation"])
+	})
+}
+
+// countLinks returns how many links userID has
+func countLinks(t *testing.T, db *database.Database, userID uuid.UUID) int {
+	t.Helper()
+	var count int
+	require.NoError(t, db.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM links WHERE user_id = $1`, userID).Scan(&count))
+	return count
+}
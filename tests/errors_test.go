@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+func renderError(t *testing.T, err error) (int, apperror.Response) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	apperror.Render(c, err)
+
+	var body apperror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+func TestErrorRendering(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"Validation", services.ErrSameEmail, http.StatusBadRequest, "same_email"},
+		{"Unauthorized", services.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+		{"Forbidden", services.ErrForbidden, http.StatusForbidden, "forbidden"},
+		{"Not found through a wrap", fmt.Errorf("failed to get link: %w", repository.ErrLinkNotFound), http.StatusNotFound, "link_not_found"},
+		{"Conflict", services.ErrAliasTaken, http.StatusConflict, "alias_taken"},
+		{"Expired", services.ErrLinkExpired, http.StatusGone, "link_expired"},
+		{"Rate limited", apperror.RateLimited("rate_limited", "Rate limit exceeded"), http.StatusTooManyRequests, "rate_limited"},
+		{"Unavailable", services.ErrProviderUnavailable.Wrap(errors.New("dial tcp: refused")), http.StatusServiceUnavailable, "provider_unavailable"},
+		{"Deadline", fmt.Errorf("failed to get user: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout"},
+		{"Internal", errors.New("pq: relation \"links\" does not exist"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := renderError(t, tt.err)
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Equal(t, tt.expectedCode, body.Code)
+			assert.NotEmpty(t, body.Error)
+		})
+	}
+
+	t.Run("Causes are not shown to clients", func(t *testing.T) {
+		_, body := renderError(t, services.ErrProviderUnavailable.Wrap(errors.New("dial tcp 10.0.0.1:443")))
+		assert.Equal(t, "identity provider unavailable", body.Error)
+
+		_, body = renderError(t, errors.New("pq: password authentication failed"))
+		assert.Equal(t, "Internal server error", body.Error)
+	})
+
+	t.Run("Field details", func(t *testing.T) {
+		_, body := renderError(t, services.ErrInvalidURL.WithField("original_url", "URL must use http or https"))
+		require.Len(t, body.Details, 1)
+		assert.Equal(t, apperror.FieldError{Field: "original_url", Message: "URL must use http or https"}, body.Details[0])
+	})
+}
+
+func TestErrorMatching(t *testing.T) {
+	wrapped := fmt.Errorf("failed to get link: %w", repository.ErrLinkNotFound)
+	assert.ErrorIs(t, wrapped, repository.ErrLinkNotFound)
+	assert.ErrorIs(t, wrapped, apperror.ErrNotFound)
+	assert.NotErrorIs(t, wrapped, repository.ErrUserNotFound)
+
+	cause := errors.New("boom")
+	withCause := services.ErrInvalidURL.WithField("original_url", "bad").Wrap(cause)
+	assert.ErrorIs(t, withCause, services.ErrInvalidURL)
+	assert.ErrorIs(t, withCause, apperror.ErrValidation)
+	assert.ErrorIs(t, withCause, cause)
+	assert.Empty(t, services.ErrInvalidURL.Fields, "WithField must not change the shared error")
+}
+
+func TestBindingErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/register", func(c *gin.Context) {
+		var req models.RegisterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apperror.Render(c, apperror.FromBinding(err))
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	post := func(body string) (int, apperror.Response) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/register", bytes.NewBufferString(body)))
+		var response apperror.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Run("Invalid fields are named after their JSON keys", func(t *testing.T) {
+		status, body := post(`{"username":"testuser","email":"invalid-email","password":"123"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_request", body.Code)
+		assert.ElementsMatch(t, []apperror.FieldError{
+			{Field: "email", Message: "must be a valid email address"},
+			{Field: "password", Message: "must be at least 6 characters"},
+		}, body.Details)
+	})
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		status, body := post(`{"username":`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_request", body.Code)
+		assert.Empty(t, body.Details)
+	})
+
+	t.Run("Wrong type", func(t *testing.T) {
+		_, body := post(`{"username":42,"email":"a@example.com","password":"password123"}`)
+		require.Len(t, body.Details, 1)
+		assert.Equal(t, "username", body.Details[0].Field)
+	})
+}
+
+func TestMiddlewareErrorEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	authMiddleware := middleware.NewAuthMiddleware(jwtMgr, allowSessions{})
+
+	router := gin.New()
+	router.GET("/private", authMiddleware.AuthRequired(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/private", nil))
+
+	var body apperror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "authorization_required", body.Code)
+	assert.Equal(t, "Authorization header required", body.Error)
+}
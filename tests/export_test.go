@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/config"
+	"link-shortener/internal/export"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+func TestExportWriter(t *testing.T) {
+	type row struct {
+		Code  string `json:"code"`
+		Title string `json:"title"`
+	}
+	rows := []row{{"abc", "Docs, v2"}, {"xyz", "<Blog>"}}
+	write := func(t *testing.T, format string, rows []row) string {
+		var buf bytes.Buffer
+		w, err := export.NewWriter(&buf, format, []string{"code", "title"})
+		require.NoError(t, err)
+		for _, r := range rows {
+			require.NoError(t, w.Write(r, []string{r.Code, r.Title}))
+		}
+		require.NoError(t, w.Close())
+		assert.Equal(t, len(rows), w.Rows())
+		return buf.String()
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		assert.Equal(t, "code,title\nabc,\"Docs, v2\"\nxyz,<Blog>\n", write(t, export.FormatCSV, rows))
+		assert.Equal(t, "code,title\n", write(t, export.FormatCSV, nil))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		out := write(t, export.FormatJSON, rows)
+		var decoded []row
+		require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+		assert.Equal(t, rows, decoded)
+		assert.Contains(t, out, "<Blog>")
+		assert.JSONEq(t, "[]", write(t, export.FormatJSON, nil))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		assert.Equal(t, "{\"code\":\"abc\",\"title\":\"Docs, v2\"}\n{\"code\":\"xyz\",\"title\":\"<Blog>\"}\n", write(t, export.FormatNDJSON, rows))
+		assert.Empty(t, write(t, export.FormatNDJSON, nil))
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := export.NewWriter(&bytes.Buffer{}, "xml", nil)
+		assert.ErrorIs(t, err, export.ErrUnknownFormat)
+	})
+}
+
+// The requests below are rejected before the export query runs
+func TestExportRequests(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	linkService := services.NewLinkService(nil, nil, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), nil)
+	router, doc := setupAPITestRouter(t, jwtMgr, linkService)
+
+	token, err := jwtMgr.GenerateToken(&models.User{ID: uuid.New(), Role: models.RoleUser})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name, path, query, code string
+	}{
+		{"Unknown format", "/export", "?format=xlsx", "unknown_export_format"},
+		{"Bad date", "/export", "?from=yesterday", "invalid_query"},
+		{"Empty range", "/export", "?from=2024-02-01&to=2024-01-01", "invalid_export_range"},
+		{"Bad link ID", "/export/clicks", "?link_id=abc", "invalid_query"},
+		{"Empty click range", "/export/clicks", "?format=ndjson&from=2024-01-01T00:00:00Z&to=2024-01-01", "invalid_export_range"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/links"+tc.path+tc.query, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			op := doc.Operation(http.MethodGet, "/api/v1/links"+tc.path)
+			require.NotNil(t, op)
+			assert.NoError(t, doc.Validate(doc.ResponseSchema(op, w.Code), w.Body.Bytes()))
+
+			var body apperror.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tc.code, body.Code)
+		})
+	}
+}
+
+func TestExportDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+	linkService := newTestLinkService(t, db, config.LinkConfig{BulkMaxItems: 1100})
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	router, _ := setupAPITestRouter(t, jwtMgr, linkService)
+	linkRepo := repository.NewLinkRepository(db)
+	ctx := context.Background()
+
+	user := createTestUser(t, db)
+	token, err := jwtMgr.GenerateToken(user)
+	require.NoError(t, err)
+	var codes []string
+	var links []*models.LinkResponse
+	for _, title := range []string{"Docs, v2", "Blog", "Shop"} {
+		link, err := linkService.CreateLink(ctx, user.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com/" + title, Title: title})
+		require.NoError(t, err)
+		codes = append(codes, link.ShortCode)
+		links = append(links, link)
+	}
+	// Someone else's link stays out of the export
+	_, err = linkService.CreateLink(ctx, createTestUser(t, db).ID, &models.CreateLinkRequest{OriginalURL: "https://example.org"})
+	require.NoError(t, err)
+
+	for _, link := range []*models.LinkResponse{links[0], links[0], links[2]} {
+		click := &models.ClickEvent{LinkID: link.ID, ClickedAt: time.Now().UTC(), Referrer: "https://news.example", UserAgent: "test"}
+		require.NoError(t, linkRepo.RecordClick(ctx, click))
+	}
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/links"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		w := get(t, "/export")
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="links-`)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		assert.Equal(t, "short_code", records[0][1])
+		assert.Equal(t, []string{codes[0], codes[1], codes[2]}, []string{records[1][1], records[2][1], records[3][1]})
+		assert.Equal(t, "Docs, v2", records[1][4])
+		assert.Equal(t, "2", records[1][6])
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w := get(t, "/export?format=json")
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var exported []models.LinkResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+		require.Len(t, exported, 3)
+		for i, link := range exported {
+			assert.Equal(t, codes[i], link.ShortCode)
+		}
+	})
+
+	t.Run("NDJSON clicks", func(t *testing.T) {
+		w := get(t, "/export/clicks?format=ndjson&link_id="+links[0].ID.String())
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			var click models.ClickEvent
+			require.NoError(t, json.Unmarshal([]byte(line), &click))
+			assert.Equal(t, links[0].ID, click.LinkID)
+			assert.Equal(t, codes[0], click.ShortCode)
+			assert.Equal(t, "https://news.example", click.Referrer)
+		}
+
+		all := get(t, "/export/clicks?format=ndjson")
+		assert.Equal(t, 3, strings.Count(all.Body.String(), "\n"))
+		future := get(t, "/export/clicks?format=ndjson&from="+time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+		assert.Empty(t, future.Body.String())
+	})
+
+	// More rows than are sent at a time still arrive whole
+	t.Run("Large exports are flushed as they go", func(t *testing.T) {
+		req := &models.BulkCreateLinksRequest{Links: make([]models.CreateLinkRequest, 1050)}
+		for i := range req.Links {
+			req.Links[i].OriginalURL = fmt.Sprintf("https://example.com/%d", i)
+		}
+		_, err := linkService.CreateLinks(ctx, user.ID, req)
+		require.NoError(t, err)
+
+		w := get(t, "/export?format=ndjson")
+		assert.Equal(t, 1053, strings.Count(w.Body.String(), "\n"))
+		records, err := csv.NewReader(get(t, "/export").Body).ReadAll()
+		require.NoError(t, err)
+		assert.Len(t, records, 1054)
+	})
+}
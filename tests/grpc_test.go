@@ -0,0 +1,338 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"link-shortener/internal/config"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/rpc"
+	pb "link-shortener/internal/rpc/shortlinkv1"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+// setupGRPCTestClient serves api over an in-memory listener
+func setupGRPCTestClient(t *testing.T, api *rpc.API) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	srv := rpc.NewServer(api)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// grpcError returns the status code, the REST error code and the invalid
+// fields of err
+func grpcError(t *testing.T, err error) (codes.Code, string, []string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status: %v", err)
+
+	var reason string
+	var fields []string
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason = detail.Reason
+		case *errdetails.BadRequest:
+			for _, violation := range detail.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	return st.Code(), reason, fields
+}
+
+// The calls below fail before the database is queried
+func TestGRPCAPI(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	linkService := services.NewLinkService(nil, nil, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), nil)
+	conn := setupGRPCTestClient(t, &rpc.API{
+		Links:         linkService,
+		Auth:          services.NewAuthService(nil, nil, nil, jwtMgr, nil, config.AuthConfig{}, ""),
+		Authenticator: middleware.NewAuthMiddleware(jwtMgr, allowSessions{}),
+	})
+	links, auth := pb.NewLinkServiceClient(conn), pb.NewAuthServiceClient(conn)
+
+	token, err := jwtMgr.GenerateToken(&models.User{ID: uuid.New(), Role: models.RoleUser})
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+
+	t.Run("Authentication", func(t *testing.T) {
+		_, err := auth.GetProfile(context.Background(), &pb.GetProfileRequest{})
+		code, reason, _ := grpcError(t, err)
+		assert.Equal(t, codes.Unauthenticated, code)
+		assert.Equal(t, "authorization_required", reason)
+
+		bad := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nope")
+		_, err = links.ListLinks(bad, &pb.ListLinksRequest{})
+		code, reason, _ = grpcError(t, err)
+		assert.Equal(t, codes.Unauthenticated, code)
+		assert.Equal(t, "invalid_access_token", reason)
+	})
+
+	t.Run("Public methods", func(t *testing.T) {
+		_, err := links.ResolveLink(context.Background(), &pb.ResolveLinkRequest{})
+		code, reason, _ := grpcError(t, err)
+		assert.Equal(t, codes.InvalidArgument, code)
+		assert.Equal(t, "short_code_required", reason)
+
+		_, err = auth.Register(context.Background(), &pb.RegisterRequest{Username: "grpc", Email: "not-an-email", Password: "123"})
+		code, reason, fields := grpcError(t, err)
+		assert.Equal(t, codes.InvalidArgument, code)
+		assert.Equal(t, "invalid_request", reason)
+		assert.ElementsMatch(t, []string{"email", "password"}, fields)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			call   func() error
+			reason string
+			field  string
+		}{
+			{"Bad link ID", func() error {
+				_, err := links.GetLink(ctx, &pb.GetLinkRequest{Id: "abc"})
+				return err
+			}, "invalid_id", "id"},
+			{"Bad workspace ID", func() error {
+				_, err := links.GetStats(ctx, &pb.GetStatsRequest{WorkspaceId: "abc"})
+				return err
+			}, "invalid_id", "workspace_id"},
+			{"Bad URL", func() error {
+				_, err := links.CreateLink(ctx, &pb.CreateLinkRequest{OriginalUrl: "not a url"})
+				return err
+			}, "invalid_request", "original_url"},
+			{"Bad expiry", func() error {
+				_, err := links.UpdateLink(ctx, &pb.UpdateLinkRequest{Id: uuid.NewString(), ExpiresAt: &timestamppb.Timestamp{Seconds: 1 << 62}})
+				return err
+			}, "invalid_request", "expires_at"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				code, reason, fields := grpcError(t, tc.call())
+				assert.Equal(t, codes.InvalidArgument, code)
+				assert.Equal(t, tc.reason, reason)
+				assert.Equal(t, []string{tc.field}, fields)
+			})
+		}
+	})
+
+	t.Run("Internal errors", func(t *testing.T) {
+		// Without a database the lookup panics; the panic is recovered and
+		// its cause kept out of the status
+		var header metadata.MD
+		requestCtx := metadata.AppendToOutgoingContext(ctx, "x-request-id", "grpc-test-1")
+		_, err := links.GetLink(requestCtx, &pb.GetLinkRequest{Id: uuid.NewString()}, grpc.Header(&header))
+		code, reason, _ := grpcError(t, err)
+		assert.Equal(t, codes.Internal, code)
+		assert.Equal(t, "internal_error", reason)
+		assert.Equal(t, "Internal server error", status.Convert(err).Message())
+		assert.Equal(t, []string{"grpc-test-1"}, header.Get("x-request-id"))
+	})
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	conn := setupGRPCTestClient(t, &rpc.API{
+		Authenticator: middleware.NewAuthMiddleware(jwtMgr, allowSessions{}),
+		RateLimiter:   middleware.NewRateLimiter(1, time.Minute),
+	})
+	links := pb.NewLinkServiceClient(conn)
+
+	_, err := links.ResolveLink(context.Background(), &pb.ResolveLinkRequest{})
+	code, _, _ := grpcError(t, err)
+	assert.Equal(t, codes.InvalidArgument, code)
+
+	_, err = links.ResolveLink(context.Background(), &pb.ResolveLinkRequest{})
+	code, reason, _ := grpcError(t, err)
+	assert.Equal(t, codes.ResourceExhausted, code)
+	assert.Equal(t, "rate_limited", reason)
+}
+
+// serveGRPC serves api on a loopback TCP port and dials it with creds
+func serveGRPC(t *testing.T, api *rpc.API, creds credentials.TransportCredentials) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := rpc.NewServer(api)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(creds))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCRateLimitForwarded(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	resolve := func(links pb.LinkServiceClient, forwardedFor string) codes.Code {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-forwarded-for", forwardedFor)
+		_, err := links.ResolveLink(ctx, &pb.ResolveLinkRequest{})
+		code, _, _ := grpcError(t, err)
+		return code
+	}
+
+	t.Run("Trusted proxies name the client", func(t *testing.T) {
+		loopback, err := middleware.ParseTrustedProxies([]string{"127.0.0.1"})
+		require.NoError(t, err)
+		links := pb.NewLinkServiceClient(serveGRPC(t, &rpc.API{
+			Authenticator:  middleware.NewAuthMiddleware(jwtMgr, allowSessions{}),
+			RateLimiter:    middleware.NewRateLimiter(1, time.Minute),
+			TrustedProxies: loopback,
+		}, insecure.NewCredentials()))
+
+		assert.Equal(t, codes.InvalidArgument, resolve(links, "203.0.113.1"))
+		assert.Equal(t, codes.InvalidArgument, resolve(links, "203.0.113.2"))
+		assert.Equal(t, codes.ResourceExhausted, resolve(links, "203.0.113.1"))
+		// Spoofed entries left of the proxy's are ignored
+		assert.Equal(t, codes.ResourceExhausted, resolve(links, "198.51.100.7, 203.0.113.2"))
+	})
+
+	t.Run("Other peers are keyed on their address", func(t *testing.T) {
+		links := pb.NewLinkServiceClient(serveGRPC(t, &rpc.API{
+			Authenticator: middleware.NewAuthMiddleware(jwtMgr, allowSessions{}),
+			RateLimiter:   middleware.NewRateLimiter(1, time.Minute),
+		}, insecure.NewCredentials()))
+
+		assert.Equal(t, codes.InvalidArgument, resolve(links, "203.0.113.1"))
+		assert.Equal(t, codes.ResourceExhausted, resolve(links, "203.0.113.2"))
+	})
+}
+
+func TestGRPCTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	server := credentials.NewServerTLSFromCert(&tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key})
+	links := pb.NewLinkServiceClient(serveGRPC(t, &rpc.API{
+		Authenticator: middleware.NewAuthMiddleware(utils.NewJWTManager("secret", time.Hour), allowSessions{}),
+		Credentials:   server,
+	}, credentials.NewClientTLSFromCert(roots, "")))
+
+	_, err = links.ResolveLink(context.Background(), &pb.ResolveLinkRequest{})
+	code, reason, _ := grpcError(t, err)
+	assert.Equal(t, codes.InvalidArgument, code)
+	assert.Equal(t, "short_code_required", reason)
+}
+
+func TestGRPCDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	authService := services.NewAuthService(repository.NewUserRepository(db), repository.NewTokenRepository(db), repository.NewRecoveryCodeRepository(db),
+		jwtMgr, mailer.NewLogMailer(cfg.Mail.From), cfg.Auth, cfg.Mail.AppURL)
+	conn := setupGRPCTestClient(t, &rpc.API{
+		Links:         newTestLinkService(t, db, config.LinkConfig{}),
+		Auth:          authService,
+		Authenticator: middleware.NewAuthMiddleware(jwtMgr, authService),
+	})
+	links, auth := pb.NewLinkServiceClient(conn), pb.NewAuthServiceClient(conn)
+
+	suffix := uuid.NewString()[:8]
+	email := "grpc-" + suffix + "@example.com"
+	registered, err := auth.Register(context.Background(), &pb.RegisterRequest{Username: "grpc-" + suffix, Email: email, Password: "password123"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.ExecContext(context.Background(), `DELETE FROM users WHERE id = $1`, registered.User.Id)
+	})
+
+	var ctx context.Context
+	t.Run("Auth", func(t *testing.T) {
+		assert.NotEmpty(t, registered.Token)
+		loggedIn, err := auth.Login(context.Background(), &pb.LoginRequest{Email: email, Password: "password123"})
+		require.NoError(t, err)
+		require.NotEmpty(t, loggedIn.Token)
+		assert.False(t, loggedIn.TwoFactorRequired)
+		ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+loggedIn.Token)
+
+		profile, err := auth.GetProfile(ctx, &pb.GetProfileRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, registered.User.Id, profile.Id)
+		assert.Equal(t, email, profile.Email)
+		assert.Equal(t, models.RoleUser, profile.Role)
+	})
+
+	t.Run("Links", func(t *testing.T) {
+		require.NotNil(t, ctx)
+		created, err := links.CreateLink(ctx, &pb.CreateLinkRequest{OriginalUrl: "https://example.com/grpc", Title: "gRPC", Tags: []string{"api"}})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/grpc", created.OriginalUrl)
+		assert.Equal(t, "http://localhost:8080/r/"+created.ShortCode, created.ShortUrl)
+		assert.True(t, created.IsActive)
+
+		got, err := links.GetLink(ctx, &pb.GetLinkRequest{Id: created.Id})
+		require.NoError(t, err)
+		assert.Equal(t, created.ShortCode, got.ShortCode)
+		assert.Equal(t, []string{"api"}, got.Tags)
+
+		list, err := links.ListLinks(ctx, &pb.ListLinksRequest{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, list.Links, 1)
+		assert.Equal(t, created.Id, list.Links[0].Id)
+
+		resolved, err := links.ResolveLink(context.Background(), &pb.ResolveLinkRequest{ShortCode: created.ShortCode, UserAgent: "grpc-test"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/grpc", resolved.OriginalUrl)
+
+		inactive := false
+		updated, err := links.UpdateLink(ctx, &pb.UpdateLinkRequest{Id: created.Id, Title: "gRPC API", IsActive: &inactive, Tags: &pb.Tags{}})
+		require.NoError(t, err)
+		assert.Equal(t, "gRPC API", updated.Title)
+		assert.False(t, updated.IsActive)
+		assert.Empty(t, updated.Tags)
+
+		stats, err := links.GetStats(ctx, &pb.GetStatsRequest{})
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, stats.TotalLinks)
+		assert.Zero(t, stats.ActiveLinks)
+
+		_, err = links.DeleteLink(ctx, &pb.DeleteLinkRequest{Id: created.Id})
+		require.NoError(t, err)
+		_, err = links.GetLink(ctx, &pb.GetLinkRequest{Id: created.Id})
+		code, _, _ := grpcError(t, err)
+		assert.Equal(t, codes.NotFound, code)
+	})
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+	"link-shortener/internal/handlers"
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+	"link-shortener/migrations"
+)
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openSlowDatabase(t, time.Second)
+	clicks := services.NewClickQueue(nil, 10, 1)
+	t.Cleanup(clicks.Close)
+
+	healthService := services.NewHealthService(db, clicks, config.HealthConfig{CheckTimeout: time.Second, ClickBacklogRatio: 0.9})
+	healthHandler := handlers.NewHealthHandler(healthService)
+	router := gin.New()
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+
+	probe := func(path string) (int, models.ReadinessResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body models.ReadinessResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("Ready when the schema is current", func(t *testing.T) {
+		schemaVersion.Store(int64(migrations.Latest()))
+
+		code, body := probe("/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, models.ReadinessReady, body.Status)
+		for _, name := range []string{"database", "migrations", "clicks"} {
+			require.Contains(t, body.Components, name)
+			assert.Equal(t, models.HealthStatusUp, body.Components[name].Status, name)
+		}
+		assert.Equal(t, float64(10), body.Components["clicks"].Details["capacity"])
+	})
+
+	t.Run("Not ready with pending migrations", func(t *testing.T) {
+		schemaVersion.Store(1)
+
+		code, body := probe("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, models.ReadinessNotReady, body.Status)
+		assert.Equal(t, models.HealthStatusDown, body.Components["migrations"].Status)
+		assert.Contains(t, body.Components["migrations"].Error, "migrations not applied: [2 3")
+		assert.Equal(t, models.HealthStatusUp, body.Components["database"].Status)
+	})
+
+	t.Run("Not ready when a migration was skipped", func(t *testing.T) {
+		schemaVersion.Store(int64(migrations.Latest()))
+		skippedMigration.Store(5)
+		defer skippedMigration.Store(0)
+
+		code, body := probe("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, models.HealthStatusDown, body.Components["migrations"].Status)
+		assert.Equal(t, "migrations not applied: [5]", body.Components["migrations"].Error)
+		assert.Equal(t, float64(migrations.Latest()), body.Components["migrations"].Details["version"])
+	})
+
+	t.Run("Not ready while shutting down", func(t *testing.T) {
+		schemaVersion.Store(int64(migrations.Latest()))
+		healthService.SetShuttingDown()
+
+		code, body := probe("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, models.ReadinessShuttingDown, body.Status)
+		assert.Empty(t, body.Components)
+
+		code, _ = probe("/livez")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestMigrationFiles(t *testing.T) {
+	all, err := migrations.All()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	// Versions run without gaps so that readiness can list what is missing
+	var versions []int
+	for i, migration := range all {
+		assert.Equal(t, i+1, migration.Version, migration.Name)
+		assert.NotEmpty(t, migration.SQL, migration.Name)
+		versions = append(versions, migration.Version)
+	}
+	assert.Equal(t, migrations.Versions(), versions)
+	assert.Equal(t, len(all), migrations.Latest())
+}
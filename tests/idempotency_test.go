@@ -0,0 +1,372 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+var idempotencyConfig = config.IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Hour}
+
+func TestIdempotencyRepository(t *testing.T) {
+	db := openTestDatabase(t)
+	repo := repository.NewIdempotencyRepository(db)
+	user := createTestUser(t, db).ID
+	ctx := context.Background()
+	created := &models.StoredResponse{StatusCode: http.StatusCreated, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+
+	t.Run("Claim inserts once", func(t *testing.T) {
+		lockID, err := repo.Claim(ctx, user, "once", "a", time.Hour, time.Hour)
+		require.NoError(t, err)
+		require.NotEqual(t, uuid.Nil, lockID)
+
+		again, err := repo.Claim(ctx, user, "once", "b", time.Hour, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, again)
+
+		record, err := repo.Get(ctx, user, "once")
+		require.NoError(t, err)
+		assert.Equal(t, "a", record.Fingerprint)
+		assert.Nil(t, record.StatusCode)
+
+		completed, err := repo.Complete(ctx, user, "once", lockID, created)
+		require.NoError(t, err)
+		assert.True(t, completed)
+		completed, err = repo.Complete(ctx, user, "once", lockID, created)
+		require.NoError(t, err)
+		assert.False(t, completed, "a stored response is final")
+
+		record, err = repo.Get(ctx, user, "once")
+		require.NoError(t, err)
+		require.NotNil(t, record.StatusCode)
+		assert.Equal(t, http.StatusCreated, *record.StatusCode)
+		assert.JSONEq(t, `{"id":1}`, string(record.Body))
+	})
+
+	t.Run("Stale claims are taken over", func(t *testing.T) {
+		first, err := repo.Claim(ctx, user, "stale", "a", time.Hour, time.Hour)
+		require.NoError(t, err)
+		second, err := repo.Claim(ctx, user, "stale", "a", time.Hour, 0)
+		require.NoError(t, err)
+		require.NotEqual(t, uuid.Nil, second)
+		assert.NotEqual(t, first, second)
+
+		completed, err := repo.Complete(ctx, user, "stale", first, created)
+		require.NoError(t, err)
+		assert.False(t, completed, "the first claim was lost")
+		require.NoError(t, repo.Release(ctx, user, "stale", first))
+		_, err = repo.Get(ctx, user, "stale")
+		require.NoError(t, err, "releasing a lost claim keeps the new one")
+
+		completed, err = repo.Complete(ctx, user, "stale", second, created)
+		require.NoError(t, err)
+		assert.True(t, completed)
+		third, err := repo.Claim(ctx, user, "stale", "a", time.Hour, 0)
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, third, "finished requests are not stale")
+	})
+
+	t.Run("Expired keys are taken over", func(t *testing.T) {
+		lockID, err := repo.Claim(ctx, user, "expired", "a", time.Hour, time.Hour)
+		require.NoError(t, err)
+		_, err = repo.Complete(ctx, user, "expired", lockID, created)
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, `UPDATE idempotency_keys SET expires_at = NOW() - INTERVAL '1 second' WHERE user_id = $1 AND key = $2`, user, "expired")
+		require.NoError(t, err)
+
+		_, err = repo.Get(ctx, user, "expired")
+		assert.ErrorIs(t, err, repository.ErrIdempotencyKeyNotFound)
+		renewed, err := repo.Claim(ctx, user, "expired", "b", time.Hour, time.Hour)
+		require.NoError(t, err)
+		require.NotEqual(t, uuid.Nil, renewed)
+
+		record, err := repo.Get(ctx, user, "expired")
+		require.NoError(t, err)
+		assert.Equal(t, "b", record.Fingerprint)
+		assert.Nil(t, record.StatusCode, "the old response is gone")
+	})
+
+	t.Run("Release and purge", func(t *testing.T) {
+		lockID, err := repo.Claim(ctx, user, "released", "a", time.Hour, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, repo.Release(ctx, user, "released", lockID))
+		_, err = repo.Get(ctx, user, "released")
+		assert.ErrorIs(t, err, repository.ErrIdempotencyKeyNotFound)
+
+		_, err = repo.Claim(ctx, user, "purged", "a", 0, time.Hour)
+		require.NoError(t, err)
+		purged, err := repo.DeleteExpired(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, purged, int64(1))
+		var remaining int
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM idempotency_keys WHERE user_id = $1 AND key = 'purged'`, user).Scan(&remaining))
+		assert.Zero(t, remaining)
+	})
+}
+
+func TestIdempotencyService(t *testing.T) {
+	db := openTestDatabase(t)
+	service := services.NewIdempotencyService(repository.NewIdempotencyRepository(db), idempotencyConfig)
+	user := createTestUser(t, db).ID
+	ctx := context.Background()
+
+	t.Run("Begin", func(t *testing.T) {
+		stored, lockID, err := service.Begin(ctx, user, "begin", "a")
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+		require.NotEqual(t, uuid.Nil, lockID)
+
+		_, _, err = service.Begin(ctx, user, "begin", "a")
+		assert.ErrorIs(t, err, services.ErrIdempotencyInProgress)
+		_, _, err = service.Begin(ctx, user, "begin", "b")
+		assert.ErrorIs(t, err, services.ErrIdempotencyKeyReused)
+
+		resp := &models.StoredResponse{StatusCode: http.StatusCreated, ContentType: "application/json", Body: []byte(`{}`)}
+		require.NoError(t, service.Complete(ctx, user, "begin", lockID, resp))
+		stored, lockID, err = service.Begin(ctx, user, "begin", "a")
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, lockID)
+		assert.Equal(t, resp, stored)
+	})
+
+	t.Run("Released keys are claimed again", func(t *testing.T) {
+		_, lockID, err := service.Begin(ctx, user, "retry", "a")
+		require.NoError(t, err)
+		require.NoError(t, service.Release(ctx, user, "retry", lockID))
+
+		_, again, err := service.Begin(ctx, user, "retry", "a")
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, again)
+	})
+
+	// Releases racing the claims make some Begin calls find the key held when
+	// claiming and gone when reading it; they claim again instead of failing
+	t.Run("Concurrent claims", func(t *testing.T) {
+		var holders, claims atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					_, lockID, err := service.Begin(ctx, user, "contended", "a")
+					if err != nil {
+						assert.ErrorIs(t, err, services.ErrIdempotencyInProgress)
+						continue
+					}
+					assert.EqualValues(t, 1, holders.Add(1), "two requests hold the key")
+					claims.Add(1)
+					holders.Add(-1)
+					assert.NoError(t, service.Release(ctx, user, "contended", lockID))
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Positive(t, claims.Load())
+	})
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openTestDatabase(t)
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	authMiddleware := middleware.NewAuthMiddleware(jwtMgr, allowSessions{})
+	store := services.NewIdempotencyService(repository.NewIdempotencyRepository(db), idempotencyConfig)
+
+	// The handler answers with a new ID each time it runs; a failing original
+	// URL makes it fail and a blocking one waits for release
+	var mu sync.Mutex
+	calls := 0
+	started, release := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.POST("/links", authMiddleware.AuthRequired(), middleware.Idempotent(store, "links.create"),
+		func(c *gin.Context) {
+			var req models.CreateLinkRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			switch req.OriginalURL {
+			case "https://fail.example.com":
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			case "https://slow.example.com":
+				close(started)
+				<-release
+			}
+			c.JSON(http.StatusCreated, gin.H{"id": uuid.NewString()})
+		})
+
+	token, err := jwtMgr.GenerateToken(createTestUser(t, db))
+	require.NoError(t, err)
+	other, err := jwtMgr.GenerateToken(createTestUser(t, db))
+	require.NoError(t, err)
+
+	// noKey sends a request without the header
+	const noKey = "<none>"
+	send := func(token, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/links", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		if key != noKey {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var body struct {
+			Code string `json:"code"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Code
+	}
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		first := send(token, "replay", `{"original_url": "https://example.com", "title": "Example"}`)
+		require.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+		// Same object, other key order and spacing
+		retry := send(token, "replay", `{"title":"Example","original_url":"https://example.com"}`)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+		assert.Equal(t, 1, callCount())
+	})
+
+	t.Run("Keys belong to the user", func(t *testing.T) {
+		before := callCount()
+		w := send(other, "replay", `{"original_url": "https://example.com", "title": "Example"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, before+1, callCount())
+	})
+
+	t.Run("Reused with another body", func(t *testing.T) {
+		w := send(token, "replay", `{"original_url": "https://example.org"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "idempotency_key_reused", errorCode(w))
+	})
+
+	t.Run("Client errors are replayed", func(t *testing.T) {
+		before := callCount()
+		first := send(token, "invalid", `{}`)
+		assert.Equal(t, http.StatusBadRequest, first.Code)
+		retry := send(token, "invalid", `{}`)
+		assert.Equal(t, http.StatusBadRequest, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, before, callCount())
+	})
+
+	t.Run("Server errors release the key", func(t *testing.T) {
+		before := callCount()
+		assert.Equal(t, http.StatusInternalServerError, send(token, "fail", `{"original_url": "https://fail.example.com"}`).Code)
+		retry := send(token, "fail", `{"original_url": "https://fail.example.com"}`)
+		assert.Equal(t, http.StatusInternalServerError, retry.Code)
+		assert.Empty(t, retry.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, before+2, callCount())
+	})
+
+	t.Run("Concurrent duplicate", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- send(token, "slow", `{"original_url": "https://slow.example.com"}`) }()
+		<-started
+
+		w := send(token, "slow", `{"original_url": "https://slow.example.com"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "idempotency_request_in_progress", errorCode(w))
+
+		close(release)
+		first := <-done
+		assert.Equal(t, http.StatusCreated, first.Code)
+		retry := send(token, "slow", `{"original_url": "https://slow.example.com"}`)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		for _, key := range []string{strings.Repeat("k", 256), "tab\tkey", "ключ", "", "   "} {
+			w := send(token, key, `{"original_url": "https://example.com"}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "invalid_idempotency_key", errorCode(w))
+		}
+	})
+
+	t.Run("Without a key", func(t *testing.T) {
+		before := callCount()
+		send(token, noKey, `{"original_url": "https://example.com"}`)
+		send(token, noKey, `{"original_url": "https://example.com"}`)
+		assert.Equal(t, before+2, callCount())
+	})
+}
+
+// TestIdempotentLinkCreation retries link creation through the mounted API
+func TestIdempotentLinkCreation(t *testing.T) {
+	db := openTestDatabase(t)
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	router, _ := setupServicesTestRouter(t, jwtMgr, apiTestServices{
+		links:       newTestLinkService(t, db, config.LinkConfig{}),
+		idempotency: services.NewIdempotencyService(repository.NewIdempotencyRepository(db), idempotencyConfig),
+	})
+	user := createTestUser(t, db)
+	token, err := jwtMgr.GenerateToken(user)
+	require.NoError(t, err)
+
+	create := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/links/", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	count := func() int {
+		var n int
+		require.NoError(t, db.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM links WHERE user_id = $1`, user.ID).Scan(&n))
+		return n
+	}
+
+	body := `{"original_url": "https://example.com/launch", "title": "Launch"}`
+	first := create("launch-1", body)
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	retry := create("launch-1", body)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, count())
+
+	reused := create("launch-1", `{"original_url": "https://example.com/other"}`)
+	assert.Equal(t, http.StatusConflict, reused.Code)
+	assert.Equal(t, 1, count())
+
+	assert.Equal(t, http.StatusCreated, create("launch-2", body).Code)
+	assert.Equal(t, 2, count())
+}
@@ -0,0 +1,324 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/config"
+	"link-shortener/internal/importer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+func TestImportParse(t *testing.T) {
+	t.Run("CSV columns are matched loosely", func(t *testing.T) {
+		file := "\ufeffLong URL,Keyword,Title,Expiry,Labels\n" +
+			"https://example.com/a,https://sho.rt/spring,Spring sale,2026-12-31,\"promo, q4\"\n" +
+			"https://example.com/b,,,,\n"
+		records, err := importer.Parse(strings.NewReader(file), importer.FormatCSV, 10)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+
+		assert.Equal(t, 2, records[0].Line)
+		assert.Equal(t, "https://example.com/a", records[0].OriginalURL)
+		assert.Equal(t, "spring", records[0].ShortCode)
+		assert.Equal(t, "Spring sale", records[0].Title)
+		assert.Equal(t, time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), *records[0].ExpiresAt)
+		assert.Equal(t, []string{"promo", "q4"}, records[0].Tags)
+		assert.Empty(t, records[1].ShortCode)
+	})
+
+	t.Run("Semicolon separated CSV", func(t *testing.T) {
+		records, err := importer.Parse(strings.NewReader("url;code\nhttps://example.com;abc\n"), importer.FormatCSV, 10)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, "abc", records[0].ShortCode)
+	})
+
+	t.Run("Short links are reduced to their code", func(t *testing.T) {
+		file := "url,code\n" +
+			"https://example.com,https://sho.rt/spring/\n" +
+			"https://example.com,sho.rt/summer//\n" +
+			"https://example.com,https://sho.rt/\n" +
+			"https://example.com,https://sho.rt\n" +
+			"https://example.com,/\n"
+		records, err := importer.Parse(strings.NewReader(file), importer.FormatCSV, 10)
+		require.NoError(t, err)
+		require.Len(t, records, 5)
+		assert.Equal(t, "spring", records[0].ShortCode)
+		assert.Equal(t, "summer", records[1].ShortCode)
+		for _, record := range records[2:] {
+			assert.Empty(t, record.ShortCode)
+			assert.ErrorIs(t, record.Err, importer.ErrInvalidRow, "line %d", record.Line)
+		}
+	})
+
+	t.Run("Bad rows are reported, not fatal", func(t *testing.T) {
+		records, err := importer.Parse(strings.NewReader("original_url,expires_at\nhttps://example.com,next week\n"), importer.FormatCSV, 10)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.ErrorIs(t, records[0].Err, importer.ErrInvalidRow)
+	})
+
+	t.Run("CSV needs a URL column", func(t *testing.T) {
+		_, err := importer.Parse(strings.NewReader("code,title\nabc,Home\n"), importer.FormatCSV, 10)
+		assert.ErrorIs(t, err, importer.ErrInvalidFile)
+	})
+
+	t.Run("JSON list or object", func(t *testing.T) {
+		for _, file := range []string{
+			`[{"url":"https://example.com","slug":"abc","tags":["a","b"],"expires_at":1798675200}, "nope"]`,
+			`{"links":[{"url":"https://example.com","slug":"abc","tags":["a","b"],"expires_at":1798675200}, "nope"]}`,
+		} {
+			records, err := importer.Parse(strings.NewReader(file), importer.FormatJSON, 10)
+			require.NoError(t, err)
+			require.Len(t, records, 2)
+			assert.Equal(t, "abc", records[0].ShortCode)
+			assert.Equal(t, []string{"a", "b"}, records[0].Tags)
+			assert.Equal(t, time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), *records[0].ExpiresAt)
+			assert.NoError(t, records[0].Err)
+			assert.ErrorIs(t, records[1].Err, importer.ErrInvalidRow)
+		}
+	})
+
+	t.Run("Too many rows", func(t *testing.T) {
+		_, err := importer.Parse(strings.NewReader("url\nhttps://a.example\nhttps://b.example\n"), importer.FormatCSV, 1)
+		assert.ErrorIs(t, err, importer.ErrTooManyRows)
+		_, err = importer.Parse(strings.NewReader(`["a","b"]`), importer.FormatJSON, 1)
+		assert.ErrorIs(t, err, importer.ErrTooManyRows)
+	})
+}
+
+// The imports below never reach the database: dry runs without custom codes
+// only validate rows
+func TestImportLinks(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	linkService := services.NewLinkService(nil, nil, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8, ImportMaxRows: 3}, utils.NewBlocklist([]string{"blocked.example"}), nil)
+	router, doc := setupAPITestRouter(t, jwtMgr, linkService)
+
+	token, err := jwtMgr.GenerateToken(&models.User{ID: uuid.New(), Role: models.RoleUser})
+	require.NoError(t, err)
+
+	post := func(t *testing.T, query, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/links/import"+query, body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		op := doc.Operation(http.MethodPost, "/api/v1/links/import")
+		require.NotNil(t, op)
+		assert.NoError(t, doc.Validate(doc.ResponseSchema(op, w.Code), w.Body.Bytes()))
+		return w
+	}
+	csv := func(s string) *bytes.Buffer { return bytes.NewBufferString(s) }
+
+	t.Run("Dry run reports every row", func(t *testing.T) {
+		w := post(t, "?dry_run=true", "text/csv", csv("original_url,title,expires_at\n"+
+			"https://example.com/a,Docs,\n"+
+			"https://blocked.example/b,,\n"+
+			"https://example.com/c,,soon\n"))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var body struct {
+			Data models.ImportReport `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		report := body.Data
+		assert.Equal(t, models.ConflictFail, report.ConflictPolicy)
+		assert.Equal(t, 3, report.Total)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.Failed)
+		require.Len(t, report.Issues, 2)
+		assert.Equal(t, 3, report.Issues[0].Line)
+		assert.Equal(t, "domain_blocked", report.Issues[0].Error.Code)
+		assert.Equal(t, 4, report.Issues[1].Line)
+		assert.Equal(t, models.ImportRowFailed, report.Issues[1].Status)
+		assert.Equal(t, "invalid_row", report.Issues[1].Error.Code)
+	})
+
+	t.Run("Multipart upload", func(t *testing.T) {
+		var buf bytes.Buffer
+		form := multipart.NewWriter(&buf)
+		part, err := form.CreateFormFile("file", "links.json")
+		require.NoError(t, err)
+		part.Write([]byte(`{"links":[{"original_url":"https://example.com"}]}`))
+		require.NoError(t, form.Close())
+
+		w := post(t, "?dry_run=1&conflict_policy=rename", form.FormDataContentType(), &buf)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"created":1`)
+	})
+
+	for _, tc := range []struct {
+		name, query, contentType, body, code string
+	}{
+		{"Unknown format", "", "text/plain", "original_url\nhttps://example.com\n", "unknown_import_format"},
+		{"Unknown policy", "?conflict_policy=overwrite", "text/csv", "original_url\nhttps://example.com\n", "invalid_conflict_policy"},
+		{"Too many rows", "?format=csv", "application/octet-stream", "url\nhttps://a.example\nhttps://b.example\nhttps://c.example\nhttps://d.example\n", "too_many_rows"},
+		{"Empty file", "", "application/json", "[]", "empty_import"},
+		{"Bad dry_run", "?dry_run=maybe", "text/csv", "url\nhttps://example.com\n", "invalid_query"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := post(t, tc.query, tc.contentType, csv(tc.body))
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			var body apperror.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tc.code, body.Code)
+		})
+	}
+}
+
+func TestImportLinksDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+	linkService := newTestLinkService(t, db, config.LinkConfig{ImportMaxRows: 10})
+	importService := services.NewImportService(linkService, repository.NewImportRepository(db))
+	t.Cleanup(importService.Close)
+	ctx := context.Background()
+
+	// file has three rows; the first asks for a code that is already taken
+	file := func(t *testing.T) (string, string) {
+		taken := "imp" + uuid.NewString()[:8]
+		_, err := linkService.CreateLink(ctx, createTestUser(t, db).ID, &models.CreateLinkRequest{OriginalURL: "https://example.com", CustomAlias: taken})
+		require.NoError(t, err)
+		return taken, "url,code\n" +
+			"https://example.com/a," + taken + "\n" +
+			"https://example.com/b,\n" +
+			"https://example.com/c,imp" + uuid.NewString()[:8] + "\n"
+	}
+	run := func(t *testing.T, userID uuid.UUID, policy, data string) (*models.ImportReport, error) {
+		records, err := importService.Parse(strings.NewReader(data), importer.FormatCSV)
+		require.NoError(t, err)
+		return importService.Run(ctx, userID, &models.ImportOptions{ConflictPolicy: policy}, records, nil)
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		user := createTestUser(t, db)
+		_, data := file(t)
+		report, err := run(t, user.ID, models.ConflictSkip, data)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, 1, report.Conflicts)
+		require.Len(t, report.Issues, 1)
+		assert.Equal(t, 2, report.Issues[0].Line)
+		assert.Equal(t, models.ImportRowSkipped, report.Issues[0].Status)
+		assert.Equal(t, "alias_taken", report.Issues[0].Error.Code)
+		assert.Equal(t, 2, countLinks(t, db, user.ID))
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		user := createTestUser(t, db)
+		taken, data := file(t)
+		report, err := run(t, user.ID, models.ConflictRename, data)
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Created)
+		assert.Equal(t, 1, report.Renamed)
+		require.Len(t, report.Issues, 1)
+		assert.Equal(t, taken+"-2", report.Issues[0].RenamedTo)
+		assert.Equal(t, 3, countLinks(t, db, user.ID))
+
+		link, err := repository.NewLinkRepository(db).GetByShortCode(ctx, taken+"-2")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, link.UserID)
+		assert.Equal(t, "https://example.com/a", link.OriginalURL)
+	})
+
+	t.Run("Fail rolls back", func(t *testing.T) {
+		user := createTestUser(t, db)
+		_, data := file(t)
+		report, err := run(t, user.ID, models.ConflictFail, data)
+		assert.ErrorIs(t, err, services.ErrImportConflicts)
+		require.NotNil(t, report)
+		assert.Zero(t, report.Created)
+		assert.Equal(t, 1, report.Failed)
+		assert.Zero(t, countLinks(t, db, user.ID))
+	})
+
+	t.Run("Rows whose batch is rolled back are not created", func(t *testing.T) {
+		linkRepo := repository.NewLinkRepository(db)
+		linkRepo.SetOutbox(failingOutbox{})
+		failing := services.NewImportService(services.NewLinkService(linkRepo, repository.NewWorkspaceRepository(db), "http://localhost:8080",
+			config.LinkConfig{ShortCodeLength: 8, ImportMaxRows: 10}, utils.NewBlocklist(nil), nil), nil)
+		user := createTestUser(t, db)
+		records, err := failing.Parse(strings.NewReader("url\nhttps://example.com/a\nhttps://example.com/b\n"), importer.FormatCSV)
+		require.NoError(t, err)
+
+		report, err := failing.Run(ctx, user.ID, &models.ImportOptions{ConflictPolicy: models.ConflictSkip}, records, nil)
+		assert.ErrorContains(t, err, "outbox unavailable")
+		require.NotNil(t, report)
+		assert.Zero(t, report.Created)
+		assert.Zero(t, countLinks(t, db, user.ID))
+	})
+
+	t.Run("Jobs are polled", func(t *testing.T) {
+		jwtMgr := utils.NewJWTManager("secret", time.Hour)
+		router, doc := setupServicesTestRouter(t, jwtMgr, apiTestServices{links: linkService, imports: importService})
+		user := createTestUser(t, db)
+		token, err := jwtMgr.GenerateToken(user)
+		require.NoError(t, err)
+		other, err := jwtMgr.GenerateToken(createTestUser(t, db))
+		require.NoError(t, err)
+
+		send := func(t *testing.T, method, path, token string, body io.Reader) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, body)
+			req.Header.Set("Content-Type", "text/csv")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			template := "/api/v1/links/import"
+			if method == http.MethodGet {
+				template += "/{id}"
+			}
+			op := doc.Operation(method, template)
+			require.NotNil(t, op)
+			assert.NoError(t, doc.Validate(doc.ResponseSchema(op, w.Code), w.Body.Bytes()))
+			return w
+		}
+		var body struct {
+			Data models.ImportJob `json:"data"`
+		}
+
+		_, data := file(t)
+		w := send(t, http.MethodPost, "/api/v1/links/import?conflict_policy=skip", token, strings.NewReader(data))
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		location := w.Header().Get("Location")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "/api/v1/links/import/"+body.Data.ID.String(), location)
+		assert.Equal(t, 3, body.Data.Total)
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+			w := send(t, http.MethodGet, location, token, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			if body.Data.Status != models.ImportRunning {
+				break
+			}
+			require.True(t, time.Now().Before(deadline), "the import did not finish")
+		}
+		assert.Equal(t, models.ImportSucceeded, body.Data.Status)
+		assert.Equal(t, 3, body.Data.Processed)
+		assert.Equal(t, 2, body.Data.Created)
+		assert.Equal(t, 1, body.Data.Skipped)
+		assert.NotNil(t, body.Data.FinishedAt)
+		assert.Equal(t, 2, countLinks(t, db, user.ID))
+
+		assert.Equal(t, http.StatusNotFound, send(t, http.MethodGet, location, other, nil).Code)
+	})
+}
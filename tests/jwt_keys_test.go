@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/models"
+	"link-shortener/internal/utils"
+)
+
+func TestAsymmetricTokens(t *testing.T) {
+	for _, algorithm := range []string{utils.AlgorithmRS256, utils.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := utils.GenerateSigningKey(algorithm)
+			require.NoError(t, err)
+
+			ring := utils.NewKeyRing()
+			ring.Set([]*utils.SigningKey{key})
+			jwtMgr := utils.NewKeyRingJWTManager(ring, time.Hour)
+
+			user := &models.User{ID: uuid.New(), Role: models.RoleUser}
+			token, err := jwtMgr.GenerateToken(user)
+			require.NoError(t, err)
+
+			claims, err := jwtMgr.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, claims.UserID)
+
+			// A token signed with a shared secret must not be accepted
+			hmacToken, err := utils.NewJWTManager("secret", time.Hour).GenerateToken(user)
+			require.NoError(t, err)
+			_, err = jwtMgr.ValidateToken(hmacToken)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	oldKey, err := utils.GenerateSigningKey(utils.AlgorithmRS256)
+	require.NoError(t, err)
+
+	ring := utils.NewKeyRing()
+	ring.Set([]*utils.SigningKey{oldKey})
+	jwtMgr := utils.NewKeyRingJWTManager(ring, time.Hour)
+
+	user := &models.User{ID: uuid.New(), Role: models.RoleUser}
+	oldToken, err := jwtMgr.GenerateToken(user)
+	require.NoError(t, err)
+
+	// Rotate: the old key is retired but still verifies
+	newKey, err := utils.GenerateSigningKey(utils.AlgorithmEdDSA)
+	require.NoError(t, err)
+	newKey.CreatedAt = oldKey.CreatedAt.Add(time.Second)
+	retiredAt := time.Now()
+	oldKey.RetiredAt = &retiredAt
+	ring.Set([]*utils.SigningKey{oldKey, newKey})
+
+	assert.Equal(t, newKey.ID, ring.Active().ID)
+	_, err = jwtMgr.ValidateToken(oldToken)
+	assert.NoError(t, err)
+
+	newToken, err := jwtMgr.GenerateToken(user)
+	require.NoError(t, err)
+	_, err = jwtMgr.ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	// Once the retired key is dropped its tokens stop validating
+	ring.Set([]*utils.SigningKey{newKey})
+	_, err = jwtMgr.ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := utils.GenerateSigningKey(utils.AlgorithmRS256)
+	require.NoError(t, err)
+	edKey, err := utils.GenerateSigningKey(utils.AlgorithmEdDSA)
+	require.NoError(t, err)
+
+	ring := utils.NewKeyRing()
+	ring.Set([]*utils.SigningKey{rsaKey, edKey})
+
+	body, err := json.Marshal(utils.NewKeyRingJWTManager(ring, time.Hour).JWKS())
+	require.NoError(t, err)
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(body, &set))
+	require.Len(t, set.Keys, 2)
+
+	for _, jwk := range set.Keys {
+		assert.NotEmpty(t, jwk["kid"])
+		assert.Equal(t, "sig", jwk["use"])
+		assert.Empty(t, jwk["d"], "private key material must not be published")
+	}
+
+	assert.Empty(t, utils.NewJWTManager("secret", time.Hour).JWKS().Keys)
+}
+
+func TestSigningKeyEncoding(t *testing.T) {
+	key, err := utils.GenerateSigningKey(utils.AlgorithmEdDSA)
+	require.NoError(t, err)
+
+	encoded, err := utils.EncodePrivateKey(key.PrivateKey)
+	require.NoError(t, err)
+
+	decoded, err := utils.DecodePrivateKey(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, key.PrivateKey.Public(), decoded.Public())
+
+	_, err = utils.GenerateSigningKey("none")
+	assert.Error(t, err)
+}
@@ -2,14 +2,18 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"link-shortener/internal/config"
 	"link-shortener/internal/database"
 	"link-shortener/internal/handlers"
@@ -17,6 +21,7 @@ import (
 	"link-shortener/internal/models"
 	"link-shortener/internal/repository"
 	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
 )
 
 func setupLinkTestRouter() (*gin.Engine, *handlers.LinkHandler, uuid.UUID) {
@@ -28,9 +33,10 @@ func setupLinkTestRouter() (*gin.Engine, *handlers.LinkHandler, uuid.UUID) {
 	
 	// Initialize repositories
 	linkRepo := repository.NewLinkRepository(db)
+	workspaceRepo := repository.NewWorkspaceRepository(db)
 	
 	// Initialize services
-	linkService := services.NewLinkService(linkRepo, "http://localhost:8080")
+	linkService := services.NewLinkService(linkRepo, workspaceRepo, "http://localhost:8080", config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), services.NewClickQueue(linkRepo, 100, 1))
 	
 	// Initialize handlers
 	linkHandler := handlers.NewLinkHandler(linkService)
@@ -152,8 +158,8 @@ func TestUpdateLink(t *testing.T) {
 	// Serve request
 	router.ServeHTTP(w, req)
 	
-	// Should return 400 since link doesn't exist
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Should return 404 since link doesn't exist
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestDeleteLink(t *testing.T) {
@@ -171,8 +177,8 @@ func TestDeleteLink(t *testing.T) {
 	// Serve request
 	router.ServeHTTP(w, req)
 	
-	// Should return 400 since link doesn't exist
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Should return 404 since link doesn't exist
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestGetStats(t *testing.T) {
@@ -215,3 +221,73 @@ func TestRedirect(t *testing.T) {
 	// Should return 404 since short code doesn't exist
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestLinkAliasRace(t *testing.T) {
+	db := openTestDatabase(t)
+	linkService := newTestLinkService(t, db, config.LinkConfig{})
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	// race runs fn concurrently and returns the errors
+	race := func(fn func() error) []error {
+		errs := make([]error, 8)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = fn()
+			}(i)
+		}
+		wg.Wait()
+		return errs
+	}
+	alias := func() string { return strings.ReplaceAll(uuid.NewString(), "-", "")[:12] }
+
+	t.Run("Create", func(t *testing.T) {
+		code := alias()
+		errs := race(func() error {
+			_, err := linkService.CreateLink(ctx, user.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com", CustomAlias: code})
+			return err
+		})
+
+		created := 0
+		for _, err := range errs {
+			if err == nil {
+				created++
+			} else {
+				assert.ErrorIs(t, err, services.ErrAliasTaken)
+			}
+		}
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		code := alias()
+		var links []*models.LinkResponse
+		for i := 0; i < 8; i++ {
+			link, err := linkService.CreateLink(ctx, user.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com"})
+			require.NoError(t, err)
+			links = append(links, link)
+		}
+
+		next := make(chan *models.LinkResponse, len(links))
+		for _, link := range links {
+			next <- link
+		}
+		errs := race(func() error {
+			_, err := linkService.UpdateLink(ctx, user.ID, (<-next).ID, &models.UpdateLinkRequest{CustomAlias: code})
+			return err
+		})
+
+		updated := 0
+		for _, err := range errs {
+			if err == nil {
+				updated++
+			} else {
+				assert.ErrorIs(t, err, services.ErrAliasTaken)
+			}
+		}
+		assert.Equal(t, 1, updated)
+	})
+}
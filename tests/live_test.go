@@ -0,0 +1,386 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/config"
+	"link-shortener/internal/handlers"
+	"link-shortener/internal/live"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+)
+
+func receive(t *testing.T, sub *live.Subscription) live.Event {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		require.True(t, ok, "subscription closed")
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return live.Event{}
+	}
+}
+
+func TestLiveHub(t *testing.T) {
+	owner, workspace := uuid.New(), uuid.New()
+	personal := &models.ClickEvent{LinkID: uuid.New(), UserID: owner, ShortCode: "mine"}
+	shared := &models.ClickEvent{LinkID: uuid.New(), UserID: owner, WorkspaceID: &workspace, ShortCode: "team"}
+
+	t.Run("Fan-out by scope", func(t *testing.T) {
+		hub := live.NewHub(10, 10, 10, 10)
+		link, user, team := subscribe(t, hub, owner, live.LinkScope(personal.LinkID)),
+			subscribe(t, hub, owner, live.UserScope(owner)), subscribe(t, hub, owner, live.WorkspaceScope(workspace))
+		assert.Equal(t, 3, hub.Subscribers())
+
+		hub.Publish(personal)
+		hub.Publish(shared)
+
+		assert.Same(t, personal, receive(t, link).Click)
+		assert.Same(t, personal, receive(t, user).Click)
+		assert.Same(t, shared, receive(t, team).Click)
+		assert.Empty(t, link.Events())
+		assert.Empty(t, user.Events(), "workspace links are not personal")
+
+		link.Close()
+		link.Close()
+		assert.Equal(t, 2, hub.Subscribers())
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		hub := live.NewHub(3, 10, 10, 10)
+		sub := subscribe(t, hub, owner, live.UserScope(owner))
+		for i := 0; i < 5; i++ {
+			hub.Publish(personal)
+			hub.Publish(shared)
+		}
+		first := receive(t, sub)
+		sub.Close()
+
+		resumed, err := hub.Subscribe(owner, live.UserScope(owner), first.ID)
+		require.NoError(t, err)
+		// Only the last three clicks are buffered, and one of them is personal
+		event := receive(t, resumed)
+		assert.Same(t, personal, event.Click)
+		assert.Empty(t, resumed.Events())
+
+		hub.Publish(personal)
+		assert.NotEqual(t, event.ID, receive(t, resumed).ID)
+
+		other, err := live.NewHub(3, 10, 10, 10).Subscribe(owner, live.UserScope(owner), first.ID)
+		require.NoError(t, err)
+		assert.Empty(t, other.Events(), "IDs of another hub are ignored")
+	})
+
+	t.Run("Slow subscribers are dropped", func(t *testing.T) {
+		hub := live.NewHub(10, 2, 10, 10)
+		slow := subscribe(t, hub, owner, live.LinkScope(personal.LinkID))
+		for i := 0; i < 3; i++ {
+			hub.Publish(personal)
+		}
+		receive(t, slow)
+		receive(t, slow)
+		_, ok := <-slow.Events()
+		assert.False(t, ok)
+		assert.Zero(t, hub.Subscribers())
+	})
+
+	t.Run("Subscriber limit", func(t *testing.T) {
+		hub := live.NewHub(10, 10, 1, 10)
+		sub := subscribe(t, hub, owner, live.UserScope(owner))
+		_, err := hub.Subscribe(owner, live.UserScope(owner), "")
+		assert.ErrorIs(t, err, live.ErrTooManySubscribers)
+
+		sub.Close()
+		subscribe(t, hub, owner, live.UserScope(owner))
+	})
+
+	t.Run("Per-user limit", func(t *testing.T) {
+		hub := live.NewHub(10, 10, 10, 2)
+		first := subscribe(t, hub, owner, live.UserScope(owner))
+		subscribe(t, hub, owner, live.WorkspaceScope(workspace))
+		_, err := hub.Subscribe(owner, live.LinkScope(personal.LinkID), "")
+		assert.ErrorIs(t, err, live.ErrTooManyStreams)
+		assert.Equal(t, http.StatusTooManyRequests, apperror.Status(err))
+
+		other := uuid.New()
+		subscribe(t, hub, other, live.UserScope(other))
+
+		first.Close()
+		subscribe(t, hub, owner, live.LinkScope(personal.LinkID))
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		hub := live.NewHub(10, 10, 10, 10)
+		sub := subscribe(t, hub, owner, live.UserScope(owner))
+		hub.Close()
+
+		_, ok := <-sub.Events()
+		assert.False(t, ok)
+		sub.Close()
+		_, err := hub.Subscribe(owner, live.UserScope(owner), "")
+		assert.ErrorIs(t, err, live.ErrClosed)
+		hub.Publish(personal)
+	})
+}
+
+func subscribe(t *testing.T, hub *live.Hub, userID uuid.UUID, scope live.Scope) *live.Subscription {
+	t.Helper()
+	sub, err := hub.Subscribe(userID, scope, "")
+	require.NoError(t, err)
+	t.Cleanup(sub.Close)
+	return sub
+}
+
+// revocableSessions accepts sessions until revoked is set
+type revocableSessions struct {
+	revoked atomic.Bool
+}
+
+func (s *revocableSessions) ValidateSession(context.Context, uuid.UUID, int) error {
+	if s.revoked.Load() {
+		return apperror.Unauthorized("session_revoked", "Session has been revoked")
+	}
+	return nil
+}
+
+// sseEvent is one event read from a stream; comments are returned with only
+// the comment set
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			event.comment = value
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+func TestLiveStream(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	linkService := services.NewLinkService(nil, nil, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), nil)
+	router, _ := setupAPITestRouter(t, jwtMgr, linkService)
+	hub := live.NewHub(10, 10, 10, 10)
+	linkService.SetLive(hub)
+	server := httptest.NewServer(router)
+	// Registered first so that it runs after the streams are closed
+	t.Cleanup(server.Close)
+
+	userID := uuid.New()
+	token, err := jwtMgr.GenerateToken(&models.User{ID: userID, Role: models.RoleUser})
+	require.NoError(t, err)
+
+	open := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/links/live", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	_, stream := open("")
+	require.Equal(t, sseEvent{}, readEvent(t, stream), "retry")
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	click := &models.ClickEvent{ID: 7, LinkID: uuid.New(), UserID: userID, ShortCode: "launch", ClickedAt: time.Now().UTC()}
+	hub.Publish(&models.ClickEvent{LinkID: uuid.New(), UserID: uuid.New()})
+	hub.Publish(click)
+
+	event := readEvent(t, stream)
+	assert.Equal(t, "click", event.event)
+	assert.NotEmpty(t, event.id)
+	var got models.ClickEvent
+	require.NoError(t, json.Unmarshal([]byte(event.data), &got))
+	assert.Equal(t, click.LinkID, got.LinkID)
+	assert.Equal(t, "launch", got.ShortCode)
+	assert.NotContains(t, event.data, userID.String())
+
+	assert.Equal(t, sseEvent{comment: "heartbeat"}, readEvent(t, stream))
+
+	t.Run("Resume", func(t *testing.T) {
+		next := &models.ClickEvent{ID: 8, LinkID: click.LinkID, UserID: userID, ShortCode: "launch"}
+		hub.Publish(next)
+
+		_, resumed := open(event.id)
+		readEvent(t, resumed)
+		missed := readEvent(t, resumed)
+		assert.Equal(t, "click", missed.event)
+		assert.Contains(t, missed.data, `"id":8`)
+	})
+
+	t.Run("Bad IDs", func(t *testing.T) {
+		for path, code := range map[string]string{
+			"/api/v1/links/abc/live":              "invalid_id",
+			"/api/v1/links/live?workspace_id=abc": "invalid_query",
+		} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code, path)
+			var body apperror.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, code, body.Code, path)
+		}
+	})
+
+	t.Run("Revoked sessions end the stream", func(t *testing.T) {
+		sessions := &revocableSessions{}
+		auth := middleware.NewAuthMiddleware(jwtMgr, sessions)
+		revocable := gin.New()
+		revocable.GET("/live", auth.AuthRequired(), handlers.NewLiveHandler(linkService, auth, 20*time.Millisecond).WatchLinks)
+		revocableServer := httptest.NewServer(revocable)
+		t.Cleanup(revocableServer.Close)
+
+		req, err := http.NewRequest(http.MethodGet, revocableServer.URL+"/live", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		stream := bufio.NewReader(resp.Body)
+		readEvent(t, stream)
+		assert.Equal(t, sseEvent{comment: "heartbeat"}, readEvent(t, stream))
+
+		sessions.revoked.Store(true)
+		done := make(chan error, 1)
+		go func() {
+			_, err := io.Copy(io.Discard, stream)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream still open")
+		}
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/links/live", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestLiveDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+	linkRepo, workspaceRepo := repository.NewLinkRepository(db), repository.NewWorkspaceRepository(db)
+	clicks := services.NewClickQueue(linkRepo, 10, 1)
+	t.Cleanup(clicks.Close)
+	linkService := services.NewLinkService(linkRepo, workspaceRepo, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), clicks)
+	hub := live.NewHub(10, 10, 10, 10)
+	linkService.SetLive(hub)
+	clicks.SetLive(hub)
+	ctx := context.Background()
+
+	owner, member := createTestUser(t, db), createTestUser(t, db)
+	workspace := &models.Workspace{ID: uuid.New(), Name: "Live"}
+	require.NoError(t, workspaceRepo.Create(ctx, workspace, owner.ID))
+	t.Cleanup(func() { workspaceRepo.Delete(context.Background(), workspace.ID) })
+	_, err := db.ExecContext(ctx, `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`,
+		workspace.ID, member.ID, models.WorkspaceRoleViewer)
+	require.NoError(t, err)
+
+	personal, err := linkService.CreateLink(ctx, owner.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com/live"})
+	require.NoError(t, err)
+	shared, err := linkService.CreateLink(ctx, owner.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com/team", WorkspaceID: &workspace.ID})
+	require.NoError(t, err)
+
+	t.Run("Recorded clicks are streamed", func(t *testing.T) {
+		link, err := linkService.WatchLink(ctx, owner.ID, personal.ID, "")
+		require.NoError(t, err)
+		defer link.Close()
+		mine, err := linkService.WatchLinks(ctx, owner.ID, nil, "")
+		require.NoError(t, err)
+		defer mine.Close()
+		team, err := linkService.WatchLinks(ctx, member.ID, &workspace.ID, "")
+		require.NoError(t, err)
+		defer team.Close()
+
+		_, err = linkService.RedirectToOriginal(ctx, personal.ShortCode, models.ClickEvent{Referrer: "https://news.example"})
+		require.NoError(t, err)
+		_, err = linkService.RedirectToOriginal(ctx, shared.ShortCode, models.ClickEvent{})
+		require.NoError(t, err)
+
+		click := receive(t, link.Subscription).Click
+		assert.Equal(t, personal.ID, click.LinkID)
+		assert.Positive(t, click.ID, "the click is streamed once stored")
+		assert.Equal(t, "https://news.example", click.Referrer)
+		assert.Equal(t, personal.ID, receive(t, mine.Subscription).Click.LinkID)
+		assert.Equal(t, shared.ID, receive(t, team.Subscription).Click.LinkID)
+		assert.Empty(t, mine.Events(), "workspace links are not personal")
+	})
+
+	t.Run("Only viewers may watch", func(t *testing.T) {
+		_, err := linkService.WatchLink(ctx, member.ID, personal.ID, "")
+		assert.ErrorIs(t, err, services.ErrForbidden)
+		_, err = linkService.WatchLinks(ctx, createTestUser(t, db).ID, &workspace.ID, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("Access is checked again", func(t *testing.T) {
+		team, err := linkService.WatchLinks(ctx, member.ID, &workspace.ID, "")
+		require.NoError(t, err)
+		defer team.Close()
+		link, err := linkService.WatchLink(ctx, owner.ID, personal.ID, "")
+		require.NoError(t, err)
+		defer link.Close()
+		require.NoError(t, team.Authorize(ctx))
+		require.NoError(t, link.Authorize(ctx))
+
+		require.NoError(t, workspaceRepo.RemoveMember(ctx, workspace.ID, member.ID, owner.ID))
+		assert.Error(t, team.Authorize(ctx), "removed members lose the stream")
+		require.NoError(t, linkService.DeleteLink(ctx, owner.ID, personal.ID))
+		assert.ErrorIs(t, link.Authorize(ctx), repository.ErrLinkNotFound)
+	})
+}
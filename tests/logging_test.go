@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/logging"
+	"link-shortener/internal/middleware"
+)
+
+func setupLoggingTest(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&logs, logging.FormatJSON, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.AccessLog(), middleware.Recovery())
+	router.GET("/r/:shortCode", func(c *gin.Context) {
+		c.Set("user_id", uuid.MustParse("11111111-1111-1111-1111-111111111111"))
+		logging.FromContext(c.Request.Context()).Info("handling")
+		c.Status(http.StatusFound)
+	})
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	return router, &logs
+}
+
+func decodeLogLines(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	t.Run("Generated request ID", func(t *testing.T) {
+		router, logs := setupLoggingTest(t)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/abc123", nil))
+
+		requestID := w.Header().Get(middleware.RequestIDHeader)
+		assert.Len(t, requestID, 32)
+
+		records := decodeLogLines(t, logs)
+		require.Len(t, records, 2)
+		assert.Equal(t, "handling", records[0]["msg"])
+		assert.Equal(t, requestID, records[0]["request_id"])
+
+		access := records[1]
+		assert.Equal(t, "request", access["msg"])
+		assert.Equal(t, requestID, access["request_id"])
+		assert.Equal(t, float64(http.StatusFound), access["status"])
+		assert.Equal(t, "abc123", access["short_code"])
+		assert.Equal(t, "11111111-1111-1111-1111-111111111111", access["user_id"])
+		assert.Contains(t, access, "latency_ms")
+	})
+
+	t.Run("Caller request ID is kept", func(t *testing.T) {
+		router, logs := setupLoggingTest(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/r/abc123", nil)
+		req.Header.Set(middleware.RequestIDHeader, "upstream-42")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "upstream-42", w.Header().Get(middleware.RequestIDHeader))
+		assert.Equal(t, "upstream-42", decodeLogLines(t, logs)[1]["request_id"])
+	})
+
+	t.Run("Invalid request ID is replaced", func(t *testing.T) {
+		router, _ := setupLoggingTest(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/r/abc123", nil)
+		req.Header.Set(middleware.RequestIDHeader, "bad id\twith spaces")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.NotEqual(t, "bad id\twith spaces", w.Header().Get(middleware.RequestIDHeader))
+	})
+
+	t.Run("Panics are logged as errors", func(t *testing.T) {
+		router, logs := setupLoggingTest(t)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		records := decodeLogLines(t, logs)
+		require.Len(t, records, 2)
+		assert.Equal(t, "panic recovered", records[0]["msg"])
+		assert.Equal(t, "ERROR", records[1]["level"])
+		assert.Equal(t, float64(http.StatusInternalServerError), records[1]["status"])
+	})
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"link-shortener/internal/metrics"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/services"
+)
+
+func scrapeMetrics(t *testing.T, registry *metrics.Registry) string {
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	return w.Body.String()
+}
+
+func TestMetricsExposition(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Register(metrics.NewGaugeFunc("test_queue_depth", "Items \"waiting\".", func() float64 { return 3 }))
+
+	var out bytes.Buffer
+	registry.Write(&out)
+	assert.Equal(t, "# HELP test_queue_depth Items \"waiting\".\n# TYPE test_queue_depth gauge\ntest_queue_depth 3\n", out.String())
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Metrics())
+	router.Use(middleware.NewRateLimiter(1, time.Minute).RateLimit())
+	router.GET("/r/:shortCode", func(c *gin.Context) { c.Status(http.StatusMovedPermanently) })
+
+	before := metrics.RateLimitRejections.Value()
+
+	for _, path := range []string{"/r/abc", "/r/def", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, before+2, metrics.RateLimitRejections.Value())
+	assert.GreaterOrEqual(t, metrics.HTTPRequests.Value("GET", "/r/:shortCode", "301"), float64(1))
+	assert.GreaterOrEqual(t, metrics.HTTPRequests.Value("GET", "/r/:shortCode", "429"), float64(1))
+
+	body := scrapeMetrics(t, metrics.Default)
+	assert.Contains(t, body, "# TYPE http_requests_total counter")
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/r/:shortCode",status="301"}`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="429"}`)
+	assert.Contains(t, body, "# TYPE http_request_duration_seconds histogram")
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/r/:shortCode",status="301",le="+Inf"}`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/r/:shortCode",status="301"}`)
+	assert.Contains(t, body, "# TYPE rate_limit_rejections_total counter")
+	assert.Contains(t, body, "link_cache_hit_ratio")
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	histogram := metrics.NewHistogramVec("test_histogram_seconds", "Test histogram.", []float64{0.1, 1}, "kind")
+	histogram.Observe(0.05, "a\"b")
+	histogram.Observe(0.5, "a\"b")
+	histogram.Observe(5, "a\"b")
+
+	var out bytes.Buffer
+	histogram.Write(&out)
+	assert.Equal(t, strings.Join([]string{
+		"# HELP test_histogram_seconds Test histogram.",
+		"# TYPE test_histogram_seconds histogram",
+		`test_histogram_seconds_bucket{kind="a\"b",le="0.1"} 1`,
+		`test_histogram_seconds_bucket{kind="a\"b",le="1"} 2`,
+		`test_histogram_seconds_bucket{kind="a\"b",le="+Inf"} 3`,
+		`test_histogram_seconds_sum{kind="a\"b"} 5.55`,
+		`test_histogram_seconds_count{kind="a\"b"} 3`,
+		"",
+	}, "\n"), out.String())
+}
+
+func TestClickQueueDrops(t *testing.T) {
+	// Without workers nothing is recorded, so nothing touches the database
+	queue := services.NewClickQueue(nil, 1, 0)
+	full, closed := metrics.ClicksDropped.Value("full"), metrics.ClicksDropped.Value("closed")
+
+	queue.Enqueue(context.Background(), &models.ClickEvent{})
+	queue.Enqueue(context.Background(), &models.ClickEvent{})
+	assert.Equal(t, 1, queue.Depth())
+	assert.Equal(t, full+1, metrics.ClicksDropped.Value("full"))
+
+	queue.Close()
+	queue.Enqueue(context.Background(), &models.ClickEvent{})
+	assert.Equal(t, closed+1, metrics.ClicksDropped.Value("closed"))
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+	"link-shortener/internal/oidc"
+)
+
+// mockIdP is a minimal in-process OpenID Connect provider. Authorization is
+// simulated with authorize, which returns a code as if the user had signed in.
+type mockIdP struct {
+	server   *httptest.Server
+	clientID string
+
+	mu       sync.Mutex
+	key      *rsa.PrivateKey
+	kid      string
+	audience string
+	codes    map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T, clientID string) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &mockIdP{clientID: clientID, key: key, kid: "key-1", audience: clientID, codes: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": idp.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) authorize(t *testing.T, authURL string) (code, state string) {
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, idp.clientID, query.Get("client_id"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code = "code-" + query.Get("state")
+	idp.codes[code] = mockGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	return code, query.Get("state")
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	grant, ok := idp.codes[r.FormValue("code")]
+	delete(idp.codes, r.FormValue("code"))
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "user-123",
+		"aud":            idp.audience,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          grant.nonce,
+		"email":          "sso@example.com",
+		"email_verified": true,
+	})
+	token.Header["kid"] = idp.kid
+	signed, _ := token.SignedString(idp.key)
+
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": signed})
+}
+
+func setupOIDCTest(t *testing.T) (*mockIdP, *oidc.Provider) {
+	idp := newMockIdP(t, "shortlink")
+	provider := oidc.NewProvider(&config.OIDCConfig{
+		IssuerURL:   idp.server.URL,
+		ClientID:    "shortlink",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	})
+	return idp, provider
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	idp, provider := setupOIDCTest(t)
+
+	flow, err := oidc.NewFlow(time.Minute)
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge())
+	require.NoError(t, err)
+
+	code, state := idp.authorize(t, authURL)
+	assert.Equal(t, flow.State, state)
+
+	token, err := provider.Exchange(code, flow.CodeVerifier)
+	require.NoError(t, err)
+
+	claims, err := provider.VerifyIDToken(token.IDToken, flow.Nonce)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", claims.Subject)
+	assert.Equal(t, "sso@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+}
+
+func TestOIDCRejectsBadTokens(t *testing.T) {
+	t.Run("Wrong PKCE verifier", func(t *testing.T) {
+		idp, provider := setupOIDCTest(t)
+		flow, _ := oidc.NewFlow(time.Minute)
+		authURL, _ := provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge())
+		code, _ := idp.authorize(t, authURL)
+
+		_, err := provider.Exchange(code, "not-the-verifier")
+		assert.Error(t, err)
+	})
+
+	t.Run("Nonce mismatch", func(t *testing.T) {
+		idp, provider := setupOIDCTest(t)
+		flow, _ := oidc.NewFlow(time.Minute)
+		authURL, _ := provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge())
+		code, _ := idp.authorize(t, authURL)
+
+		token, err := provider.Exchange(code, flow.CodeVerifier)
+		require.NoError(t, err)
+		_, err = provider.VerifyIDToken(token.IDToken, "other-nonce")
+		assert.Error(t, err)
+	})
+
+	t.Run("Wrong audience", func(t *testing.T) {
+		idp, provider := setupOIDCTest(t)
+		idp.audience = "another-client"
+		flow, _ := oidc.NewFlow(time.Minute)
+		authURL, _ := provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge())
+		code, _ := idp.authorize(t, authURL)
+
+		token, err := provider.Exchange(code, flow.CodeVerifier)
+		require.NoError(t, err)
+		_, err = provider.VerifyIDToken(token.IDToken, flow.Nonce)
+		assert.Error(t, err)
+	})
+
+	t.Run("Rotated key is fetched", func(t *testing.T) {
+		idp, provider := setupOIDCTest(t)
+		flow, _ := oidc.NewFlow(time.Minute)
+		authURL, _ := provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge())
+		code, _ := idp.authorize(t, authURL)
+		token, _ := provider.Exchange(code, flow.CodeVerifier)
+		_, err := provider.VerifyIDToken(token.IDToken, flow.Nonce)
+		require.NoError(t, err)
+
+		newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		idp.mu.Lock()
+		idp.key, idp.kid = newKey, "key-2"
+		idp.mu.Unlock()
+
+		code, _ = idp.authorize(t, authURL)
+		token, err = provider.Exchange(code, flow.CodeVerifier)
+		require.NoError(t, err)
+		_, err = provider.VerifyIDToken(token.IDToken, flow.Nonce)
+		assert.NoError(t, err)
+	})
+}
+
+func TestOIDCFlowSealing(t *testing.T) {
+	flow, err := oidc.NewFlow(time.Minute)
+	require.NoError(t, err)
+
+	sealed, err := flow.Seal("secret")
+	require.NoError(t, err)
+
+	opened, err := oidc.OpenFlow("secret", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, flow.State, opened.State)
+	assert.Equal(t, flow.CodeVerifier, opened.CodeVerifier)
+
+	_, err = oidc.OpenFlow("other-secret", sealed)
+	assert.Error(t, err)
+
+	expired, _ := oidc.NewFlow(-time.Minute)
+	sealed, _ = expired.Seal("secret")
+	_, err = oidc.OpenFlow("secret", sealed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,327 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+	"link-shortener/internal/handlers"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/openapi"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+	"link-shortener/migrations"
+)
+
+// setupOpenAPITestRouter mounts every documented route the way the server
+// does. Services the tested requests never reach are left nil.
+func setupOpenAPITestRouter(t *testing.T, jwtMgr *utils.JWTManager) (*gin.Engine, *openapi.Document) {
+	return setupAPITestRouter(t, jwtMgr, nil)
+}
+
+// setupAPITestRouter is setupOpenAPITestRouter with a link service
+func setupAPITestRouter(t *testing.T, jwtMgr *utils.JWTManager, linkService *services.LinkService) (*gin.Engine, *openapi.Document) {
+	return setupServicesTestRouter(t, jwtMgr, apiTestServices{links: linkService})
+}
+
+// apiTestServices are the services setupServicesTestRouter mounts. The auth
+// service, when set, also checks sessions; idempotency, when set, guards
+// link creation. Imports default to a service without a job store.
+type apiTestServices struct {
+	auth        *services.AuthService
+	links       *services.LinkService
+	imports     *services.ImportService
+	idempotency *services.IdempotencyService
+}
+
+// setupServicesTestRouter is setupAPITestRouter with more services
+func setupServicesTestRouter(t *testing.T, jwtMgr *utils.JWTManager, svc apiTestServices) (*gin.Engine, *openapi.Document) {
+	gin.SetMode(gin.TestMode)
+	authService, linkService, importService := svc.auth, svc.links, svc.imports
+	if importService == nil {
+		importService = services.NewImportService(linkService, nil)
+	}
+	clicks := services.NewClickQueue(nil, 10, 1)
+	t.Cleanup(clicks.Close)
+	healthService := services.NewHealthService(openSlowDatabase(t, time.Second), clicks,
+		config.HealthConfig{CheckTimeout: time.Second, ClickBacklogRatio: 0.9})
+
+	router := gin.New()
+	doc := handlers.NewOpenAPIDocument()
+	root := openapi.NewRouter(&router.RouterGroup, doc)
+
+	handlers.RegisterProbes(root, handlers.NewHealthHandler(healthService))
+	router.GET("/openapi.json", handlers.NewDocsHandler(doc).OpenAPI)
+	handlers.RegisterJWKS(root, jwtMgr.JWKS)
+	var sessions middleware.SessionValidator = allowSessions{}
+	if authService != nil {
+		sessions = authService
+	}
+	authMiddleware := middleware.NewAuthMiddleware(jwtMgr, sessions)
+	api := &handlers.API{
+		Auth:       handlers.NewAuthHandler(authService),
+		SSO:        handlers.NewSSOHandler(nil),
+		Links:      handlers.NewLinkHandler(linkService),
+		Imports:    handlers.NewImportHandler(importService),
+		Workspaces: handlers.NewWorkspaceHandler(nil),
+		Webhooks:   handlers.NewWebhookHandler(services.NewWebhookService(nil, linkService, config.WebhookConfig{})),
+		Live:       handlers.NewLiveHandler(linkService, authMiddleware, 50*time.Millisecond),
+		Admin:      handlers.NewAdminHandler(nil),
+		Middleware: authMiddleware,
+	}
+	if svc.idempotency != nil {
+		api.Idempotency = middleware.Idempotent(svc.idempotency, "links.create")
+	}
+	handlers.MountAPI(root, []handlers.APIVersion{{Name: "v1", Register: api.Register}}, "v1",
+		&middleware.Deprecation{Since: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)})
+	handlers.RegisterRedirect(root, api.Links)
+	return router, doc
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	router, doc := setupOpenAPITestRouter(t, utils.NewJWTManager("secret", time.Hour))
+	ginParam := regexp.MustCompile(`:(\w+)`)
+
+	t.Run("Every route is documented", func(t *testing.T) {
+		documented := 0
+		for _, item := range doc.Paths {
+			documented += len(*item)
+		}
+
+		routes := 0
+		for _, route := range router.Routes() {
+			if route.Path == "/openapi.json" {
+				continue
+			}
+			path := ginParam.ReplaceAllString(route.Path, "{$1}")
+			// The unversioned alias is left out of the document on purpose
+			if strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/api/v1/") {
+				path = "/api/v1" + strings.TrimPrefix(path, "/api")
+				assert.NotNil(t, doc.Operation(route.Method, path), "%s %s is not an alias of v1", route.Method, route.Path)
+				continue
+			}
+			routes++
+			assert.NotNil(t, doc.Operation(route.Method, path), "%s %s is not documented", route.Method, route.Path)
+		}
+		assert.Equal(t, routes, documented)
+	})
+
+	t.Run("Operations are complete", func(t *testing.T) {
+		ids := map[string]bool{}
+		for path, item := range doc.Paths {
+			for method, op := range *item {
+				assert.False(t, ids[op.OperationID], "duplicate operationId %s", op.OperationID)
+				ids[op.OperationID] = true
+				assert.NotEmpty(t, op.Summary, "%s %s has no summary", method, path)
+				assert.Contains(t, op.Responses, "default")
+
+				for _, match := range regexp.MustCompile(`\{(\w+)\}`).FindAllStringSubmatch(path, -1) {
+					declared := false
+					for _, param := range op.Parameters {
+						declared = declared || (param.In == "path" && param.Name == match[1])
+					}
+					assert.True(t, declared, "%s %s does not declare %s", method, path, match[1])
+				}
+			}
+		}
+		assert.NotNil(t, doc.Operation(http.MethodGet, "/api/v1/links/{id}").Security)
+		assert.Nil(t, doc.Operation(http.MethodPost, "/api/v1/auth/login").Security)
+	})
+
+	t.Run("Schemas follow the models", func(t *testing.T) {
+		register := doc.Components.Schemas["RegisterRequest"]
+		require.NotNil(t, register)
+		assert.ElementsMatch(t, []string{"username", "email", "password"}, register.Required)
+		assert.Equal(t, "email", register.Properties["email"].Format)
+
+		link := doc.Components.Schemas["LinkResponse"]
+		require.NotNil(t, link)
+		assert.Equal(t, "uuid", link.Properties["id"].Format)
+		assert.Equal(t, "date-time", link.Properties["created_at"].Format)
+		assert.NotContains(t, link.Required, "expires_at")
+		assert.True(t, link.Properties["expires_at"].Nullable)
+	})
+
+	t.Run("Served as JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var served map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+		assert.Equal(t, openapi.Version, served["openapi"])
+		assert.Contains(t, served["paths"], "/api/v1/links/{id}")
+
+		// Every reference must resolve
+		for _, ref := range regexp.MustCompile(`"\$ref":"#/components/(\w+)/(\w+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+			switch ref[1] {
+			case "schemas":
+				assert.Contains(t, doc.Components.Schemas, ref[2])
+			case "responses":
+				assert.Contains(t, doc.Components.Responses, ref[2])
+			default:
+				t.Errorf("unexpected reference %s", ref[0])
+			}
+		}
+	})
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	key, err := utils.GenerateSigningKey(utils.AlgorithmRS256)
+	require.NoError(t, err)
+	ring := utils.NewKeyRing()
+	ring.Set([]*utils.SigningKey{key})
+	jwtMgr := utils.NewKeyRingJWTManager(ring, time.Hour)
+	router, doc := setupOpenAPITestRouter(t, jwtMgr)
+	schemaVersion.Store(int64(migrations.Latest()))
+
+	token, err := jwtMgr.GenerateToken(&models.User{ID: uuid.New(), Role: models.RoleUser})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+	}{
+		{"Liveness", http.MethodGet, "/livez", "", "", http.StatusOK},
+		{"Legacy health", http.MethodGet, "/health", "", "", http.StatusOK},
+		{"Readiness", http.MethodGet, "/readyz", "", "", http.StatusOK},
+		{"Signing keys", http.MethodGet, "/.well-known/jwks.json", "", "", http.StatusOK},
+		{"Missing token", http.MethodGet, "/api/v1/links/", "", "", http.StatusUnauthorized},
+		{"Invalid body", http.MethodPost, "/api/v1/auth/register", `{"username":"a","email":"bad"}`, "", http.StatusBadRequest},
+		{"Malformed JSON", http.MethodPost, "/api/v1/auth/login", `{`, "", http.StatusBadRequest},
+		{"Invalid path parameter", http.MethodGet, "/api/v1/links/not-a-uuid", "", token, http.StatusBadRequest},
+		{"Invalid query parameter", http.MethodGet, "/api/v1/links/stats?workspace_id=1", "", token, http.StatusBadRequest},
+		{"Missing permission", http.MethodGet, "/api/v1/admin/users", "", token, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+
+			path, _, _ := strings.Cut(tt.path, "?")
+			template := doc.Match(path)
+			require.NotEmpty(t, template, "no documented path matches %s", path)
+			op := doc.Operation(tt.method, template)
+			require.NotNil(t, op, "%s %s is not documented", tt.method, template)
+			schema := doc.ResponseSchema(op, w.Code)
+			require.NotNil(t, schema, "no schema for status %d", w.Code)
+			assert.NoError(t, doc.Validate(schema, w.Body.Bytes()))
+		})
+	}
+
+	t.Run("Success responses", testSuccessResponses)
+
+	t.Run("Mismatches are reported", func(t *testing.T) {
+		schema := doc.ResponseSchema(doc.Operation(http.MethodGet, "/livez"), http.StatusOK)
+		assert.NoError(t, doc.Validate(schema, []byte(`{"status":"ok"}`)))
+		assert.ErrorContains(t, doc.Validate(schema, []byte(`{"status":1}`)), "expected a string")
+		assert.ErrorContains(t, doc.Validate(schema, []byte(`{}`)), `missing required property "status"`)
+		assert.ErrorContains(t, doc.Validate(schema, []byte(`{"status":"ok","uptime":1}`)), `undocumented property "uptime"`)
+
+		errorSchema := doc.ResponseSchema(doc.Operation(http.MethodGet, "/livez"), http.StatusInternalServerError)
+		assert.ErrorContains(t, doc.Validate(errorSchema, []byte(`{"error":"x"}`)), `missing required property "code"`)
+	})
+}
+
+// testSuccessResponses checks the responses of the main auth and link flows
+// against the document. It needs the test database.
+func testSuccessResponses(t *testing.T) {
+	db := openTestDatabase(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+
+	userRepo := repository.NewUserRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewTokenRepository(db), repository.NewRecoveryCodeRepository(db),
+		jwtMgr, mailer.NewLogMailer(cfg.Mail.From), cfg.Auth, cfg.Mail.AppURL)
+	linkService := newTestLinkService(t, db, config.LinkConfig{})
+	router, doc := setupServicesTestRouter(t, jwtMgr, apiTestServices{auth: authService, links: linkService})
+
+	// call makes a request, checks the response against its documented
+	// schema and returns the data it carries
+	call := func(t *testing.T, method, path, body, token string, status int) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, status, w.Code, w.Body.String())
+
+		path, _, _ = strings.Cut(path, "?")
+		op := doc.Operation(method, doc.Match(path))
+		require.NotNil(t, op, "%s %s is not documented", method, path)
+		schema := doc.ResponseSchema(op, w.Code)
+		require.NotNil(t, schema, "no schema for status %d", w.Code)
+		require.NoError(t, doc.Validate(schema, w.Body.Bytes()))
+
+		var response struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	suffix := uuid.New().String()[:8]
+	email := "openapi-" + suffix + "@example.com"
+	registered := call(t, http.MethodPost, "/api/v1/auth/register",
+		`{"username":"openapi-`+suffix+`","email":"`+email+`","password":"password123"}`, "", http.StatusCreated)
+	user := registered["user"].(map[string]interface{})
+	t.Cleanup(func() {
+		db.ExecContext(context.Background(), `DELETE FROM users WHERE id = $1`, user["id"])
+	})
+
+	var token string
+	t.Run("Auth", func(t *testing.T) {
+		loggedIn := call(t, http.MethodPost, "/api/v1/auth/login", `{"email":"`+email+`","password":"password123"}`, "", http.StatusOK)
+		token = loggedIn["token"].(string)
+		require.NotEmpty(t, token)
+
+		profile := call(t, http.MethodGet, "/api/v1/auth/profile", "", token, http.StatusOK)
+		assert.Equal(t, email, profile["email"])
+	})
+
+	t.Run("Links", func(t *testing.T) {
+		require.NotEmpty(t, token)
+		created := call(t, http.MethodPost, "/api/v1/links/",
+			`{"original_url":"https://example.com/docs","title":"Docs","tags":["spec"]}`, token, http.StatusCreated)
+		id := created["id"].(string)
+
+		call(t, http.MethodGet, "/api/v1/links/", "", token, http.StatusOK)
+		call(t, http.MethodGet, "/api/v1/links/"+id, "", token, http.StatusOK)
+		updated := call(t, http.MethodPut, "/api/v1/links/"+id, `{"title":"Documentation","is_active":false}`, token, http.StatusOK)
+		assert.Equal(t, "Documentation", updated["title"])
+		call(t, http.MethodGet, "/api/v1/links/stats", "", token, http.StatusOK)
+		call(t, http.MethodDelete, "/api/v1/links/"+id, "", token, http.StatusOK)
+	})
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/config"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/utils"
+)
+
+func TestConfigReload(t *testing.T) {
+	setValidConfigEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "8080"
+rate_limit:
+  requests: 100
+log:
+  level: info
+`)
+	opts := config.Options{File: path}
+	cfg, err := config.LoadWithOptions(opts)
+	require.NoError(t, err)
+
+	var applied *config.Config
+	reloader := config.NewReloader(opts, cfg, func(next *config.Config) { applied = next })
+
+	t.Run("Applies live settings and rejects the rest", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: "9090"
+rate_limit:
+  requests: 5
+log:
+  level: debug
+blocklist:
+  domains: [spam.example]
+`), 0o600))
+
+		rejected, err := reloader.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"server.port"}, rejected)
+
+		require.NotNil(t, applied)
+		assert.Equal(t, 5, applied.RateLimit.Requests)
+		assert.Equal(t, "debug", applied.Log.Level)
+		assert.Equal(t, []string{"spam.example"}, applied.Blocklist.Domains)
+		assert.Equal(t, "8080", applied.Server.Port)
+		assert.Same(t, applied, reloader.Current())
+	})
+
+	t.Run("Invalid live settings apply nothing", func(t *testing.T) {
+		applied = nil
+		require.NoError(t, os.WriteFile(path, []byte(`
+rate_limit:
+  requests: 0
+log:
+  level: warn
+`), 0o600))
+
+		_, err := reloader.Reload()
+		assert.ErrorContains(t, err, "RATE_LIMIT_REQUESTS")
+		assert.Nil(t, applied)
+		assert.Equal(t, "debug", reloader.Current().Log.Level)
+	})
+}
+
+func TestBlocklist(t *testing.T) {
+	blocklist := utils.NewBlocklist([]string{"Spam.example"})
+
+	assert.True(t, blocklist.Blocks("https://spam.example/offer"))
+	assert.True(t, blocklist.Blocks("cdn.spam.example/x"))
+	assert.False(t, blocklist.Blocks("https://notspam.example"))
+	assert.False(t, blocklist.Blocks("https://example.com"))
+
+	blocklist.Set(nil)
+	assert.False(t, blocklist.Blocks("https://spam.example/offer"))
+}
+
+func TestCORSPolicyUpdate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := middleware.NewCORSPolicy([]string{"https://app.example.com"})
+	router := gin.New()
+	router.Use(policy.Handler())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	allowedOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "Origin", w.Header().Get("Vary"), origin)
+		allowed := w.Header().Get("Access-Control-Allow-Origin")
+		if allowed == "" {
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"), origin)
+		} else {
+			assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"), origin)
+		}
+		return allowed
+	}
+
+	assert.Equal(t, "https://app.example.com", allowedOrigin("https://app.example.com"))
+	assert.Empty(t, allowedOrigin("https://evil.example"))
+
+	policy.Update([]string{"https://evil.example"})
+	assert.Equal(t, "https://evil.example", allowedOrigin("https://evil.example"))
+	assert.Empty(t, allowedOrigin("https://app.example.com"))
+}
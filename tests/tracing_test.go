@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/tracing"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(ctx context.Context) error { return nil }
+
+func (e *recordingExporter) byName() map[string]tracing.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := map[string]tracing.SpanData{}
+	for _, span := range e.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func setupTracer(t *testing.T, sampleRatio float64) (*tracing.Tracer, *recordingExporter) {
+	exporter := &recordingExporter{}
+	tracer := tracing.NewTracer(exporter, sampleRatio)
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() {
+		tracing.SetDefault(nil)
+		tracer.Shutdown(context.Background())
+	})
+	return tracer, exporter
+}
+
+func TestTraceParent(t *testing.T) {
+	sc, ok := tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.TraceParent())
+
+	for _, value := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		_, ok := tracing.ParseTraceParent(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracer, exporter := setupTracer(t, 1)
+
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Tracing())
+	router.GET("/r/:shortCode", func(c *gin.Context) {
+		_, span := tracing.Start(c.Request.Context(), "LinkService.RedirectToOriginal")
+		span.End()
+		c.Status(http.StatusFound)
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Error(io.ErrUnexpectedEOF)
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/r/abc123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	require.NoError(t, tracer.ForceFlush(context.Background()))
+
+	spans := exporter.byName()
+	server := spans["GET /r/:shortCode"]
+	assert.Equal(t, tracing.KindServer, server.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.String())
+	assert.Contains(t, server.Attributes, tracing.Int("http.response.status_code", http.StatusFound))
+	assert.Equal(t, tracing.StatusUnset, server.Status)
+
+	child := spans["LinkService.RedirectToOriginal"]
+	assert.Equal(t, server.SpanContext.TraceID, child.SpanContext.TraceID)
+	assert.Equal(t, server.SpanContext.SpanID, child.Parent)
+
+	failed := spans["GET /fail"]
+	assert.Equal(t, tracing.StatusError, failed.Status)
+	require.Len(t, failed.Events, 1)
+	assert.Equal(t, "exception", failed.Events[0].Name)
+}
+
+func TestTracingSampling(t *testing.T) {
+	tracer, exporter := setupTracer(t, 0)
+
+	ctx, span := tracing.Start(context.Background(), "unsampled")
+	_, child := tracing.Start(ctx, "child")
+	child.End()
+	span.End()
+
+	// A sampled remote parent overrides the ratio for its trace
+	parent, _ := tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, remote := tracing.Start(tracing.ContextWithRemoteParent(context.Background(), parent), "remote")
+	remote.End()
+
+	require.NoError(t, tracer.ForceFlush(context.Background()))
+	spans := exporter.byName()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans, "remote")
+	assert.Equal(t, span.SpanContext().TraceID, child.SpanContext().TraceID)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	var path string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer collector.Close()
+
+	tracer := tracing.NewTracer(tracing.NewOTLPExporter(collector.URL, "link-shortener"), 1)
+	_, span := tracer.Start(context.Background(), "SELECT", tracing.KindClient, tracing.String("db.system", "postgresql"), tracing.Int("rows", 2))
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	assert.Equal(t, "/v1/traces", path)
+	resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	resource := resourceSpans["resource"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "link-shortener"}},
+		resource["attributes"].([]interface{})[0])
+
+	exported := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "SELECT", exported["name"])
+	assert.Equal(t, float64(tracing.KindClient), exported["kind"])
+	assert.Equal(t, span.SpanContext().TraceID.String(), exported["traceId"])
+	assert.Len(t, exported["spanId"], 16)
+	assert.Contains(t, exported["attributes"], map[string]interface{}{"key": "rows", "value": map[string]interface{}{"intValue": "2"}})
+}
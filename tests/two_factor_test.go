@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"link-shortener/internal/models"
+	"link-shortener/internal/utils"
+)
+
+// Secret "12345678901234567890" from the RFC 6238 test vectors
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := utils.GenerateTOTPCode(rfcTOTPSecret, utils.TOTPStep(time.Unix(tt.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, code)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := utils.GenerateTOTPCode(rfcTOTPSecret, utils.TOTPStep(now))
+
+	step, ok := utils.ValidateTOTPCode(rfcTOTPSecret, code, now.Add(30*time.Second))
+	assert.True(t, ok, "code from the previous period is accepted")
+	assert.Equal(t, utils.TOTPStep(now), step)
+
+	_, ok = utils.ValidateTOTPCode(rfcTOTPSecret, code, now.Add(2*time.Minute))
+	assert.False(t, ok, "stale code is rejected")
+
+	_, ok = utils.ValidateTOTPCode(rfcTOTPSecret, "12345", now)
+	assert.False(t, ok, "short code is rejected")
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := utils.TOTPURI("Link Shortener", "user@example.com", rfcTOTPSecret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Link%20Shortener:user@example.com?"))
+	assert.Contains(t, uri, "secret="+rfcTOTPSecret)
+	assert.Contains(t, uri, "issuer=Link+Shortener")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestChallengeTokenScope(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	userID := uuid.New()
+
+	challenge, err := jwtMgr.GenerateChallengeToken(userID, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = jwtMgr.ValidateToken(challenge)
+	assert.Error(t, err, "challenge token must not work as an access token")
+
+	claims, err := jwtMgr.ValidateChallengeToken(challenge)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, jwt.ClaimStrings{utils.AudienceTwoFactorChallenge}, claims.Audience)
+
+	access, _ := jwtMgr.GenerateToken(&models.User{ID: userID, Username: "user", Email: "user@example.com"})
+	_, err = jwtMgr.ValidateChallengeToken(access)
+	assert.Error(t, err, "access token must not work as a challenge token")
+	claims, err = jwtMgr.ValidateToken(access)
+	assert.NoError(t, err)
+	assert.Equal(t, jwt.ClaimStrings{utils.AudienceAPI}, claims.Audience)
+
+	// The audience is checked on its own, not only the scope
+	unscoped, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &utils.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			Issuer:    "link-shortener",
+			Audience:  jwt.ClaimStrings{utils.AudienceTwoFactorChallenge},
+		},
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = jwtMgr.ValidateToken(unscoped)
+	assert.Error(t, err, "challenge audience must not work as an access token")
+}
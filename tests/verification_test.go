@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"link-shortener/internal/config"
+	"link-shortener/internal/mailer"
+	"link-shortener/internal/models"
+	"link-shortener/internal/utils"
+)
+
+func TestActionToken(t *testing.T) {
+	token, hash, err := utils.GenerateActionToken("secret", models.TokenPurposePasswordReset)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.HashToken(token), hash)
+
+	tests := []struct {
+		name        string
+		secret      string
+		purpose     string
+		token       string
+		expectedErr bool
+	}{
+		{"Valid token", "secret", models.TokenPurposePasswordReset, token, false},
+		{"Wrong purpose", "secret", models.TokenPurposeEmailVerification, token, true},
+		{"Wrong secret", "other-secret", models.TokenPurposePasswordReset, token, true},
+		{"Tampered token", "secret", models.TokenPurposePasswordReset, "x" + token, true},
+		{"Malformed token", "secret", models.TokenPurposePasswordReset, "not-a-token", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := utils.VerifyActionToken(tt.secret, tt.purpose, tt.token)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, hash, got)
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+
+	m, err := mailer.New(&config.MailConfig{Driver: "file", FilePath: path, From: "no-reply@example.com"})
+	assert.NoError(t, err)
+
+	err = m.Send(&mailer.Message{To: "user@example.com", Subject: "Hello", Body: "First line\nSecond line"})
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "From: no-reply@example.com\r\n")
+	assert.Contains(t, string(content), "To: user@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: Hello\r\n")
+	assert.Contains(t, string(content), "First line\r\nSecond line")
+}
+
+func TestUnknownMailDriver(t *testing.T) {
+	_, err := mailer.New(&config.MailConfig{Driver: "pigeon"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/handlers"
+	"link-shortener/internal/middleware"
+	"link-shortener/internal/models"
+	"link-shortener/internal/openapi"
+)
+
+// LinkResponse stands in for the link model of a future API version
+type LinkResponse struct {
+	Destination string   `json:"destination"`
+	Tags        []string `json:"tags"`
+}
+
+func linksVersion(name string, model interface{}) handlers.APIVersion {
+	return handlers.APIVersion{
+		Name: name,
+		Register: func(r *openapi.Router) {
+			r.GET("/links/:id", openapi.Op("Get a link", "links").Returns(http.StatusOK, "Link", model),
+				func(c *gin.Context) { c.JSON(http.StatusOK, model) })
+		},
+	}
+}
+
+func setupVersionedRouter(versions []handlers.APIVersion, current string, legacy *middleware.Deprecation) (*gin.Engine, *openapi.Document) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	doc := handlers.NewOpenAPIDocument()
+	handlers.MountAPI(openapi.NewRouter(&router.RouterGroup, doc), versions, current, legacy)
+	return router, doc
+}
+
+func TestAPIVersioning(t *testing.T) {
+	deprecated := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC)
+	get := func(router *gin.Engine, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	id := "/links/0b5e2a4c-3f7d-4d8e-9a51-2c6b8f1e7d30"
+
+	t.Run("Unversioned alias is deprecated", func(t *testing.T) {
+		router, doc := setupVersionedRouter([]handlers.APIVersion{linksVersion("v1", models.LinkResponse{})}, "v1",
+			&middleware.Deprecation{Since: deprecated, Sunset: sunset})
+
+		w := get(router, "/api/v1"+id)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+
+		w = get(router, "/api"+id)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "@1792281600", w.Header().Get("Deprecation"))
+		assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+		assert.Equal(t, `</api/v1>; rel="successor-version"`, w.Header().Get("Link"))
+
+		assert.NotNil(t, doc.Operation(http.MethodGet, "/api/v1/links/{id}"))
+		assert.NotContains(t, doc.Paths, "/api/links/{id}")
+	})
+
+	t.Run("Sunset is omitted until set", func(t *testing.T) {
+		router, _ := setupVersionedRouter([]handlers.APIVersion{linksVersion("v1", models.LinkResponse{})}, "v1",
+			&middleware.Deprecation{Since: deprecated})
+
+		w := get(router, "/api"+id)
+		assert.Equal(t, "@1792281600", w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+	})
+
+	t.Run("Alias can be turned off", func(t *testing.T) {
+		router, _ := setupVersionedRouter([]handlers.APIVersion{linksVersion("v1", models.LinkResponse{})}, "v1", nil)
+
+		assert.Equal(t, http.StatusOK, get(router, "/api/v1"+id).Code)
+		assert.Equal(t, http.StatusNotFound, get(router, "/api"+id).Code)
+	})
+
+	t.Run("Versions run side by side", func(t *testing.T) {
+		v1 := linksVersion("v1", models.LinkResponse{})
+		v1.Deprecation = &middleware.Deprecation{Since: deprecated, Sunset: sunset, Successor: "/api/v2"}
+		v2 := linksVersion("v2", LinkResponse{Destination: "https://example.com", Tags: []string{"docs"}})
+		router, doc := setupVersionedRouter([]handlers.APIVersion{v1, v2}, "v2", &middleware.Deprecation{Since: deprecated})
+
+		w := get(router, "/api/v1"+id)
+		assert.Equal(t, "@1792281600", w.Header().Get("Deprecation"))
+		assert.Equal(t, `</api/v2>; rel="successor-version"`, w.Header().Get("Link"))
+
+		w = get(router, "/api/v2"+id)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		require.NoError(t, doc.Validate(doc.ResponseSchema(doc.Operation(http.MethodGet, "/api/v2/links/{id}"), w.Code), w.Body.Bytes()))
+
+		// The alias follows the current version
+		w = get(router, "/api"+id)
+		assert.JSONEq(t, `{"destination":"https://example.com","tags":["docs"]}`, w.Body.String())
+		assert.Equal(t, `</api/v2>; rel="successor-version"`, w.Header().Get("Link"))
+
+		assert.True(t, doc.Operation(http.MethodGet, "/api/v1/links/{id}").Deprecated)
+		assert.False(t, doc.Operation(http.MethodGet, "/api/v2/links/{id}").Deprecated)
+		// Models of the same name get their own schemas
+		assert.Contains(t, doc.Components.Schemas, "LinkResponse")
+		assert.Contains(t, doc.Components.Schemas, "TestsLinkResponse")
+	})
+}
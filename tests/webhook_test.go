@@ -0,0 +1,298 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"link-shortener/internal/apperror"
+	"link-shortener/internal/config"
+	"link-shortener/internal/models"
+	"link-shortener/internal/repository"
+	"link-shortener/internal/services"
+	"link-shortener/internal/utils"
+	"link-shortener/internal/webhook"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"type":"link.created"}`)
+	now := time.Now().Unix()
+	signature := webhook.Sign("whsec_test", now, body)
+
+	assert.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+	assert.True(t, webhook.Verify("whsec_test", signature, now, body, 5*time.Minute))
+	assert.False(t, webhook.Verify("whsec_other", signature, now, body, 5*time.Minute), "wrong secret")
+	assert.False(t, webhook.Verify("whsec_test", signature, now, []byte(`{"type":"link.deleted"}`), 5*time.Minute), "altered body")
+	assert.False(t, webhook.Verify("whsec_test", signature, now+1, body, 5*time.Minute), "altered timestamp")
+
+	old := now - 600
+	assert.False(t, webhook.Verify("whsec_test", webhook.Sign("whsec_test", old, body), old, body, 5*time.Minute), "stale timestamp")
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	base, max := 30*time.Second, 10*time.Minute
+	assert.Equal(t, 30*time.Second, webhook.Backoff(1, base, max))
+	assert.Equal(t, time.Minute, webhook.Backoff(2, base, max))
+	assert.Equal(t, 4*time.Minute, webhook.Backoff(4, base, max))
+	assert.Equal(t, max, webhook.Backoff(6, base, max))
+	assert.Equal(t, max, webhook.Backoff(100, base, max))
+}
+
+func TestWebhookCheckURL(t *testing.T) {
+	for _, raw := range []string{"https://example.com/hooks", "http://203.0.113.7:8080/"} {
+		assert.NoError(t, webhook.CheckURL(raw, false), raw)
+	}
+	for _, raw := range []string{"http://localhost/hook", "http://127.0.0.1/hook", "http://10.1.2.3/", "http://[::1]/", "http://169.254.169.254/latest"} {
+		assert.ErrorIs(t, webhook.CheckURL(raw, false), webhook.ErrPrivateAddress, raw)
+		assert.NoError(t, webhook.CheckURL(raw, true), raw)
+	}
+	assert.Error(t, webhook.CheckURL("ftp://example.com/", true))
+}
+
+func TestWebhookSender(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Clone(), body}
+		if status == http.StatusFound {
+			http.Redirect(w, r, "/elsewhere", status)
+			return
+		}
+		w.WriteHeader(status)
+		if status >= 400 {
+			w.Write([]byte("try later"))
+		}
+	}))
+	defer server.Close()
+
+	delivery := &models.DueDelivery{
+		ID:      uuid.New(),
+		EventID: uuid.New(),
+		Event:   models.EventLinkCreated,
+		Payload: []byte(`{"id":"1","type":"link.created"}`),
+		URL:     server.URL + "/hooks",
+		Secret:  "whsec_test",
+	}
+
+	t.Run("Delivered", func(t *testing.T) {
+		sender := webhook.NewSender(time.Second, true)
+		code, err := sender.Send(context.Background(), delivery)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, code)
+
+		got := <-requests
+		assert.Equal(t, delivery.Payload, got.body)
+		assert.Equal(t, "application/json", got.header.Get("Content-Type"))
+		assert.Equal(t, delivery.ID.String(), got.header.Get(webhook.HeaderID))
+		assert.Equal(t, models.EventLinkCreated, got.header.Get(webhook.HeaderEvent))
+		timestamp, err := strconv.ParseInt(got.header.Get(webhook.HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.True(t, webhook.Verify("whsec_test", got.header.Get(webhook.HeaderSignature), timestamp, got.body, time.Minute))
+	})
+
+	t.Run("Error status", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		code, err := webhook.NewSender(time.Second, true).Send(context.Background(), delivery)
+		<-requests
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.ErrorContains(t, err, "try later")
+	})
+
+	t.Run("Redirects are not followed", func(t *testing.T) {
+		status = http.StatusFound
+		code, err := webhook.NewSender(time.Second, true).Send(context.Background(), delivery)
+		<-requests
+		assert.Equal(t, http.StatusFound, code)
+		assert.Error(t, err)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("Private address", func(t *testing.T) {
+		code, err := webhook.NewSender(time.Second, false).Send(context.Background(), delivery)
+		assert.Zero(t, code)
+		assert.ErrorIs(t, err, webhook.ErrPrivateAddress)
+		assert.Empty(t, requests)
+	})
+}
+
+// The requests below are rejected before the database is queried
+func TestWebhookRequests(t *testing.T) {
+	jwtMgr := utils.NewJWTManager("secret", time.Hour)
+	linkService := services.NewLinkService(nil, nil, "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), nil)
+	router, doc := setupAPITestRouter(t, jwtMgr, linkService)
+
+	token, err := jwtMgr.GenerateToken(&models.User{ID: uuid.New(), Role: models.RoleUser})
+	require.NoError(t, err)
+
+	hookPath := "/api/v1/webhooks/" + uuid.NewString()
+	for _, tc := range []struct {
+		name, method, path, template, body, code string
+	}{
+		{"Unknown event", http.MethodPost, "/api/v1/webhooks/", "/api/v1/webhooks/",
+			`{"url":"https://example.com/hook","events":["link.renamed"]}`, "invalid_request"},
+		{"No events", http.MethodPost, "/api/v1/webhooks/", "/api/v1/webhooks/",
+			`{"url":"https://example.com/hook","events":[]}`, "invalid_request"},
+		{"Private URL", http.MethodPost, "/api/v1/webhooks/", "/api/v1/webhooks/",
+			`{"url":"http://127.0.0.1:9000/hook","events":["link.created"]}`, "invalid_webhook_url"},
+		{"Bad webhook ID", http.MethodGet, "/api/v1/webhooks/abc", "/api/v1/webhooks/{id}", "", "invalid_id"},
+		{"Bad delivery status", http.MethodGet, hookPath + "/deliveries?status=failed", "/api/v1/webhooks/{id}/deliveries", "", "invalid_delivery_status"},
+		{"Bad delivery ID", http.MethodPost, hookPath + "/deliveries/abc/retry", "/api/v1/webhooks/{id}/deliveries/{deliveryId}/retry", "", "invalid_id"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			op := doc.Operation(tc.method, tc.template)
+			require.NotNil(t, op)
+			assert.NoError(t, doc.Validate(doc.ResponseSchema(op, w.Code), w.Body.Bytes()))
+
+			var body apperror.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tc.code, body.Code)
+		})
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	db := openTestDatabase(t)
+	linkRepo := repository.NewLinkRepository(db)
+	linkService := services.NewLinkService(linkRepo, repository.NewWorkspaceRepository(db), "http://localhost:8080",
+		config.LinkConfig{ShortCodeLength: 8}, utils.NewBlocklist(nil), nil)
+	webhookService := services.NewWebhookService(repository.NewWebhookRepository(db), linkService, config.WebhookConfig{
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      time.Second,
+		Workers:      2,
+		MaxAttempts:  3,
+		RetryBase:    10 * time.Millisecond,
+		RetryMax:     50 * time.Millisecond,
+		LogRetention: time.Hour,
+		AllowPrivate: true,
+	})
+	linkRepo.SetOutbox(webhookService)
+	webhookService.Start()
+	t.Cleanup(webhookService.Close)
+	ctx := context.Background()
+
+	// /ok accepts every request, /flaky fails twice before accepting and
+	// /down fails until it is brought up
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	headers := map[string]http.Header{}
+	bodies := map[string][]byte{}
+	var down atomic.Bool
+	down.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		attempts[r.URL.Path]++
+		n := attempts[r.URL.Path]
+		headers[r.URL.Path], bodies[r.URL.Path] = r.Header.Clone(), body
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/flaky" && n <= 2, r.URL.Path == "/down" && down.Load():
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	user := createTestUser(t, db)
+	hooks := map[string]*models.CreatedWebhook{}
+	for _, path := range []string{"/ok", "/flaky", "/down"} {
+		hook, err := webhookService.CreateWebhook(ctx, user.ID, &models.CreateWebhookRequest{URL: server.URL + path, Events: []string{models.EventLinkCreated}})
+		require.NoError(t, err)
+		hooks[path] = hook
+	}
+	link, err := linkService.CreateLink(ctx, user.ID, &models.CreateLinkRequest{OriginalURL: "https://example.com/hooked"})
+	require.NoError(t, err)
+
+	// settled waits until the webhook's only delivery is no longer pending
+	settled := func(t *testing.T, path string) *models.WebhookDelivery {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			deliveries, err := webhookService.ListDeliveries(ctx, user.ID, hooks[path].ID, "", 10, 0)
+			require.NoError(t, err)
+			require.Len(t, deliveries, 1)
+			if deliveries[0].Status != models.DeliveryPending {
+				return deliveries[0]
+			}
+			require.True(t, time.Now().Before(deadline), "%s is still pending after %d attempts", path, deliveries[0].Attempts)
+		}
+	}
+
+	t.Run("Delivered", func(t *testing.T) {
+		delivery := settled(t, "/ok")
+		assert.Equal(t, models.DeliveryDelivered, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		require.NotNil(t, delivery.LastStatusCode)
+		assert.Equal(t, http.StatusNoContent, *delivery.LastStatusCode)
+
+		mu.Lock()
+		header, body := headers["/ok"], bodies["/ok"]
+		mu.Unlock()
+		assert.Equal(t, delivery.ID.String(), header.Get(webhook.HeaderID))
+		timestamp, err := strconv.ParseInt(header.Get(webhook.HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.True(t, webhook.Verify(hooks["/ok"].Secret, header.Get(webhook.HeaderSignature), timestamp, body, time.Minute))
+
+		var event struct {
+			Type string              `json:"type"`
+			Data models.LinkResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, models.EventLinkCreated, event.Type)
+		assert.Equal(t, link.ShortCode, event.Data.ShortCode)
+	})
+
+	t.Run("Retried", func(t *testing.T) {
+		delivery := settled(t, "/flaky")
+		assert.Equal(t, models.DeliveryDelivered, delivery.Status)
+		assert.Equal(t, 3, delivery.Attempts)
+		assert.NotNil(t, delivery.DeliveredAt)
+	})
+
+	t.Run("Dead and redelivered", func(t *testing.T) {
+		delivery := settled(t, "/down")
+		assert.Equal(t, models.DeliveryDead, delivery.Status)
+		assert.Equal(t, 3, delivery.Attempts)
+		require.NotNil(t, delivery.LastStatusCode)
+		assert.Equal(t, http.StatusServiceUnavailable, *delivery.LastStatusCode)
+		assert.Contains(t, delivery.LastError, "unavailable")
+		mu.Lock()
+		assert.Equal(t, 3, attempts["/down"], "dead deliveries are not sent again")
+		mu.Unlock()
+
+		down.Store(false)
+		retried, err := webhookService.RetryDelivery(ctx, user.ID, hooks["/down"].ID, delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryPending, retried.Status)
+		assert.Zero(t, retried.Attempts)
+
+		delivery = settled(t, "/down")
+		assert.Equal(t, models.DeliveryDelivered, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+	})
+}
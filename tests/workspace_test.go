@@ -0,0 +1,34 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"link-shortener/internal/models"
+)
+
+func TestWorkspaceRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		allowed  bool
+	}{
+		{models.WorkspaceRoleOwner, models.WorkspaceRoleEditor, true},
+		{models.WorkspaceRoleOwner, models.WorkspaceRoleOwner, true},
+		{models.WorkspaceRoleEditor, models.WorkspaceRoleEditor, true},
+		{models.WorkspaceRoleEditor, models.WorkspaceRoleOwner, false},
+		{models.WorkspaceRoleViewer, models.WorkspaceRoleViewer, true},
+		{models.WorkspaceRoleViewer, models.WorkspaceRoleEditor, false},
+		{"", models.WorkspaceRoleViewer, false},
+		{"admin", models.WorkspaceRoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+" as "+tt.required, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, models.WorkspaceRoleAllows(tt.role, tt.required))
+		})
+	}
+
+	assert.True(t, models.IsValidWorkspaceRole(models.WorkspaceRoleViewer))
+	assert.False(t, models.IsValidWorkspaceRole("admin"))
+}